@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/florianloch/cassette/internal/cli"
+)
+
+func main() {
+	err := cli.Run(os.Args[1:], os.Stdout, os.Stderr)
+	if err == cli.ErrUsage {
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+}
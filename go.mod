@@ -17,6 +17,7 @@ require (
 	github.com/rs/zerolog v1.20.0
 	github.com/xdg/stringprep v1.0.0 // indirect
 	github.com/zmb3/spotify v1.1.1
+	go.etcd.io/bbolt v1.3.5
 	go.mongodb.org/mongo-driver v1.5.0
 	golang.org/x/crypto v0.0.0-20210314154223-e6e6c4f2bb5b // indirect
 	golang.org/x/oauth2 v0.0.0-20210313182246-cd4f82c27b84
@@ -0,0 +1,306 @@
+package autosave
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	spotifyAPI "github.com/zmb3/spotify"
+	"golang.org/x/oauth2"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/events"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/spotify"
+)
+
+// ClientCreator has to return a client persisting refreshed tokens for the given user
+type ClientCreator func(userID string, token *oauth2.Token) spotify.SpotClient
+
+// Worker periodically saves the progress of all users having opted in to auto save.
+// For every context listened to a dedicated slot is kept up to date. When the user switches
+// to another context the previous slot is left as it is, containing the last position seen.
+type Worker struct {
+	dao          persistence.Persistor
+	bus          events.Bus
+	createClient ClientCreator
+	interval     time.Duration
+	// trashRetention tells how long slots dropped as there are too many saved automatically are kept in the trash
+	trashRetention time.Duration
+	// lastSeen allows skipping users whose playback did not change since the last run, e.g., because it is paused.
+	// Users not having opted in anymore are dropped every run.
+	lastSeen map[string]position
+}
+
+type position struct {
+	contextURI spotifyAPI.URI
+	itemURI    spotifyAPI.URI
+	progress   int
+}
+
+func NewWorker(dao persistence.Persistor, bus events.Bus, createClient ClientCreator, interval time.Duration, trashRetention time.Duration) *Worker {
+	return &Worker{
+		dao:            dao,
+		bus:            bus,
+		createClient:   createClient,
+		interval:       interval,
+		trashRetention: trashRetention,
+		lastSeen:       make(map[string]position),
+	}
+}
+
+// Run blocks until stop gets closed
+func (w *Worker) Run(stop <-chan struct{}) {
+	log.Info().Msgf("Auto saving progress of users every %s.", w.interval)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.RunOnce()
+		}
+	}
+}
+
+// RunOnce saves the progress of all users having opted in
+func (w *Worker) RunOnce() {
+	users, err := w.dao.LoadAutoSaveUsers()
+	if err != nil {
+		log.Error().Err(err).Msg("Could not load users having opted in to auto save.")
+		return
+	}
+
+	optedIn := make(map[string]bool, len(users))
+	for _, user := range users {
+		optedIn[user.UserID] = true
+	}
+
+	for userID := range w.lastSeen {
+		if !optedIn[userID] {
+			delete(w.lastSeen, userID)
+		}
+	}
+
+	for _, user := range users {
+		err = w.autoSave(user)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to auto save progress of user.")
+		}
+	}
+}
+
+func (w *Worker) autoSave(user *persistence.AutoSaveUser) error {
+	token, err := w.dao.LoadToken(user.UserID)
+	if err != nil {
+		if err == persistence.ErrTokenNotFound {
+			log.Info().Msg("User revoked access to Cassette, disabling auto save.")
+			delete(w.lastSeen, user.UserID)
+
+			return w.dao.DisableAutoSave(user.UserID)
+		}
+
+		return fmt.Errorf("could not load token: %w", err)
+	}
+
+	client := w.createClient(user.UserID, token)
+
+	playerState, err := client.PlayerState()
+	if err != nil {
+		if isTokenRevoked(err) {
+			log.Warn().Err(err).Msg("User revoked access to Spotify, disabling auto save.")
+			delete(w.lastSeen, user.UserID)
+
+			err = w.dao.DeleteToken(user.UserID)
+			if err != nil {
+				return err
+			}
+
+			return w.dao.DisableAutoSave(user.UserID)
+		}
+
+		return fmt.Errorf("could not read whats currently playing: %w", err)
+	}
+
+	if playerState.Item == nil && playerState.PlaybackContext.URI == "" {
+		// Nothing playing at all
+		return nil
+	}
+
+	current := position{contextURI: playerState.PlaybackContext.URI, progress: playerState.Progress}
+	if playerState.Item != nil {
+		current.itemURI = playerState.Item.URI
+	}
+
+	last, seenBefore := w.lastSeen[user.UserID]
+	if seenBefore && last == current {
+		return nil
+	}
+
+	if seenBefore && last.contextURI != current.contextURI {
+		log.Debug().Msg("User switched to another context, keeping the last position of the previous one.")
+	}
+
+	state, err := spotify.PlayerStateOf(client, playerState)
+	if err != nil {
+		if errors.Is(err, spotify.ErrContextNotSuspendable) || errors.Is(err, spotify.ErrNoEpisodePlaying) {
+			w.lastSeen[user.UserID] = current
+			return nil
+		}
+
+		return fmt.Errorf("could not get suspendable player state: %w", err)
+	}
+
+	state.AutoSaved = true
+
+	playerStates, revision, err := w.dao.LoadPlayerStates(user.UserID)
+	if err != nil {
+		return fmt.Errorf("could not load player states: %w", err)
+	}
+
+	before := make(map[string]bool, len(playerStates))
+	for _, cur := range playerStates {
+		before[cur.ID] = true
+	}
+
+	playerStates, outdated := mergeAutoSaved(playerStates, state)
+
+	// Outdated slots are trashed first, so they are not lost in case saving the player states fails. Then they are
+	// taken out of the trash again.
+	trashed := make([]*persistence.PlayerState, 0, len(outdated))
+	for _, cur := range outdated {
+		ok, err := persistence.TrashDeleted(w.dao, user.UserID, cur, time.Unix(state.SuspendedAtTs, 0), w.trashRetention)
+		if err != nil {
+			w.undoTrashing(user.UserID, trashed)
+			return fmt.Errorf("could not move outdated slot to trash: %w", err)
+		}
+
+		if ok {
+			trashed = append(trashed, cur)
+		}
+	}
+
+	archived, err := persistence.ArchiveFinished(w.dao, user.UserID, state, time.Unix(state.SuspendedAtTs, 0))
+	if err != nil {
+		w.undoTrashing(user.UserID, trashed)
+		return fmt.Errorf("could not archive finished slot: %w", err)
+	}
+
+	if archived {
+		playerStates = persistence.WithoutSlot(playerStates, state)
+	}
+
+	_, err = w.dao.SavePlayerStates(user.UserID, playerStates, revision)
+	if err != nil {
+		w.undoTrashing(user.UserID, trashed)
+
+		if archived {
+			undoErr := persistence.UndoArchiving(w.dao, user.UserID, state)
+			if undoErr != nil {
+				log.Error().Err(undoErr).Msg("Could not remove slot from archive again.")
+			}
+		}
+
+		if err == persistence.ErrRevisionMismatch {
+			// The user modified the player states in the meantime, try again next run
+			return nil
+		}
+
+		return fmt.Errorf("could not persist player states: %w", err)
+	}
+
+	w.lastSeen[user.UserID] = current
+	w.publishChanges(user.UserID, before, playerStates, state, archived)
+
+	return nil
+}
+
+// undoTrashing takes the slots out of the trash again in case they could not be removed from the player states
+func (w *Worker) undoTrashing(userID string, trashed []*persistence.PlayerState) {
+	for _, cur := range trashed {
+		err := persistence.UndoTrashing(w.dao, userID, cur)
+		if err != nil {
+			log.Error().Err(err).Str("slot", cur.ID).Msg("Could not remove slot from trash again.")
+		}
+	}
+}
+
+// publishChanges notifies the clients of the user about the slot saved and the outdated ones having been dropped.
+// IDs are assigned when saving, so the state saved is known to be new when its ID has not been there before.
+func (w *Worker) publishChanges(userID string, before map[string]bool, playerStates []*persistence.PlayerState, saved *persistence.PlayerState, archived bool) {
+	if archived {
+		w.bus.Publish(events.New(events.SlotArchived, userID, saved.ID))
+		delete(before, saved.ID)
+	} else if before[saved.ID] {
+		w.bus.Publish(events.New(events.SlotUpdated, userID, saved.ID))
+	} else {
+		w.bus.Publish(events.New(events.SlotCreated, userID, saved.ID))
+	}
+
+	for _, cur := range playerStates {
+		delete(before, cur.ID)
+	}
+
+	for id := range before {
+		w.bus.Publish(events.New(events.SlotDeleted, userID, id))
+	}
+}
+
+// mergeAutoSaved replaces the auto saved slot of the same context, if there is none a new slot is added.
+// Only the most recent slots saved automatically are kept, the outdated ones are returned separately.
+func mergeAutoSaved(playerStates []*persistence.PlayerState, state *persistence.PlayerState) ([]*persistence.PlayerState, []*persistence.PlayerState) {
+	for idx, cur := range playerStates {
+		if cur.AutoSaved && cur.SameContext(state) {
+			state.TakeOver(cur)
+			playerStates[idx] = state
+
+			return playerStates, nil
+		}
+	}
+
+	playerStates = append(playerStates, state)
+
+	autoSaved := make([]*persistence.PlayerState, 0)
+	for _, cur := range playerStates {
+		if cur.AutoSaved {
+			autoSaved = append(autoSaved, cur)
+		}
+	}
+
+	if len(autoSaved) <= constants.AutoSaveMaxSlots {
+		return playerStates, nil
+	}
+
+	sort.SliceStable(autoSaved, func(i, j int) bool {
+		return autoSaved[i].SuspendedAtTs > autoSaved[j].SuspendedAtTs
+	})
+
+	outdated := make(map[*persistence.PlayerState]bool)
+	for _, cur := range autoSaved[constants.AutoSaveMaxSlots:] {
+		outdated[cur] = true
+	}
+
+	kept := make([]*persistence.PlayerState, 0, len(playerStates)-len(outdated))
+	for _, cur := range playerStates {
+		if !outdated[cur] {
+			kept = append(kept, cur)
+		}
+	}
+
+	return kept, autoSaved[constants.AutoSaveMaxSlots:]
+}
+
+// isTokenRevoked checks whether Spotify refused refreshing the token
+func isTokenRevoked(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) {
+		return false
+	}
+
+	return retrieveErr.Response != nil && retrieveErr.Response.StatusCode == 400
+}
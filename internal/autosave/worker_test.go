@@ -0,0 +1,377 @@
+package autosave_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	spotifyAPI "github.com/zmb3/spotify"
+	"golang.org/x/oauth2"
+
+	"github.com/florianloch/cassette/internal/autosave"
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/e2e_test/mocks"
+	"github.com/florianloch/cassette/internal/events"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/spotify"
+)
+
+const (
+	dummyUserID         = "audiophile_gopher"
+	dummyTrashRetention = 30 * 24 * time.Hour
+)
+
+var (
+	dummyToken = &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}
+	dummyUsers = []*persistence.AutoSaveUser{{UserID: dummyUserID}}
+	dummyTrack = &spotifyAPI.FullTrack{
+		SimpleTrack: spotifyAPI.SimpleTrack{
+			Name: "Song for Gophers",
+			URI:  "spotify:track:789",
+		},
+	}
+)
+
+func TestAutoSaveKeepsSlotUpToDate(t *testing.T) {
+	bus := events.NewInProcessBus()
+	subscription, unsubscribe := bus.Subscribe(dummyUserID)
+	defer unsubscribe()
+
+	worker, ctrl, daoMock, clientMock := beforeEachWithBus(t, bus)
+	defer ctrl.Finish()
+
+	daoMock.EXPECT().LoadAutoSaveUsers().Times(3).Return(dummyUsers, nil)
+	daoMock.EXPECT().LoadToken(dummyUserID).AnyTimes().Return(dummyToken, nil)
+
+	manual := &persistence.PlayerState{ID: "manual", PlaybackItemURI: string(dummyTrack.URI), ContextType: "track"}
+
+	// First run creates an auto saved slot, next to the one suspended manually
+	clientMock.EXPECT().PlayerState().Times(1).Return(singleTrackPlaying(1000), nil)
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{manual}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if len(playerStates) != 2 || playerStates[0] != manual {
+			t.Fatalf("expected auto saved slot to be added, got %+v", playerStates)
+		}
+
+		if state := playerStates[1]; !state.AutoSaved || state.Progress != 1000 {
+			t.Fatalf("slot has not been auto saved properly: %+v", state)
+		}
+
+		playerStates[1].ID = "auto"
+
+		return 2, nil
+	})
+
+	worker.RunOnce()
+
+	expectEvent(t, subscription, events.SlotCreated, "auto")
+
+	// Second run updates this very slot
+	clientMock.EXPECT().PlayerState().Times(1).Return(singleTrackPlaying(5000), nil)
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{manual, {
+		ID:              "auto",
+		PlaybackItemURI: string(dummyTrack.URI),
+		ContextType:     "track",
+		Progress:        1000,
+		AutoSaved:       true,
+	}}, 2, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 2).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if len(playerStates) != 2 || playerStates[0] != manual {
+			t.Fatalf("expected auto saved slot to be updated, got %+v", playerStates)
+		}
+
+		if state := playerStates[1]; state.ID != "auto" || !state.AutoSaved || state.Progress != 5000 {
+			t.Fatalf("slot has not been auto saved properly: %+v", state)
+		}
+
+		return 3, nil
+	})
+
+	worker.RunOnce()
+
+	expectEvent(t, subscription, events.SlotUpdated, "auto")
+
+	// Third run finds playback paused, there is nothing to save
+	clientMock.EXPECT().PlayerState().Times(1).Return(singleTrackPlaying(5000), nil)
+
+	worker.RunOnce()
+
+	if len(subscription) != 0 {
+		t.Fatalf("unexpected event: %+v", <-subscription)
+	}
+}
+
+func TestAutoSaveForgetsUsersHavingOptedOut(t *testing.T) {
+	worker, ctrl, daoMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	daoMock.EXPECT().LoadToken(dummyUserID).AnyTimes().Return(dummyToken, nil)
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(2).Return([]*persistence.PlayerState{}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(2).Return(2, nil)
+	clientMock.EXPECT().PlayerState().Times(2).Return(singleTrackPlaying(1000), nil)
+
+	gomock.InOrder(
+		daoMock.EXPECT().LoadAutoSaveUsers().Times(1).Return(dummyUsers, nil),
+		daoMock.EXPECT().LoadAutoSaveUsers().Times(1).Return([]*persistence.AutoSaveUser{}, nil),
+		daoMock.EXPECT().LoadAutoSaveUsers().Times(1).Return(dummyUsers, nil),
+	)
+
+	worker.RunOnce()
+	worker.RunOnce()
+	// Having opted in again, the playback gets saved although it did not change
+	worker.RunOnce()
+}
+
+func TestAutoSaveIgnoresContextsNotSuspendable(t *testing.T) {
+	worker, ctrl, daoMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	daoMock.EXPECT().LoadAutoSaveUsers().Times(1).Return(dummyUsers, nil)
+	daoMock.EXPECT().LoadToken(dummyUserID).AnyTimes().Return(dummyToken, nil)
+	clientMock.EXPECT().PlayerState().Times(1).Return(&spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			PlaybackContext: spotifyAPI.PlaybackContext{
+				Type: "artist",
+				URI:  "spotify:artist:123",
+			},
+			Item: dummyTrack,
+		},
+	}, nil)
+	daoMock.EXPECT().SavePlayerStates(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	worker.RunOnce()
+}
+
+func TestAutoSaveKeepsOnlyMostRecentSlots(t *testing.T) {
+	bus := events.NewInProcessBus()
+	subscription, unsubscribe := bus.Subscribe(dummyUserID)
+	defer unsubscribe()
+
+	worker, ctrl, daoMock, clientMock := beforeEachWithBus(t, bus)
+	defer ctrl.Finish()
+
+	daoMock.EXPECT().LoadAutoSaveUsers().Times(1).Return(dummyUsers, nil)
+	daoMock.EXPECT().LoadToken(dummyUserID).AnyTimes().Return(dummyToken, nil)
+	clientMock.EXPECT().PlayerState().Times(1).Return(singleTrackPlaying(1000), nil)
+
+	playerStates := []*persistence.PlayerState{{ID: "manual", SuspendedAtTs: 1}}
+	for i := 0; i < constants.AutoSaveMaxSlots; i++ {
+		playerStates = append(playerStates, &persistence.PlayerState{
+			ID:                 string(rune('a' + i)),
+			PlaybackContextURI: "spotify:album:" + string(rune('a'+i)),
+			ContextType:        "album",
+			SuspendedAtTs:      int64(10 + i),
+			AutoSaved:          true,
+		})
+	}
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return(playerStates, 1, nil)
+	// The oldest slot saved automatically is moved to the trash instead of getting lost
+	daoMock.EXPECT().TrashSlot(dummyUserID, gomock.Any()).Times(1).DoAndReturn(func(_ string, slot *persistence.TrashedSlot) error {
+		if slot.PlayerState.ID != "a" || slot.PurgeAtTs-slot.DeletedAtTs != int64(dummyTrashRetention/time.Second) {
+			t.Fatalf("unexpected slot trashed: %+v", slot)
+		}
+
+		return nil
+	})
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if len(playerStates) != constants.AutoSaveMaxSlots+1 {
+			t.Fatalf("expected %d slots, got %d", constants.AutoSaveMaxSlots+1, len(playerStates))
+		}
+
+		// The oldest slot saved automatically got dropped, the one suspended manually is kept
+		if playerStates[0].ID != "manual" || playerStates[1].ID != "b" || !playerStates[len(playerStates)-1].SameContext(&persistence.PlayerState{
+			PlaybackItemURI: string(dummyTrack.URI),
+			ContextType:     "track",
+		}) {
+			t.Fatalf("unexpected slots: %+v", playerStates)
+		}
+
+		playerStates[len(playerStates)-1].ID = "new"
+
+		return 2, nil
+	})
+
+	worker.RunOnce()
+
+	expectEvent(t, subscription, events.SlotCreated, "new")
+	expectEvent(t, subscription, events.SlotDeleted, "a")
+}
+
+func TestAutoSaveUndoesTrashingWhenSavingFails(t *testing.T) {
+	worker, ctrl, daoMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	daoMock.EXPECT().LoadAutoSaveUsers().Times(1).Return(dummyUsers, nil)
+	daoMock.EXPECT().LoadToken(dummyUserID).AnyTimes().Return(dummyToken, nil)
+	clientMock.EXPECT().PlayerState().Times(1).Return(singleTrackPlaying(1000), nil)
+
+	playerStates := make([]*persistence.PlayerState, 0, constants.AutoSaveMaxSlots)
+	for i := 0; i < constants.AutoSaveMaxSlots; i++ {
+		playerStates = append(playerStates, &persistence.PlayerState{
+			ID:                 string(rune('a' + i)),
+			PlaybackContextURI: "spotify:album:" + string(rune('a'+i)),
+			ContextType:        "album",
+			SuspendedAtTs:      int64(10 + i),
+			AutoSaved:          true,
+		})
+	}
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return(playerStates, 1, nil)
+	gomock.InOrder(
+		daoMock.EXPECT().TrashSlot(dummyUserID, gomock.Any()).Times(1),
+		daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).Return(0, persistence.ErrRevisionMismatch),
+		// The slot is still contained in the player states
+		daoMock.EXPECT().RemoveTrashedSlot(dummyUserID, "a").Times(1),
+	)
+
+	worker.RunOnce()
+}
+
+func TestAutoSaveArchivesFinishedSlot(t *testing.T) {
+	bus := events.NewInProcessBus()
+	subscription, unsubscribe := bus.Subscribe(dummyUserID)
+	defer unsubscribe()
+
+	worker, ctrl, daoMock, clientMock := beforeEachWithBus(t, bus)
+	defer ctrl.Finish()
+
+	daoMock.EXPECT().LoadAutoSaveUsers().Times(1).Return(dummyUsers, nil)
+	daoMock.EXPECT().LoadToken(dummyUserID).AnyTimes().Return(dummyToken, nil)
+
+	expectAlbumPlayedToItsEnd(clientMock)
+
+	manual := &persistence.PlayerState{ID: "manual", ContextType: "album"}
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{manual, {
+		ID:                 "auto",
+		PlaybackContextURI: "spotify:album:123",
+		ContextType:        "album",
+		Progress:           1000,
+		AutoSaved:          true,
+	}}, 1, nil)
+	gomock.InOrder(
+		daoMock.EXPECT().ArchiveSlot(dummyUserID, gomock.Any()).Times(1).DoAndReturn(func(_ string, slot *persistence.ArchivedSlot) error {
+			if state := slot.PlayerState; state.ID != "auto" || state.Progress != 60000 || slot.CompletedAtTs != state.SuspendedAtTs {
+				t.Fatalf("slot has not been archived properly: %+v", slot)
+			}
+
+			return nil
+		}),
+		daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+			if len(playerStates) != 1 || playerStates[0] != manual {
+				t.Fatalf("expected archived slot to be removed, got %+v", playerStates)
+			}
+
+			return 2, nil
+		}),
+	)
+
+	worker.RunOnce()
+
+	// Archiving the slot must not be told as deleting it
+	expectEvent(t, subscription, events.SlotArchived, "auto")
+	if len(subscription) != 0 {
+		t.Fatalf("unexpected event: %+v", <-subscription)
+	}
+}
+
+func TestAutoSaveUndoesArchivingWhenSavingFails(t *testing.T) {
+	worker, ctrl, daoMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	daoMock.EXPECT().LoadAutoSaveUsers().Times(1).Return(dummyUsers, nil)
+	daoMock.EXPECT().LoadToken(dummyUserID).AnyTimes().Return(dummyToken, nil)
+
+	expectAlbumPlayedToItsEnd(clientMock)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{}, 1, nil)
+	gomock.InOrder(
+		daoMock.EXPECT().ArchiveSlot(dummyUserID, gomock.Any()).Times(1).DoAndReturn(func(_ string, slot *persistence.ArchivedSlot) error {
+			slot.PlayerState.ID = "auto"
+			return nil
+		}),
+		daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).Return(0, persistence.ErrRevisionMismatch),
+		// The next run archives the slot again
+		daoMock.EXPECT().RemoveArchivedSlot(dummyUserID, "auto").Times(1),
+	)
+
+	worker.RunOnce()
+}
+
+func TestAutoSaveStopsWhenAccessRevoked(t *testing.T) {
+	worker, ctrl, daoMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	daoMock.EXPECT().LoadAutoSaveUsers().Times(1).Return(dummyUsers, nil)
+	daoMock.EXPECT().LoadToken(dummyUserID).Times(1).Return(nil, persistence.ErrTokenNotFound)
+	daoMock.EXPECT().DisableAutoSave(dummyUserID).Times(1)
+	clientMock.EXPECT().PlayerState().Times(0)
+
+	worker.RunOnce()
+}
+
+func expectEvent(t *testing.T, subscription <-chan events.Event, eventType events.Type, slot string) {
+	t.Helper()
+
+	select {
+	case event := <-subscription:
+		if event.Type != eventType || event.Slot != slot {
+			t.Fatalf("expected %s event for slot '%s', got %+v", eventType, slot, event)
+		}
+	default:
+		t.Fatalf("expected %s event for slot '%s'", eventType, slot)
+	}
+}
+
+// expectAlbumPlayedToItsEnd lets Spotify report an album consisting of a single track having been played to its very end
+func expectAlbumPlayedToItsEnd(clientMock *mocks.MockSpotClient) {
+	track := spotifyAPI.SimpleTrack{ID: "789", URI: dummyTrack.URI, Name: dummyTrack.Name, Duration: 60000}
+
+	clientMock.EXPECT().PlayerState().Times(1).Return(&spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			PlaybackContext: spotifyAPI.PlaybackContext{
+				URI:  "spotify:album:123",
+				Type: "album",
+			},
+			Progress: 60000,
+			Item:     &spotifyAPI.FullTrack{SimpleTrack: track},
+		},
+	}, nil)
+	clientMock.EXPECT().GetAlbumTracksOpt(spotifyAPI.ID("123"), gomock.Any()).Times(1).DoAndReturn(func(_ spotifyAPI.ID, _ *spotifyAPI.Options) (*spotifyAPI.SimpleTrackPage, error) {
+		page := &spotifyAPI.SimpleTrackPage{Tracks: []spotifyAPI.SimpleTrack{track}}
+		page.Total = 1
+
+		return page, nil
+	})
+}
+
+func singleTrackPlaying(progress int) *spotifyAPI.PlayerState {
+	return &spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			Progress: progress,
+			Item:     dummyTrack,
+		},
+	}
+}
+
+func beforeEach(t *testing.T) (*autosave.Worker, *gomock.Controller, *mocks.MockPersistor, *mocks.MockSpotClient) {
+	return beforeEachWithBus(t, events.NewInProcessBus())
+}
+
+func beforeEachWithBus(t *testing.T, bus events.Bus) (*autosave.Worker, *gomock.Controller, *mocks.MockPersistor, *mocks.MockSpotClient) {
+	ctrl := gomock.NewController(t)
+
+	daoMock := mocks.NewMockPersistor(ctrl)
+	clientMock := mocks.NewMockSpotClient(ctrl)
+
+	worker := autosave.NewWorker(daoMock, bus, func(userID string, token *oauth2.Token) spotify.SpotClient {
+		if userID != dummyUserID || token != dummyToken {
+			t.Fatalf("worker uses unexpected token %+v for user '%s'", token, userID)
+		}
+
+		return clientMock
+	}, 0, dummyTrashRetention)
+
+	return worker, ctrl, daoMock, clientMock
+}
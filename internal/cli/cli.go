@@ -0,0 +1,286 @@
+// Package cli implements a command-line client for the API of Cassette, it authenticates using a personal API token.
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/florianloch/cassette/internal/spotify"
+)
+
+const (
+	envServer = "CASSETTE_SERVER"
+	envToken  = "CASSETTE_TOKEN"
+
+	outputTable = "table"
+	outputJSON  = "json"
+
+	usage = `Usage: cassette-cli [-server URL] [-token TOKEN] [-o table|json] <command> [arguments]
+
+Commands:
+  list                          list all slots
+  devices                       list the active devices
+  suspend [slot]                suspend the current playback into a new slot resp. the given one
+  restore [-device NAME] <slot> restore the given slot, on the named device if given
+  export                        print all data stored about you as JSON
+
+Slots are referred to by their ID or their position as shown by 'list'.
+Server and token default to $CASSETTE_SERVER and $CASSETTE_TOKEN.
+`
+)
+
+// ErrUsage is returned when the command line is malformed, the usage has been printed already
+var ErrUsage = errors.New("invalid usage")
+
+type command struct {
+	client *Client
+	output string
+	out    io.Writer
+}
+
+// Run executes the command line given as args (without the program name) and writes the results to out
+func Run(args []string, out, errOut io.Writer) error {
+	flags := flag.NewFlagSet("cassette-cli", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	flags.Usage = func() {
+		fmt.Fprint(errOut, usage)
+	}
+
+	server := flags.String("server", os.Getenv(envServer), "URL of the Cassette server")
+	token := flags.String("token", os.Getenv(envToken), "personal API token")
+	output := flags.String("o", outputTable, "output format, either 'table' or 'json'")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return ErrUsage
+	}
+
+	if flags.NArg() == 0 || (*output != outputTable && *output != outputJSON) {
+		flags.Usage()
+		return ErrUsage
+	}
+
+	if *server == "" || *token == "" {
+		return fmt.Errorf("server and token have to be given, either as flags or via $%s and $%s", envServer, envToken)
+	}
+
+	cmd := &command{NewClient(*server, *token), *output, out}
+	args = flags.Args()[1:]
+
+	switch flags.Arg(0) {
+	case "list":
+		return cmd.list()
+	case "devices":
+		return cmd.devices()
+	case "suspend":
+		if len(args) > 1 {
+			flags.Usage()
+			return ErrUsage
+		}
+
+		slot := ""
+		if len(args) == 1 {
+			slot = args[0]
+		}
+
+		return cmd.suspend(slot)
+	case "restore":
+		restoreFlags := flag.NewFlagSet("restore", flag.ContinueOnError)
+		restoreFlags.SetOutput(errOut)
+		restoreFlags.Usage = flags.Usage
+		device := restoreFlags.String("device", "", "name of the device to restore the playback on")
+
+		positional, err := parseInterspersed(restoreFlags, args)
+		if err != nil || len(positional) != 1 {
+			flags.Usage()
+			return ErrUsage
+		}
+
+		return cmd.restore(positional[0], *device)
+	case "export":
+		return cmd.client.Export(out)
+	default:
+		flags.Usage()
+		return ErrUsage
+	}
+}
+
+func (c *command) list() error {
+	playerStates, err := c.client.PlayerStates()
+	if err != nil {
+		return err
+	}
+
+	if c.output == outputJSON {
+		return c.printJSON(playerStates)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tID\tTYPE\tTITLE\tTRACK\tPROGRESS\tSUSPENDED AT")
+
+	for i, playerState := range playerStates {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d/%d\t%s\t%s\n",
+			i+1,
+			playerState.ID,
+			playerState.ContextType,
+			playerState.DisplayTitle(),
+			playerState.TrackIndex+1,
+			playerState.TotalTracks,
+			formatDuration(playerState.Progress),
+			time.Unix(playerState.SuspendedAtTs, 0).Format("2006-01-02 15:04"))
+	}
+
+	return w.Flush()
+}
+
+func (c *command) devices() error {
+	devices, err := c.client.ActiveDevices()
+	if err != nil {
+		return err
+	}
+
+	if c.output == outputJSON {
+		return c.printJSON(devices)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tACTIVE")
+
+	for _, device := range devices {
+		fmt.Fprintf(w, "%s\t%s\t%t\n", device.ID, device.Name, device.Active)
+	}
+
+	return w.Flush()
+}
+
+func (c *command) suspend(slot string) error {
+	if slot != "" {
+		var err error
+		slot, err = c.resolveSlot(slot)
+		if err != nil {
+			return err
+		}
+	}
+
+	id, err := c.client.Suspend(slot)
+	if err != nil {
+		return err
+	}
+
+	if c.output == outputJSON {
+		return c.printJSON(map[string]string{"id": id})
+	}
+
+	_, err = fmt.Fprintf(c.out, "Suspended playback into slot %s.\n", id)
+
+	return err
+}
+
+func (c *command) restore(slot, deviceName string) error {
+	slot, err := c.resolveSlot(slot)
+	if err != nil {
+		return err
+	}
+
+	deviceID := ""
+	if deviceName != "" {
+		device, err := c.findDevice(deviceName)
+		if err != nil {
+			return err
+		}
+
+		deviceID = device.ID
+	}
+
+	err = c.client.Restore(slot, deviceID)
+	if err != nil {
+		return err
+	}
+
+	if c.output == outputJSON {
+		return c.printJSON(map[string]string{"id": slot, "deviceID": deviceID})
+	}
+
+	_, err = fmt.Fprintf(c.out, "Restored playback from slot %s.\n", slot)
+
+	return err
+}
+
+// resolveSlot translates the position of a slot as shown by 'list' into its ID, IDs are taken as they are
+func (c *command) resolveSlot(slot string) (string, error) {
+	position, err := strconv.Atoi(slot)
+	if err != nil {
+		return slot, nil
+	}
+
+	playerStates, err := c.client.PlayerStates()
+	if err != nil {
+		return "", err
+	}
+
+	if position < 1 || position > len(playerStates) {
+		return "", fmt.Errorf("there is no slot at position %d", position)
+	}
+
+	return playerStates[position-1].ID, nil
+}
+
+// findDevice looks up the device by its name, ignoring the case
+func (c *command) findDevice(name string) (*spotify.CondensedPlayerDevice, error) {
+	devices, err := c.client.ActiveDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range devices {
+		if strings.EqualFold(devices[i].Name, name) {
+			return &devices[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("there is no active device named %q", name)
+}
+
+func (c *command) printJSON(v interface{}) error {
+	json, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not serialize output: %w", err)
+	}
+
+	_, err = fmt.Fprintln(c.out, string(json))
+
+	return err
+}
+
+// parseInterspersed allows flags to be given before and after the positional arguments
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		err := flags.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+
+		if flags.NArg() == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+func formatDuration(ms int) string {
+	d := time.Duration(ms) * time.Millisecond
+
+	return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
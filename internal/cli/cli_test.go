@@ -0,0 +1,238 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/florianloch/cassette/internal/spotify"
+)
+
+const testToken = "cassette_id_secret"
+
+// serverForTest fakes the API, it records the requests received as "METHOD /path?query"
+func serverForTest(t *testing.T) (*httptest.Server, *[]string) {
+	var requests []string
+
+	playerStates := []*persistence.PlayerState{{
+		ID:          "aaa",
+		ContextType: "album",
+		AlbumName:   "Book for Gophers",
+		TrackIndex:  2,
+		TotalTracks: 60,
+		Progress:    3723000,
+	}, {
+		ID:          "bbb",
+		ContextType: "show",
+		ShowName:    "Podcast for Gophers",
+	}}
+
+	devices := []spotify.CondensedPlayerDevice{
+		{ID: "d1", Name: "Kitchen", Active: true},
+		{ID: "d2", Name: "Living Room"},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		request := r.Method + " " + r.URL.Path
+		if r.URL.RawQuery != "" {
+			request += "?" + r.URL.RawQuery
+		}
+		requests = append(requests, request)
+
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			w.Header().Set("Content-Type", problem.ContentType)
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(problem.Problem{Title: "Unauthorized", Status: http.StatusUnauthorized, Detail: "Invalid token.", Code: problem.CodeInvalidAPIToken})
+			return
+		}
+
+		switch request {
+		case "GET /api/playerStates":
+			_ = json.NewEncoder(w).Encode(playerStates)
+		case "GET /api/activeDevices":
+			_ = json.NewEncoder(w).Encode(devices)
+		case "GET /api/you":
+			_, _ = w.Write([]byte(`{"playerStates":[]}`))
+		case "POST /api/playerStates":
+			w.Header().Set("Location", "/api/playerStates/ccc")
+			w.WriteHeader(http.StatusCreated)
+		case "PUT /api/playerStates/bbb":
+			w.Header().Set("Location", "/api/playerStates/bbb")
+			w.WriteHeader(http.StatusCreated)
+		case "POST /api/playerStates/aaa/restore", "POST /api/playerStates/aaa/restore?deviceID=d2":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Content-Type", problem.ContentType)
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(problem.Problem{Title: "Bad Request", Status: http.StatusBadRequest, Detail: "'slot' does not refer to an existing slot.", Code: problem.CodeSlotOutOfRange})
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func run(t *testing.T, server *httptest.Server, args ...string) (string, error) {
+	var out bytes.Buffer
+
+	err := Run(append([]string{"-server", server.URL, "-token", testToken}, args...), &out, &bytes.Buffer{})
+
+	return out.String(), err
+}
+
+func TestListAsTable(t *testing.T) {
+	server, _ := serverForTest(t)
+
+	out, err := run(t, server, "list")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected header and two slots, got:\n%s", out)
+	}
+
+	if !strings.HasPrefix(lines[0], "#") || !strings.Contains(lines[1], "Book for Gophers") || !strings.Contains(lines[1], "3/60") || !strings.Contains(lines[1], "1:02:03") {
+		t.Fatalf("unexpected table:\n%s", out)
+	}
+
+	if !strings.Contains(lines[2], "Podcast for Gophers") {
+		t.Fatalf("unexpected table:\n%s", out)
+	}
+}
+
+func TestListAsJSON(t *testing.T) {
+	server, _ := serverForTest(t)
+
+	out, err := run(t, server, "-o", "json", "list")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var playerStates []*persistence.PlayerState
+	err = json.Unmarshal([]byte(out), &playerStates)
+	if err != nil {
+		t.Fatalf("output is not valid JSON: %s", err)
+	}
+
+	if len(playerStates) != 2 || playerStates[0].ID != "aaa" || playerStates[1].ShowName != "Podcast for Gophers" {
+		t.Fatalf("unexpected player states: %s", out)
+	}
+}
+
+func TestDevices(t *testing.T) {
+	server, _ := serverForTest(t)
+
+	out, err := run(t, server, "devices")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !strings.Contains(out, "Kitchen") || !strings.Contains(out, "Living Room") {
+		t.Fatalf("unexpected table:\n%s", out)
+	}
+}
+
+func TestSuspend(t *testing.T) {
+	server, requests := serverForTest(t)
+
+	out, err := run(t, server, "-o", "json", "suspend")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if strings.TrimSpace(out) != "{\n  \"id\": \"ccc\"\n}" {
+		t.Fatalf("unexpected output: %s", out)
+	}
+
+	// The slot is given by its position
+	out, err = run(t, server, "suspend", "2")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if out != "Suspended playback into slot bbb.\n" {
+		t.Fatalf("unexpected output: %s", out)
+	}
+
+	expected := "POST /api/playerStates, GET /api/playerStates, PUT /api/playerStates/bbb"
+	if strings.Join(*requests, ", ") != expected {
+		t.Fatalf("unexpected requests: %v", *requests)
+	}
+}
+
+func TestRestoreOnNamedDevice(t *testing.T) {
+	server, requests := serverForTest(t)
+
+	// The flag may be given after the slot, the device name is matched ignoring the case
+	_, err := run(t, server, "restore", "aaa", "-device", "living room")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := "GET /api/activeDevices, POST /api/playerStates/aaa/restore?deviceID=d2"
+	if strings.Join(*requests, ", ") != expected {
+		t.Fatalf("unexpected requests: %v", *requests)
+	}
+
+	_, err = run(t, server, "restore", "-device", "Bathroom", "aaa")
+	if err == nil || !strings.Contains(err.Error(), "Bathroom") {
+		t.Fatalf("expected error about unknown device, got: %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	server, _ := serverForTest(t)
+
+	out, err := run(t, server, "export")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if out != `{"playerStates":[]}` {
+		t.Fatalf("unexpected export: %s", out)
+	}
+}
+
+func TestProblemsAreReported(t *testing.T) {
+	server, _ := serverForTest(t)
+
+	_, err := run(t, server, "restore", "zzz")
+
+	p, ok := err.(*ProblemError)
+	if !ok || p.Code != problem.CodeSlotOutOfRange {
+		t.Fatalf("expected problem, got: %v", err)
+	}
+
+	var out bytes.Buffer
+	err = Run([]string{"-server", server.URL, "-token", "wrong", "list"}, &out, &bytes.Buffer{})
+
+	p, ok = err.(*ProblemError)
+	if !ok || p.Code != problem.CodeInvalidAPIToken || p.Error() != "Invalid token. (invalid_api_token)" {
+		t.Fatalf("expected problem, got: %v", err)
+	}
+}
+
+func TestInvalidUsage(t *testing.T) {
+	server, requests := serverForTest(t)
+
+	for _, args := range [][]string{{}, {"unknown"}, {"-o", "yaml", "list"}, {"restore"}, {"suspend", "1", "2"}} {
+		_, err := run(t, server, args...)
+		if err != ErrUsage {
+			t.Fatalf("expected usage error for %v, got: %v", args, err)
+		}
+	}
+
+	if len(*requests) != 0 {
+		t.Fatalf("unexpected requests: %v", *requests)
+	}
+}
@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/florianloch/cassette/internal/spotify"
+)
+
+// Client talks to the API of a Cassette server on behalf of the owner of a personal API token
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// ProblemError is returned when the server responds with a problem, its detail is meant to be shown to the user
+type ProblemError struct {
+	problem.Problem
+}
+
+func (e *ProblemError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("%s (%s)", e.Title, e.Code)
+	}
+
+	return fmt.Sprintf("%s (%s)", e.Detail, e.Code)
+}
+
+// NewClient expects the URL the server is reachable at, e.g., "https://cassette.example.com"
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *Client) PlayerStates() ([]*persistence.PlayerState, error) {
+	var playerStates []*persistence.PlayerState
+
+	_, err := c.do(http.MethodGet, "/playerStates", nil, &playerStates)
+	if err != nil {
+		return nil, err
+	}
+
+	return playerStates, nil
+}
+
+func (c *Client) ActiveDevices() ([]spotify.CondensedPlayerDevice, error) {
+	var devices []spotify.CondensedPlayerDevice
+
+	_, err := c.do(http.MethodGet, "/activeDevices", nil, &devices)
+	if err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
+
+// Suspend stores the current playback in the given slot, a new slot is added if slot is empty.
+// Returns the ID of the slot the playback has been stored in.
+func (c *Client) Suspend(slot string) (string, error) {
+	method, endpoint := http.MethodPost, "/playerStates"
+	if slot != "" {
+		method, endpoint = http.MethodPut, "/playerStates/"+url.PathEscape(slot)
+	}
+
+	resp, err := c.do(method, endpoint, nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Base(resp.Header.Get("Location")), nil
+}
+
+// Restore resumes the playback stored in the given slot, the currently active device is used if deviceID is empty
+func (c *Client) Restore(slot, deviceID string) error {
+	query := url.Values{}
+	if deviceID != "" {
+		query.Set("deviceID", deviceID)
+	}
+
+	_, err := c.do(http.MethodPost, "/playerStates/"+url.PathEscape(slot)+"/restore", query, nil)
+
+	return err
+}
+
+// Export writes all data stored about the user as provided by the server
+func (c *Client) Export(w io.Writer) error {
+	var dump json.RawMessage
+
+	_, err := c.do(http.MethodGet, "/you", nil, &dump)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(dump)
+
+	return err
+}
+
+// do sends the request to the given endpoint of the API and decodes the response into result, unless it is nil
+func (c *Client) do(method, endpoint string, query url.Values, result interface{}) (*http.Response, error) {
+	target := c.baseURL + "/api" + endpoint
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		var p ProblemError
+		if strings.HasPrefix(resp.Header.Get("Content-Type"), problem.ContentType) && json.Unmarshal(body, &p.Problem) == nil {
+			return nil, &p
+		}
+
+		return nil, fmt.Errorf("server responded with status %d", resp.StatusCode)
+	}
+
+	if result != nil {
+		err = json.Unmarshal(body, result)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode response: %w", err)
+		}
+	}
+
+	return resp, nil
+}
@@ -8,9 +8,32 @@ const (
 	ConsentNoticeHeaderName = "X-Cassette-Consent-Notice"
 	DefaultNetworkInterface = "localhost"
 	DefaultPort             = "8080"
-	JumpBackNSeconds        = 10
 	WebStaticContentPath    = "./web/dist"
 	OAuthCallbackRoute      = "/spotify-oauth-callback"
+	AutoSaveInterval        = "1m"
+	AutoSaveMaxSlots        = 5
+	TrackListingCache       = "memory" // either "memory", "shared" (using the database) or "off"
+	TrackListingCacheTTL    = "24h"
+	TrackListingCacheSize   = 1000
+	TrackListingPruning     = 600 // seconds between pruning the track listings cached in the database
+	SpotifyRequestBudget    = 120 // requests per user and minute
+	EventsHeartbeatInterval = 30  // seconds, keeps proxies from closing idle event streams
+	SleepTimerInterval      = 5   // seconds between checks for sleep timers being due
+	SleepTimerMaxDelay      = 600 // seconds, sleep timers failing to fire are given up afterwards
+	SleepTimerTrackEndSlack = 2   // seconds, sleep timers waiting for the end of a track fire when it is about to end
+	SleepTimerMaxMinutes    = 24 * 60
+	ScheduleInterval        = 30  // seconds between checks for schedules being due
+	ScheduleRunSlack        = 120 // seconds, runs being due for longer count as missed
+	ScheduleMaxDelay        = 600 // seconds, failing runs are given up afterwards
+	ScheduleMaxPerUser      = 20
+	MaxJumpBackSeconds      = 300
+	MaxTagsPerSlot          = 20
+	MaxTagLength            = 50
+	MaxTitleLength          = 200
+	MaxNotesLength          = 2000
+	TrashRetention          = "720h" // deleted slots can be undeleted for this long
+	AccountDeletionGrace    = "168h" // deletions of user records can be cancelled for this long
+	PurgeInterval           = 300    // seconds between purging the trash and carrying out account deletions being due
 
 	// Names of envs
 	EnvENV                 = "CASSETTE_ENV"
@@ -18,19 +41,36 @@ const (
 	EnvPort                = "CASSETTE_PORT"
 	EnvAppURL              = "CASSETTE_APP_URL"
 	EnvSecret              = "CASSETTE_SECRET"
-	EnvMongoURI            = "CASSETTE_MONGODB_URI"
+	EnvDBURI               = "CASSETTE_DB_URI"
+	EnvMongoURI            = "CASSETTE_MONGODB_URI" // deprecated, superseded by EnvDBURI
 	EnvSpotifyClientID     = "CASSETTE_SPOTIFY_CLIENT_ID"
 	EnvSpotifyClientSecret = "CASSETTE_SPOTIFY_CLIENT_KEY"
+	EnvAutoSaveInterval    = "CASSETTE_AUTO_SAVE_INTERVAL"
+	EnvTrackListingCache   = "CASSETTE_TRACK_LISTING_CACHE"
+	EnvTrackListingTTL     = "CASSETTE_TRACK_LISTING_CACHE_TTL"
+	EnvTrashRetention      = "CASSETTE_TRASH_RETENTION"
+	EnvDeletionGrace       = "CASSETTE_ACCOUNT_DELETION_GRACE"
+)
 
-	// Keys for context fields
+// Keys for context fields
+const (
 	FieldKeySession = ctxKey(iota)
 	FieldKeyDao
 	FieldKeySlot
+	FieldKeyBookmark
 	FieldKeyUser
 	FieldKeySpotifyClient
+	FieldKeyAPITokenID
+	FieldKeyEventBus
+	FieldKeySchedule
+	FieldKeyRetention
+)
 
-	// Keys for session values, as these are stored in the session cookie use something small
-	SessionKeyUser = sessionKey(iota)
+// Keys for session values, as these are stored in the session cookie use something small.
+// They must not change as existing sessions could not be read anymore, the numbering continues
+// where it ended when these were declared together with all the other constants.
+const (
+	SessionKeyUser = sessionKey(iota + 23)
 	SessionKeySpotifyToken
 	SessionKeyInitiallyRequestedRoute
 	SessionKeyOAuthRandomState
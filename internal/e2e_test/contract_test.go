@@ -0,0 +1,313 @@
+package e2e_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/go-chi/chi"
+	"github.com/golang/mock/gomock"
+	spotifyAPI "github.com/zmb3/spotify"
+	"golang.org/x/oauth2"
+
+	main "github.com/florianloch/cassette/internal"
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/e2e_test/mocks"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/spotify"
+)
+
+// contract checks the responses of the API against the OpenAPI document served by it
+type contract struct {
+	t        *testing.T
+	document map[string]interface{}
+}
+
+func fetchContract(t *testing.T, e *httpexpect.Expect) *contract {
+	r := e.GET("/api/openapi.json").Expect()
+	r.Status(http.StatusOK)
+	r.ContentType("application/json")
+
+	return &contract{t, r.JSON().Object().Raw()}
+}
+
+// check ensures the status of the response is documented for the operation and the response matches it
+func (c *contract) check(r *httpexpect.Response, method, path string) {
+	c.t.Helper()
+
+	operation, ok := c.lookup("paths", path, strings.ToLower(method))
+	if !ok {
+		c.t.Errorf("%s %s is not documented", method, path)
+		return
+	}
+
+	status := strconv.Itoa(r.Raw().StatusCode)
+
+	response, ok := c.lookupIn(operation, "responses", status)
+	if !ok {
+		c.t.Errorf("status %s of %s %s is not documented", status, method, path)
+		return
+	}
+
+	if headers, ok := c.lookupIn(response, "headers"); ok {
+		for name := range headers {
+			header, _ := c.lookupIn(headers, name)
+			if header["required"] == true {
+				r.Header(name).NotEmpty()
+			}
+		}
+	}
+
+	content, ok := c.lookupIn(response, "content")
+	if !ok {
+		r.Body().Empty()
+		return
+	}
+
+	mediaType := strings.Split(r.Raw().Header.Get("Content-Type"), ";")[0]
+
+	mediaTypeObject, ok := c.lookupIn(content, mediaType)
+	if !ok {
+		c.t.Errorf("content type %q of %s %s with status %s is not documented", mediaType, method, path, status)
+		return
+	}
+
+	r.JSON(httpexpect.ContentOpts{MediaType: mediaType}).Schema(c.schema(mediaTypeObject["schema"].(map[string]interface{})))
+}
+
+// schema makes the components available to the given schema, so references to them can be resolved
+func (c *contract) schema(schema map[string]interface{}) map[string]interface{} {
+	withComponents := map[string]interface{}{
+		"components": c.document["components"],
+	}
+	for key, value := range schema {
+		withComponents[key] = value
+	}
+
+	return withComponents
+}
+
+// operations lists all operations documented, e.g., "GET /playerStates"
+func (c *contract) operations() []string {
+	var operations []string
+
+	for path, pathItem := range c.document["paths"].(map[string]interface{}) {
+		for method := range pathItem.(map[string]interface{}) {
+			if method != "parameters" {
+				operations = append(operations, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+
+	sort.Strings(operations)
+
+	return operations
+}
+
+func (c *contract) lookup(keys ...string) (map[string]interface{}, bool) {
+	return c.lookupIn(c.document, keys...)
+}
+
+// lookupIn descends into the document following the given keys, references are resolved along the way
+func (c *contract) lookupIn(node map[string]interface{}, keys ...string) (map[string]interface{}, bool) {
+	for _, key := range keys {
+		child, ok := node[key].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		node = c.resolve(child)
+	}
+
+	return node, true
+}
+
+func (c *contract) resolve(node map[string]interface{}) map[string]interface{} {
+	ref, ok := node["$ref"].(string)
+	if !ok {
+		return node
+	}
+
+	resolved, ok := c.lookup(strings.Split(strings.TrimPrefix(ref, "#/"), "/")...)
+	if !ok {
+		c.t.Fatalf("could not resolve reference %q", ref)
+	}
+
+	return resolved
+}
+
+func TestOpenAPIDocumentIsValidJSON(t *testing.T) {
+	e, _, _, _, _ := beforeEach(t)
+
+	r := e.GET("/api/openapi.json").Expect()
+	r.Status(http.StatusOK)
+
+	var document map[string]interface{}
+	err := json.Unmarshal([]byte(r.Body().Raw()), &document)
+	if err != nil {
+		t.Fatalf("OpenAPI document is not valid JSON: %s", err)
+	}
+
+	if document["openapi"] != "3.0.3" {
+		t.Fatalf("unexpected OpenAPI version: %v", document["openapi"])
+	}
+}
+
+func TestServedOpenAPIDocumentIsUpToDate(t *testing.T) {
+	e, _, _, _, _ := beforeEach(t)
+
+	document, err := ioutil.ReadFile("../../api/openapi.json")
+	if err != nil {
+		t.Fatalf("Could not read OpenAPI document: %s", err)
+	}
+
+	r := e.GET("/api/openapi.json").Expect()
+	r.Status(http.StatusOK)
+
+	if r.Body().Raw() != string(document) {
+		t.Fatal("OpenAPI document served differs from api/openapi.json, run 'go generate ./internal/handler'")
+	}
+}
+
+func TestAllRoutesAreDocumented(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	webRoot, err := filepath.Abs("../../")
+	if err != nil {
+		t.Fatalf("Could not get path of web root: %s", err)
+	}
+
+	router := main.SetupForTest(mocks.NewMockPersistor(ctrl), mocks.NewMockSpotAuthenticator(ctrl), func(_ string, _ *oauth2.Token) spotify.SpotClient {
+		return mocks.NewMockSpotClient(ctrl)
+	}, webRoot)
+
+	var routed []string
+	err = chi.Walk(router.(chi.Routes), func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/api/") {
+			routed = append(routed, fmt.Sprintf("%s %s", method, strings.TrimSuffix(strings.TrimPrefix(route, "/api"), "/")))
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Could not walk routes: %s", err)
+	}
+
+	sort.Strings(routed)
+
+	e, _, _, _, _ := beforeEach(t)
+	documented := fetchContract(t, e).operations()
+
+	if strings.Join(routed, "\n") != strings.Join(documented, "\n") {
+		t.Fatalf("routes and OpenAPI document differ\nrouted:\n%s\n\ndocumented:\n%s", strings.Join(routed, "\n"), strings.Join(documented, "\n"))
+	}
+}
+
+func TestResponsesMatchContract(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	c := fetchContract(t, e)
+
+	c.check(e.HEAD("/api/csrfToken").Expect(), "HEAD", "/csrfToken")
+	c.check(e.GET("/api/activeDevices").Expect(), "GET", "/activeDevices")
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	clientMock.EXPECT().PlayerDevices().Times(1).Return(dummyDevices, nil)
+
+	c.check(e.GET("/api/activeDevices").Expect(), "GET", "/activeDevices")
+
+	playerStates := func() []*persistence.PlayerState {
+		return []*persistence.PlayerState{{
+			ID:              "book",
+			ContextType:     "album",
+			AlbumName:       "Book for Gophers",
+			TrackIndex:      3,
+			TotalTracks:     60,
+			Progress:        30000,
+			Duration:        60000,
+			ContextDuration: 3600000,
+			ContextElapsed:  120000,
+			SuspendedAtTs:   1615000000,
+			Bookmarks: []*persistence.Bookmark{{
+				ID:          "b1",
+				Name:        "Favourite passage",
+				Note:        "The one with the dragon",
+				TrackURI:    "spotify:track:42",
+				Progress:    60000,
+				CreatedAtTs: 1615000000,
+			}},
+		}, {
+			ID:          "episode",
+			ContextType: "show",
+			ShowName:    "Podcast for Gophers",
+			AutoSaved:   true,
+		}}
+	}
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(4).DoAndReturn(func(_ string) ([]*persistence.PlayerState, int, error) {
+		return playerStates(), 1, nil
+	})
+
+	c.check(e.GET("/api/playerStates").Expect(), "GET", "/playerStates")
+	c.check(e.GET("/api/playerStates/book/bookmarks").Expect(), "GET", "/playerStates/{slot}/bookmarks")
+	c.check(e.GET("/api/playerStates/unknown/bookmarks").Expect(), "GET", "/playerStates/{slot}/bookmarks")
+
+	r := e.DELETE("/api/playerStates/book").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithHeader("If-Match", `"0"`).
+		Expect()
+	c.check(r, "DELETE", "/playerStates/{slot}")
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return(playerStates(), 1, nil)
+	daoMock.EXPECT().TrashSlot(dummyUserID, gomock.Any()).Times(1)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).Return(2, nil)
+
+	r = e.DELETE("/api/playerStates/episode").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "DELETE", "/playerStates/{slot}")
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return(playerStates(), 2, nil)
+	clientMock.EXPECT().Pause().Times(1)
+	clientMock.EXPECT().Shuffle(false).Times(1).Return(&spotify.RateLimitedError{RetryAfter: time.Minute})
+
+	r = e.POST("/api/playerStates/book/restore").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "POST", "/playerStates/{slot}/restore")
+
+	clientMock.EXPECT().PlayerState().Times(1).Return(&spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			PlaybackContext: spotifyAPI.PlaybackContext{
+				URI:  "spotify:artist:123",
+				Type: "artist",
+			},
+			Item: dummyTrack,
+		},
+	}, nil)
+
+	r = e.POST("/api/playerStates").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "POST", "/playerStates")
+
+	daoMock.EXPECT().AutoSaveEnabled(dummyUserID).Times(1).Return(true, nil)
+
+	c.check(e.GET("/api/you/autoSave").Expect(), "GET", "/you/autoSave")
+
+	daoMock.EXPECT().FetchJSONDump(dummyUserID).Times(1).Return(json.Marshal(map[string]interface{}{
+		"version":      1,
+		"revision":     2,
+		"_id":          "hashed user ID",
+		"playerStates": playerStates(),
+	}))
+
+	c.check(e.GET("/api/you").Expect(), "GET", "/you")
+}
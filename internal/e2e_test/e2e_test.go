@@ -1,11 +1,13 @@
 package e2e_test
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,6 +20,7 @@ import (
 	"github.com/florianloch/cassette/internal/constants"
 	"github.com/florianloch/cassette/internal/e2e_test/mocks"
 	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
 	"github.com/florianloch/cassette/internal/spotify"
 )
 
@@ -42,6 +45,24 @@ var (
 		Name:   "Device 2",
 		Active: true,
 	}}
+	dummyTrack = &spotifyAPI.FullTrack{
+		SimpleTrack: spotifyAPI.SimpleTrack{
+			Name:     "Song for Gophers",
+			URI:      "spotify:track:789",
+			Duration: 180000,
+		},
+	}
+	dummyShow = spotifyAPI.SimpleShow{
+		Name:      "Podcast for Gophers",
+		Publisher: "Gopher Radio",
+		URI:       "spotify:show:123",
+	}
+	dummyEpisode = &spotifyAPI.EpisodePage{
+		Name:        "Episode 1",
+		URI:         "spotify:episode:456",
+		Duration_ms: 3600000,
+		Show:        dummyShow,
+	}
 )
 
 func TestAPIHasOwn404Route(t *testing.T) {
@@ -52,7 +73,86 @@ func TestAPIHasOwn404Route(t *testing.T) {
 	r.Status(http.StatusForbidden)
 
 	r = e.GET("/api/currentDevices").Expect()
-	r.Status(http.StatusNotFound)
+	expectProblem(r, http.StatusNotFound, problem.CodeNotFound)
+}
+
+func TestProblemResponses(t *testing.T) {
+	e, ctrl, _, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	r := e.GET("/api/activeDevices").Expect()
+	expectProblem(r, http.StatusForbidden, problem.CodeNotLoggedIn)
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	// The token expected must not be revealed
+	r = e.POST("/api/playerStates").WithHeader(constants.CSRFHeaderName, "forged token").Expect()
+	expectProblem(r, http.StatusForbidden, problem.CodeInvalidCSRFToken)
+	r.Body().NotContains("forged token")
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	r = e.DELETE("/api/playerStates").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	expectProblem(r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed)
+
+	clientMock.EXPECT().PlayerDevices().Times(1).Return(nil, errors.New("something went wrong"))
+
+	// Exactly one problem is written
+	r = e.GET("/api/activeDevices").Expect()
+	o := expectProblem(r, http.StatusInternalServerError, problem.CodeSpotifyError)
+	o.Keys().ContainsOnly("type", "title", "status", "detail", "code")
+}
+
+func TestRestoreWithoutActiveDevice(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(2).Return([]*persistence.PlayerState{dummyPlayerState("book 1")}, 1, nil)
+
+	clientMock.EXPECT().Pause().Times(2)
+	gomock.InOrder(
+		// Spotify tells there is no device to control
+		clientMock.EXPECT().Shuffle(false).Times(1).Return(spotifyAPI.Error{Message: "Player command failed: No active device found", Status: http.StatusNotFound}),
+		clientMock.EXPECT().Shuffle(false).Times(1),
+	)
+	clientMock.EXPECT().PlayerDevices().Times(1).Return([]spotifyAPI.PlayerDevice{}, nil)
+
+	r := e.POST("/api/playerStates/book 1/restore").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	expectProblem(r, http.StatusBadRequest, problem.CodeNoActiveDevice)
+
+	r = e.POST("/api/playerStates/book 1/restore").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	expectProblem(r, http.StatusBadRequest, problem.CodeNoActiveDevice)
+}
+
+func TestSuspendNotSuspendableContext(t *testing.T) {
+	e, ctrl, _, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	clientMock.EXPECT().PlayerState().Times(1).Return(&spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			PlaybackContext: spotifyAPI.PlaybackContext{
+				URI:  "spotify:artist:123",
+				Type: "artist",
+			},
+			Item: dummyTrack,
+		},
+	}, nil)
+
+	r := e.POST("/api/playerStates").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	expectProblem(r, http.StatusBadRequest, problem.CodeContextNotSuspendable)
+
+	r = e.POST("/api/playerStates/unknown/bookmarks").WithHeader(constants.CSRFHeaderName, csrfToken).WithText("not json").Expect()
+	expectProblem(r, http.StatusBadRequest, problem.CodeInvalidRequest)
 }
 
 func TestConsentCheck(t *testing.T) {
@@ -91,7 +191,7 @@ func TestRetrievalOfPlayerStates(t *testing.T) {
 	login(t, e, authMock)
 
 	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).
-		Return([]*persistence.PlayerState{dummyPlayerState("book 1"), dummyPlayerState("book 2")}, nil)
+		Return([]*persistence.PlayerState{dummyPlayerState("book 1"), dummyPlayerState("book 2")}, 1, nil)
 
 	// currentUser gets stored in the session so should only be called once in the scope of a test
 	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
@@ -99,6 +199,7 @@ func TestRetrievalOfPlayerStates(t *testing.T) {
 	r := e.GET("/api/playerStates").Expect()
 	r.Status(http.StatusOK)
 	r.ContentType("application/json")
+	r.Header("ETag").Equal(`"1"`)
 	a := r.JSON().Array()
 	a.Length().Equal(2)
 	a.Element(0).Object().Value("albumName").String().Equal("book 1")
@@ -111,6 +212,7 @@ func TestRetrievalOfActiveDevices(t *testing.T) {
 
 	login(t, e, authMock)
 
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
 	clientMock.EXPECT().PlayerDevices().Times(1).Return(dummyDevices, nil)
 
 	r := e.GET("/api/activeDevices").Expect()
@@ -126,38 +228,2027 @@ func TestRetrievalOfActiveDevices(t *testing.T) {
 	o2.Value("active").Boolean().True()
 }
 
-func TestSavePlayerState(t *testing.T) {
-	// TODO: implement!
-	// 1. With invalid/not-attached CSRF token
-	// 2. With specific slot
-	// 3. Without specific slot
+func TestSaveEpisodePlayerState(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	// Spotify does not include episodes in the player's state unless explicitly asked for
+	clientMock.EXPECT().PlayerState().Times(1).Return(&spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			PlaybackContext: spotifyAPI.PlaybackContext{
+				Type: "show",
+				URI:  dummyShow.URI,
+			},
+			Progress: 42000,
+		},
+	}, nil)
+	clientMock.EXPECT().CurrentlyPlayingEpisode().Times(1).Return(dummyEpisode, nil)
+	clientMock.EXPECT().Pause().Times(1)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1")}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if len(playerStates) != 2 {
+			t.Fatalf("expected 2 player states, got %d", len(playerStates))
+		}
+
+		state := playerStates[1]
+		if state.ContextType != "show" ||
+			state.PlaybackContextURI != string(dummyShow.URI) ||
+			state.PlaybackItemURI != string(dummyEpisode.URI) ||
+			state.ShowName != dummyShow.Name ||
+			state.TrackName != dummyEpisode.Name ||
+			state.Progress != 42000 ||
+			state.Duration != dummyEpisode.Duration_ms {
+			t.Fatalf("episode has not been captured properly: %+v", state)
+		}
+
+		return 2, nil
+	})
+
+	r := e.POST("/api/playerStates").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusCreated)
 }
 
-func TestRestorePlayerState(t *testing.T) {
-	// TODO: implement!
-	// 1. With specific device
-	// 2. With default device
+func TestSaveEpisodePlayedFromShow(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	// Asking for episodes explicitly, Spotify provides an item even though it is not a track
+	clientMock.EXPECT().PlayerState().Times(1).Return(&spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			PlaybackContext: spotifyAPI.PlaybackContext{
+				Type: "show",
+				URI:  dummyShow.URI,
+			},
+			Progress: 42000,
+			Item:     &spotifyAPI.FullTrack{SimpleTrack: spotifyAPI.SimpleTrack{URI: dummyEpisode.URI, Name: dummyEpisode.Name}},
+		},
+	}, nil)
+	clientMock.EXPECT().CurrentlyPlayingEpisode().Times(1).Return(dummyEpisode, nil)
+	clientMock.EXPECT().Pause().Times(1)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if len(playerStates) != 1 {
+			t.Fatalf("expected 1 player state, got %d", len(playerStates))
+		}
+
+		state := playerStates[0]
+		if state.ContextType != "show" ||
+			state.PlaybackContextURI != string(dummyShow.URI) ||
+			state.PlaybackItemURI != string(dummyEpisode.URI) ||
+			state.ShowName != dummyShow.Name ||
+			state.Progress != 42000 {
+			t.Fatalf("episode has not been captured properly: %+v", state)
+		}
+
+		return 2, nil
+	})
+
+	r := e.POST("/api/playerStates").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusCreated)
 }
 
-func TestDeletePlayerState(t *testing.T) {
-	// TODO: implement!
+func TestOverwritePlayerState(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	clientMock.EXPECT().PlayerState().Times(1).Return(&spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			Progress: 1337,
+			Item:     dummyTrack,
+		},
+	}, nil)
+	clientMock.EXPECT().Pause().Times(1)
+
+	overwritten := dummyPlayerState("book 2")
+	overwritten.Bookmarks = []*persistence.Bookmark{{ID: "bookmark"}}
+	overwritten.Title = "Book 2 (unabridged)"
+	overwritten.Notes = "Recommended by Alice"
+	overwritten.Tags = []string{"fantasy"}
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1"), overwritten}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if len(playerStates) != 2 || playerStates[0].AlbumName != "book 1" {
+			t.Fatalf("unexpected player states: %+v", playerStates)
+		}
+
+		// The slot has to keep its ID along with the labels set by the user, the bookmarks refer to the context
+		// replaced though
+		if state := playerStates[1]; state.ID != "book 2" || state.TrackName != dummyTrack.Name || len(state.Bookmarks) != 0 ||
+			state.Title != "Book 2 (unabridged)" || state.Notes != "Recommended by Alice" || len(state.Tags) != 1 {
+			t.Fatalf("slot has not been overwritten properly: %+v", state)
+		}
+
+		return 2, nil
+	})
+
+	r := e.PUT("/api/playerStates/book 2").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusCreated)
+	r.Header("Location").Equal("/api/playerStates/book 2")
 }
 
-func TestExportUserData(t *testing.T) {
-	// TODO: implement!
+func TestOverwritePlayerStateKeepsBookmarksOfSameContext(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	clientMock.EXPECT().PlayerState().Times(1).Return(&spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			Progress: 1337,
+			Item:     dummyTrack,
+		},
+	}, nil)
+	clientMock.EXPECT().Pause().Times(1)
+
+	overwritten := &persistence.PlayerState{
+		ID:              "song",
+		PlaybackItemURI: string(dummyTrack.URI),
+		ContextType:     "track",
+		Progress:        42,
+		Bookmarks:       []*persistence.Bookmark{{ID: "bookmark"}},
+		Title:           "Song for Gophers (live)",
+	}
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{overwritten}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if len(playerStates) != 1 {
+			t.Fatalf("unexpected player states: %+v", playerStates)
+		}
+
+		if state := playerStates[0]; state.ID != "song" || state.Progress != 1337 || len(state.Bookmarks) != 1 || state.Title != "Song for Gophers (live)" {
+			t.Fatalf("slot has not been overwritten properly: %+v", state)
+		}
+
+		return 2, nil
+	})
+
+	r := e.PUT("/api/playerStates/song").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusCreated)
 }
 
-func TestDeleteUserData(t *testing.T) {
-	// TODO: implement!
+func TestEditAndFilterByLabels(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	c := fetchContract(t, e)
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	tooManyTags := make([]string, constants.MaxTagsPerSlot+1)
+	for i := range tooManyTags {
+		tooManyTags[i] = fmt.Sprintf("tag %d", i)
+	}
+
+	r := e.PATCH("/api/playerStates/book 1").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"tags": tooManyTags}).
+		Expect()
+	c.check(r, "PATCH", "/playerStates/{slot}")
+	expectProblem(r, http.StatusBadRequest, problem.CodeInvalidRequest)
+
+	r = e.PATCH("/api/playerStates/book 1").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"title": strings.Repeat("a", constants.MaxTitleLength+1)}).
+		Expect()
+	expectProblem(r, http.StatusBadRequest, problem.CodeInvalidRequest)
+
+	r = e.PATCH("/api/playerStates/book 1").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"notes": strings.Repeat("a", constants.MaxNotesLength+1)}).
+		Expect()
+	expectProblem(r, http.StatusBadRequest, problem.CodeInvalidRequest)
+
+	playerStates := func() []*persistence.PlayerState {
+		book1 := dummyPlayerState("book 1")
+		book1.Notes = "Recommended by Alice"
+
+		book2 := dummyPlayerState("book 2")
+		book2.Tags = []string{"Fantasy"}
+
+		return []*persistence.PlayerState{book1, book2}
+	}
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return(playerStates(), 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		state := playerStates[0]
+		// Fields missing are left unchanged, duplicate tags are dropped
+		if state.Title != "Book 1" || state.Notes != "Recommended by Alice" || !reflect.DeepEqual(state.Tags, []string{"fantasy", "favorite"}) {
+			t.Fatalf("slot has not been edited properly: %+v", state)
+		}
+
+		return 2, nil
+	})
+
+	r = e.PATCH("/api/playerStates/book 1").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithHeader("If-Match", `"1"`).
+		WithJSON(map[string]interface{}{"title": " Book 1 ", "tags": []string{"fantasy", "favorite", "Fantasy "}}).
+		Expect()
+	c.check(r, "PATCH", "/playerStates/{slot}")
+	r.Status(http.StatusOK)
+	r.Header("ETag").Equal(`"2"`)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(2).Return(playerStates(), 1, nil)
+
+	r = e.GET("/api/playerStates").WithQuery("tag", "fantasy").Expect()
+	c.check(r, "GET", "/playerStates")
+	r.Status(http.StatusOK)
+	tagged := r.JSON().Array()
+	tagged.Length().Equal(1)
+	tagged.Element(0).Object().ValueEqual("id", "book 2").ValueEqual("tags", []string{"Fantasy"})
+
+	// All of the tags are required
+	r = e.GET("/api/playerStates").WithQuery("tag", "fantasy").WithQuery("tag", "favorite").Expect()
+	r.Status(http.StatusOK)
+	r.JSON().Array().Length().Equal(0)
+}
+
+func TestOrderOfSlots(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	c := fetchContract(t, e)
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	playerStates := func() []*persistence.PlayerState {
+		book1 := dummyPlayerState("book 1")
+		book1.SuspendedAtTs = 100
+
+		book2 := dummyPlayerState("book 2")
+		book2.SuspendedAtTs = 300
+
+		book3 := dummyPlayerState("book 3")
+		book3.SuspendedAtTs = 200
+		book3.Pinned = true
+
+		return []*persistence.PlayerState{book1, book2, book3}
+	}
+
+	r := e.GET("/api/playerStates").WithQuery("sort", "random").Expect()
+	c.check(r, "GET", "/playerStates")
+	expectProblem(r, http.StatusBadRequest, problem.CodeInvalidRequest)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(2).Return(playerStates(), 1, nil)
+
+	// Pinned slots come first, the others keep their manual order by default
+	r = e.GET("/api/playerStates").Expect()
+	c.check(r, "GET", "/playerStates")
+	r.Status(http.StatusOK)
+	r.Header("ETag").Equal(`"1"`)
+	r.JSON().Path("$[*].id").Equal([]string{"book 3", "book 1", "book 2"})
+
+	r = e.GET("/api/playerStates").WithQuery("sort", "recent").Expect()
+	c.check(r, "GET", "/playerStates")
+	r.Status(http.StatusOK)
+	r.JSON().Path("$[*].id").Equal([]string{"book 3", "book 2", "book 1"})
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return(playerStates(), 1, nil)
+
+	r = e.PUT("/api/playerStates/order").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"slots": []string{"book 2", "book 1", "book 2"}}).
+		Expect()
+	c.check(r, "PUT", "/playerStates/order")
+	expectProblem(r, http.StatusBadRequest, problem.CodeInvalidRequest)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return(playerStates(), 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if len(playerStates) != 3 || playerStates[0].ID != "book 2" || playerStates[1].ID != "book 3" || playerStates[2].ID != "book 1" {
+			t.Fatalf("slots have not been reordered properly: %+v", playerStates)
+		}
+
+		return 2, nil
+	})
+
+	r = e.PUT("/api/playerStates/order").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithHeader("If-Match", `"1"`).
+		WithJSON(map[string]interface{}{"slots": []string{"book 2", "book 3", "book 1"}}).
+		Expect()
+	c.check(r, "PUT", "/playerStates/order")
+	r.Status(http.StatusOK)
+	r.Header("ETag").Equal(`"2"`)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return(playerStates(), 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if playerStates[2].Pinned {
+			t.Fatalf("slot has not been unpinned: %+v", playerStates[2])
+		}
+
+		return 2, nil
+	})
+
+	r = e.PATCH("/api/playerStates/book 3").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"pinned": false}).
+		Expect()
+	c.check(r, "PATCH", "/playerStates/{slot}")
+	r.Status(http.StatusOK)
+}
+
+func TestRestoreEpisodePlayerState(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1"), {
+		ID:                 "episode",
+		PlaybackContextURI: string(dummyShow.URI),
+		PlaybackItemURI:    string(dummyEpisode.URI),
+		ContextType:        "show",
+		ShowName:           dummyShow.Name,
+		Progress:           42000,
+	}}, 1, nil)
+
+	deviceID := spotifyAPI.ID("002")
+	clientMock.EXPECT().Pause().Times(1)
+	clientMock.EXPECT().Shuffle(false).Times(1)
+	// Spotify does not support an offset within shows, so the episode has to be played directly
+	clientMock.EXPECT().PlayOpt(&spotifyAPI.PlayOptions{
+		DeviceID:   &deviceID,
+		URIs:       []spotifyAPI.URI{dummyEpisode.URI},
+		PositionMs: 42000 - persistence.DefaultJumpBackSeconds*1e3,
+	}).Times(1)
+
+	r := e.POST("/api/playerStates/episode/restore").
+		WithQuery("deviceID", deviceID).
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		Expect()
+	r.Status(http.StatusOK)
+}
+
+func TestSaveSingleTrackPlayerState(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	// Playing a track from the search results there is no context at all
+	clientMock.EXPECT().PlayerState().Times(1).Return(&spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			Progress: 1337,
+			Item:     dummyTrack,
+		},
+	}, nil)
+	clientMock.EXPECT().Pause().Times(1)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if len(playerStates) != 1 {
+			t.Fatalf("expected 1 player state, got %d", len(playerStates))
+		}
+
+		state := playerStates[0]
+		if state.ContextType != "track" ||
+			state.PlaybackContextURI != "" ||
+			state.PlaybackItemURI != string(dummyTrack.URI) ||
+			state.TrackName != dummyTrack.Name ||
+			state.Progress != 1337 {
+			t.Fatalf("single track has not been captured properly: %+v", state)
+		}
+
+		return 2, nil
+	})
+
+	r := e.POST("/api/playerStates").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusCreated)
+}
+
+func TestRestoreSingleTrackPlayerState(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{{
+		PlaybackItemURI:  string(dummyTrack.URI),
+		ContextType:      "track",
+		Progress:         60000,
+		ShuffleActivated: true,
+	}}, 1, nil)
+
+	// Without a given device the active one should be used
+	activeDeviceID := dummyDevices[1].ID
+	clientMock.EXPECT().Pause().Times(1)
+	clientMock.EXPECT().Shuffle(true).Times(1)
+	clientMock.EXPECT().PlayerDevices().Times(1).Return(dummyDevices, nil)
+	clientMock.EXPECT().PlayOpt(&spotifyAPI.PlayOptions{
+		DeviceID:   &activeDeviceID,
+		URIs:       []spotifyAPI.URI{dummyTrack.URI},
+		PositionMs: 60000 - persistence.DefaultJumpBackSeconds*1e3,
+	}).Times(1)
+
+	// Addressing slots by their position is still supported but deprecated
+	r := e.POST("/api/playerStates/0/restore").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusOK)
+	r.Header("Deprecation").Equal("true")
+}
+
+func TestSavePlayerSettings(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	clientMock.EXPECT().PlayerState().Times(1).Return(&spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			Progress: 1337,
+			Item:     dummyTrack,
+		},
+		Device:      spotifyAPI.PlayerDevice{ID: "002", Name: "Device 2", Active: true, Volume: 35},
+		RepeatState: "track",
+	}, nil)
+	clientMock.EXPECT().Pause().Times(1)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if state := playerStates[0]; state.RepeatState != "track" || state.Volume != 35 || state.DeviceName != "Device 2" {
+			t.Fatalf("player settings have not been captured properly: %+v", state)
+		}
+
+		return 2, nil
+	})
+
+	r := e.POST("/api/playerStates").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusCreated)
+}
+
+func TestRestorePlayerSettingsOntoOriginalDevice(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEachWithoutTokenStore(t)
+	defer ctrl.Finish()
+
+	daoMock.EXPECT().StoreToken(dummyUserID, dummyOAuthToken).AnyTimes()
+	daoMock.EXPECT().LoadToken(dummyUserID).AnyTimes().Return(dummyOAuthToken, nil)
+	daoMock.EXPECT().LoadPreferences(dummyUserID).Times(1).Return(&persistence.Preferences{RestoreDevice: true}, nil)
+	daoMock.EXPECT().AppendHistoryEvent(dummyUserID, gomock.Any()).Times(1)
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{{
+		ID:              "track",
+		PlaybackItemURI: string(dummyTrack.URI),
+		ContextType:     "track",
+		Progress:        60000,
+		RepeatState:     "context",
+		Volume:          35,
+		DeviceName:      "Device 1",
+	}}, 1, nil)
+
+	// The device suspended on is not the active one, so the playback has to be transferred before restoring it
+	originalDeviceID := dummyDevices[0].ID
+	gomock.InOrder(
+		clientMock.EXPECT().Pause().Times(1),
+		clientMock.EXPECT().PlayerDevices().Times(1).Return(dummyDevices, nil),
+		clientMock.EXPECT().TransferPlayback(originalDeviceID, false).Times(1),
+		clientMock.EXPECT().Shuffle(false).Times(1),
+		clientMock.EXPECT().PlayOpt(&spotifyAPI.PlayOptions{
+			DeviceID:   &originalDeviceID,
+			URIs:       []spotifyAPI.URI{dummyTrack.URI},
+			PositionMs: 60000,
+		}).Times(1),
+		clientMock.EXPECT().Repeat("context").Times(1),
+		// Failing to restore the volume does not fail restoring the slot
+		clientMock.EXPECT().Volume(35).Times(1).Return(spotifyAPI.Error{Message: "Player command failed: Restriction violated", Status: http.StatusForbidden}),
+	)
+
+	r := e.POST("/api/playerStates/track/restore").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusOK)
+}
+
+func TestSaveAlbumPlayerStateWithOverallProgress(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	// The album spans two pages, the current track is the second one on the first page
+	chapters := make([]spotifyAPI.SimpleTrack, 60)
+	for i := range chapters {
+		chapters[i] = spotifyAPI.SimpleTrack{
+			ID:       spotifyAPI.ID(fmt.Sprintf("chapter%d", i)),
+			Duration: (i + 1) * 1000,
+		}
+	}
+
+	chapter := &spotifyAPI.FullTrack{SimpleTrack: chapters[1]}
+	chapter.URI = "spotify:track:chapter1"
+
+	clientMock.EXPECT().PlayerState().Times(1).Return(&spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			PlaybackContext: spotifyAPI.PlaybackContext{
+				URI:  "spotify:album:123",
+				Type: "album",
+			},
+			Progress: 500,
+			Item:     chapter,
+		},
+	}, nil)
+	clientMock.EXPECT().GetAlbumTracksOpt(spotifyAPI.ID("123"), gomock.Any()).Times(2).DoAndReturn(func(_ spotifyAPI.ID, opt *spotifyAPI.Options) (*spotifyAPI.SimpleTrackPage, error) {
+		end := *opt.Offset + *opt.Limit
+		if end > len(chapters) {
+			end = len(chapters)
+		}
+
+		page := &spotifyAPI.SimpleTrackPage{Tracks: chapters[*opt.Offset:end]}
+		page.Total = len(chapters)
+
+		return page, nil
+	})
+	clientMock.EXPECT().Pause().Times(1)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		state := playerStates[0]
+		if state.TrackIndex != 2 ||
+			state.TotalTracks != 60 ||
+			state.ContextElapsed != 1000 ||
+			state.ContextDuration != 60*61/2*1000 {
+			t.Fatalf("position within album has not been captured properly: %+v", state)
+		}
+
+		return 2, nil
+	})
+
+	r := e.POST("/api/playerStates").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusCreated)
+}
+
+func TestTrackListingsOfContextsGetCached(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	chapter := &spotifyAPI.FullTrack{SimpleTrack: spotifyAPI.SimpleTrack{ID: "chapter", URI: "spotify:track:chapter", Duration: 60000}}
+	playingIn := func(contextURI spotifyAPI.URI, typ string) *spotifyAPI.PlayerState {
+		return &spotifyAPI.PlayerState{
+			CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+				PlaybackContext: spotifyAPI.PlaybackContext{URI: contextURI, Type: typ},
+				Item:            chapter,
+			},
+		}
+	}
+
+	albumPage := &spotifyAPI.SimpleTrackPage{Tracks: []spotifyAPI.SimpleTrack{chapter.SimpleTrack}}
+	albumPage.Total = 1
+	playlistPage := &spotifyAPI.PlaylistTrackPage{Tracks: []spotifyAPI.PlaylistTrack{{Track: *chapter}}}
+	playlistPage.Total = 1
+
+	// The album only has to be fetched once, the playlist once per snapshot
+	gomock.InOrder(
+		clientMock.EXPECT().PlayerState().Times(2).Return(playingIn("spotify:album:123", "album"), nil),
+		clientMock.EXPECT().PlayerState().Times(3).Return(playingIn("spotify:playlist:456", "playlist"), nil),
+	)
+	clientMock.EXPECT().GetAlbumTracksOpt(spotifyAPI.ID("123"), gomock.Any()).Times(1).Return(albumPage, nil)
+	gomock.InOrder(
+		clientMock.EXPECT().GetPlaylistOpt(spotifyAPI.ID("456"), "name,snapshot_id").Times(2).Return(&spotifyAPI.FullPlaylist{SimplePlaylist: spotifyAPI.SimplePlaylist{SnapshotID: "snapshot1"}}, nil),
+		clientMock.EXPECT().GetPlaylistOpt(spotifyAPI.ID("456"), "name,snapshot_id").Times(1).Return(&spotifyAPI.FullPlaylist{SimplePlaylist: spotifyAPI.SimplePlaylist{SnapshotID: "snapshot2"}}, nil),
+	)
+	clientMock.EXPECT().GetPlaylistTracksOpt(spotifyAPI.ID("456"), gomock.Any(), gomock.Any()).Times(2).Return(playlistPage, nil)
+	clientMock.EXPECT().Pause().Times(5)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(5).Return([]*persistence.PlayerState{}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(5).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if state := playerStates[0]; state.TrackIndex != 1 || state.ContextDuration != 60000 {
+			t.Fatalf("position within context has not been captured properly: %+v", state)
+		}
+
+		return 2, nil
+	})
+
+	for i := 0; i < 5; i++ {
+		r := e.POST("/api/playerStates").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+		r.Status(http.StatusCreated)
+	}
+}
+
+func TestOverallProgressOfPlayerStates(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{{
+		ContextType:     "album",
+		Progress:        30000,
+		Duration:        60000,
+		ContextElapsed:  270000,
+		ContextDuration: 400000,
+	}, {
+		ContextType: "show",
+		Progress:    900000,
+		Duration:    3600000,
+	}, {
+		// Suspended before the durations of the tracks got stored
+		ContextType: "playlist",
+		Progress:    30000,
+		Duration:    60000,
+	}}, 1, nil)
+
+	r := e.GET("/api/playerStates").Expect()
+	r.Status(http.StatusOK)
+	a := r.JSON().Array()
+	a.Element(0).Object().Value("percentComplete").Number().Equal(75)
+	a.Element(0).Object().Value("timeRemaining").Number().Equal(100000)
+	a.Element(1).Object().Value("percentComplete").Number().Equal(25)
+	a.Element(1).Object().Value("timeRemaining").Number().Equal(2700000)
+	a.Element(2).Object().NotContainsKey("percentComplete")
+	a.Element(2).Object().NotContainsKey("timeRemaining")
+}
+
+func TestRestoreWhileSpotifyIsRateLimiting(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1")}, 1, nil)
+
+	clientMock.EXPECT().Pause().Times(1)
+	clientMock.EXPECT().Shuffle(false).Times(1).Return(&spotify.RateLimitedError{RetryAfter: 1500 * time.Millisecond})
+
+	r := e.POST("/api/playerStates/book 1/restore").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	expectProblem(r, http.StatusServiceUnavailable, problem.CodeSpotifyUnavailable)
+	r.Header("Retry-After").Equal("2")
+}
+
+func TestRestoreExceedingRequestBudget(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	c := fetchContract(t, e)
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1")}, 1, nil)
+
+	clientMock.EXPECT().Pause().Times(1)
+	clientMock.EXPECT().Shuffle(false).Times(1).Return(&spotify.BudgetExceededError{RetryAfter: 2500 * time.Millisecond})
+
+	r := e.POST("/api/playerStates/book 1/restore").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "POST", "/playerStates/{slot}/restore")
+	expectProblem(r, http.StatusTooManyRequests, problem.CodeRequestBudgetExceeded)
+	r.Header("Retry-After").Equal("3")
+}
+
+func TestSavePlayerState(t *testing.T) {
+	// TODO: implement!
+	// 1. With invalid/not-attached CSRF token
+	// 2. With specific slot
+	// 3. Without specific slot
+}
+
+func TestRestorePlayerState(t *testing.T) {
+	// TODO: implement!
+	// 1. With specific device
+	// 2. With default device
+}
+
+func TestDeletePlayerState(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	// Deleting by ID must not be affected by the position of the slot changing in the meantime
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1"), dummyPlayerState("book 2"), dummyPlayerState("book 3")}, 1, nil)
+	// The slot is kept in the trash until the retention is over
+	daoMock.EXPECT().TrashSlot(dummyUserID, gomock.Any()).Times(1).DoAndReturn(func(_ string, slot *persistence.TrashedSlot) error {
+		if slot.PlayerState.ID != "book 2" || slot.PurgeAtTs-slot.DeletedAtTs != int64((30*24*time.Hour).Seconds()) {
+			t.Fatalf("slot has not been trashed properly: %+v", slot)
+		}
+
+		return nil
+	})
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, []*persistence.PlayerState{dummyPlayerState("book 1"), dummyPlayerState("book 3")}, 1).Times(1).Return(2, nil)
+
+	r := e.DELETE("/api/playerStates/book 2").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusOK)
+	r.Header("Deprecation").Empty()
+	r.Header("ETag").Equal(`"2"`)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1")}, 1, nil)
+
+	r = e.DELETE("/api/playerStates/book 2").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusNotFound)
+}
+
+func TestDeletePlayerStateWithStaleRevision(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	// Another tab modified the player states after this client fetched them
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1"), dummyPlayerState("book 2")}, 2, nil)
+	// The revision is checked before touching the trash
+	daoMock.EXPECT().TrashSlot(gomock.Any(), gomock.Any()).Times(0)
+	daoMock.EXPECT().SavePlayerStates(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	r := e.DELETE("/api/playerStates/book 2").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithHeader("If-Match", `"1"`).
+		Expect()
+	r.Status(http.StatusPreconditionFailed)
+}
+
+func TestDeletePlayerStateWithMatchingRevision(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1"), dummyPlayerState("book 2")}, 2, nil)
+	daoMock.EXPECT().TrashSlot(dummyUserID, gomock.Any()).Times(1)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, []*persistence.PlayerState{dummyPlayerState("book 1")}, 2).Times(1).Return(3, nil)
+
+	r := e.DELETE("/api/playerStates/book 2").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithHeader("If-Match", `"2"`).
+		Expect()
+	r.Status(http.StatusOK)
+	r.Header("ETag").Equal(`"3"`)
+}
+
+func TestDeletePlayerStateModifiedConcurrently(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1"), dummyPlayerState("book 2")}, 1, nil)
+	gomock.InOrder(
+		daoMock.EXPECT().TrashSlot(dummyUserID, gomock.Any()).Times(1),
+		daoMock.EXPECT().SavePlayerStates(dummyUserID, []*persistence.PlayerState{dummyPlayerState("book 1")}, 1).Times(1).Return(0, persistence.ErrRevisionMismatch),
+		// The slot has not been deleted, so it must not be kept in the trash
+		daoMock.EXPECT().RemoveTrashedSlot(dummyUserID, "book 2").Times(1),
+	)
+
+	r := e.DELETE("/api/playerStates/book 2").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	expectProblem(r, http.StatusConflict, problem.CodeModifiedConcurrently)
+}
+
+func TestSavePlayerStateModifiedConcurrently(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	clientMock.EXPECT().PlayerState().Times(1).Return(&spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			Progress: 1337,
+			Item:     dummyTrack,
+		},
+	}, nil)
+	// Playback must not be paused in case the state could not be stored
+	clientMock.EXPECT().Pause().Times(0)
+
+	// Another request stored the player states between loading and saving them
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).Return(0, persistence.ErrRevisionMismatch)
+
+	r := e.POST("/api/playerStates").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusConflict)
+}
+
+func TestAutoSaveOptInAndOut(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	daoMock.EXPECT().AutoSaveEnabled(dummyUserID).Times(1).Return(false, nil)
+
+	r := e.GET("/api/you/autoSave").Expect()
+	r.Status(http.StatusOK)
+	r.JSON().Object().Value("enabled").Boolean().False()
+
+	daoMock.EXPECT().EnableAutoSave(dummyUserID).Times(1)
+
+	r = e.PUT("/api/you/autoSave").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusNoContent)
+
+	daoMock.EXPECT().DisableAutoSave(dummyUserID).Times(1)
+
+	r = e.DELETE("/api/you/autoSave").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusNoContent)
+}
+
+func TestSuspendTakesOverAutoSavedSlot(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	clientMock.EXPECT().PlayerState().Times(1).Return(&spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			Progress: 1337,
+			Item:     dummyTrack,
+		},
+	}, nil)
+	clientMock.EXPECT().Pause().Times(1)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1"), {
+		ID:              "auto",
+		PlaybackItemURI: string(dummyTrack.URI),
+		ContextType:     "track",
+		AutoSaved:       true,
+	}}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if len(playerStates) != 2 {
+			t.Fatalf("expected 2 player states, got %d", len(playerStates))
+		}
+
+		if state := playerStates[1]; state.ID != "auto" || state.AutoSaved || state.Progress != 1337 {
+			t.Fatalf("auto saved slot has not been taken over: %+v", state)
+		}
+
+		return 2, nil
+	})
+
+	r := e.POST("/api/playerStates").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusCreated)
+	r.Header("Location").Equal("/api/playerStates/auto")
+}
+
+func TestTokenGetsMovedToTokenStore(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEachWithoutTokenStore(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+
+	// The first request identifies the user and moves the token from the session to the token store...
+	gomock.InOrder(
+		clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil),
+		daoMock.EXPECT().StoreToken(dummyUserID, dummyOAuthToken).Times(1),
+	)
+	clientMock.EXPECT().PlayerDevices().Times(2).Return(dummyDevices, nil)
+
+	r := e.GET("/api/activeDevices").Expect()
+	r.Status(http.StatusOK)
+
+	// ... subsequent requests use the stored token
+	daoMock.EXPECT().LoadToken(dummyUserID).Times(1).Return(dummyOAuthToken, nil)
+
+	r = e.GET("/api/activeDevices").Expect()
+	r.Status(http.StatusOK)
+}
+
+func TestRevokeToken(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEachWithoutTokenStore(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	daoMock.EXPECT().StoreToken(dummyUserID, dummyOAuthToken).Times(1)
+	daoMock.EXPECT().AutoSaveEnabled(dummyUserID).Times(1).Return(true, nil)
+
+	r := e.GET("/api/you/autoSave").Expect()
+	r.Status(http.StatusOK)
+
+	gomock.InOrder(
+		daoMock.EXPECT().LoadToken(dummyUserID).Times(1).Return(dummyOAuthToken, nil),
+		daoMock.EXPECT().DeleteToken(dummyUserID).Times(1),
+	)
+	daoMock.EXPECT().DisableAutoSave(dummyUserID).Times(1)
+
+	r = e.DELETE("/api/you/token").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusNoContent)
+
+	// The session has been ended, so the user has to log in again
+	r = e.GET("/api/activeDevices").Expect()
+	r.Status(http.StatusForbidden)
+
+	authMock.EXPECT().AuthURL(gomock.Any()).Times(1).Return(spotifyAuthURL)
+
+	r = e.GET("/").Expect()
+	r.Status(http.StatusTemporaryRedirect)
+	r.Header("Location").Equal(spotifyAuthURL)
+}
+
+func TestRevokedTokenEndsOtherSessions(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEachWithoutTokenStore(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	daoMock.EXPECT().StoreToken(dummyUserID, dummyOAuthToken).Times(1)
+	clientMock.EXPECT().PlayerDevices().Times(1).Return(dummyDevices, nil)
+
+	r := e.GET("/api/activeDevices").Expect()
+	r.Status(http.StatusOK)
+
+	// Access got revoked using another session
+	daoMock.EXPECT().LoadToken(dummyUserID).Times(1).Return(nil, persistence.ErrTokenNotFound)
+
+	r = e.GET("/api/activeDevices").Expect()
+	r.Status(http.StatusForbidden)
+
+	// The session has been cleared, so the user has to log in again
+	authMock.EXPECT().AuthURL(gomock.Any()).Times(1).Return(spotifyAuthURL)
+
+	r = e.GET("/").Expect()
+	r.Status(http.StatusTemporaryRedirect)
+	r.Header("Location").Equal(spotifyAuthURL)
+}
+
+func TestManageAPITokens(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	c := fetchContract(t, e)
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	daoMock.EXPECT().CreateAPIToken(dummyUserID, gomock.Any()).Times(1).DoAndReturn(func(_ string, token *persistence.APIToken) (string, error) {
+		if token.Name != "Smart speaker" || !reflect.DeepEqual(token.Scopes, []persistence.Scope{persistence.ScopeRead, persistence.ScopeRestore}) {
+			t.Fatalf("API token has not been created properly: %+v", token)
+		}
+
+		token.ID = "t1"
+
+		return "cassette_t1_secret", nil
+	})
+
+	r := e.POST("/api/you/apiTokens").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"name": " Smart speaker ", "scopes": []string{"read", "restore"}}).
+		Expect()
+	c.check(r, "POST", "/you/apiTokens")
+	r.Status(http.StatusCreated)
+	r.Header("Location").Equal("/api/you/apiTokens/t1")
+	r.Header("Cache-Control").Equal("no-store")
+	r.JSON().Object().Value("token").String().Equal("cassette_t1_secret")
+
+	r = e.POST("/api/you/apiTokens").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"name": "Admin", "scopes": []string{"admin"}}).
+		Expect()
+	c.check(r, "POST", "/you/apiTokens")
+	expectProblem(r, http.StatusBadRequest, problem.CodeInvalidRequest)
+
+	daoMock.EXPECT().LoadAPITokens(dummyUserID).Times(1).Return([]*persistence.APIToken{{
+		ID:          "t1",
+		UserID:      dummyUserID,
+		Name:        "Smart speaker",
+		Scopes:      []persistence.Scope{persistence.ScopeRead, persistence.ScopeRestore},
+		CreatedAtTs: 1615000000,
+	}}, nil)
+
+	r = e.GET("/api/you/apiTokens").Expect()
+	c.check(r, "GET", "/you/apiTokens")
+	r.Status(http.StatusOK)
+	r.JSON().Array().Element(0).Object().NotContainsKey("token")
+
+	daoMock.EXPECT().DeleteAPIToken(dummyUserID, "t1").Times(1).Return(nil)
+	daoMock.EXPECT().DeleteAPIToken(dummyUserID, "t2").Times(1).Return(persistence.ErrAPITokenNotFound)
+
+	r = e.DELETE("/api/you/apiTokens/t1").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "DELETE", "/you/apiTokens/{apiToken}")
+	r.Status(http.StatusNoContent)
+
+	r = e.DELETE("/api/you/apiTokens/t2").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "DELETE", "/you/apiTokens/{apiToken}")
+	expectProblem(r, http.StatusNotFound, problem.CodeAPITokenNotFound)
+}
+
+func TestSleepTimer(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	c := fetchContract(t, e)
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	daoMock.EXPECT().LoadSleepTimer(dummyUserID).Times(1).Return(nil, persistence.ErrSleepTimerNotFound)
+
+	r := e.GET("/api/sleepTimer").Expect()
+	c.check(r, "GET", "/sleepTimer")
+	expectProblem(r, http.StatusNotFound, problem.CodeSleepTimerNotFound)
+
+	r = e.PUT("/api/sleepTimer").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"mode": "duration", "minutes": 0}).
+		Expect()
+	c.check(r, "PUT", "/sleepTimer")
+	expectProblem(r, http.StatusBadRequest, problem.CodeInvalidRequest)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1")}, 1, nil)
+	daoMock.EXPECT().StoreSleepTimer(gomock.Any()).Times(1).DoAndReturn(func(timer *persistence.SleepTimer) error {
+		remaining := timer.FiresAtTs - time.Now().Unix()
+		if timer.UserID != dummyUserID || timer.Mode != persistence.SleepTimerAfterDuration || timer.Slot != "book 1" || remaining < 29*60 || remaining > 30*60 {
+			t.Fatalf("sleep timer has not been set properly: %+v", timer)
+		}
+
+		return nil
+	})
+
+	r = e.PUT("/api/sleepTimer").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"mode": "duration", "minutes": 30, "slot": "book 1"}).
+		Expect()
+	c.check(r, "PUT", "/sleepTimer")
+	r.Status(http.StatusOK)
+	r.JSON().Object().ValueEqual("mode", "duration").ValueEqual("slot", "book 1")
+
+	// There is no track to wait for
+	clientMock.EXPECT().PlayerState().Times(1).Return(&spotifyAPI.PlayerState{}, nil)
+
+	r = e.PUT("/api/sleepTimer").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"mode": "trackEnd"}).
+		Expect()
+	c.check(r, "PUT", "/sleepTimer")
+	expectProblem(r, http.StatusBadRequest, problem.CodeNothingPlaying)
+
+	gomock.InOrder(
+		daoMock.EXPECT().DeleteSleepTimer(dummyUserID).Times(1).Return(nil),
+		daoMock.EXPECT().DeleteSleepTimer(dummyUserID).Times(1).Return(persistence.ErrSleepTimerNotFound),
+	)
+
+	r = e.DELETE("/api/sleepTimer").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "DELETE", "/sleepTimer")
+	r.Status(http.StatusNoContent)
+
+	r = e.DELETE("/api/sleepTimer").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "DELETE", "/sleepTimer")
+	expectProblem(r, http.StatusNotFound, problem.CodeSleepTimerNotFound)
+}
+
+func TestSchedules(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	c := fetchContract(t, e)
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).AnyTimes().Return([]*persistence.PlayerState{dummyPlayerState("book 1")}, 1, nil)
+	clientMock.EXPECT().PlayerDevices().AnyTimes().Return(dummyDevices, nil)
+
+	schedules := &persistence.UserSchedules{UserID: dummyUserID, Schedules: []*persistence.Schedule{}}
+	daoMock.EXPECT().LoadSchedules(dummyUserID).AnyTimes().Return(schedules, nil)
+
+	r := e.PUT("/api/you/timeZone").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"timeZone": "Mars/Olympus_Mons"}).
+		Expect()
+	c.check(r, "PUT", "/you/timeZone")
+	expectProblem(r, http.StatusBadRequest, problem.CodeInvalidRequest)
+
+	daoMock.EXPECT().SaveSchedules(schedules).Times(1).Return(nil)
+
+	r = e.PUT("/api/you/timeZone").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"timeZone": "Europe/Berlin"}).
+		Expect()
+	c.check(r, "PUT", "/you/timeZone")
+	r.Status(http.StatusOK)
+	r.JSON().Object().ValueEqual("timeZone", "Europe/Berlin")
+
+	for _, body := range []map[string]interface{}{
+		{"slot": "book 1", "deviceID": "001", "cron": "30 7 * *"},
+		{"slot": "book 1", "deviceID": "001", "cron": "0 0 31 2 *"},
+		{"slot": "book 1", "deviceID": "001", "cron": "30 7 * * *", "missedRun": "sometimes"},
+		{"slot": "book 1", "deviceID": "003", "cron": "30 7 * * *"},
+	} {
+		r = e.POST("/api/schedules").WithHeader(constants.CSRFHeaderName, csrfToken).WithJSON(body).Expect()
+		c.check(r, "POST", "/schedules")
+		expectProblem(r, http.StatusBadRequest, problem.CodeInvalidRequest)
+	}
+
+	r = e.POST("/api/schedules").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"slot": "book 2", "deviceID": "001", "cron": "30 7 * * *"}).
+		Expect()
+	c.check(r, "POST", "/schedules")
+	expectProblem(r, http.StatusBadRequest, problem.CodeSlotOutOfRange)
+
+	daoMock.EXPECT().SaveSchedules(schedules).Times(1).DoAndReturn(func(schedules *persistence.UserSchedules) error {
+		schedule := schedules.Schedules[0]
+		if len(schedules.Schedules) != 1 || schedule.Slot != "book 1" || schedule.DeviceName != "Device 1" || schedule.MissedRun != persistence.MissedRunSkip || schedule.NextRunAtTs <= time.Now().Unix() {
+			t.Fatalf("schedule has not been created properly: %+v", schedule)
+		}
+
+		schedule.ID = "s1"
+
+		return nil
+	})
+
+	r = e.POST("/api/schedules").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"name": "Morning", "slot": "book 1", "deviceID": "001", "cron": " 30  7 * * 1-5"}).
+		Expect()
+	c.check(r, "POST", "/schedules")
+	r.Status(http.StatusCreated)
+	r.Header("Location").Equal("/api/schedules/s1")
+	r.JSON().Object().ValueEqual("id", "s1").ValueEqual("cron", "30 7 * * 1-5")
+
+	r = e.GET("/api/schedules").Expect()
+	c.check(r, "GET", "/schedules")
+	r.Status(http.StatusOK)
+	r.JSON().Object().ValueEqual("timeZone", "Europe/Berlin").Value("schedules").Array().Length().Equal(1)
+
+	r = e.GET("/api/schedules/s1").Expect()
+	c.check(r, "GET", "/schedules/{schedule}")
+	r.Status(http.StatusOK)
+	r.JSON().Object().ValueEqual("name", "Morning")
+
+	daoMock.EXPECT().SaveSchedules(schedules).Times(1).Return(persistence.ErrRevisionMismatch)
+
+	r = e.PUT("/api/schedules/s1").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"slot": "book 1", "deviceID": "002", "cron": "0 8 * * *", "missedRun": "catchUp"}).
+		Expect()
+	c.check(r, "PUT", "/schedules/{schedule}")
+	expectProblem(r, http.StatusConflict, problem.CodeModifiedConcurrently)
+
+	daoMock.EXPECT().SaveSchedules(schedules).Times(1).Return(nil)
+
+	r = e.PUT("/api/schedules/s1").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"slot": "book 1", "deviceID": "002", "cron": "0 8 * * *", "missedRun": "catchUp"}).
+		Expect()
+	c.check(r, "PUT", "/schedules/{schedule}")
+	r.Status(http.StatusOK)
+	r.JSON().Object().ValueEqual("deviceName", "Device 2").ValueEqual("missedRun", "catchUp").NotContainsKey("name")
+
+	r = e.GET("/api/schedules/s2").Expect()
+	c.check(r, "GET", "/schedules/{schedule}")
+	expectProblem(r, http.StatusNotFound, problem.CodeScheduleNotFound)
+
+	daoMock.EXPECT().SaveSchedules(schedules).Times(1).Return(nil)
+
+	r = e.DELETE("/api/schedules/s1").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "DELETE", "/schedules/{schedule}")
+	r.Status(http.StatusNoContent)
+
+	if len(schedules.Schedules) != 0 {
+		t.Fatalf("expected schedule to be deleted, got %+v", schedules.Schedules)
+	}
+
+	r = e.GET("/api/you/timeZone").Expect()
+	c.check(r, "GET", "/you/timeZone")
+	r.Status(http.StatusOK)
+	r.JSON().Object().ValueEqual("timeZone", "Europe/Berlin")
+}
+
+func TestPreferences(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	c := fetchContract(t, e)
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	r := e.GET("/api/you/preferences").Expect()
+	c.check(r, "GET", "/you/preferences")
+	r.Status(http.StatusOK)
+	r.JSON().Object().ValueEqual("jumpBackSeconds", persistence.DefaultJumpBackSeconds).ValueEqual("smartJumpBack", false).ValueEqual("restoreDevice", false)
+
+	for _, body := range []map[string]interface{}{
+		{"smartJumpBack": true},
+		{"jumpBackSeconds": -1},
+		{"jumpBackSeconds": constants.MaxJumpBackSeconds + 1},
+	} {
+		r = e.PUT("/api/you/preferences").WithHeader(constants.CSRFHeaderName, csrfToken).WithJSON(body).Expect()
+		c.check(r, "PUT", "/you/preferences")
+		expectProblem(r, http.StatusBadRequest, problem.CodeInvalidRequest)
+	}
+
+	daoMock.EXPECT().StorePreferences(dummyUserID, &persistence.Preferences{JumpBackSeconds: 30, SmartJumpBack: true, RestoreDevice: true}).Times(1)
+
+	r = e.PUT("/api/you/preferences").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"jumpBackSeconds": 30, "smartJumpBack": true, "restoreDevice": true}).
+		Expect()
+	c.check(r, "PUT", "/you/preferences")
+	r.Status(http.StatusOK)
+	r.JSON().Object().ValueEqual("jumpBackSeconds", 30).ValueEqual("smartJumpBack", true).ValueEqual("restoreDevice", true)
+}
+
+func TestHistory(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	c := fetchContract(t, e)
+
+	login(t, e, authMock)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	book := func(progress int) *persistence.PlayerState {
+		state := dummyPlayerState("book 1")
+		state.PlaybackContextURI = "spotify:album:book1"
+		state.ContextType = "album"
+		state.Progress = progress
+		state.ContextDuration = 3600000
+
+		return state
+	}
+
+	suspendedAt := time.Date(2021, 3, 1, 20, 0, 0, 0, time.UTC)
+	history := []*persistence.HistoryEvent{
+		persistence.NewHistoryEvent(persistence.HistorySuspended, book(600000), suspendedAt),
+		persistence.NewHistoryEvent(persistence.HistoryRestored, book(590000), suspendedAt.Add(24*time.Hour)),
+	}
+
+	daoMock.EXPECT().LoadHistory(dummyUserID).Times(2).Return(history, []*persistence.HistoryAggregate{}, nil)
+
+	r := e.GET("/api/history").Expect()
+	c.check(r, "GET", "/history")
+	r.Status(http.StatusOK)
+	events := r.JSON().Array()
+	events.Length().Equal(2)
+	// The latest event comes first
+	events.Element(0).Object().ValueEqual("type", "restored").ValueEqual("slot", "book 1")
+
+	r = e.GET("/api/history/stats").Expect()
+	c.check(r, "GET", "/history/stats")
+	r.Status(http.StatusOK)
+	stats := r.JSON().Array()
+	stats.Length().Equal(1)
+	stats.Element(0).Object().
+		ValueEqual("sessions", 1).
+		ValueEqual("listenedMs", 600000).
+		ValueEqual("lastEventAtTs", suspendedAt.Add(24*time.Hour).Unix()).
+		ContainsKey("estimatedFinishAtTs")
+}
+
+func TestSuspendFinishedSlotGetsArchived(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	c := fetchContract(t, e)
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	expectLastChapterAboutToEnd(clientMock)
+	clientMock.EXPECT().Pause().Times(1)
+
+	var archivedID string
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("other")}, 1, nil)
+	daoMock.EXPECT().ArchiveSlot(dummyUserID, gomock.Any()).Times(1).DoAndReturn(func(_ string, slot *persistence.ArchivedSlot) error {
+		slot.PlayerState.ID = "finished"
+		archivedID = slot.PlayerState.ID
+
+		return nil
+	})
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if len(playerStates) != 1 || playerStates[0].ID != "other" {
+			t.Fatalf("finished slot has not been removed from the player states: %+v", playerStates)
+		}
+
+		return 2, nil
+	})
+
+	r := e.POST("/api/playerStates").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "POST", "/playerStates")
+	r.Status(http.StatusCreated)
+	r.Header("Location").Equal("/api/archive/" + archivedID)
+}
+
+func TestSuspendFinishedSlotConcurrently(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	c := fetchContract(t, e)
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	expectLastChapterAboutToEnd(clientMock)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("other")}, 1, nil)
+	gomock.InOrder(
+		daoMock.EXPECT().ArchiveSlot(dummyUserID, gomock.Any()).Times(1).DoAndReturn(func(_ string, slot *persistence.ArchivedSlot) error {
+			slot.PlayerState.ID = "finished"
+			return nil
+		}),
+		daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).Return(0, persistence.ErrRevisionMismatch),
+		// The slot is kept among the player states, so it must not be archived either
+		daoMock.EXPECT().RemoveArchivedSlot(dummyUserID, "finished").Times(1),
+	)
+
+	r := e.POST("/api/playerStates").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "POST", "/playerStates")
+	expectProblem(r, http.StatusConflict, problem.CodeModifiedConcurrently)
+}
+
+// expectLastChapterAboutToEnd lets Spotify report the end of the last chapter of an audiobook being played
+func expectLastChapterAboutToEnd(clientMock *mocks.MockSpotClient) {
+	chapters := []spotifyAPI.SimpleTrack{
+		{ID: "chapter0", Duration: 600000},
+		{ID: "chapter1", Duration: 600000},
+	}
+
+	chapter := &spotifyAPI.FullTrack{SimpleTrack: chapters[1]}
+	chapter.URI = "spotify:track:chapter1"
+
+	clientMock.EXPECT().PlayerState().Times(1).Return(&spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			PlaybackContext: spotifyAPI.PlaybackContext{
+				URI:  "spotify:album:123",
+				Type: "album",
+			},
+			Progress: 599000,
+			Item:     chapter,
+		},
+	}, nil)
+	clientMock.EXPECT().GetAlbumTracksOpt(spotifyAPI.ID("123"), gomock.Any()).Times(1).DoAndReturn(func(_ spotifyAPI.ID, _ *spotifyAPI.Options) (*spotifyAPI.SimpleTrackPage, error) {
+		page := &spotifyAPI.SimpleTrackPage{Tracks: chapters}
+		page.Total = len(chapters)
+
+		return page, nil
+	})
+}
+
+func TestArchive(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	c := fetchContract(t, e)
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	archive := func() []*persistence.ArchivedSlot {
+		return []*persistence.ArchivedSlot{
+			{PlayerState: dummyPlayerState("book 1"), CompletedAtTs: 1615000000},
+			{PlayerState: dummyPlayerState("book 2"), CompletedAtTs: 1616000000},
+		}
+	}
+
+	daoMock.EXPECT().LoadArchive(dummyUserID).Times(3).Return(archive(), 3, nil)
+
+	r := e.GET("/api/archive").Expect()
+	c.check(r, "GET", "/archive")
+	r.Status(http.StatusOK)
+	archived := r.JSON().Array()
+	archived.Length().Equal(2)
+	// The slot completed most recently comes first
+	archived.Element(0).Object().ValueEqual("completedAtTs", 1616000000).Path("$.playerState.id").Equal("book 2")
+
+	r = e.GET("/api/archive/book 1").Expect()
+	c.check(r, "GET", "/archive/{slot}")
+	r.Status(http.StatusOK)
+	r.JSON().Path("$.playerState.id").Equal("book 1")
+
+	r = e.GET("/api/archive/book 3").Expect()
+	c.check(r, "GET", "/archive/{slot}")
+	expectProblem(r, http.StatusNotFound, problem.CodeArchivedSlotNotFound)
+
+	daoMock.EXPECT().LoadArchive(dummyUserID).Times(1).Return(archive(), 3, nil)
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("other")}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if len(playerStates) != 2 || playerStates[1].ID != "book 1" {
+			t.Fatalf("slot has not been unarchived: %+v", playerStates)
+		}
+
+		return 2, nil
+	})
+	daoMock.EXPECT().SaveArchive(dummyUserID, gomock.Any(), 3).Times(1).DoAndReturn(func(_ string, archive []*persistence.ArchivedSlot, _ int) (int, error) {
+		if len(archive) != 1 || archive[0].PlayerState.ID != "book 2" {
+			t.Fatalf("slot has not been removed from the archive: %+v", archive)
+		}
+
+		return 4, nil
+	})
+
+	r = e.POST("/api/archive/book 1/unarchive").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "POST", "/archive/{slot}/unarchive")
+	r.Status(http.StatusCreated)
+	r.Header("Location").Equal("/api/playerStates/book 1")
+
+	daoMock.EXPECT().LoadArchive(dummyUserID).Times(1).Return(archive(), 3, nil)
+	daoMock.EXPECT().SaveArchive(dummyUserID, gomock.Any(), 3).Times(1).DoAndReturn(func(_ string, archive []*persistence.ArchivedSlot, _ int) (int, error) {
+		if len(archive) != 1 || archive[0].PlayerState.ID != "book 1" {
+			t.Fatalf("archived slot has not been purged: %+v", archive)
+		}
+
+		return 4, nil
+	})
+
+	r = e.DELETE("/api/archive/book 2").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "DELETE", "/archive/{slot}")
+	r.Status(http.StatusNoContent)
+
+	daoMock.EXPECT().LoadArchive(dummyUserID).Times(1).Return(archive(), 3, nil)
+	daoMock.EXPECT().SaveArchive(dummyUserID, []*persistence.ArchivedSlot{}, 3).Times(1).Return(0, persistence.ErrRevisionMismatch)
+
+	r = e.DELETE("/api/archive").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "DELETE", "/archive")
+	expectProblem(r, http.StatusConflict, problem.CodeModifiedConcurrently)
+}
+
+func TestJumpBackOfSlot(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	c := fetchContract(t, e)
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	r := e.PUT("/api/playerStates/music/jumpBack").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{}).
+		Expect()
+	c.check(r, "PUT", "/playerStates/{slot}/jumpBack")
+	expectProblem(r, http.StatusBadRequest, problem.CodeInvalidRequest)
+
+	music := &persistence.PlayerState{
+		ID:                 "music",
+		PlaybackContextURI: "spotify:album:123",
+		PlaybackItemURI:    "spotify:track:1",
+		ContextType:        "album",
+		Progress:           90000,
+	}
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(3).Return([]*persistence.PlayerState{music}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if playerStates[0].JumpBackSeconds == nil || *playerStates[0].JumpBackSeconds != 0 {
+			t.Fatalf("jump-back of slot has not been set: %+v", playerStates[0])
+		}
+
+		return 2, nil
+	})
+
+	r = e.PUT("/api/playerStates/music/jumpBack").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"seconds": 0}).
+		Expect()
+	c.check(r, "PUT", "/playerStates/{slot}/jumpBack")
+	r.Status(http.StatusOK)
+	r.Header("ETag").Equal(`"2"`)
+
+	deviceID := spotifyAPI.ID("002")
+	contextURI := spotifyAPI.URI("spotify:album:123")
+	clientMock.EXPECT().Pause().Times(1)
+	clientMock.EXPECT().Shuffle(false).Times(1)
+	clientMock.EXPECT().PlayOpt(&spotifyAPI.PlayOptions{
+		DeviceID:        &deviceID,
+		PlaybackContext: &contextURI,
+		PlaybackOffset:  &spotifyAPI.PlaybackOffset{URI: "spotify:track:1"},
+		PositionMs:      90000,
+	}).Times(1)
+
+	r = e.POST("/api/playerStates/music/restore").
+		WithQuery("deviceID", deviceID).
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		Expect()
+	r.Status(http.StatusOK)
+
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if playerStates[0].JumpBackSeconds != nil {
+			t.Fatalf("jump-back of slot has not been dropped: %+v", playerStates[0])
+		}
+
+		return 2, nil
+	})
+
+	r = e.DELETE("/api/playerStates/music/jumpBack").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "DELETE", "/playerStates/{slot}/jumpBack")
+	r.Status(http.StatusOK)
+}
+
+func TestAPITokenAuthentication(t *testing.T) {
+	e, ctrl, daoMock, _, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	c := fetchContract(t, e)
+
+	const readToken = "cassette_t1_secret"
+	const restoreToken = "cassette_t2_secret"
+
+	daoMock.EXPECT().LookupAPIToken(readToken).AnyTimes().Return(&persistence.APIToken{
+		ID:     "t1",
+		UserID: dummyUserID,
+		Scopes: []persistence.Scope{persistence.ScopeRead},
+	}, nil)
+	daoMock.EXPECT().LookupAPIToken(restoreToken).AnyTimes().Return(&persistence.APIToken{
+		ID:     "t2",
+		UserID: dummyUserID,
+		Scopes: []persistence.Scope{persistence.ScopeRestore},
+	}, nil)
+	daoMock.EXPECT().LookupAPIToken("cassette_t3_revoked").Times(1).Return(nil, persistence.ErrAPITokenNotFound)
+
+	// No session is needed, the user is identified by the token
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1")}, 1, nil)
+
+	r := e.GET("/api/playerStates").WithHeader("Authorization", "Bearer "+readToken).Expect()
+	c.check(r, "GET", "/playerStates")
+	r.Status(http.StatusOK)
+	r.JSON().Array().Length().Equal(1)
+
+	r = e.GET("/api/playerStates").WithHeader("Authorization", "Bearer cassette_t3_revoked").Expect()
+	c.check(r, "GET", "/playerStates")
+	expectProblem(r, http.StatusUnauthorized, problem.CodeInvalidAPIToken)
+	r.Header("WWW-Authenticate").Equal(`Bearer error="invalid_token"`)
+
+	// The CSRF token is not required, but the scope is
+	r = e.POST("/api/playerStates/book 1/restore").WithHeader("Authorization", "Bearer "+readToken).Expect()
+	c.check(r, "POST", "/playerStates/{slot}/restore")
+	expectProblem(r, http.StatusForbidden, problem.CodeInsufficientScope)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1")}, 1, nil)
+	clientMock.EXPECT().Pause().Times(1)
+	clientMock.EXPECT().Shuffle(false).Times(1).Return(&spotify.RateLimitedError{RetryAfter: time.Minute})
+
+	r = e.POST("/api/playerStates/book 1/restore").WithHeader("Authorization", "Bearer "+restoreToken).Expect()
+	c.check(r, "POST", "/playerStates/{slot}/restore")
+	expectProblem(r, http.StatusServiceUnavailable, problem.CodeSpotifyUnavailable)
+
+	// Deleting slots and managing the account is only possible using the web app
+	r = e.DELETE("/api/playerStates/book 1").WithHeader("Authorization", "Bearer "+restoreToken).Expect()
+	expectProblem(r, http.StatusForbidden, problem.CodeInsufficientScope)
+
+	r = e.DELETE("/api/you").WithHeader("Authorization", "Bearer "+readToken).Expect()
+	expectProblem(r, http.StatusForbidden, problem.CodeInsufficientScope)
+
+	// Exporting the data is only reading
+	daoMock.EXPECT().FetchJSONDump(dummyUserID).Times(1).Return([]byte(`{"version":4,"revision":1,"_id":"hashed","playerStates":[]}`), nil)
+
+	r = e.GET("/api/you").WithHeader("Authorization", "Bearer "+readToken).Expect()
+	c.check(r, "GET", "/you")
+	r.Status(http.StatusOK)
+
+	r = e.POST("/api/you/apiTokens").WithHeader("Authorization", "Bearer "+readToken).Expect()
+	expectProblem(r, http.StatusForbidden, problem.CodeInsufficientScope)
+}
+
+func TestCreateBookmarkAtCurrentPosition(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	clientMock.EXPECT().PlayerState().Times(2).Return(&spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			Progress: 1337,
+			Item:     dummyTrack,
+		},
+	}, nil)
+
+	slot := &persistence.PlayerState{
+		ID:              "single",
+		PlaybackItemURI: string(dummyTrack.URI),
+		ContextType:     "track",
+		Bookmarks:       []*persistence.Bookmark{{ID: "first", Name: "first"}},
+	}
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1"), slot}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		bookmarks := playerStates[1].Bookmarks
+		if len(bookmarks) != 2 || bookmarks[0].ID != "first" {
+			t.Fatalf("expected bookmark to be added, got %+v", bookmarks)
+		}
+
+		bookmark := bookmarks[1]
+		if bookmark.Name != "Partner stopped here" ||
+			bookmark.Note != "" ||
+			bookmark.TrackURI != string(dummyTrack.URI) ||
+			bookmark.TrackName != dummyTrack.Name ||
+			bookmark.Progress != 1337 {
+			t.Fatalf("bookmark has not been created properly: %+v", bookmark)
+		}
+
+		bookmark.ID = "second"
+
+		return 2, nil
+	})
+
+	r := e.POST("/api/playerStates/single/bookmarks").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]string{"name": " Partner stopped here "}).
+		Expect()
+	r.Status(http.StatusCreated)
+	r.Header("Location").Equal("/api/playerStates/single/bookmarks/second")
+	r.Header("ETag").Equal(`"2"`)
+
+	// Bookmarks can only be created for the context of the slot
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1"), slot}, 2, nil)
+
+	r = e.POST("/api/playerStates/book 1/bookmarks").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]string{"name": "wrong book"}).
+		Expect()
+	r.Status(http.StatusBadRequest)
+}
+
+func TestCreateBookmarkAtGivenPosition(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+	clientMock.EXPECT().PlayerState().Times(0)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1")}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		bookmarks := playerStates[0].Bookmarks
+		if len(bookmarks) != 1 {
+			t.Fatalf("expected bookmark to be added, got %+v", bookmarks)
+		}
+
+		if bookmark := bookmarks[0]; bookmark.Name != "Favourite passage" || bookmark.Note != "The one with the dragon" || bookmark.TrackURI != "spotify:track:42" || bookmark.Progress != 60000 {
+			t.Fatalf("bookmark has not been created properly: %+v", bookmark)
+		}
+
+		return 2, nil
+	})
+
+	r := e.POST("/api/playerStates/book 1/bookmarks").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{
+			"name":     "Favourite passage",
+			"note":     "The one with the dragon",
+			"trackURI": "spotify:track:42",
+			"progress": 60000,
+		}).
+		Expect()
+	r.Status(http.StatusCreated)
+
+	r = e.POST("/api/playerStates/book 1/bookmarks").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]interface{}{"trackURI": "spotify:album:42"}).
+		Expect()
+	r.Status(http.StatusBadRequest)
+}
+
+func TestListUpdateAndDeleteBookmarks(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	withBookmarks := func() []*persistence.PlayerState {
+		playerState := dummyPlayerState("book 1")
+		playerState.Bookmarks = []*persistence.Bookmark{{ID: "b1", Name: "one"}, {ID: "b2", Name: "two"}}
+
+		return []*persistence.PlayerState{playerState}
+	}
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return(withBookmarks(), 1, nil)
+
+	r := e.GET("/api/playerStates/book 1/bookmarks").Expect()
+	r.Status(http.StatusOK)
+	a := r.JSON().Array()
+	a.Length().Equal(2)
+	a.Element(1).Object().Value("name").String().Equal("two")
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return(withBookmarks(), 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if bookmark := playerStates[0].Bookmarks[1]; bookmark.Name != "renamed" || bookmark.Note != "with a note" {
+			t.Fatalf("bookmark has not been updated: %+v", bookmark)
+		}
+
+		return 2, nil
+	})
+
+	r = e.PUT("/api/playerStates/book 1/bookmarks/b2").
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		WithJSON(map[string]string{"name": "renamed", "note": "with a note"}).
+		Expect()
+	r.Status(http.StatusOK)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return(withBookmarks(), 2, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 2).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if bookmarks := playerStates[0].Bookmarks; len(bookmarks) != 1 || bookmarks[0].ID != "b2" {
+			t.Fatalf("bookmark has not been deleted: %+v", bookmarks)
+		}
+
+		return 3, nil
+	})
+
+	r = e.DELETE("/api/playerStates/book 1/bookmarks/b1").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusOK)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return(withBookmarks(), 3, nil)
+
+	r = e.DELETE("/api/playerStates/book 1/bookmarks/unknown").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	r.Status(http.StatusNotFound)
+}
+
+func TestRestoreBookmark(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{{
+		ID:                 "book",
+		PlaybackContextURI: "spotify:album:123",
+		PlaybackItemURI:    "spotify:track:1",
+		ContextType:        "album",
+		Progress:           90000,
+		Bookmarks: []*persistence.Bookmark{{
+			ID:       "passage",
+			TrackURI: "spotify:track:2",
+			Progress: 30000,
+		}},
+	}}, 1, nil)
+
+	deviceID := spotifyAPI.ID("002")
+	contextURI := spotifyAPI.URI("spotify:album:123")
+	clientMock.EXPECT().Pause().Times(1)
+	clientMock.EXPECT().Shuffle(false).Times(1)
+	clientMock.EXPECT().PlayOpt(&spotifyAPI.PlayOptions{
+		DeviceID:        &deviceID,
+		PlaybackContext: &contextURI,
+		PlaybackOffset:  &spotifyAPI.PlaybackOffset{URI: "spotify:track:2"},
+		PositionMs:      30000 - persistence.DefaultJumpBackSeconds*1e3,
+	}).Times(1)
+
+	r := e.POST("/api/playerStates/book/bookmarks/passage/restore").
+		WithQuery("deviceID", deviceID).
+		WithHeader(constants.CSRFHeaderName, csrfToken).
+		Expect()
+	r.Status(http.StatusOK)
+}
+
+func TestExportUserData(t *testing.T) {
+	// TODO: implement!
+}
+
+func TestTrash(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	c := fetchContract(t, e)
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	trash := func() []*persistence.TrashedSlot {
+		return []*persistence.TrashedSlot{
+			{PlayerState: dummyPlayerState("book 1"), DeletedAtTs: 1615000000, PurgeAtTs: 1617592000},
+			{PlayerState: dummyPlayerState("book 2"), DeletedAtTs: 1616000000, PurgeAtTs: 1618592000},
+		}
+	}
+
+	daoMock.EXPECT().LoadTrash(dummyUserID).Times(3).Return(trash(), 3, nil)
+
+	r := e.GET("/api/trash").Expect()
+	c.check(r, "GET", "/trash")
+	r.Status(http.StatusOK)
+	trashed := r.JSON().Array()
+	trashed.Length().Equal(2)
+	// The slot deleted most recently comes first
+	trashed.Element(0).Object().ValueEqual("purgeAtTs", 1618592000).Path("$.playerState.id").Equal("book 2")
+
+	r = e.GET("/api/trash/book 1").Expect()
+	c.check(r, "GET", "/trash/{slot}")
+	r.Status(http.StatusOK)
+	r.JSON().Path("$.playerState.id").Equal("book 1")
+
+	r = e.GET("/api/trash/book 3").Expect()
+	c.check(r, "GET", "/trash/{slot}")
+	expectProblem(r, http.StatusNotFound, problem.CodeTrashedSlotNotFound)
+
+	daoMock.EXPECT().LoadTrash(dummyUserID).Times(1).Return(trash(), 3, nil)
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("other")}, 1, nil)
+	daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+		if len(playerStates) != 2 || playerStates[1].ID != "book 1" {
+			t.Fatalf("slot has not been undeleted: %+v", playerStates)
+		}
+
+		return 2, nil
+	})
+	daoMock.EXPECT().SaveTrash(dummyUserID, gomock.Any(), 3).Times(1).DoAndReturn(func(_ string, trash []*persistence.TrashedSlot, _ int) (int, error) {
+		if len(trash) != 1 || trash[0].PlayerState.ID != "book 2" {
+			t.Fatalf("slot has not been removed from the trash: %+v", trash)
+		}
+
+		return 4, nil
+	})
+
+	r = e.POST("/api/trash/book 1/undelete").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "POST", "/trash/{slot}/undelete")
+	r.Status(http.StatusCreated)
+	r.Header("Location").Equal("/api/playerStates/book 1")
+
+	daoMock.EXPECT().LoadTrash(dummyUserID).Times(1).Return(trash(), 3, nil)
+	daoMock.EXPECT().SaveTrash(dummyUserID, gomock.Any(), 3).Times(1).DoAndReturn(func(_ string, trash []*persistence.TrashedSlot, _ int) (int, error) {
+		if len(trash) != 1 || trash[0].PlayerState.ID != "book 1" {
+			t.Fatalf("slot has not been purged: %+v", trash)
+		}
+
+		return 4, nil
+	})
+
+	r = e.DELETE("/api/trash/book 2").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "DELETE", "/trash/{slot}")
+	r.Status(http.StatusNoContent)
+
+	daoMock.EXPECT().LoadTrash(dummyUserID).Times(1).Return(trash(), 3, nil)
+	daoMock.EXPECT().SaveTrash(dummyUserID, []*persistence.TrashedSlot{}, 3).Times(1).Return(4, nil)
+
+	r = e.DELETE("/api/trash").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "DELETE", "/trash")
+	r.Status(http.StatusNoContent)
+}
+
+func TestDeleteUserData(t *testing.T) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	c := fetchContract(t, e)
+
+	login(t, e, authMock)
+	csrfToken := fetchCSRFToken(e)
+
+	clientMock.EXPECT().CurrentUser().Times(1).Return(dummyUser, nil)
+
+	var scheduled *persistence.AccountDeletion
+	gomock.InOrder(
+		daoMock.EXPECT().LoadAccountDeletion(dummyUserID).Times(1).Return(nil, persistence.ErrAccountDeletionNotFound),
+		daoMock.EXPECT().ScheduleAccountDeletion(gomock.Any()).Times(1).DoAndReturn(func(deletion *persistence.AccountDeletion) error {
+			if deletion.UserID != dummyUserID || deletion.DeleteAtTs-deletion.RequestedAtTs != int64((7*24*time.Hour).Seconds()) {
+				t.Fatalf("deletion has not been scheduled properly: %+v", deletion)
+			}
+
+			scheduled = deletion
+
+			return nil
+		}),
+	)
+
+	// Nothing gets deleted before the grace period is over
+	daoMock.EXPECT().DeleteUserRecord(gomock.Any()).Times(0)
+
+	r := e.DELETE("/api/you").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "DELETE", "/you")
+	r.Status(http.StatusAccepted)
+	r.JSON().Object().ValueEqual("deleteAtTs", scheduled.DeleteAtTs)
+
+	// Requesting the deletion again does not postpone it
+	daoMock.EXPECT().LoadAccountDeletion(dummyUserID).Times(2).Return(scheduled, nil)
+
+	r = e.DELETE("/api/you").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "DELETE", "/you")
+	r.Status(http.StatusAccepted)
+	r.JSON().Object().ValueEqual("deleteAtTs", scheduled.DeleteAtTs)
+
+	r = e.GET("/api/you/deletion").Expect()
+	c.check(r, "GET", "/you/deletion")
+	r.Status(http.StatusOK)
+	r.JSON().Object().ValueEqual("requestedAtTs", scheduled.RequestedAtTs)
+
+	gomock.InOrder(
+		daoMock.EXPECT().CancelAccountDeletion(dummyUserID).Times(1).Return(nil),
+		daoMock.EXPECT().CancelAccountDeletion(dummyUserID).Times(1).Return(persistence.ErrAccountDeletionNotFound),
+	)
+
+	r = e.DELETE("/api/you/deletion").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "DELETE", "/you/deletion")
+	r.Status(http.StatusNoContent)
+
+	r = e.DELETE("/api/you/deletion").WithHeader(constants.CSRFHeaderName, csrfToken).Expect()
+	c.check(r, "DELETE", "/you/deletion")
+	expectProblem(r, http.StatusNotFound, problem.CodeDeletionNotFound)
+}
+
+func expectProblem(r *httpexpect.Response, status int, code problem.Code) *httpexpect.Object {
+	r.Status(status)
+
+	o := r.JSON(httpexpect.ContentOpts{MediaType: problem.ContentType}).Object()
+	o.Value("status").Number().Equal(status)
+	o.Value("code").String().Equal(string(code))
+
+	return o
+}
+
+func fetchCSRFToken(e *httpexpect.Expect) string {
+	r := e.HEAD("/api/csrfToken").Expect()
+	r.Status(http.StatusOK)
+
+	return r.Header(constants.CSRFHeaderName).NotEmpty().Raw()
+}
+
+func beforeEach(t *testing.T) (*httpexpect.Expect, *gomock.Controller, *mocks.MockPersistor, *mocks.MockSpotAuthenticator, *mocks.MockSpotClient) {
+	e, ctrl, daoMock, authMock, clientMock := beforeEachWithoutTokenStore(t)
+
+	// The token gets moved from the session to the token store with the first request to the API
+	daoMock.EXPECT().StoreToken(dummyUserID, dummyOAuthToken).AnyTimes()
+	daoMock.EXPECT().LoadToken(dummyUserID).AnyTimes().Return(dummyOAuthToken, nil)
+	// Restoring slots applies the preferences of the user
+	daoMock.EXPECT().LoadPreferences(dummyUserID).AnyTimes().Return(&persistence.Preferences{JumpBackSeconds: persistence.DefaultJumpBackSeconds}, nil)
+	// Suspending and restoring slots gets recorded
+	daoMock.EXPECT().AppendHistoryEvent(dummyUserID, gomock.Any()).AnyTimes()
+
+	return e, ctrl, daoMock, authMock, clientMock
 }
 
-func beforeEach(t *testing.T) (*httpexpect.Expect, *gomock.Controller, *mocks.MockPlayerStatesPersistor, *mocks.MockSpotAuthenticator, *mocks.MockSpotClient) {
+func beforeEachWithoutTokenStore(t *testing.T) (*httpexpect.Expect, *gomock.Controller, *mocks.MockPersistor, *mocks.MockSpotAuthenticator, *mocks.MockSpotClient) {
 	ctrl := gomock.NewController(t)
 
-	daoMock := mocks.NewMockPlayerStatesPersistor(ctrl)
+	daoMock := mocks.NewMockPersistor(ctrl)
 	authMock := mocks.NewMockSpotAuthenticator(ctrl)
 	clientMock := mocks.NewMockSpotClient(ctrl)
-	spotClientMockCreator := func(token *oauth2.Token) spotify.SpotClient {
+	spotClientMockCreator := func(_ string, token *oauth2.Token) spotify.SpotClient {
 		// Just for completeness and to check that the token is what we expect it to be
 		// Can get called quite often, requests to almost any route cause a spotClient to be attached
 		authMock.EXPECT().NewClient(dummyOAuthToken).AnyTimes()
@@ -253,6 +2344,7 @@ func newPointerMatcher(ptr *string) *pointerMatcher {
 
 func dummyPlayerState(albumName string) *persistence.PlayerState {
 	return &persistence.PlayerState{
+		ID:        albumName,
 		AlbumName: albumName,
 	}
 }
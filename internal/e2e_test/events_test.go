@@ -0,0 +1,136 @@
+package e2e_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/golang/mock/gomock"
+	"golang.org/x/oauth2"
+
+	main "github.com/florianloch/cassette/internal"
+	"github.com/florianloch/cassette/internal/e2e_test/mocks"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/spotify"
+)
+
+type streamedEvent struct {
+	name string
+	data map[string]interface{}
+}
+
+// openEventStream connects to the event stream, the events received are passed to the channel returned
+func openEventStream(t *testing.T, serverURL, apiToken string) (<-chan streamedEvent, func()) {
+	req, err := http.NewRequest(http.MethodGet, serverURL+"/api/events", nil)
+	if err != nil {
+		t.Fatalf("Could not create request: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+apiToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Could not connect to event stream: %s", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Unexpected response when connecting to event stream: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	streamed := make(chan streamedEvent)
+
+	go func() {
+		defer close(streamed)
+
+		var event streamedEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.data)
+			case line == "" && event.name != "":
+				streamed <- event
+				event = streamedEvent{}
+			}
+		}
+	}()
+
+	return streamed, func() {
+		resp.Body.Close()
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	daoMock := mocks.NewMockPersistor(ctrl)
+	clientMock := mocks.NewMockSpotClient(ctrl)
+
+	webRoot, err := filepath.Abs("../../")
+	if err != nil {
+		t.Fatalf("Could not get path of web root: %s", err)
+	}
+
+	// Events are streamed, this does not work with httpexpect's binder
+	server := httptest.NewServer(main.SetupForTest(daoMock, mocks.NewMockSpotAuthenticator(ctrl), func(_ string, _ *oauth2.Token) spotify.SpotClient {
+		return clientMock
+	}, webRoot))
+	defer server.Close()
+
+	e := httpexpect.New(t, server.URL)
+	c := fetchContract(t, e)
+
+	const apiToken = "cassette_t1_secret"
+
+	daoMock.EXPECT().LookupAPIToken(apiToken).AnyTimes().Return(&persistence.APIToken{
+		ID:     "t1",
+		UserID: dummyUserID,
+		Scopes: []persistence.Scope{persistence.ScopeRead, persistence.ScopeRestore},
+	}, nil)
+	daoMock.EXPECT().LoadToken(dummyUserID).AnyTimes().Return(dummyOAuthToken, nil)
+
+	streamed, disconnect := openEventStream(t, server.URL, apiToken)
+	defer disconnect()
+
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState("book 1")}, 1, nil)
+	daoMock.EXPECT().LoadPreferences(dummyUserID).Times(1).Return(&persistence.Preferences{}, nil)
+	daoMock.EXPECT().AppendHistoryEvent(dummyUserID, gomock.Any()).Times(1).DoAndReturn(func(_ string, event *persistence.HistoryEvent) error {
+		if event.Type != persistence.HistoryRestored || event.Slot != "book 1" || event.PlayerState.AlbumName != "book 1" {
+			t.Fatalf("expected restore to be recorded, got %+v", event)
+		}
+
+		return nil
+	})
+	clientMock.EXPECT().Pause().Times(1)
+	clientMock.EXPECT().Shuffle(false).Times(1)
+	clientMock.EXPECT().PlayOpt(gomock.Any()).Times(1)
+
+	r := e.POST("/api/playerStates/book 1/restore").
+		WithQuery("deviceID", "001").
+		WithHeader("Authorization", "Bearer "+apiToken).
+		Expect()
+	r.Status(http.StatusOK)
+
+	select {
+	case event := <-streamed:
+		if event.name != "playback-restored" {
+			t.Fatalf("Unexpected event: %+v", event)
+		}
+
+		schema, _ := c.lookup("components", "schemas", "Event")
+		e.Value(event.data).Schema(c.schema(schema))
+		e.Value(event.data).Object().ValueEqual("type", "playback-restored").ValueEqual("slot", "book 1")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Event has not been streamed")
+	}
+}
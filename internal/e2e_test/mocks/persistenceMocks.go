@@ -7,7 +7,9 @@ package mocks
 import (
 	persistence "github.com/florianloch/cassette/internal/persistence"
 	gomock "github.com/golang/mock/gomock"
+	oauth2 "golang.org/x/oauth2"
 	reflect "reflect"
+	time "time"
 )
 
 // MockPlayerStatesPersistor is a mock of PlayerStatesPersistor interface
@@ -34,12 +36,13 @@ func (m *MockPlayerStatesPersistor) EXPECT() *MockPlayerStatesPersistorMockRecor
 }
 
 // LoadPlayerStates mocks base method
-func (m *MockPlayerStatesPersistor) LoadPlayerStates(userID string) ([]*persistence.PlayerState, error) {
+func (m *MockPlayerStatesPersistor) LoadPlayerStates(userID string) ([]*persistence.PlayerState, int, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "LoadPlayerStates", userID)
 	ret0, _ := ret[0].([]*persistence.PlayerState)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // LoadPlayerStates indicates an expected call of LoadPlayerStates
@@ -49,17 +52,18 @@ func (mr *MockPlayerStatesPersistorMockRecorder) LoadPlayerStates(userID interfa
 }
 
 // SavePlayerStates mocks base method
-func (m *MockPlayerStatesPersistor) SavePlayerStates(userID string, playerStates []*persistence.PlayerState) error {
+func (m *MockPlayerStatesPersistor) SavePlayerStates(userID string, playerStates []*persistence.PlayerState, revision int) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SavePlayerStates", userID, playerStates)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "SavePlayerStates", userID, playerStates, revision)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // SavePlayerStates indicates an expected call of SavePlayerStates
-func (mr *MockPlayerStatesPersistorMockRecorder) SavePlayerStates(userID, playerStates interface{}) *gomock.Call {
+func (mr *MockPlayerStatesPersistorMockRecorder) SavePlayerStates(userID, playerStates, revision interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SavePlayerStates", reflect.TypeOf((*MockPlayerStatesPersistor)(nil).SavePlayerStates), userID, playerStates)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SavePlayerStates", reflect.TypeOf((*MockPlayerStatesPersistor)(nil).SavePlayerStates), userID, playerStates, revision)
 }
 
 // FetchJSONDump mocks base method
@@ -90,3 +94,1449 @@ func (mr *MockPlayerStatesPersistorMockRecorder) DeleteUserRecord(userID interfa
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserRecord", reflect.TypeOf((*MockPlayerStatesPersistor)(nil).DeleteUserRecord), userID)
 }
+
+// MockAutoSavePersistor is a mock of AutoSavePersistor interface
+type MockAutoSavePersistor struct {
+	ctrl     *gomock.Controller
+	recorder *MockAutoSavePersistorMockRecorder
+}
+
+// MockAutoSavePersistorMockRecorder is the mock recorder for MockAutoSavePersistor
+type MockAutoSavePersistorMockRecorder struct {
+	mock *MockAutoSavePersistor
+}
+
+// NewMockAutoSavePersistor creates a new mock instance
+func NewMockAutoSavePersistor(ctrl *gomock.Controller) *MockAutoSavePersistor {
+	mock := &MockAutoSavePersistor{ctrl: ctrl}
+	mock.recorder = &MockAutoSavePersistorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAutoSavePersistor) EXPECT() *MockAutoSavePersistorMockRecorder {
+	return m.recorder
+}
+
+// EnableAutoSave mocks base method
+func (m *MockAutoSavePersistor) EnableAutoSave(userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableAutoSave", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnableAutoSave indicates an expected call of EnableAutoSave
+func (mr *MockAutoSavePersistorMockRecorder) EnableAutoSave(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableAutoSave", reflect.TypeOf((*MockAutoSavePersistor)(nil).EnableAutoSave), userID)
+}
+
+// DisableAutoSave mocks base method
+func (m *MockAutoSavePersistor) DisableAutoSave(userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableAutoSave", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableAutoSave indicates an expected call of DisableAutoSave
+func (mr *MockAutoSavePersistorMockRecorder) DisableAutoSave(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableAutoSave", reflect.TypeOf((*MockAutoSavePersistor)(nil).DisableAutoSave), userID)
+}
+
+// AutoSaveEnabled mocks base method
+func (m *MockAutoSavePersistor) AutoSaveEnabled(userID string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AutoSaveEnabled", userID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AutoSaveEnabled indicates an expected call of AutoSaveEnabled
+func (mr *MockAutoSavePersistorMockRecorder) AutoSaveEnabled(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AutoSaveEnabled", reflect.TypeOf((*MockAutoSavePersistor)(nil).AutoSaveEnabled), userID)
+}
+
+// LoadAutoSaveUsers mocks base method
+func (m *MockAutoSavePersistor) LoadAutoSaveUsers() ([]*persistence.AutoSaveUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadAutoSaveUsers")
+	ret0, _ := ret[0].([]*persistence.AutoSaveUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadAutoSaveUsers indicates an expected call of LoadAutoSaveUsers
+func (mr *MockAutoSavePersistorMockRecorder) LoadAutoSaveUsers() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadAutoSaveUsers", reflect.TypeOf((*MockAutoSavePersistor)(nil).LoadAutoSaveUsers))
+}
+
+// MockTokenStore is a mock of TokenStore interface
+type MockTokenStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockTokenStoreMockRecorder
+}
+
+// MockTokenStoreMockRecorder is the mock recorder for MockTokenStore
+type MockTokenStoreMockRecorder struct {
+	mock *MockTokenStore
+}
+
+// NewMockTokenStore creates a new mock instance
+func NewMockTokenStore(ctrl *gomock.Controller) *MockTokenStore {
+	mock := &MockTokenStore{ctrl: ctrl}
+	mock.recorder = &MockTokenStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockTokenStore) EXPECT() *MockTokenStoreMockRecorder {
+	return m.recorder
+}
+
+// LoadToken mocks base method
+func (m *MockTokenStore) LoadToken(userID string) (*oauth2.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadToken", userID)
+	ret0, _ := ret[0].(*oauth2.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadToken indicates an expected call of LoadToken
+func (mr *MockTokenStoreMockRecorder) LoadToken(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadToken", reflect.TypeOf((*MockTokenStore)(nil).LoadToken), userID)
+}
+
+// StoreToken mocks base method
+func (m *MockTokenStore) StoreToken(userID string, token *oauth2.Token) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreToken", userID, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreToken indicates an expected call of StoreToken
+func (mr *MockTokenStoreMockRecorder) StoreToken(userID, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreToken", reflect.TypeOf((*MockTokenStore)(nil).StoreToken), userID, token)
+}
+
+// DeleteToken mocks base method
+func (m *MockTokenStore) DeleteToken(userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteToken", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteToken indicates an expected call of DeleteToken
+func (mr *MockTokenStoreMockRecorder) DeleteToken(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteToken", reflect.TypeOf((*MockTokenStore)(nil).DeleteToken), userID)
+}
+
+// MockTrackListingPersistor is a mock of TrackListingPersistor interface
+type MockTrackListingPersistor struct {
+	ctrl     *gomock.Controller
+	recorder *MockTrackListingPersistorMockRecorder
+}
+
+// MockTrackListingPersistorMockRecorder is the mock recorder for MockTrackListingPersistor
+type MockTrackListingPersistorMockRecorder struct {
+	mock *MockTrackListingPersistor
+}
+
+// NewMockTrackListingPersistor creates a new mock instance
+func NewMockTrackListingPersistor(ctrl *gomock.Controller) *MockTrackListingPersistor {
+	mock := &MockTrackListingPersistor{ctrl: ctrl}
+	mock.recorder = &MockTrackListingPersistorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockTrackListingPersistor) EXPECT() *MockTrackListingPersistorMockRecorder {
+	return m.recorder
+}
+
+// LoadTrackListing mocks base method
+func (m *MockTrackListingPersistor) LoadTrackListing(key string) (*persistence.TrackListing, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadTrackListing", key)
+	ret0, _ := ret[0].(*persistence.TrackListing)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadTrackListing indicates an expected call of LoadTrackListing
+func (mr *MockTrackListingPersistorMockRecorder) LoadTrackListing(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadTrackListing", reflect.TypeOf((*MockTrackListingPersistor)(nil).LoadTrackListing), key)
+}
+
+// StoreTrackListing mocks base method
+func (m *MockTrackListingPersistor) StoreTrackListing(key string, listing *persistence.TrackListing) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreTrackListing", key, listing)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreTrackListing indicates an expected call of StoreTrackListing
+func (mr *MockTrackListingPersistorMockRecorder) StoreTrackListing(key, listing interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreTrackListing", reflect.TypeOf((*MockTrackListingPersistor)(nil).StoreTrackListing), key, listing)
+}
+
+// PruneTrackListings mocks base method
+func (m *MockTrackListingPersistor) PruneTrackListings(cachedBeforeTs int64, maxEntries int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PruneTrackListings", cachedBeforeTs, maxEntries)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PruneTrackListings indicates an expected call of PruneTrackListings
+func (mr *MockTrackListingPersistorMockRecorder) PruneTrackListings(cachedBeforeTs, maxEntries interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PruneTrackListings", reflect.TypeOf((*MockTrackListingPersistor)(nil).PruneTrackListings), cachedBeforeTs, maxEntries)
+}
+
+// MockAPITokenStore is a mock of APITokenStore interface
+type MockAPITokenStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPITokenStoreMockRecorder
+}
+
+// MockAPITokenStoreMockRecorder is the mock recorder for MockAPITokenStore
+type MockAPITokenStoreMockRecorder struct {
+	mock *MockAPITokenStore
+}
+
+// NewMockAPITokenStore creates a new mock instance
+func NewMockAPITokenStore(ctrl *gomock.Controller) *MockAPITokenStore {
+	mock := &MockAPITokenStore{ctrl: ctrl}
+	mock.recorder = &MockAPITokenStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAPITokenStore) EXPECT() *MockAPITokenStoreMockRecorder {
+	return m.recorder
+}
+
+// CreateAPIToken mocks base method
+func (m *MockAPITokenStore) CreateAPIToken(userID string, token *persistence.APIToken) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIToken", userID, token)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAPIToken indicates an expected call of CreateAPIToken
+func (mr *MockAPITokenStoreMockRecorder) CreateAPIToken(userID, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIToken", reflect.TypeOf((*MockAPITokenStore)(nil).CreateAPIToken), userID, token)
+}
+
+// LoadAPITokens mocks base method
+func (m *MockAPITokenStore) LoadAPITokens(userID string) ([]*persistence.APIToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadAPITokens", userID)
+	ret0, _ := ret[0].([]*persistence.APIToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadAPITokens indicates an expected call of LoadAPITokens
+func (mr *MockAPITokenStoreMockRecorder) LoadAPITokens(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadAPITokens", reflect.TypeOf((*MockAPITokenStore)(nil).LoadAPITokens), userID)
+}
+
+// LookupAPIToken mocks base method
+func (m *MockAPITokenStore) LookupAPIToken(token string) (*persistence.APIToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LookupAPIToken", token)
+	ret0, _ := ret[0].(*persistence.APIToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LookupAPIToken indicates an expected call of LookupAPIToken
+func (mr *MockAPITokenStoreMockRecorder) LookupAPIToken(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LookupAPIToken", reflect.TypeOf((*MockAPITokenStore)(nil).LookupAPIToken), token)
+}
+
+// DeleteAPIToken mocks base method
+func (m *MockAPITokenStore) DeleteAPIToken(userID, tokenID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAPIToken", userID, tokenID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAPIToken indicates an expected call of DeleteAPIToken
+func (mr *MockAPITokenStoreMockRecorder) DeleteAPIToken(userID, tokenID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAPIToken", reflect.TypeOf((*MockAPITokenStore)(nil).DeleteAPIToken), userID, tokenID)
+}
+
+// MockSleepTimerStore is a mock of SleepTimerStore interface
+type MockSleepTimerStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockSleepTimerStoreMockRecorder
+}
+
+// MockSleepTimerStoreMockRecorder is the mock recorder for MockSleepTimerStore
+type MockSleepTimerStoreMockRecorder struct {
+	mock *MockSleepTimerStore
+}
+
+// NewMockSleepTimerStore creates a new mock instance
+func NewMockSleepTimerStore(ctrl *gomock.Controller) *MockSleepTimerStore {
+	mock := &MockSleepTimerStore{ctrl: ctrl}
+	mock.recorder = &MockSleepTimerStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockSleepTimerStore) EXPECT() *MockSleepTimerStoreMockRecorder {
+	return m.recorder
+}
+
+// StoreSleepTimer mocks base method
+func (m *MockSleepTimerStore) StoreSleepTimer(timer *persistence.SleepTimer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreSleepTimer", timer)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreSleepTimer indicates an expected call of StoreSleepTimer
+func (mr *MockSleepTimerStoreMockRecorder) StoreSleepTimer(timer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreSleepTimer", reflect.TypeOf((*MockSleepTimerStore)(nil).StoreSleepTimer), timer)
+}
+
+// LoadSleepTimer mocks base method
+func (m *MockSleepTimerStore) LoadSleepTimer(userID string) (*persistence.SleepTimer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadSleepTimer", userID)
+	ret0, _ := ret[0].(*persistence.SleepTimer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadSleepTimer indicates an expected call of LoadSleepTimer
+func (mr *MockSleepTimerStoreMockRecorder) LoadSleepTimer(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadSleepTimer", reflect.TypeOf((*MockSleepTimerStore)(nil).LoadSleepTimer), userID)
+}
+
+// LoadSleepTimers mocks base method
+func (m *MockSleepTimerStore) LoadSleepTimers() ([]*persistence.SleepTimer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadSleepTimers")
+	ret0, _ := ret[0].([]*persistence.SleepTimer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadSleepTimers indicates an expected call of LoadSleepTimers
+func (mr *MockSleepTimerStoreMockRecorder) LoadSleepTimers() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadSleepTimers", reflect.TypeOf((*MockSleepTimerStore)(nil).LoadSleepTimers))
+}
+
+// DeleteSleepTimer mocks base method
+func (m *MockSleepTimerStore) DeleteSleepTimer(userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSleepTimer", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSleepTimer indicates an expected call of DeleteSleepTimer
+func (mr *MockSleepTimerStoreMockRecorder) DeleteSleepTimer(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSleepTimer", reflect.TypeOf((*MockSleepTimerStore)(nil).DeleteSleepTimer), userID)
+}
+
+// MockScheduleStore is a mock of ScheduleStore interface
+type MockScheduleStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockScheduleStoreMockRecorder
+}
+
+// MockScheduleStoreMockRecorder is the mock recorder for MockScheduleStore
+type MockScheduleStoreMockRecorder struct {
+	mock *MockScheduleStore
+}
+
+// NewMockScheduleStore creates a new mock instance
+func NewMockScheduleStore(ctrl *gomock.Controller) *MockScheduleStore {
+	mock := &MockScheduleStore{ctrl: ctrl}
+	mock.recorder = &MockScheduleStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockScheduleStore) EXPECT() *MockScheduleStoreMockRecorder {
+	return m.recorder
+}
+
+// LoadSchedules mocks base method
+func (m *MockScheduleStore) LoadSchedules(userID string) (*persistence.UserSchedules, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadSchedules", userID)
+	ret0, _ := ret[0].(*persistence.UserSchedules)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadSchedules indicates an expected call of LoadSchedules
+func (mr *MockScheduleStoreMockRecorder) LoadSchedules(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadSchedules", reflect.TypeOf((*MockScheduleStore)(nil).LoadSchedules), userID)
+}
+
+// SaveSchedules mocks base method
+func (m *MockScheduleStore) SaveSchedules(schedules *persistence.UserSchedules) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveSchedules", schedules)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveSchedules indicates an expected call of SaveSchedules
+func (mr *MockScheduleStoreMockRecorder) SaveSchedules(schedules interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveSchedules", reflect.TypeOf((*MockScheduleStore)(nil).SaveSchedules), schedules)
+}
+
+// LoadAllSchedules mocks base method
+func (m *MockScheduleStore) LoadAllSchedules() ([]*persistence.UserSchedules, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadAllSchedules")
+	ret0, _ := ret[0].([]*persistence.UserSchedules)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadAllSchedules indicates an expected call of LoadAllSchedules
+func (mr *MockScheduleStoreMockRecorder) LoadAllSchedules() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadAllSchedules", reflect.TypeOf((*MockScheduleStore)(nil).LoadAllSchedules))
+}
+
+// MockPreferenceStore is a mock of PreferenceStore interface
+type MockPreferenceStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockPreferenceStoreMockRecorder
+}
+
+// MockPreferenceStoreMockRecorder is the mock recorder for MockPreferenceStore
+type MockPreferenceStoreMockRecorder struct {
+	mock *MockPreferenceStore
+}
+
+// NewMockPreferenceStore creates a new mock instance
+func NewMockPreferenceStore(ctrl *gomock.Controller) *MockPreferenceStore {
+	mock := &MockPreferenceStore{ctrl: ctrl}
+	mock.recorder = &MockPreferenceStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockPreferenceStore) EXPECT() *MockPreferenceStoreMockRecorder {
+	return m.recorder
+}
+
+// LoadPreferences mocks base method
+func (m *MockPreferenceStore) LoadPreferences(userID string) (*persistence.Preferences, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadPreferences", userID)
+	ret0, _ := ret[0].(*persistence.Preferences)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadPreferences indicates an expected call of LoadPreferences
+func (mr *MockPreferenceStoreMockRecorder) LoadPreferences(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadPreferences", reflect.TypeOf((*MockPreferenceStore)(nil).LoadPreferences), userID)
+}
+
+// StorePreferences mocks base method
+func (m *MockPreferenceStore) StorePreferences(userID string, preferences *persistence.Preferences) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StorePreferences", userID, preferences)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StorePreferences indicates an expected call of StorePreferences
+func (mr *MockPreferenceStoreMockRecorder) StorePreferences(userID, preferences interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StorePreferences", reflect.TypeOf((*MockPreferenceStore)(nil).StorePreferences), userID, preferences)
+}
+
+// MockHistoryStore is a mock of HistoryStore interface
+type MockHistoryStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockHistoryStoreMockRecorder
+}
+
+// MockHistoryStoreMockRecorder is the mock recorder for MockHistoryStore
+type MockHistoryStoreMockRecorder struct {
+	mock *MockHistoryStore
+}
+
+// NewMockHistoryStore creates a new mock instance
+func NewMockHistoryStore(ctrl *gomock.Controller) *MockHistoryStore {
+	mock := &MockHistoryStore{ctrl: ctrl}
+	mock.recorder = &MockHistoryStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockHistoryStore) EXPECT() *MockHistoryStoreMockRecorder {
+	return m.recorder
+}
+
+// AppendHistoryEvent mocks base method
+func (m *MockHistoryStore) AppendHistoryEvent(userID string, event *persistence.HistoryEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendHistoryEvent", userID, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AppendHistoryEvent indicates an expected call of AppendHistoryEvent
+func (mr *MockHistoryStoreMockRecorder) AppendHistoryEvent(userID, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendHistoryEvent", reflect.TypeOf((*MockHistoryStore)(nil).AppendHistoryEvent), userID, event)
+}
+
+// LoadHistory mocks base method
+func (m *MockHistoryStore) LoadHistory(userID string) ([]*persistence.HistoryEvent, []*persistence.HistoryAggregate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadHistory", userID)
+	ret0, _ := ret[0].([]*persistence.HistoryEvent)
+	ret1, _ := ret[1].([]*persistence.HistoryAggregate)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LoadHistory indicates an expected call of LoadHistory
+func (mr *MockHistoryStoreMockRecorder) LoadHistory(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadHistory", reflect.TypeOf((*MockHistoryStore)(nil).LoadHistory), userID)
+}
+
+// MockArchiveStore is a mock of ArchiveStore interface
+type MockArchiveStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockArchiveStoreMockRecorder
+}
+
+// MockArchiveStoreMockRecorder is the mock recorder for MockArchiveStore
+type MockArchiveStoreMockRecorder struct {
+	mock *MockArchiveStore
+}
+
+// NewMockArchiveStore creates a new mock instance
+func NewMockArchiveStore(ctrl *gomock.Controller) *MockArchiveStore {
+	mock := &MockArchiveStore{ctrl: ctrl}
+	mock.recorder = &MockArchiveStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockArchiveStore) EXPECT() *MockArchiveStoreMockRecorder {
+	return m.recorder
+}
+
+// ArchiveSlot mocks base method
+func (m *MockArchiveStore) ArchiveSlot(userID string, slot *persistence.ArchivedSlot) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ArchiveSlot", userID, slot)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ArchiveSlot indicates an expected call of ArchiveSlot
+func (mr *MockArchiveStoreMockRecorder) ArchiveSlot(userID, slot interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ArchiveSlot", reflect.TypeOf((*MockArchiveStore)(nil).ArchiveSlot), userID, slot)
+}
+
+// RemoveArchivedSlot mocks base method
+func (m *MockArchiveStore) RemoveArchivedSlot(userID, slotID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveArchivedSlot", userID, slotID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveArchivedSlot indicates an expected call of RemoveArchivedSlot
+func (mr *MockArchiveStoreMockRecorder) RemoveArchivedSlot(userID, slotID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveArchivedSlot", reflect.TypeOf((*MockArchiveStore)(nil).RemoveArchivedSlot), userID, slotID)
+}
+
+// LoadArchive mocks base method
+func (m *MockArchiveStore) LoadArchive(userID string) ([]*persistence.ArchivedSlot, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadArchive", userID)
+	ret0, _ := ret[0].([]*persistence.ArchivedSlot)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LoadArchive indicates an expected call of LoadArchive
+func (mr *MockArchiveStoreMockRecorder) LoadArchive(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadArchive", reflect.TypeOf((*MockArchiveStore)(nil).LoadArchive), userID)
+}
+
+// SaveArchive mocks base method
+func (m *MockArchiveStore) SaveArchive(userID string, archive []*persistence.ArchivedSlot, revision int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveArchive", userID, archive, revision)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveArchive indicates an expected call of SaveArchive
+func (mr *MockArchiveStoreMockRecorder) SaveArchive(userID, archive, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveArchive", reflect.TypeOf((*MockArchiveStore)(nil).SaveArchive), userID, archive, revision)
+}
+
+// MockTrashStore is a mock of TrashStore interface
+type MockTrashStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockTrashStoreMockRecorder
+}
+
+// MockTrashStoreMockRecorder is the mock recorder for MockTrashStore
+type MockTrashStoreMockRecorder struct {
+	mock *MockTrashStore
+}
+
+// NewMockTrashStore creates a new mock instance
+func NewMockTrashStore(ctrl *gomock.Controller) *MockTrashStore {
+	mock := &MockTrashStore{ctrl: ctrl}
+	mock.recorder = &MockTrashStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockTrashStore) EXPECT() *MockTrashStoreMockRecorder {
+	return m.recorder
+}
+
+// TrashSlot mocks base method
+func (m *MockTrashStore) TrashSlot(userID string, slot *persistence.TrashedSlot) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TrashSlot", userID, slot)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TrashSlot indicates an expected call of TrashSlot
+func (mr *MockTrashStoreMockRecorder) TrashSlot(userID, slot interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TrashSlot", reflect.TypeOf((*MockTrashStore)(nil).TrashSlot), userID, slot)
+}
+
+// RemoveTrashedSlot mocks base method
+func (m *MockTrashStore) RemoveTrashedSlot(userID, slotID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveTrashedSlot", userID, slotID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveTrashedSlot indicates an expected call of RemoveTrashedSlot
+func (mr *MockTrashStoreMockRecorder) RemoveTrashedSlot(userID, slotID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveTrashedSlot", reflect.TypeOf((*MockTrashStore)(nil).RemoveTrashedSlot), userID, slotID)
+}
+
+// LoadTrash mocks base method
+func (m *MockTrashStore) LoadTrash(userID string) ([]*persistence.TrashedSlot, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadTrash", userID)
+	ret0, _ := ret[0].([]*persistence.TrashedSlot)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LoadTrash indicates an expected call of LoadTrash
+func (mr *MockTrashStoreMockRecorder) LoadTrash(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadTrash", reflect.TypeOf((*MockTrashStore)(nil).LoadTrash), userID)
+}
+
+// SaveTrash mocks base method
+func (m *MockTrashStore) SaveTrash(userID string, trash []*persistence.TrashedSlot, revision int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveTrash", userID, trash, revision)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveTrash indicates an expected call of SaveTrash
+func (mr *MockTrashStoreMockRecorder) SaveTrash(userID, trash, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveTrash", reflect.TypeOf((*MockTrashStore)(nil).SaveTrash), userID, trash, revision)
+}
+
+// PurgeTrash mocks base method
+func (m *MockTrashStore) PurgeTrash(now time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeTrash", now)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeTrash indicates an expected call of PurgeTrash
+func (mr *MockTrashStoreMockRecorder) PurgeTrash(now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeTrash", reflect.TypeOf((*MockTrashStore)(nil).PurgeTrash), now)
+}
+
+// MockAccountDeletionStore is a mock of AccountDeletionStore interface
+type MockAccountDeletionStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockAccountDeletionStoreMockRecorder
+}
+
+// MockAccountDeletionStoreMockRecorder is the mock recorder for MockAccountDeletionStore
+type MockAccountDeletionStoreMockRecorder struct {
+	mock *MockAccountDeletionStore
+}
+
+// NewMockAccountDeletionStore creates a new mock instance
+func NewMockAccountDeletionStore(ctrl *gomock.Controller) *MockAccountDeletionStore {
+	mock := &MockAccountDeletionStore{ctrl: ctrl}
+	mock.recorder = &MockAccountDeletionStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAccountDeletionStore) EXPECT() *MockAccountDeletionStoreMockRecorder {
+	return m.recorder
+}
+
+// ScheduleAccountDeletion mocks base method
+func (m *MockAccountDeletionStore) ScheduleAccountDeletion(deletion *persistence.AccountDeletion) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScheduleAccountDeletion", deletion)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScheduleAccountDeletion indicates an expected call of ScheduleAccountDeletion
+func (mr *MockAccountDeletionStoreMockRecorder) ScheduleAccountDeletion(deletion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScheduleAccountDeletion", reflect.TypeOf((*MockAccountDeletionStore)(nil).ScheduleAccountDeletion), deletion)
+}
+
+// LoadAccountDeletion mocks base method
+func (m *MockAccountDeletionStore) LoadAccountDeletion(userID string) (*persistence.AccountDeletion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadAccountDeletion", userID)
+	ret0, _ := ret[0].(*persistence.AccountDeletion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadAccountDeletion indicates an expected call of LoadAccountDeletion
+func (mr *MockAccountDeletionStoreMockRecorder) LoadAccountDeletion(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadAccountDeletion", reflect.TypeOf((*MockAccountDeletionStore)(nil).LoadAccountDeletion), userID)
+}
+
+// LoadAccountDeletions mocks base method
+func (m *MockAccountDeletionStore) LoadAccountDeletions() ([]*persistence.AccountDeletion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadAccountDeletions")
+	ret0, _ := ret[0].([]*persistence.AccountDeletion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadAccountDeletions indicates an expected call of LoadAccountDeletions
+func (mr *MockAccountDeletionStoreMockRecorder) LoadAccountDeletions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadAccountDeletions", reflect.TypeOf((*MockAccountDeletionStore)(nil).LoadAccountDeletions))
+}
+
+// CancelAccountDeletion mocks base method
+func (m *MockAccountDeletionStore) CancelAccountDeletion(userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelAccountDeletion", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelAccountDeletion indicates an expected call of CancelAccountDeletion
+func (mr *MockAccountDeletionStoreMockRecorder) CancelAccountDeletion(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelAccountDeletion", reflect.TypeOf((*MockAccountDeletionStore)(nil).CancelAccountDeletion), userID)
+}
+
+// MockPersistor is a mock of Persistor interface
+type MockPersistor struct {
+	ctrl     *gomock.Controller
+	recorder *MockPersistorMockRecorder
+}
+
+// MockPersistorMockRecorder is the mock recorder for MockPersistor
+type MockPersistorMockRecorder struct {
+	mock *MockPersistor
+}
+
+// NewMockPersistor creates a new mock instance
+func NewMockPersistor(ctrl *gomock.Controller) *MockPersistor {
+	mock := &MockPersistor{ctrl: ctrl}
+	mock.recorder = &MockPersistorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockPersistor) EXPECT() *MockPersistorMockRecorder {
+	return m.recorder
+}
+
+// LoadPlayerStates mocks base method
+func (m *MockPersistor) LoadPlayerStates(userID string) ([]*persistence.PlayerState, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadPlayerStates", userID)
+	ret0, _ := ret[0].([]*persistence.PlayerState)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LoadPlayerStates indicates an expected call of LoadPlayerStates
+func (mr *MockPersistorMockRecorder) LoadPlayerStates(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadPlayerStates", reflect.TypeOf((*MockPersistor)(nil).LoadPlayerStates), userID)
+}
+
+// SavePlayerStates mocks base method
+func (m *MockPersistor) SavePlayerStates(userID string, playerStates []*persistence.PlayerState, revision int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SavePlayerStates", userID, playerStates, revision)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SavePlayerStates indicates an expected call of SavePlayerStates
+func (mr *MockPersistorMockRecorder) SavePlayerStates(userID, playerStates, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SavePlayerStates", reflect.TypeOf((*MockPersistor)(nil).SavePlayerStates), userID, playerStates, revision)
+}
+
+// FetchJSONDump mocks base method
+func (m *MockPersistor) FetchJSONDump(userID string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchJSONDump", userID)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchJSONDump indicates an expected call of FetchJSONDump
+func (mr *MockPersistorMockRecorder) FetchJSONDump(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchJSONDump", reflect.TypeOf((*MockPersistor)(nil).FetchJSONDump), userID)
+}
+
+// DeleteUserRecord mocks base method
+func (m *MockPersistor) DeleteUserRecord(userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserRecord", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserRecord indicates an expected call of DeleteUserRecord
+func (mr *MockPersistorMockRecorder) DeleteUserRecord(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserRecord", reflect.TypeOf((*MockPersistor)(nil).DeleteUserRecord), userID)
+}
+
+// EnableAutoSave mocks base method
+func (m *MockPersistor) EnableAutoSave(userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableAutoSave", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnableAutoSave indicates an expected call of EnableAutoSave
+func (mr *MockPersistorMockRecorder) EnableAutoSave(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableAutoSave", reflect.TypeOf((*MockPersistor)(nil).EnableAutoSave), userID)
+}
+
+// DisableAutoSave mocks base method
+func (m *MockPersistor) DisableAutoSave(userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableAutoSave", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableAutoSave indicates an expected call of DisableAutoSave
+func (mr *MockPersistorMockRecorder) DisableAutoSave(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableAutoSave", reflect.TypeOf((*MockPersistor)(nil).DisableAutoSave), userID)
+}
+
+// AutoSaveEnabled mocks base method
+func (m *MockPersistor) AutoSaveEnabled(userID string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AutoSaveEnabled", userID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AutoSaveEnabled indicates an expected call of AutoSaveEnabled
+func (mr *MockPersistorMockRecorder) AutoSaveEnabled(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AutoSaveEnabled", reflect.TypeOf((*MockPersistor)(nil).AutoSaveEnabled), userID)
+}
+
+// LoadAutoSaveUsers mocks base method
+func (m *MockPersistor) LoadAutoSaveUsers() ([]*persistence.AutoSaveUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadAutoSaveUsers")
+	ret0, _ := ret[0].([]*persistence.AutoSaveUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadAutoSaveUsers indicates an expected call of LoadAutoSaveUsers
+func (mr *MockPersistorMockRecorder) LoadAutoSaveUsers() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadAutoSaveUsers", reflect.TypeOf((*MockPersistor)(nil).LoadAutoSaveUsers))
+}
+
+// LoadToken mocks base method
+func (m *MockPersistor) LoadToken(userID string) (*oauth2.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadToken", userID)
+	ret0, _ := ret[0].(*oauth2.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadToken indicates an expected call of LoadToken
+func (mr *MockPersistorMockRecorder) LoadToken(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadToken", reflect.TypeOf((*MockPersistor)(nil).LoadToken), userID)
+}
+
+// StoreToken mocks base method
+func (m *MockPersistor) StoreToken(userID string, token *oauth2.Token) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreToken", userID, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreToken indicates an expected call of StoreToken
+func (mr *MockPersistorMockRecorder) StoreToken(userID, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreToken", reflect.TypeOf((*MockPersistor)(nil).StoreToken), userID, token)
+}
+
+// DeleteToken mocks base method
+func (m *MockPersistor) DeleteToken(userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteToken", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteToken indicates an expected call of DeleteToken
+func (mr *MockPersistorMockRecorder) DeleteToken(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteToken", reflect.TypeOf((*MockPersistor)(nil).DeleteToken), userID)
+}
+
+// LoadTrackListing mocks base method
+func (m *MockPersistor) LoadTrackListing(key string) (*persistence.TrackListing, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadTrackListing", key)
+	ret0, _ := ret[0].(*persistence.TrackListing)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadTrackListing indicates an expected call of LoadTrackListing
+func (mr *MockPersistorMockRecorder) LoadTrackListing(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadTrackListing", reflect.TypeOf((*MockPersistor)(nil).LoadTrackListing), key)
+}
+
+// StoreTrackListing mocks base method
+func (m *MockPersistor) StoreTrackListing(key string, listing *persistence.TrackListing) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreTrackListing", key, listing)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreTrackListing indicates an expected call of StoreTrackListing
+func (mr *MockPersistorMockRecorder) StoreTrackListing(key, listing interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreTrackListing", reflect.TypeOf((*MockPersistor)(nil).StoreTrackListing), key, listing)
+}
+
+// PruneTrackListings mocks base method
+func (m *MockPersistor) PruneTrackListings(cachedBeforeTs int64, maxEntries int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PruneTrackListings", cachedBeforeTs, maxEntries)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PruneTrackListings indicates an expected call of PruneTrackListings
+func (mr *MockPersistorMockRecorder) PruneTrackListings(cachedBeforeTs, maxEntries interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PruneTrackListings", reflect.TypeOf((*MockPersistor)(nil).PruneTrackListings), cachedBeforeTs, maxEntries)
+}
+
+// CreateAPIToken mocks base method
+func (m *MockPersistor) CreateAPIToken(userID string, token *persistence.APIToken) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIToken", userID, token)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAPIToken indicates an expected call of CreateAPIToken
+func (mr *MockPersistorMockRecorder) CreateAPIToken(userID, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIToken", reflect.TypeOf((*MockPersistor)(nil).CreateAPIToken), userID, token)
+}
+
+// LoadAPITokens mocks base method
+func (m *MockPersistor) LoadAPITokens(userID string) ([]*persistence.APIToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadAPITokens", userID)
+	ret0, _ := ret[0].([]*persistence.APIToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadAPITokens indicates an expected call of LoadAPITokens
+func (mr *MockPersistorMockRecorder) LoadAPITokens(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadAPITokens", reflect.TypeOf((*MockPersistor)(nil).LoadAPITokens), userID)
+}
+
+// LookupAPIToken mocks base method
+func (m *MockPersistor) LookupAPIToken(token string) (*persistence.APIToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LookupAPIToken", token)
+	ret0, _ := ret[0].(*persistence.APIToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LookupAPIToken indicates an expected call of LookupAPIToken
+func (mr *MockPersistorMockRecorder) LookupAPIToken(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LookupAPIToken", reflect.TypeOf((*MockPersistor)(nil).LookupAPIToken), token)
+}
+
+// DeleteAPIToken mocks base method
+func (m *MockPersistor) DeleteAPIToken(userID, tokenID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAPIToken", userID, tokenID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAPIToken indicates an expected call of DeleteAPIToken
+func (mr *MockPersistorMockRecorder) DeleteAPIToken(userID, tokenID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAPIToken", reflect.TypeOf((*MockPersistor)(nil).DeleteAPIToken), userID, tokenID)
+}
+
+// StoreSleepTimer mocks base method
+func (m *MockPersistor) StoreSleepTimer(timer *persistence.SleepTimer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreSleepTimer", timer)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreSleepTimer indicates an expected call of StoreSleepTimer
+func (mr *MockPersistorMockRecorder) StoreSleepTimer(timer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreSleepTimer", reflect.TypeOf((*MockPersistor)(nil).StoreSleepTimer), timer)
+}
+
+// LoadSleepTimer mocks base method
+func (m *MockPersistor) LoadSleepTimer(userID string) (*persistence.SleepTimer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadSleepTimer", userID)
+	ret0, _ := ret[0].(*persistence.SleepTimer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadSleepTimer indicates an expected call of LoadSleepTimer
+func (mr *MockPersistorMockRecorder) LoadSleepTimer(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadSleepTimer", reflect.TypeOf((*MockPersistor)(nil).LoadSleepTimer), userID)
+}
+
+// LoadSleepTimers mocks base method
+func (m *MockPersistor) LoadSleepTimers() ([]*persistence.SleepTimer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadSleepTimers")
+	ret0, _ := ret[0].([]*persistence.SleepTimer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadSleepTimers indicates an expected call of LoadSleepTimers
+func (mr *MockPersistorMockRecorder) LoadSleepTimers() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadSleepTimers", reflect.TypeOf((*MockPersistor)(nil).LoadSleepTimers))
+}
+
+// DeleteSleepTimer mocks base method
+func (m *MockPersistor) DeleteSleepTimer(userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSleepTimer", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSleepTimer indicates an expected call of DeleteSleepTimer
+func (mr *MockPersistorMockRecorder) DeleteSleepTimer(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSleepTimer", reflect.TypeOf((*MockPersistor)(nil).DeleteSleepTimer), userID)
+}
+
+// LoadSchedules mocks base method
+func (m *MockPersistor) LoadSchedules(userID string) (*persistence.UserSchedules, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadSchedules", userID)
+	ret0, _ := ret[0].(*persistence.UserSchedules)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadSchedules indicates an expected call of LoadSchedules
+func (mr *MockPersistorMockRecorder) LoadSchedules(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadSchedules", reflect.TypeOf((*MockPersistor)(nil).LoadSchedules), userID)
+}
+
+// SaveSchedules mocks base method
+func (m *MockPersistor) SaveSchedules(schedules *persistence.UserSchedules) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveSchedules", schedules)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveSchedules indicates an expected call of SaveSchedules
+func (mr *MockPersistorMockRecorder) SaveSchedules(schedules interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveSchedules", reflect.TypeOf((*MockPersistor)(nil).SaveSchedules), schedules)
+}
+
+// LoadAllSchedules mocks base method
+func (m *MockPersistor) LoadAllSchedules() ([]*persistence.UserSchedules, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadAllSchedules")
+	ret0, _ := ret[0].([]*persistence.UserSchedules)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadAllSchedules indicates an expected call of LoadAllSchedules
+func (mr *MockPersistorMockRecorder) LoadAllSchedules() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadAllSchedules", reflect.TypeOf((*MockPersistor)(nil).LoadAllSchedules))
+}
+
+// LoadPreferences mocks base method
+func (m *MockPersistor) LoadPreferences(userID string) (*persistence.Preferences, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadPreferences", userID)
+	ret0, _ := ret[0].(*persistence.Preferences)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadPreferences indicates an expected call of LoadPreferences
+func (mr *MockPersistorMockRecorder) LoadPreferences(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadPreferences", reflect.TypeOf((*MockPersistor)(nil).LoadPreferences), userID)
+}
+
+// StorePreferences mocks base method
+func (m *MockPersistor) StorePreferences(userID string, preferences *persistence.Preferences) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StorePreferences", userID, preferences)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StorePreferences indicates an expected call of StorePreferences
+func (mr *MockPersistorMockRecorder) StorePreferences(userID, preferences interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StorePreferences", reflect.TypeOf((*MockPersistor)(nil).StorePreferences), userID, preferences)
+}
+
+// AppendHistoryEvent mocks base method
+func (m *MockPersistor) AppendHistoryEvent(userID string, event *persistence.HistoryEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendHistoryEvent", userID, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AppendHistoryEvent indicates an expected call of AppendHistoryEvent
+func (mr *MockPersistorMockRecorder) AppendHistoryEvent(userID, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendHistoryEvent", reflect.TypeOf((*MockPersistor)(nil).AppendHistoryEvent), userID, event)
+}
+
+// LoadHistory mocks base method
+func (m *MockPersistor) LoadHistory(userID string) ([]*persistence.HistoryEvent, []*persistence.HistoryAggregate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadHistory", userID)
+	ret0, _ := ret[0].([]*persistence.HistoryEvent)
+	ret1, _ := ret[1].([]*persistence.HistoryAggregate)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LoadHistory indicates an expected call of LoadHistory
+func (mr *MockPersistorMockRecorder) LoadHistory(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadHistory", reflect.TypeOf((*MockPersistor)(nil).LoadHistory), userID)
+}
+
+// ArchiveSlot mocks base method
+func (m *MockPersistor) ArchiveSlot(userID string, slot *persistence.ArchivedSlot) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ArchiveSlot", userID, slot)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ArchiveSlot indicates an expected call of ArchiveSlot
+func (mr *MockPersistorMockRecorder) ArchiveSlot(userID, slot interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ArchiveSlot", reflect.TypeOf((*MockPersistor)(nil).ArchiveSlot), userID, slot)
+}
+
+// RemoveArchivedSlot mocks base method
+func (m *MockPersistor) RemoveArchivedSlot(userID, slotID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveArchivedSlot", userID, slotID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveArchivedSlot indicates an expected call of RemoveArchivedSlot
+func (mr *MockPersistorMockRecorder) RemoveArchivedSlot(userID, slotID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveArchivedSlot", reflect.TypeOf((*MockPersistor)(nil).RemoveArchivedSlot), userID, slotID)
+}
+
+// LoadArchive mocks base method
+func (m *MockPersistor) LoadArchive(userID string) ([]*persistence.ArchivedSlot, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadArchive", userID)
+	ret0, _ := ret[0].([]*persistence.ArchivedSlot)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LoadArchive indicates an expected call of LoadArchive
+func (mr *MockPersistorMockRecorder) LoadArchive(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadArchive", reflect.TypeOf((*MockPersistor)(nil).LoadArchive), userID)
+}
+
+// SaveArchive mocks base method
+func (m *MockPersistor) SaveArchive(userID string, archive []*persistence.ArchivedSlot, revision int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveArchive", userID, archive, revision)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveArchive indicates an expected call of SaveArchive
+func (mr *MockPersistorMockRecorder) SaveArchive(userID, archive, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveArchive", reflect.TypeOf((*MockPersistor)(nil).SaveArchive), userID, archive, revision)
+}
+
+// TrashSlot mocks base method
+func (m *MockPersistor) TrashSlot(userID string, slot *persistence.TrashedSlot) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TrashSlot", userID, slot)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TrashSlot indicates an expected call of TrashSlot
+func (mr *MockPersistorMockRecorder) TrashSlot(userID, slot interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TrashSlot", reflect.TypeOf((*MockPersistor)(nil).TrashSlot), userID, slot)
+}
+
+// RemoveTrashedSlot mocks base method
+func (m *MockPersistor) RemoveTrashedSlot(userID, slotID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveTrashedSlot", userID, slotID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveTrashedSlot indicates an expected call of RemoveTrashedSlot
+func (mr *MockPersistorMockRecorder) RemoveTrashedSlot(userID, slotID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveTrashedSlot", reflect.TypeOf((*MockPersistor)(nil).RemoveTrashedSlot), userID, slotID)
+}
+
+// LoadTrash mocks base method
+func (m *MockPersistor) LoadTrash(userID string) ([]*persistence.TrashedSlot, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadTrash", userID)
+	ret0, _ := ret[0].([]*persistence.TrashedSlot)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LoadTrash indicates an expected call of LoadTrash
+func (mr *MockPersistorMockRecorder) LoadTrash(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadTrash", reflect.TypeOf((*MockPersistor)(nil).LoadTrash), userID)
+}
+
+// SaveTrash mocks base method
+func (m *MockPersistor) SaveTrash(userID string, trash []*persistence.TrashedSlot, revision int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveTrash", userID, trash, revision)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveTrash indicates an expected call of SaveTrash
+func (mr *MockPersistorMockRecorder) SaveTrash(userID, trash, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveTrash", reflect.TypeOf((*MockPersistor)(nil).SaveTrash), userID, trash, revision)
+}
+
+// PurgeTrash mocks base method
+func (m *MockPersistor) PurgeTrash(now time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeTrash", now)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeTrash indicates an expected call of PurgeTrash
+func (mr *MockPersistorMockRecorder) PurgeTrash(now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeTrash", reflect.TypeOf((*MockPersistor)(nil).PurgeTrash), now)
+}
+
+// ScheduleAccountDeletion mocks base method
+func (m *MockPersistor) ScheduleAccountDeletion(deletion *persistence.AccountDeletion) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScheduleAccountDeletion", deletion)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScheduleAccountDeletion indicates an expected call of ScheduleAccountDeletion
+func (mr *MockPersistorMockRecorder) ScheduleAccountDeletion(deletion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScheduleAccountDeletion", reflect.TypeOf((*MockPersistor)(nil).ScheduleAccountDeletion), deletion)
+}
+
+// LoadAccountDeletion mocks base method
+func (m *MockPersistor) LoadAccountDeletion(userID string) (*persistence.AccountDeletion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadAccountDeletion", userID)
+	ret0, _ := ret[0].(*persistence.AccountDeletion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadAccountDeletion indicates an expected call of LoadAccountDeletion
+func (mr *MockPersistorMockRecorder) LoadAccountDeletion(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadAccountDeletion", reflect.TypeOf((*MockPersistor)(nil).LoadAccountDeletion), userID)
+}
+
+// LoadAccountDeletions mocks base method
+func (m *MockPersistor) LoadAccountDeletions() ([]*persistence.AccountDeletion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadAccountDeletions")
+	ret0, _ := ret[0].([]*persistence.AccountDeletion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadAccountDeletions indicates an expected call of LoadAccountDeletions
+func (mr *MockPersistorMockRecorder) LoadAccountDeletions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadAccountDeletions", reflect.TypeOf((*MockPersistor)(nil).LoadAccountDeletions))
+}
+
+// CancelAccountDeletion mocks base method
+func (m *MockPersistor) CancelAccountDeletion(userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelAccountDeletion", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelAccountDeletion indicates an expected call of CancelAccountDeletion
+func (mr *MockPersistorMockRecorder) CancelAccountDeletion(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelAccountDeletion", reflect.TypeOf((*MockPersistor)(nil).CancelAccountDeletion), userID)
+}
@@ -113,6 +113,21 @@ func (m *MockSpotClient) EXPECT() *MockSpotClientMockRecorder {
 	return m.recorder
 }
 
+// CurrentlyPlayingEpisode mocks base method
+func (m *MockSpotClient) CurrentlyPlayingEpisode() (*spotify.EpisodePage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CurrentlyPlayingEpisode")
+	ret0, _ := ret[0].(*spotify.EpisodePage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CurrentlyPlayingEpisode indicates an expected call of CurrentlyPlayingEpisode
+func (mr *MockSpotClientMockRecorder) CurrentlyPlayingEpisode() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CurrentlyPlayingEpisode", reflect.TypeOf((*MockSpotClient)(nil).CurrentlyPlayingEpisode))
+}
+
 // CurrentUser mocks base method
 func (m *MockSpotClient) CurrentUser() (*spotify.PrivateUser, error) {
 	m.ctrl.T.Helper()
@@ -231,6 +246,20 @@ func (mr *MockSpotClientMockRecorder) PlayOpt(opt interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PlayOpt", reflect.TypeOf((*MockSpotClient)(nil).PlayOpt), opt)
 }
 
+// Repeat mocks base method
+func (m *MockSpotClient) Repeat(state string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Repeat", state)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Repeat indicates an expected call of Repeat
+func (mr *MockSpotClientMockRecorder) Repeat(state interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Repeat", reflect.TypeOf((*MockSpotClient)(nil).Repeat), state)
+}
+
 // Shuffle mocks base method
 func (m *MockSpotClient) Shuffle(shuffle bool) error {
 	m.ctrl.T.Helper()
@@ -244,3 +273,46 @@ func (mr *MockSpotClientMockRecorder) Shuffle(shuffle interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shuffle", reflect.TypeOf((*MockSpotClient)(nil).Shuffle), shuffle)
 }
+
+// Token mocks base method
+func (m *MockSpotClient) Token() (*oauth2.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Token")
+	ret0, _ := ret[0].(*oauth2.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Token indicates an expected call of Token
+func (mr *MockSpotClientMockRecorder) Token() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Token", reflect.TypeOf((*MockSpotClient)(nil).Token))
+}
+
+// TransferPlayback mocks base method
+func (m *MockSpotClient) TransferPlayback(deviceID spotify.ID, play bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransferPlayback", deviceID, play)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TransferPlayback indicates an expected call of TransferPlayback
+func (mr *MockSpotClientMockRecorder) TransferPlayback(deviceID, play interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferPlayback", reflect.TypeOf((*MockSpotClient)(nil).TransferPlayback), deviceID, play)
+}
+
+// Volume mocks base method
+func (m *MockSpotClient) Volume(percent int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Volume", percent)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Volume indicates an expected call of Volume
+func (mr *MockSpotClientMockRecorder) Volume(percent interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Volume", reflect.TypeOf((*MockSpotClient)(nil).Volume), percent)
+}
@@ -0,0 +1,106 @@
+// Package events notifies clients about changes of a user's slots, e.g., so the web app opened on several devices
+// stays up to date.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Type tells what happened, once introduced a type must not change as clients rely on it
+type Type string
+
+const (
+	SlotCreated      Type = "slot-created"
+	SlotUpdated      Type = "slot-updated" // also published when the bookmarks of the slot change
+	SlotDeleted      Type = "slot-deleted"
+	SlotArchived     Type = "slot-archived" // the slot has been listened to completely and moved to the archive
+	PlaybackRestored Type = "playback-restored"
+	SlotsReordered   Type = "slots-reordered" // refers to no slot as the order of all slots has changed
+)
+
+// subscriptionBuffer is the number of events kept for a subscriber not keeping up, further events get dropped
+const subscriptionBuffer = 16
+
+// Event refers to the slot affected, clients are expected to fetch the slot on their own
+type Event struct {
+	Type     Type   `json:"type"`
+	UserID   string `json:"-"`
+	Slot     string `json:"slot"`
+	Bookmark string `json:"bookmark,omitempty"` // only populated when a bookmark of the slot has been restored
+	Ts       int64  `json:"ts"`
+}
+
+// New creates an event happening right now
+func New(eventType Type, userID, slot string) Event {
+	return Event{
+		Type:   eventType,
+		UserID: userID,
+		Slot:   slot,
+		Ts:     time.Now().Unix(),
+	}
+}
+
+// Bus delivers the events published to all subscribers of the user the event belongs to.
+// Delivery is best-effort, subscribers must not rely on receiving every event.
+// Running multiple instances requires a bus shared between them, e.g., backed by Redis or Mongo change streams.
+type Bus interface {
+	Publish(event Event)
+	// Subscribe returns the events of the user, the subscription has to be ended by calling the function returned
+	Subscribe(userID string) (<-chan Event, func())
+}
+
+type inProcessBus struct {
+	mutex       sync.RWMutex
+	subscribers map[string]map[chan Event]struct{}
+}
+
+// NewInProcessBus creates a bus only delivering events within this instance
+func NewInProcessBus() Bus {
+	return &inProcessBus{
+		subscribers: make(map[string]map[chan Event]struct{}),
+	}
+}
+
+func (b *inProcessBus) Publish(event Event) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for subscriber := range b.subscribers[event.UserID] {
+		select {
+		case subscriber <- event:
+		default:
+			// Publishing must not block the handlers, the subscriber will catch up when reloading anyway
+			log.Debug().Str("type", string(event.Type)).Msg("Dropped event as subscriber is not keeping up.")
+		}
+	}
+}
+
+func (b *inProcessBus) Subscribe(userID string) (<-chan Event, func()) {
+	subscriber := make(chan Event, subscriptionBuffer)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan Event]struct{})
+	}
+	b.subscribers[userID][subscriber] = struct{}{}
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+
+			delete(b.subscribers[userID], subscriber)
+			if len(b.subscribers[userID]) == 0 {
+				delete(b.subscribers, userID)
+			}
+		})
+	}
+
+	return subscriber, unsubscribe
+}
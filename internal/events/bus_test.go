@@ -0,0 +1,71 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestEventsGetDeliveredToSubscribersOfTheUser(t *testing.T) {
+	bus := NewInProcessBus()
+
+	first, unsubscribeFirst := bus.Subscribe("alice")
+	defer unsubscribeFirst()
+	second, unsubscribeSecond := bus.Subscribe("alice")
+	defer unsubscribeSecond()
+	other, unsubscribeOther := bus.Subscribe("bob")
+	defer unsubscribeOther()
+
+	bus.Publish(New(SlotCreated, "alice", "slot"))
+
+	for _, subscriber := range []<-chan Event{first, second} {
+		select {
+		case event := <-subscriber:
+			if event.Type != SlotCreated || event.Slot != "slot" || event.UserID != "alice" {
+				t.Fatalf("unexpected event: %+v", event)
+			}
+		default:
+			t.Fatal("event has not been delivered")
+		}
+	}
+
+	select {
+	case event := <-other:
+		t.Fatalf("event of another user has been delivered: %+v", event)
+	default:
+	}
+}
+
+func TestUnsubscribedSubscribersDoNotReceiveEvents(t *testing.T) {
+	bus := NewInProcessBus()
+
+	subscriber, unsubscribe := bus.Subscribe("alice")
+	unsubscribe()
+	// Unsubscribing twice does no harm
+	unsubscribe()
+
+	bus.Publish(New(SlotDeleted, "alice", "slot"))
+
+	select {
+	case event := <-subscriber:
+		t.Fatalf("event has been delivered after unsubscribing: %+v", event)
+	default:
+	}
+
+	if len(bus.(*inProcessBus).subscribers) != 0 {
+		t.Fatal("subscription has not been removed")
+	}
+}
+
+func TestPublishingDoesNotBlockOnSlowSubscribers(t *testing.T) {
+	bus := NewInProcessBus()
+
+	subscriber, unsubscribe := bus.Subscribe("alice")
+	defer unsubscribe()
+
+	for i := 0; i < subscriptionBuffer+5; i++ {
+		bus.Publish(New(SlotUpdated, "alice", "slot"))
+	}
+
+	if len(subscriber) != subscriptionBuffer {
+		t.Fatalf("expected %d buffered events, got %d", subscriptionBuffer, len(subscriber))
+	}
+}
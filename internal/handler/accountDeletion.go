@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/rs/zerolog/hlog"
+	spotifyAPI "github.com/zmb3/spotify"
+)
+
+// AccountDeletionGetHandler tells when the deletion requested by the user is going to be carried out
+func AccountDeletionGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.AccountDeletionStore)
+
+	deletion, ok := loadAccountDeletion(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	respondWithAccountDeletion(w, r, http.StatusOK, deletion)
+}
+
+// AccountDeletionDeleteHandler cancels the deletion requested by the user, everything stays as it is
+func AccountDeletionDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.AccountDeletionStore)
+
+	err := dao.CancelAccountDeletion(user.ID)
+	if err != nil {
+		if err == persistence.ErrAccountDeletionNotFound {
+			problem.Respond(w, r, http.StatusNotFound, problem.CodeDeletionNotFound, "No deletion of your data is scheduled.")
+			return
+		}
+
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed cancelling account deletion.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not cancel deletion of your data.")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// scheduleAccountDeletion keeps the deletion scheduled already, requesting it again does not postpone it
+func scheduleAccountDeletion(w http.ResponseWriter, r *http.Request, dao persistence.AccountDeletionStore, userID string, gracePeriod time.Duration) {
+	deletion, err := dao.LoadAccountDeletion(userID)
+	if err != nil && err != persistence.ErrAccountDeletionNotFound {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed loading account deletion from DB.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not schedule deletion of your data.")
+		return
+	}
+
+	if deletion == nil {
+		now := time.Now()
+		deletion = &persistence.AccountDeletion{
+			UserID:        userID,
+			RequestedAtTs: now.Unix(),
+			DeleteAtTs:    now.Add(gracePeriod).Unix(),
+		}
+
+		err = dao.ScheduleAccountDeletion(deletion)
+		if err != nil {
+			hlog.FromRequest(r).Error().Err(err).Msg("Failed scheduling account deletion.")
+			problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not schedule deletion of your data.")
+			return
+		}
+	}
+
+	respondWithAccountDeletion(w, r, http.StatusAccepted, deletion)
+}
+
+func loadAccountDeletion(w http.ResponseWriter, r *http.Request, dao persistence.AccountDeletionStore, userID string) (*persistence.AccountDeletion, bool) {
+	deletion, err := dao.LoadAccountDeletion(userID)
+	if err != nil {
+		if err == persistence.ErrAccountDeletionNotFound {
+			problem.Respond(w, r, http.StatusNotFound, problem.CodeDeletionNotFound, "No deletion of your data is scheduled.")
+			return nil, false
+		}
+
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed loading account deletion from DB.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not retrieve deletion of your data from DB.")
+		return nil, false
+	}
+
+	return deletion, true
+}
+
+func respondWithAccountDeletion(w http.ResponseWriter, r *http.Request, status int, deletion *persistence.AccountDeletion) {
+	json, err := json.Marshal(deletion)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Could not serialize account deletion.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Failed to provide deletion of your data as JSON.")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_, err = w.Write(json)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed to write JSON response.")
+	}
+}
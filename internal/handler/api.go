@@ -4,10 +4,16 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/events"
 	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
 	"github.com/florianloch/cassette/internal/spotify"
+	"github.com/gorilla/sessions"
 	"github.com/rs/zerolog/hlog"
 	spotifyAPI "github.com/zmb3/spotify"
 )
@@ -17,10 +23,9 @@ func ActiveDevicesHandler(w http.ResponseWriter, r *http.Request) {
 	spotifyClient := ctx.Value(constants.FieldKeySpotifyClient).(spotify.SpotClient)
 
 	playerDevices, err := spotify.ActiveSpotifyDevices(spotifyClient)
-
 	if err != nil {
-		hlog.FromRequest(r).Error().Err(err).Msg("Could not fetch list of active devices.")
-		http.Error(w, "Could not fetch list of active devices from Spotify!", http.StatusInternalServerError)
+		respondWithSpotifyError(w, r, err, "Could not fetch list of active devices from Spotify!")
+		return
 	}
 
 	json, err := json.Marshal(playerDevices)
@@ -28,12 +33,8 @@ func ActiveDevicesHandler(w http.ResponseWriter, r *http.Request) {
 		hlog.FromRequest(r).Error().
 			Err(err).Interface("playerDevices", playerDevices).
 			Msg("Could not serialize player devices.")
-		http.Error(w, "Could not fetch list of active devices from Spotify!", http.StatusInternalServerError)
-	}
-
-	if err != nil {
-		hlog.FromRequest(r).Error().Err(err).Msg("Could not fetch list of active devices.")
-		http.Error(w, "Could not fetch list of active devices from Spotify!", http.StatusInternalServerError)
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Failed to provide list of active devices as JSON.")
+		return
 	}
 
 	respondWithJSON(w, r, json)
@@ -43,51 +44,64 @@ func PlayerStatesPostHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
 	spotifyClient := ctx.Value(constants.FieldKeySpotifyClient).(spotify.SpotClient)
-	dao := ctx.Value(constants.FieldKeyDao).(persistence.PlayerStatesPersistor)
-	slot, ok := ctx.Value(constants.FieldKeySlot).(int)
-	if !ok {
-		slot = -1
-	}
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.Persistor)
+	slot, replaceSlot := ctx.Value(constants.FieldKeySlot).(string)
 
 	currentState, err := spotify.CurrentPlayerState(spotifyClient)
 	if err != nil {
 		if err == spotify.ErrContextNotSuspendable {
 			hlog.FromRequest(r).Debug().Err(err).Msg("Requested player state resp. its context cannot be suspended.")
-			http.Error(w, "Only albums and playlists can be suspended.", http.StatusBadRequest)
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeContextNotSuspendable, "Only albums, playlists, podcasts and single tracks can be suspended.")
 		} else {
-			hlog.FromRequest(r).Error().Err(err).Msg("Failed to get current state of player.")
-			http.Error(w, "Could not retrieve player state from Spotify. Please make sure your device is playing and online.", http.StatusInternalServerError)
+			respondWithSpotifyError(w, r, err, "Could not retrieve player state from Spotify. Please make sure your device is playing and online.")
 		}
 		return
 	}
 
-	playerStates, err := dao.LoadPlayerStates(user.ID)
+	playerStates, revision, err := dao.LoadPlayerStates(user.ID)
 	if err != nil {
 		hlog.FromRequest(r).Error().Err(err).Msg("Failed loading player states from DB.")
-		http.Error(w, "Could not retrieve player states from DB.", http.StatusInternalServerError)
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not retrieve player states from DB.")
 		return
 	}
 
-	// replace, if < 0 then append a new slot
-	if slot >= 0 {
-		if slot >= len(playerStates) {
-			http.Error(w, "'slot' is not in the range of existing slots.", http.StatusBadRequest)
-			hlog.FromRequest(r).Debug().Int("slot", slot).Msg("Slot is out of range.")
+	if !checkIfMatch(w, r, revision) {
+		return
+	}
+
+	// replace, if no slot is given then append a new slot
+	idx := -1
+	if replaceSlot {
+		idx = indexOfSlot(w, playerStates, slot)
+		if idx < 0 {
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeSlotOutOfRange, "'slot' does not refer to an existing slot.")
+			hlog.FromRequest(r).Debug().Str("slot", slot).Msg("Slot does not exist.")
 			return
 		}
+	}
+
+	playerStates, added := persistence.MergeSuspended(playerStates, currentState, idx)
 
-		playerStates[slot] = currentState
-	} else {
-		playerStates = append(playerStates, currentState)
+	eventType := events.SlotUpdated
+	if added {
+		eventType = events.SlotCreated
 	}
 
-	err = dao.SavePlayerStates(user.ID, playerStates)
-	if err != nil {
-		hlog.FromRequest(r).Error().
-			Err(err).
-			Interface("playerStates", playerStates).
-			Msg("Could not persist player states in DB.")
-		http.Error(w, "Could not persist player states in DB.", http.StatusInternalServerError)
+	playerStates, archived, ok := archiveIfFinished(w, r, dao, user.ID, playerStates, currentState)
+	if !ok {
+		return
+	}
+
+	location := "/api/playerStates/"
+	if archived {
+		eventType = events.SlotArchived
+		location = "/api/archive/"
+	}
+
+	if !savePlayerStates(w, r, dao, user.ID, playerStates, revision) {
+		if archived {
+			undoArchiving(r, dao, user.ID, currentState)
+		}
 		return
 	}
 
@@ -97,6 +111,11 @@ func PlayerStatesPostHandler(w http.ResponseWriter, r *http.Request) {
 		hlog.FromRequest(r).Debug().Err(err).Msg("Could not pause player.")
 	}
 
+	// The ID has been assigned when saving
+	publish(r, eventType, currentState.ID)
+	recordHistory(r, persistence.HistorySuspended, currentState, "")
+
+	w.Header().Set("Location", location+currentState.ID)
 	w.WriteHeader(http.StatusCreated)
 }
 
@@ -105,81 +124,127 @@ func PlayerStatesGetHandler(w http.ResponseWriter, r *http.Request) {
 	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
 	dao := ctx.Value(constants.FieldKeyDao).(persistence.PlayerStatesPersistor)
 
-	playerStates, err := dao.LoadPlayerStates(user.ID)
+	order := persistence.SortManual
+	if param := r.URL.Query().Get("sort"); param != "" {
+		order = persistence.SortOrder(param)
+	}
+
+	if !order.Valid() {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "'sort' has to be one of 'manual', 'recent', 'title' or 'progress'.")
+		return
+	}
+
+	playerStates, revision, err := dao.LoadPlayerStates(user.ID)
 	if err != nil {
 		hlog.FromRequest(r).Error().Err(err).Msg("Failed loading player states from DB.")
-		http.Error(w, "Could not retrieve player states from DB.", http.StatusInternalServerError)
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not retrieve player states from DB.")
 		return
 	}
 
+	// Neither sorting nor filtering changes the ETag, the revision still refers to all slots in their manual order
+	playerStates = persistence.SortPlayerStates(playerStates, order)
+
+	if tags := r.URL.Query()["tag"]; len(tags) > 0 {
+		tagged := make([]*persistence.PlayerState, 0)
+		for _, playerState := range playerStates {
+			if playerState.HasTags(tags) {
+				tagged = append(tagged, playerState)
+			}
+		}
+
+		playerStates = tagged
+	}
+
 	json, err := json.Marshal(playerStates)
 	if err != nil {
 		hlog.FromRequest(r).Error().
 			Err(err).
 			Interface("playerStates", playerStates).
 			Msg("Could not serialize player states to JSON.")
-		http.Error(w, "Failed to provide player states as JSON.", http.StatusInternalServerError)
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Failed to provide player states as JSON.")
 		return
 	}
 
+	w.Header().Set("ETag", etagOf(revision))
 	respondWithJSON(w, r, json)
 }
 
+// PlayerStatesDeleteHandler moves the slot to the trash, it can be undeleted until it gets purged
 func PlayerStatesDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
-	dao := ctx.Value(constants.FieldKeyDao).(persistence.PlayerStatesPersistor)
-	slot := ctx.Value(constants.FieldKeySlot).(int)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.Persistor)
+	slot := ctx.Value(constants.FieldKeySlot).(string)
 
-	playerStates, err := dao.LoadPlayerStates(user.ID)
+	playerStates, revision, err := dao.LoadPlayerStates(user.ID)
 	if err != nil {
 		hlog.FromRequest(r).Error().Err(err).Msg("Failed loading player states from DB.")
-		http.Error(w, "Could not retrieve player states from DB.", http.StatusInternalServerError)
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not retrieve player states from DB.")
+		return
+	}
+
+	if !checkIfMatch(w, r, revision) {
 		return
 	}
 
-	if slot >= len(playerStates) {
+	idx := indexOfSlot(w, playerStates, slot)
+	if idx < 0 {
 		hlog.FromRequest(r).Debug().
-			Int("slot", slot).
+			Str("slot", slot).
 			Interface("playerStates", playerStates).
-			Msg("Unable to delete player state - slot out of range.")
-		http.Error(w, "'slot' is not in the range of existing slots.", http.StatusInternalServerError)
+			Msg("Unable to delete player state - slot does not exist.")
+		problem.Respond(w, r, http.StatusNotFound, problem.CodeSlotOutOfRange, "'slot' does not refer to an existing slot.")
 		return
 	}
 
-	playerStates = append(playerStates[:slot], playerStates[slot+1:]...)
+	// The slot is trashed first, so it is not lost in case saving the player states fails. Then it is taken out of
+	// the trash again.
+	deleted := playerStates[idx]
+	trashed, ok := trashSlot(w, r, dao, user.ID, deleted)
+	if !ok {
+		return
+	}
 
-	err = dao.SavePlayerStates(user.ID, playerStates)
-	if err != nil {
-		hlog.FromRequest(r).Error().
-			Err(err).
-			Interface("playerStates", playerStates).
-			Msg("Could not persist player states in DB.")
-		http.Error(w, "Could not persist player states in DB.", http.StatusInternalServerError)
+	playerStates = append(playerStates[:idx], playerStates[idx+1:]...)
+
+	if !savePlayerStates(w, r, dao, user.ID, playerStates, revision) {
+		if trashed {
+			undoTrashing(r, dao, user.ID, deleted)
+		}
+
+		return
 	}
+
+	publish(r, events.SlotDeleted, deleted.ID)
 }
 
 func PlayerStatesRestoreHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
 	spotifyClient := ctx.Value(constants.FieldKeySpotifyClient).(spotify.SpotClient)
-	dao := ctx.Value(constants.FieldKeyDao).(persistence.PlayerStatesPersistor)
-	slot := ctx.Value(constants.FieldKeySlot).(int)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.Persistor)
+	slot := ctx.Value(constants.FieldKeySlot).(string)
 
 	deviceID := r.URL.Query().Get("deviceID")
-	playerStates, err := dao.LoadPlayerStates(user.ID)
+	playerStates, _, err := dao.LoadPlayerStates(user.ID)
 	if err != nil {
 		hlog.FromRequest(r).Error().Err(err).Msg("Failed loading player states from DB.")
-		http.Error(w, "Could not retrieve player states from DB.", http.StatusInternalServerError)
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not retrieve player states from DB.")
 		return
 	}
 
-	if slot >= len(playerStates) {
+	idx := indexOfSlot(w, playerStates, slot)
+	if idx < 0 {
 		hlog.FromRequest(r).Debug().
-			Int("slot", slot).
+			Str("slot", slot).
 			Interface("playerStates", playerStates).
-			Msg("Unable to restore player state. Slot out of range.")
-		http.Error(w, "'slot' is not in the range of existing slots.", http.StatusBadRequest)
+			Msg("Unable to restore player state. Slot does not exist.")
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeSlotOutOfRange, "'slot' does not refer to an existing slot.")
+		return
+	}
+
+	preferences, ok := loadPreferences(w, r, dao, user.ID)
+	if !ok {
 		return
 	}
 
@@ -189,18 +254,42 @@ func PlayerStatesRestoreHandler(w http.ResponseWriter, r *http.Request) {
 		hlog.FromRequest(r).Debug().Err(err).Msg("Could not pause player.")
 	}
 
-	stateToRestore := playerStates[slot]
+	stateToRestore := playerStates[idx]
+	deviceID = deviceForRestoring(r, spotifyClient, preferences, stateToRestore, deviceID)
 
-	err = spotify.RestorePlayerState(spotifyClient, stateToRestore, deviceID)
+	jumpBack := preferences.JumpBack(stateToRestore, time.Since(time.Unix(stateToRestore.SuspendedAtTs, 0)))
+
+	err = spotify.RestorePlayerState(spotifyClient, stateToRestore, nil, deviceID, jumpBack)
 	if err != nil {
 		hlog.FromRequest(r).Debug().
 			Err(err).
-			Int("slot", slot).
+			Str("slot", slot).
 			Str("deviceID", deviceID).
 			Interface("stateToRestore", stateToRestore).
 			Msg("Could not restore player state.")
-		http.Error(w, "Could not restore player state. Please check that there is at least one active device.", http.StatusBadRequest)
+		respondWithPlaybackError(w, r, err, "Could not restore player state.")
+		return
 	}
+
+	publish(r, events.PlaybackRestored, stateToRestore.ID)
+	recordHistory(r, persistence.HistoryRestored, stateToRestore, "")
+}
+
+// deviceForRestoring returns the device the slot has been suspended on if the user prefers restoring onto it and no
+// device has been requested explicitly. Falls back to the requested one (or the active one) if it is not available.
+func deviceForRestoring(r *http.Request, client spotify.SpotClient, preferences *persistence.Preferences, playerState *persistence.PlayerState, deviceID string) string {
+	if deviceID != "" || !preferences.RestoreDevice {
+		return deviceID
+	}
+
+	originalDeviceID, err := spotify.TransferToOriginalDevice(client, playerState)
+	if err != nil {
+		// Restoring onto the currently active device is better than not restoring at all
+		hlog.FromRequest(r).Debug().Err(err).Str("deviceName", playerState.DeviceName).Msg("Could not transfer playback to original device.")
+		return deviceID
+	}
+
+	return originalDeviceID
 }
 
 func UserExportHandler(w http.ResponseWriter, r *http.Request) {
@@ -212,10 +301,10 @@ func UserExportHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if errors.Is(err, persistence.ErrUserNotFound) {
 			hlog.FromRequest(r).Debug().Msg("User requested to exports her/his data - but nothing found in DB.")
-			http.Error(w, "No data stored in db for this user.", http.StatusBadRequest)
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeNoDataStored, "No data stored in db for this user.")
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			hlog.FromRequest(r).Debug().Err(err).Msg("Failed exporting user data.")
+			hlog.FromRequest(r).Error().Err(err).Msg("Failed exporting user data.")
+			problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not export data from DB.")
 		}
 
 		return
@@ -224,21 +313,180 @@ func UserExportHandler(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, r, json)
 }
 
+// UserDeleteHandler schedules the deletion of everything stored for the user, it is carried out once the grace
+// period is over. Without a grace period everything gets deleted right away.
 func UserDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
-	dao := ctx.Value(constants.FieldKeyDao).(persistence.PlayerStatesPersistor)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.Persistor)
+	retention := ctx.Value(constants.FieldKeyRetention).(Retention)
+
+	if retention.AccountDeletion > 0 {
+		scheduleAccountDeletion(w, r, dao, user.ID, retention.AccountDeletion)
+		return
+	}
 
 	err := dao.DeleteUserRecord(user.ID)
 	if err != nil {
 		if errors.Is(err, persistence.ErrUserNotFound) {
 			hlog.FromRequest(r).Debug().Msg("User requested to delete her/his data - but nothing found in DB.")
-			http.Error(w, "No data stored in db for this user.", http.StatusBadRequest)
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeNoDataStored, "No data stored in db for this user.")
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			hlog.FromRequest(r).Debug().Err(err).Msg("Failed deleting user data.")
+			hlog.FromRequest(r).Error().Err(err).Msg("Failed deleting user data.")
+			problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not delete data from DB.")
+		}
+	}
+}
+
+// TokenDeleteHandler revokes Cassette's access to Spotify on behalf of the user by deleting the stored
+// token. This also ends all sessions of the user and stops saving the progress in the background.
+func TokenDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	session := ctx.Value(constants.FieldKeySession).(*sessions.Session)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.Persistor)
+
+	err := dao.DeleteToken(user.ID)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed deleting token.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not delete token from DB.")
+		return
+	}
+
+	err = dao.DisableAutoSave(user.ID)
+	if err != nil {
+		// The worker disables auto save on its own as soon as it notices the token is gone
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed disabling auto save.")
+	}
+
+	delete(session.Values, constants.SessionKeyUser)
+	delete(session.Values, constants.SessionKeySpotifyToken)
+
+	err = session.Save(r, w)
+	if err != nil {
+		// Not an issue, the next request will notice the token is gone
+		hlog.FromRequest(r).Error().Err(err).Msg("Could not clear user's session.")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func loadPlayerStates(w http.ResponseWriter, r *http.Request, dao persistence.PlayerStatesPersistor, userID string) ([]*persistence.PlayerState, int, bool) {
+	playerStates, revision, err := dao.LoadPlayerStates(userID)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed loading player states from DB.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not retrieve player states from DB.")
+		return nil, 0, false
+	}
+
+	return playerStates, revision, true
+}
+
+// checkIfMatch ensures the player states did not change since the client fetched them - in case the
+// client provided the revision it knows via 'If-Match'. Responds with 412 if this check fails.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, revision int) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || ifMatch == "*" {
+		return true
+	}
+
+	for _, etag := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(etag) == etagOf(revision) {
+			return true
+		}
+	}
+
+	hlog.FromRequest(r).Debug().
+		Str("ifMatch", ifMatch).
+		Int("revision", revision).
+		Msg("Client is not aware of the latest player states.")
+	problem.Respond(w, r, http.StatusPreconditionFailed, problem.CodeModifiedConcurrently, "Player states have been modified in the meantime. Please reload them and try again.")
+
+	return false
+}
+
+// savePlayerStates persists the player states in case they are still at the given revision and sets
+// the ETag of the new revision. Responds with 409 in case they have been modified concurrently.
+func savePlayerStates(w http.ResponseWriter, r *http.Request, dao persistence.PlayerStatesPersistor, userID string, playerStates []*persistence.PlayerState, revision int) bool {
+	newRevision, err := dao.SavePlayerStates(userID, playerStates, revision)
+	if err != nil {
+		if err == persistence.ErrRevisionMismatch {
+			hlog.FromRequest(r).Debug().Int("revision", revision).Msg("Player states have been modified concurrently.")
+			problem.Respond(w, r, http.StatusConflict, problem.CodeModifiedConcurrently, "Player states have been modified concurrently. Please reload them and try again.")
+			return false
 		}
+
+		hlog.FromRequest(r).Error().
+			Err(err).
+			Interface("playerStates", playerStates).
+			Msg("Could not persist player states in DB.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not persist player states in DB.")
+		return false
+	}
+
+	w.Header().Set("ETag", etagOf(newRevision))
+
+	return true
+}
+
+// respondWithSpotifyError responds with 429 in case the user has used up her/his budget of requests and with 503 in
+// case Spotify cannot be accessed for the time being, e.g., because of rate limiting. Any other error is considered a
+// failure of Spotify.
+func respondWithSpotifyError(w http.ResponseWriter, r *http.Request, err error, detail string) {
+	var budgetExceeded *spotify.BudgetExceededError
+	if errors.As(err, &budgetExceeded) {
+		hlog.FromRequest(r).Debug().Err(err).Msg("User has used up her/his budget of requests.")
+		problem.RespondTooManyRequests(w, r, problem.CodeRequestBudgetExceeded, "Too many requests have been sent to Spotify. Please try again later.", budgetExceeded.RetryAfter)
+		return
 	}
+
+	if retryAfter, temporary := spotify.RetryAfter(err); temporary {
+		hlog.FromRequest(r).Warn().Err(err).Dur("retryAfter", retryAfter).Msg("Spotify is not available at the moment.")
+		problem.RespondRetryLater(w, r, problem.CodeSpotifyUnavailable, "Spotify is not available at the moment. Please try again later.", retryAfter)
+		return
+	}
+
+	hlog.FromRequest(r).Error().Err(err).Msg("Request to Spotify failed.")
+	problem.Respond(w, r, http.StatusInternalServerError, problem.CodeSpotifyError, detail)
+}
+
+// respondWithPlaybackError tells apart the reasons starting playback can fail for
+func respondWithPlaybackError(w http.ResponseWriter, r *http.Request, err error, detail string) {
+	if _, temporary := spotify.RetryAfter(err); temporary {
+		respondWithSpotifyError(w, r, err, detail)
+		return
+	}
+
+	if errors.Is(err, spotify.ErrNoActiveDeviceForPlayback) {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeNoActiveDevice, detail+" Please check that there is at least one active device.")
+		return
+	}
+
+	problem.Respond(w, r, http.StatusBadRequest, problem.CodePlaybackFailed, detail)
+}
+
+func etagOf(revision int) string {
+	return `"` + strconv.Itoa(revision) + `"`
+}
+
+// indexOfSlot resolves the given slot to the position of the player state it refers to, -1 if there is none.
+// Slots are addressed by the ID of their player state. For compatibility with clients not being aware
+// of IDs the position within the list of player states is accepted as well, but marked as deprecated.
+func indexOfSlot(w http.ResponseWriter, playerStates []*persistence.PlayerState, slot string) int {
+	for idx, playerState := range playerStates {
+		if playerState.ID == slot {
+			return idx
+		}
+	}
+
+	idx, err := strconv.Atoi(slot)
+	if err != nil || idx < 0 || idx >= len(playerStates) {
+		return -1
+	}
+
+	w.Header().Set("Deprecation", "true")
+
+	return idx
 }
 
 func respondWithJSON(w http.ResponseWriter, r *http.Request, json []byte) {
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/rs/zerolog/hlog"
+	spotifyAPI "github.com/zmb3/spotify"
+)
+
+type apiTokenRequest struct {
+	Name   string              `json:"name"`
+	Scopes []persistence.Scope `json:"scopes"`
+}
+
+// createdAPIToken is the only response containing the token itself
+type createdAPIToken struct {
+	*persistence.APIToken
+	Token string `json:"token"`
+}
+
+func APITokensGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.APITokenStore)
+
+	tokens, err := dao.LoadAPITokens(user.ID)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed loading API tokens from DB.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not retrieve API tokens from DB.")
+		return
+	}
+
+	json, err := json.Marshal(tokens)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Could not serialize API tokens.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Failed to provide API tokens as JSON.")
+		return
+	}
+
+	respondWithJSON(w, r, json)
+}
+
+func APITokensPostHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.APITokenStore)
+
+	var body apiTokenRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		hlog.FromRequest(r).Debug().Err(err).Msg("Could not decode API token.")
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Could not process request. Please provide the API token as JSON.")
+		return
+	}
+
+	token := &persistence.APIToken{
+		Name:        strings.TrimSpace(body.Name),
+		Scopes:      body.Scopes,
+		CreatedAtTs: time.Now().Unix(),
+	}
+
+	if token.Name == "" {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "'name' must not be empty.")
+		return
+	}
+
+	if !validScopes(token.Scopes) {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "'scopes' has to contain at least one of 'read', 'suspend' and 'restore'.")
+		return
+	}
+
+	secret, err := dao.CreateAPIToken(user.ID, token)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed creating API token.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not persist API token in DB.")
+		return
+	}
+
+	json, err := json.Marshal(createdAPIToken{token, secret})
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Could not serialize API token.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Failed to provide API token as JSON.")
+		return
+	}
+
+	w.Header().Set("Location", "/api/you/apiTokens/"+token.ID)
+	w.Header().Set("Content-Type", "application/json")
+	// The token must not end up in any cache
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+
+	_, err = w.Write(json)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed to write JSON response.")
+	}
+}
+
+func APITokensDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.APITokenStore)
+	tokenID := ctx.Value(constants.FieldKeyAPITokenID).(string)
+
+	err := dao.DeleteAPIToken(user.ID, tokenID)
+	if err != nil {
+		if err == persistence.ErrAPITokenNotFound {
+			hlog.FromRequest(r).Debug().Str("apiToken", tokenID).Msg("API token does not exist.")
+			problem.Respond(w, r, http.StatusNotFound, problem.CodeAPITokenNotFound, "'apiToken' does not refer to an existing API token.")
+			return
+		}
+
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed deleting API token.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not delete API token from DB.")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func validScopes(scopes []persistence.Scope) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+
+	for _, scope := range scopes {
+		known := false
+		for _, cur := range persistence.Scopes {
+			known = known || scope == cur
+		}
+
+		if !known {
+			return false
+		}
+	}
+
+	return true
+}
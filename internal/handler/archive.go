@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/rs/zerolog/hlog"
+)
+
+var archiveCollection = &slotCollection{
+	name:           "archive",
+	notFoundCode:   problem.CodeArchivedSlotNotFound,
+	notFoundDetail: "'slot' does not refer to an archived slot.",
+	load: func(dao persistence.Persistor, userID string) (slotList, int, error) {
+		archive, revision, err := dao.LoadArchive(userID)
+		return archivedSlots(archive), revision, err
+	},
+	save: func(dao persistence.Persistor, userID string, slots slotList, revision int) error {
+		_, err := dao.SaveArchive(userID, slots.(archivedSlots), revision)
+		return err
+	},
+}
+
+var (
+	// ArchiveGetHandler lists the slots having been listened to completely, the ones completed most recently come
+	// first
+	ArchiveGetHandler = archiveCollection.listHandler
+	// ArchiveDeleteHandler purges all archived slots
+	ArchiveDeleteHandler = archiveCollection.clearHandler
+	// ArchivedSlotGetHandler provides the archived slot along with the time it has been completed at
+	ArchivedSlotGetHandler = archiveCollection.slotGetHandler
+	// ArchivedSlotDeleteHandler purges the archived slot
+	ArchivedSlotDeleteHandler = archiveCollection.slotDeleteHandler
+	// UnarchiveHandler moves the archived slot back to the end of the player states
+	UnarchiveHandler = archiveCollection.restoreHandler
+)
+
+// archiveIfFinished moves the slot just suspended to the archive in case it has been listened to completely,
+// the slot gets removed from the player states returned
+func archiveIfFinished(w http.ResponseWriter, r *http.Request, dao persistence.ArchiveStore, userID string, playerStates []*persistence.PlayerState, playerState *persistence.PlayerState) ([]*persistence.PlayerState, bool, bool) {
+	archived, err := persistence.ArchiveFinished(dao, userID, playerState, time.Unix(playerState.SuspendedAtTs, 0))
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed archiving finished slot.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not archive finished slot.")
+		return nil, false, false
+	}
+
+	if archived {
+		playerStates = persistence.WithoutSlot(playerStates, playerState)
+	}
+
+	return playerStates, archived, true
+}
+
+// undoArchiving is called in case the player states could not be saved after archiving the slot, failing to undo it
+// the slot is contained in both
+func undoArchiving(r *http.Request, dao persistence.ArchiveStore, userID string, playerState *persistence.PlayerState) {
+	err := persistence.UndoArchiving(dao, userID, playerState)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Str("slot", playerState.ID).Msg("Failed removing slot from archive again.")
+	}
+}
+
+type archivedSlots []*persistence.ArchivedSlot
+
+func (s archivedSlots) Len() int {
+	return len(s)
+}
+
+func (s archivedSlots) Less(i, j int) bool {
+	return s[i].CompletedAtTs > s[j].CompletedAtTs
+}
+
+func (s archivedSlots) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s archivedSlots) at(idx int) interface{} {
+	return s[idx]
+}
+
+func (s archivedSlots) playerState(idx int) *persistence.PlayerState {
+	return s[idx].PlayerState
+}
+
+func (s archivedSlots) without(idx int) slotList {
+	return append(s[:idx], s[idx+1:]...)
+}
+
+func (s archivedSlots) cleared() slotList {
+	return make(archivedSlots, 0)
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/rs/zerolog/hlog"
+	spotifyAPI "github.com/zmb3/spotify"
+)
+
+type autoSaveSetting struct {
+	Enabled bool `json:"enabled"`
+}
+
+func AutoSaveGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.AutoSavePersistor)
+
+	enabled, err := dao.AutoSaveEnabled(user.ID)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed checking whether auto save is enabled.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not retrieve auto save setting from DB.")
+		return
+	}
+
+	json, err := json.Marshal(autoSaveSetting{enabled})
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Could not serialize auto save setting.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Failed to provide auto save setting as JSON.")
+		return
+	}
+
+	respondWithJSON(w, r, json)
+}
+
+// AutoSavePutHandler opts the user in to having her/his progress saved in the background.
+// The worker uses the token stored for the user in order to access Spotify outside of requests.
+func AutoSavePutHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.AutoSavePersistor)
+
+	err := dao.EnableAutoSave(user.ID)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed enabling auto save.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not persist auto save setting in DB.")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AutoSaveDeleteHandler opts the user out, the stored token gets deleted.
+// Slots already saved in the background are kept.
+func AutoSaveDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.AutoSavePersistor)
+
+	err := dao.DisableAutoSave(user.ID)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed disabling auto save.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not persist auto save setting in DB.")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
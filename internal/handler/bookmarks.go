@@ -0,0 +1,290 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/events"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/florianloch/cassette/internal/spotify"
+	"github.com/rs/zerolog/hlog"
+	spotifyAPI "github.com/zmb3/spotify"
+)
+
+// bookmarkRequest is the body for creating resp. updating a bookmark. When creating a bookmark without
+// giving a track the current position of the player is used.
+type bookmarkRequest struct {
+	Name     string `json:"name"`
+	Note     string `json:"note"`
+	TrackURI string `json:"trackURI"`
+	Progress int    `json:"progress"`
+}
+
+func BookmarksGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.PlayerStatesPersistor)
+	slot := ctx.Value(constants.FieldKeySlot).(string)
+
+	playerStates, revision, ok := loadPlayerStates(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	idx := indexOfSlot(w, playerStates, slot)
+	if idx < 0 {
+		hlog.FromRequest(r).Debug().Str("slot", slot).Msg("Slot does not exist.")
+		problem.Respond(w, r, http.StatusNotFound, problem.CodeSlotOutOfRange, "'slot' does not refer to an existing slot.")
+		return
+	}
+
+	bookmarks := playerStates[idx].Bookmarks
+	if bookmarks == nil {
+		bookmarks = make([]*persistence.Bookmark, 0)
+	}
+
+	json, err := json.Marshal(bookmarks)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Interface("bookmarks", bookmarks).Msg("Could not serialize bookmarks.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Failed to provide bookmarks as JSON.")
+		return
+	}
+
+	w.Header().Set("ETag", etagOf(revision))
+	respondWithJSON(w, r, json)
+}
+
+func BookmarksPostHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	spotifyClient := ctx.Value(constants.FieldKeySpotifyClient).(spotify.SpotClient)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.PlayerStatesPersistor)
+	slot := ctx.Value(constants.FieldKeySlot).(string)
+
+	var body bookmarkRequest
+	if !decodeBookmarkRequest(w, r, &body) {
+		return
+	}
+
+	playerStates, revision, ok := loadPlayerStates(w, r, dao, user.ID)
+	if !ok || !checkIfMatch(w, r, revision) {
+		return
+	}
+
+	idx := indexOfSlot(w, playerStates, slot)
+	if idx < 0 {
+		hlog.FromRequest(r).Debug().Str("slot", slot).Msg("Slot does not exist.")
+		problem.Respond(w, r, http.StatusNotFound, problem.CodeSlotOutOfRange, "'slot' does not refer to an existing slot.")
+		return
+	}
+
+	playerState := playerStates[idx]
+
+	bookmark := &persistence.Bookmark{
+		Name:        strings.TrimSpace(body.Name),
+		Note:        strings.TrimSpace(body.Note),
+		TrackURI:    body.TrackURI,
+		Progress:    body.Progress,
+		CreatedAtTs: time.Now().Unix(),
+	}
+
+	if bookmark.TrackURI == "" {
+		currentState, err := spotify.CurrentPlayerState(spotifyClient)
+		if err != nil {
+			respondWithSpotifyError(w, r, err, "Could not retrieve player state from Spotify. Please make sure your device is playing and online.")
+			return
+		}
+
+		if !currentState.SameContext(playerState) {
+			hlog.FromRequest(r).Debug().Str("slot", slot).Msg("Currently playing does not belong to the context of the slot.")
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeContextMismatch, "What is currently playing does not belong to this slot. Please start playback of this slot first.")
+			return
+		}
+
+		bookmark.TrackURI = currentState.PlaybackItemURI
+		bookmark.TrackName = currentState.TrackName
+		bookmark.Progress = currentState.Progress
+	} else if bookmark.TrackURI == playerState.PlaybackItemURI {
+		bookmark.TrackName = playerState.TrackName
+	}
+
+	if bookmark.Name == "" {
+		bookmark.Name = bookmark.TrackName
+	}
+
+	playerState.Bookmarks = append(playerState.Bookmarks, bookmark)
+
+	if !savePlayerStates(w, r, dao, user.ID, playerStates, revision) {
+		return
+	}
+
+	publish(r, events.SlotUpdated, playerState.ID)
+
+	// The ID has been assigned when saving
+	w.Header().Set("Location", "/api/playerStates/"+playerState.ID+"/bookmarks/"+bookmark.ID)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// BookmarksPutHandler renames a bookmark resp. changes its note, its position cannot be changed
+func BookmarksPutHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.PlayerStatesPersistor)
+	slot := ctx.Value(constants.FieldKeySlot).(string)
+	bookmarkID := ctx.Value(constants.FieldKeyBookmark).(string)
+
+	var body bookmarkRequest
+	if !decodeBookmarkRequest(w, r, &body) {
+		return
+	}
+
+	if strings.TrimSpace(body.Name) == "" {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "'name' must not be empty.")
+		return
+	}
+
+	playerStates, revision, ok := loadPlayerStates(w, r, dao, user.ID)
+	if !ok || !checkIfMatch(w, r, revision) {
+		return
+	}
+
+	playerState, bookmark := findBookmark(w, r, playerStates, slot, bookmarkID)
+	if bookmark == nil {
+		return
+	}
+
+	bookmark.Name = strings.TrimSpace(body.Name)
+	bookmark.Note = strings.TrimSpace(body.Note)
+
+	if savePlayerStates(w, r, dao, user.ID, playerStates, revision) {
+		publish(r, events.SlotUpdated, playerState.ID)
+	}
+}
+
+func BookmarksDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.PlayerStatesPersistor)
+	slot := ctx.Value(constants.FieldKeySlot).(string)
+	bookmarkID := ctx.Value(constants.FieldKeyBookmark).(string)
+
+	playerStates, revision, ok := loadPlayerStates(w, r, dao, user.ID)
+	if !ok || !checkIfMatch(w, r, revision) {
+		return
+	}
+
+	playerState, bookmark := findBookmark(w, r, playerStates, slot, bookmarkID)
+	if bookmark == nil {
+		return
+	}
+
+	remaining := make([]*persistence.Bookmark, 0, len(playerState.Bookmarks)-1)
+	for _, cur := range playerState.Bookmarks {
+		if cur != bookmark {
+			remaining = append(remaining, cur)
+		}
+	}
+	playerState.Bookmarks = remaining
+
+	if savePlayerStates(w, r, dao, user.ID, playerStates, revision) {
+		publish(r, events.SlotUpdated, playerState.ID)
+	}
+}
+
+func BookmarksRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	spotifyClient := ctx.Value(constants.FieldKeySpotifyClient).(spotify.SpotClient)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.Persistor)
+	slot := ctx.Value(constants.FieldKeySlot).(string)
+	bookmarkID := ctx.Value(constants.FieldKeyBookmark).(string)
+
+	deviceID := r.URL.Query().Get("deviceID")
+
+	playerStates, _, ok := loadPlayerStates(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	playerState, bookmark := findBookmark(w, r, playerStates, slot, bookmarkID)
+	if bookmark == nil {
+		return
+	}
+
+	preferences, ok := loadPreferences(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	err := spotifyClient.Pause()
+	if err != nil {
+		// No serious error, we do not need to tell the client, he might notice anyway
+		hlog.FromRequest(r).Debug().Err(err).Msg("Could not pause player.")
+	}
+
+	deviceID = deviceForRestoring(r, spotifyClient, preferences, playerState, deviceID)
+
+	// Bookmarks mark exact positions, so the time the slot has been suspended for does not matter
+	err = spotify.RestorePlayerState(spotifyClient, playerState, bookmark, deviceID, preferences.JumpBack(playerState, 0))
+	if err != nil {
+		hlog.FromRequest(r).Debug().
+			Err(err).
+			Str("slot", slot).
+			Str("bookmark", bookmarkID).
+			Str("deviceID", deviceID).
+			Msg("Could not restore bookmark.")
+		respondWithPlaybackError(w, r, err, "Could not restore bookmark.")
+		return
+	}
+
+	event := events.New(events.PlaybackRestored, user.ID, playerState.ID)
+	event.Bookmark = bookmark.ID
+	publishEvent(r, event)
+	recordHistory(r, persistence.HistoryRestored, playerState, bookmark.ID)
+}
+
+func decodeBookmarkRequest(w http.ResponseWriter, r *http.Request, body *bookmarkRequest) bool {
+	err := json.NewDecoder(r.Body).Decode(body)
+	if err != nil {
+		hlog.FromRequest(r).Debug().Err(err).Msg("Could not decode bookmark.")
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Could not process request. Please provide the bookmark as JSON.")
+		return false
+	}
+
+	if body.TrackURI != "" && !strings.HasPrefix(body.TrackURI, "spotify:track:") && !strings.HasPrefix(body.TrackURI, "spotify:episode:") {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "'trackURI' has to be the URI of a track or an episode.")
+		return false
+	}
+
+	if body.Progress < 0 {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "'progress' must not be negative.")
+		return false
+	}
+
+	return true
+}
+
+// findBookmark responds with 404 in case either the slot or the bookmark does not exist
+func findBookmark(w http.ResponseWriter, r *http.Request, playerStates []*persistence.PlayerState, slot, bookmarkID string) (*persistence.PlayerState, *persistence.Bookmark) {
+	idx := indexOfSlot(w, playerStates, slot)
+	if idx < 0 {
+		hlog.FromRequest(r).Debug().Str("slot", slot).Msg("Slot does not exist.")
+		problem.Respond(w, r, http.StatusNotFound, problem.CodeSlotOutOfRange, "'slot' does not refer to an existing slot.")
+		return nil, nil
+	}
+
+	for _, bookmark := range playerStates[idx].Bookmarks {
+		if bookmark.ID == bookmarkID {
+			return playerStates[idx], bookmark
+		}
+	}
+
+	hlog.FromRequest(r).Debug().Str("slot", slot).Str("bookmark", bookmarkID).Msg("Bookmark does not exist.")
+	problem.Respond(w, r, http.StatusNotFound, problem.CodeBookmarkNotFound, "'bookmark' does not refer to an existing bookmark.")
+
+	return nil, nil
+}
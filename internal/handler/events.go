@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/events"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/rs/zerolog/hlog"
+	spotifyAPI "github.com/zmb3/spotify"
+)
+
+// EventsHandler streams the events of the user as Server-Sent Events until the client disconnects
+func EventsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	bus := ctx.Value(constants.FieldKeyEventBus).(events.Bus)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		hlog.FromRequest(r).Error().Msg("Response writer does not support streaming.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Streaming events is not supported.")
+		return
+	}
+
+	subscription, unsubscribe := bus.Subscribe(user.ID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Disables buffering by nginx, otherwise events would be delayed
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(constants.EventsHeartbeatInterval * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			_, err := fmt.Fprint(w, ": heartbeat\n\n")
+			if err != nil {
+				return
+			}
+		case event := <-subscription:
+			data, err := json.Marshal(event)
+			if err != nil {
+				// Cannot happen, the event consists of strings and numbers only
+				hlog.FromRequest(r).Error().Err(err).Msg("Could not serialize event.")
+				continue
+			}
+
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			if err != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
+
+// publish notifies the clients of the user about the change made by the request
+func publish(r *http.Request, eventType events.Type, slot string) {
+	publishEvent(r, events.New(eventType, r.Context().Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser).ID, slot))
+}
+
+func publishEvent(r *http.Request, event events.Event) {
+	bus := r.Context().Value(constants.FieldKeyEventBus).(events.Bus)
+
+	bus.Publish(event)
+}
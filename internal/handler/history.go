@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/rs/zerolog/hlog"
+	spotifyAPI "github.com/zmb3/spotify"
+)
+
+// HistoryGetHandler lists the slots suspended and restored by the user, the latest events come first
+func HistoryGetHandler(w http.ResponseWriter, r *http.Request) {
+	history, _, ok := loadHistory(w, r)
+	if !ok {
+		return
+	}
+
+	latestFirst := make([]*persistence.HistoryEvent, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		latestFirst = append(latestFirst, history[i])
+	}
+
+	respondWithHistory(w, r, latestFirst)
+}
+
+// HistoryStatsGetHandler summarizes the history per album, playlist, episode resp. single track
+func HistoryStatsGetHandler(w http.ResponseWriter, r *http.Request) {
+	history, aggregates, ok := loadHistory(w, r)
+	if !ok {
+		return
+	}
+
+	respondWithHistory(w, r, persistence.HistoryStats(aggregates, history))
+}
+
+// recordHistory appends the event to the history of the user. Failing to do so does not fail the request, the
+// slot has been suspended resp. restored after all.
+func recordHistory(r *http.Request, eventType persistence.HistoryEventType, playerState *persistence.PlayerState, bookmark string) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.HistoryStore)
+
+	at := time.Now()
+	if eventType == persistence.HistorySuspended {
+		at = time.Unix(playerState.SuspendedAtTs, 0)
+	}
+
+	event := persistence.NewHistoryEvent(eventType, playerState, at)
+	event.Bookmark = bookmark
+
+	err := dao.AppendHistoryEvent(user.ID, event)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Str("type", string(eventType)).Msg("Failed recording history.")
+	}
+}
+
+func loadHistory(w http.ResponseWriter, r *http.Request) ([]*persistence.HistoryEvent, []*persistence.HistoryAggregate, bool) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.HistoryStore)
+
+	history, aggregates, err := dao.LoadHistory(user.ID)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed loading history from DB.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not retrieve history from DB.")
+		return nil, nil, false
+	}
+
+	return history, aggregates, true
+}
+
+func respondWithHistory(w http.ResponseWriter, r *http.Request, history interface{}) {
+	json, err := json.Marshal(history)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Could not serialize history.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Failed to provide history as JSON.")
+		return
+	}
+
+	respondWithJSON(w, r, json)
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/events"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/rs/zerolog/hlog"
+	spotifyAPI "github.com/zmb3/spotify"
+)
+
+// labelsRequest edits the fields of a slot set by the user, fields missing are left unchanged while empty ones
+// clear the field
+type labelsRequest struct {
+	Title  *string   `json:"title"`
+	Notes  *string   `json:"notes"`
+	Tags   *[]string `json:"tags"`
+	Pinned *bool     `json:"pinned"`
+}
+
+// PlayerStatesPatchHandler edits the custom title, the notes, the tags resp. pins the slot
+func PlayerStatesPatchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.PlayerStatesPersistor)
+	slot := ctx.Value(constants.FieldKeySlot).(string)
+
+	var body labelsRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		hlog.FromRequest(r).Debug().Err(err).Msg("Could not decode labels.")
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Could not process request. Please provide the fields to edit as JSON.")
+		return
+	}
+
+	if body.Title != nil && len(strings.TrimSpace(*body.Title)) > constants.MaxTitleLength {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, fmt.Sprintf("'title' must not be longer than %d characters.", constants.MaxTitleLength))
+		return
+	}
+
+	if body.Notes != nil && len(strings.TrimSpace(*body.Notes)) > constants.MaxNotesLength {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, fmt.Sprintf("'notes' must not be longer than %d characters.", constants.MaxNotesLength))
+		return
+	}
+
+	var tags []string
+	if body.Tags != nil {
+		var ok bool
+		tags, ok = normalizeTags(w, r, *body.Tags)
+		if !ok {
+			return
+		}
+	}
+
+	playerStates, revision, ok := loadPlayerStates(w, r, dao, user.ID)
+	if !ok || !checkIfMatch(w, r, revision) {
+		return
+	}
+
+	idx := indexOfSlot(w, playerStates, slot)
+	if idx < 0 {
+		hlog.FromRequest(r).Debug().Str("slot", slot).Msg("Slot does not exist.")
+		problem.Respond(w, r, http.StatusNotFound, problem.CodeSlotOutOfRange, "'slot' does not refer to an existing slot.")
+		return
+	}
+
+	playerState := playerStates[idx]
+
+	if body.Title != nil {
+		playerState.Title = strings.TrimSpace(*body.Title)
+	}
+
+	if body.Notes != nil {
+		playerState.Notes = strings.TrimSpace(*body.Notes)
+	}
+
+	if body.Tags != nil {
+		playerState.Tags = tags
+	}
+
+	if body.Pinned != nil {
+		playerState.Pinned = *body.Pinned
+	}
+
+	if savePlayerStates(w, r, dao, user.ID, playerStates, revision) {
+		publish(r, events.SlotUpdated, playerState.ID)
+	}
+}
+
+// normalizeTags trims the tags and drops duplicates, it responds with 400 in case a tag is empty, too long or
+// there are too many of them
+func normalizeTags(w http.ResponseWriter, r *http.Request, tags []string) ([]string, bool) {
+	normalized := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || len(tag) > constants.MaxTagLength {
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, fmt.Sprintf("'tags' must not be empty and not be longer than %d characters.", constants.MaxTagLength))
+			return nil, false
+		}
+
+		duplicate := false
+		for _, cur := range normalized {
+			duplicate = duplicate || strings.EqualFold(cur, tag)
+		}
+
+		if !duplicate {
+			normalized = append(normalized, tag)
+		}
+	}
+
+	if len(normalized) > constants.MaxTagsPerSlot {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, fmt.Sprintf("A slot must not have more than %d tags.", constants.MaxTagsPerSlot))
+		return nil, false
+	}
+
+	if len(normalized) == 0 {
+		return nil, true
+	}
+
+	return normalized, true
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+//go:generate go run openapi_gen.go
+
+// OpenAPIHandler serves the OpenAPI document describing the API. The contract tests check the
+// responses of the API against it, so changes to the API have to be reflected there.
+// The document is compiled into the binary, run 'go generate ./internal/handler' after editing api/openapi.json.
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	_, err := w.Write([]byte(openAPIDocument))
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed to write OpenAPI document.")
+	}
+}
@@ -0,0 +1,3142 @@
+// Code generated by openapi_gen.go from api/openapi.json; DO NOT EDIT.
+
+package handler
+
+// openAPIDocument is served at /api/openapi.json, it is edited in api/openapi.json
+const openAPIDocument = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Cassette",
+    "description": "Suspend the playback of audiobooks, podcasts and playlists on Spotify and restore it later on. Requests are either authenticated by the session established by signing in with Spotify via the web app, then requests changing state additionally require the CSRF token to be sent in the X-Cassette-CSRF header, or by a personal access token (API token) being sent as bearer token. API tokens only grant access to the operations matching their scopes: 'read', 'suspend' resp. 'restore'.",
+    "version": "1"
+  },
+  "servers": [
+    {
+      "url": "/api"
+    }
+  ],
+  "security": [
+    {
+      "session": []
+    }
+  ],
+  "paths": {
+    "/openapi.json": {
+      "get": {
+        "summary": "This document",
+        "operationId": "getOpenAPIDocument",
+        "responses": {
+          "200": {
+            "description": "The OpenAPI document",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              }
+            }
+          }
+        },
+        "security": []
+      }
+    },
+    "/csrfToken": {
+      "head": {
+        "summary": "Fetch the CSRF token",
+        "operationId": "fetchCSRFToken",
+        "responses": {
+          "200": {
+            "description": "The token is given in the header, it has to be sent along with all requests changing state",
+            "headers": {
+              "X-Cassette-CSRF": {
+                "required": true,
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          }
+        },
+        "security": []
+      }
+    },
+    "/you": {
+      "get": {
+        "summary": "Export all data stored for the user",
+        "operationId": "exportUserData",
+        "description": "Can be accessed using API tokens having the scope 'read'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Everything stored for the user",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/UserRecord"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "delete": {
+        "summary": "Delete all data stored for the user once the grace period is over, without a grace period right away",
+        "operationId": "deleteUserData",
+        "responses": {
+          "200": {
+            "description": "Data has been deleted, only without a grace period"
+          },
+          "202": {
+            "description": "Deletion has been scheduled, requesting it again does not postpone it",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/AccountDeletion"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/you/deletion": {
+      "get": {
+        "summary": "Get when the deletion of all data stored for the user is carried out",
+        "operationId": "fetchAccountDeletion",
+        "responses": {
+          "200": {
+            "description": "The deletion scheduled",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/AccountDeletion"
+                }
+              }
+            }
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "delete": {
+        "summary": "Cancel the deletion of all data stored for the user",
+        "operationId": "cancelAccountDeletion",
+        "responses": {
+          "204": {
+            "description": "Deletion has been cancelled"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/you/token": {
+      "delete": {
+        "summary": "Revoke the access to Spotify, this ends all sessions of the user",
+        "operationId": "revokeToken",
+        "responses": {
+          "204": {
+            "description": "Token has been deleted"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/you/autoSave": {
+      "get": {
+        "summary": "Check whether the progress gets saved in the background",
+        "operationId": "fetchAutoSaveEnabled",
+        "responses": {
+          "200": {
+            "description": "The setting",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/AutoSaveSetting"
+                }
+              }
+            }
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "put": {
+        "summary": "Save the progress in the background",
+        "operationId": "enableAutoSave",
+        "responses": {
+          "204": {
+            "description": "Auto save has been enabled"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "delete": {
+        "summary": "Stop saving the progress in the background, slots saved already are kept",
+        "operationId": "disableAutoSave",
+        "responses": {
+          "204": {
+            "description": "Auto save has been disabled"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/you/timeZone": {
+      "get": {
+        "summary": "Fetch the time zone schedules are evaluated in",
+        "operationId": "fetchTimeZone",
+        "responses": {
+          "200": {
+            "description": "The time zone, 'UTC' unless set",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/TimeZoneSetting"
+                }
+              }
+            }
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "put": {
+        "summary": "Set the time zone schedules are evaluated in",
+        "operationId": "setTimeZone",
+        "description": "The next runs of all schedules get planned anew.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/TimeZoneSetting"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "The time zone",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/TimeZoneSetting"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/you/preferences": {
+      "get": {
+        "summary": "Fetch the preferences applied when restoring slots",
+        "operationId": "fetchPreferences",
+        "responses": {
+          "200": {
+            "description": "The preferences, the defaults unless set",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Preferences"
+                }
+              }
+            }
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "put": {
+        "summary": "Replace the preferences applied when restoring slots",
+        "operationId": "setPreferences",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/PreferencesRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "The preferences",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Preferences"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/you/apiTokens": {
+      "get": {
+        "summary": "List the API tokens of the user",
+        "operationId": "fetchAPITokens",
+        "responses": {
+          "200": {
+            "description": "The API tokens, without the tokens themselves",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/APIToken"
+                  }
+                }
+              }
+            }
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "post": {
+        "summary": "Create an API token",
+        "operationId": "createAPIToken",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/APITokenRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "The API token, this is the only time the token itself is given",
+            "headers": {
+              "Location": {
+                "required": true,
+                "description": "The new API token",
+                "schema": {
+                  "type": "string"
+                }
+              }
+            },
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/CreatedAPIToken"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/you/apiTokens/{apiToken}": {
+      "parameters": [
+        {
+          "$ref": "#/components/parameters/APIToken"
+        }
+      ],
+      "delete": {
+        "summary": "Revoke the API token",
+        "operationId": "revokeAPIToken",
+        "responses": {
+          "204": {
+            "description": "API token has been revoked"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/activeDevices": {
+      "get": {
+        "summary": "List the devices the playback can be restored on",
+        "operationId": "fetchActiveDevices",
+        "description": "Can be accessed using API tokens having the scope 'read'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The devices",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/Device"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/SpotifyUnavailable"
+          }
+        }
+      }
+    },
+    "/events": {
+      "get": {
+        "summary": "Stream changes of the slots as Server-Sent Events",
+        "operationId": "streamEvents",
+        "description": "Can be accessed using API tokens having the scope 'read'. The stream stays open until the client disconnects. Every event is named after its type, its data is an Event. Comments are sent regularly to keep the connection alive. Delivery is best-effort, clients should reload the slots when reconnecting.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The stream of events",
+            "content": {
+              "text/event-stream": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/SpotifyUnavailable"
+          }
+        }
+      }
+    },
+    "/history": {
+      "get": {
+        "summary": "List the slots suspended and restored, the latest events come first",
+        "operationId": "fetchHistory",
+        "description": "Can be accessed using API tokens having the scope 'read'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The history of the user",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/HistoryEvent"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/history/stats": {
+      "get": {
+        "summary": "Summarize the history per album, playlist, episode resp. single track",
+        "operationId": "fetchHistoryStats",
+        "description": "Can be accessed using API tokens having the scope 'read'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The stats, the contexts touched most recently come first",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/ContextStats"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/archive": {
+      "get": {
+        "summary": "List the slots having been listened to completely, the ones completed most recently come first",
+        "operationId": "fetchArchive",
+        "description": "Can be accessed using API tokens having the scope 'read'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The archived slots",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/ArchivedSlot"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "delete": {
+        "summary": "Purge all archived slots",
+        "operationId": "purgeArchive",
+        "responses": {
+          "204": {
+            "description": "Archive has been purged"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/archive/{slot}": {
+      "parameters": [
+        {
+          "$ref": "#/components/parameters/ArchivedSlot"
+        }
+      ],
+      "get": {
+        "summary": "Fetch the archived slot",
+        "operationId": "fetchArchivedSlot",
+        "description": "Can be accessed using API tokens having the scope 'read'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The archived slot",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ArchivedSlot"
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "delete": {
+        "summary": "Purge the archived slot",
+        "operationId": "purgeArchivedSlot",
+        "responses": {
+          "204": {
+            "description": "Archived slot has been purged"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/archive/{slot}/unarchive": {
+      "parameters": [
+        {
+          "$ref": "#/components/parameters/ArchivedSlot"
+        }
+      ],
+      "post": {
+        "summary": "Move the archived slot back to the end of the slots",
+        "operationId": "unarchiveSlot",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/IfMatch"
+          }
+        ],
+        "responses": {
+          "201": {
+            "description": "Slot has been unarchived",
+            "headers": {
+              "ETag": {
+                "$ref": "#/components/headers/ETag"
+              },
+              "Location": {
+                "required": true,
+                "description": "The slot unarchived",
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "412": {
+            "$ref": "#/components/responses/PreconditionFailed"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/trash": {
+      "get": {
+        "summary": "List the slots deleted but not purged yet, the ones deleted most recently come first",
+        "operationId": "fetchTrash",
+        "description": "Can be accessed using API tokens having the scope 'read'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The slots in the trash",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/TrashedSlot"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "delete": {
+        "summary": "Purge all slots in the trash right away",
+        "operationId": "purgeTrash",
+        "responses": {
+          "204": {
+            "description": "Trash has been purged"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/trash/{slot}": {
+      "parameters": [
+        {
+          "$ref": "#/components/parameters/TrashedSlot"
+        }
+      ],
+      "get": {
+        "summary": "Fetch the slot in the trash",
+        "operationId": "fetchTrashedSlot",
+        "description": "Can be accessed using API tokens having the scope 'read'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The slot in the trash",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/TrashedSlot"
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "delete": {
+        "summary": "Purge the slot in the trash right away",
+        "operationId": "purgeTrashedSlot",
+        "responses": {
+          "204": {
+            "description": "Slot has been purged"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/trash/{slot}/undelete": {
+      "parameters": [
+        {
+          "$ref": "#/components/parameters/TrashedSlot"
+        }
+      ],
+      "post": {
+        "summary": "Move the slot in the trash back to the end of the slots",
+        "operationId": "undeleteSlot",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/IfMatch"
+          }
+        ],
+        "responses": {
+          "201": {
+            "description": "Slot has been undeleted",
+            "headers": {
+              "ETag": {
+                "$ref": "#/components/headers/ETag"
+              },
+              "Location": {
+                "required": true,
+                "description": "The slot undeleted",
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "412": {
+            "$ref": "#/components/responses/PreconditionFailed"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/sleepTimer": {
+      "get": {
+        "summary": "Fetch the sleep timer",
+        "operationId": "fetchSleepTimer",
+        "description": "Can be accessed using API tokens having the scope 'read'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The sleep timer",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/SleepTimer"
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/SpotifyUnavailable"
+          }
+        }
+      },
+      "put": {
+        "summary": "Set the sleep timer, replacing the previous one",
+        "operationId": "setSleepTimer",
+        "description": "Can be accessed using API tokens having the scope 'suspend'. When firing, the playback gets suspended into the given slot (resp. a new one) and paused afterwards.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/SleepTimerRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "The sleep timer",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/SleepTimer"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/SpotifyUnavailable"
+          }
+        }
+      },
+      "delete": {
+        "summary": "Cancel the sleep timer",
+        "operationId": "cancelSleepTimer",
+        "description": "Can be accessed using API tokens having the scope 'suspend'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "Sleep timer has been cancelled"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/SpotifyUnavailable"
+          }
+        }
+      }
+    },
+    "/schedules": {
+      "get": {
+        "summary": "List the schedules",
+        "operationId": "fetchSchedules",
+        "description": "Can be accessed using API tokens having the scope 'read'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The schedules along with the time zone they are evaluated in",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Schedules"
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/SpotifyUnavailable"
+          }
+        }
+      },
+      "post": {
+        "summary": "Create a schedule restoring a slot onto a device",
+        "operationId": "createSchedule",
+        "description": "Can be accessed using API tokens having the scope 'restore'. The device has to be available when creating the schedule.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/ScheduleRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "The schedule",
+            "headers": {
+              "Location": {
+                "required": true,
+                "description": "The new schedule",
+                "schema": {
+                  "type": "string"
+                }
+              }
+            },
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Schedule"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/SpotifyUnavailable"
+          }
+        }
+      }
+    },
+    "/schedules/{schedule}": {
+      "parameters": [
+        {
+          "$ref": "#/components/parameters/Schedule"
+        }
+      ],
+      "get": {
+        "summary": "Fetch the schedule",
+        "operationId": "fetchSchedule",
+        "description": "Can be accessed using API tokens having the scope 'read'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The schedule",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Schedule"
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/SpotifyUnavailable"
+          }
+        }
+      },
+      "put": {
+        "summary": "Replace the schedule",
+        "operationId": "replaceSchedule",
+        "description": "Can be accessed using API tokens having the scope 'restore'. The next run gets planned anew.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/ScheduleRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "The schedule",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Schedule"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/SpotifyUnavailable"
+          }
+        }
+      },
+      "delete": {
+        "summary": "Delete the schedule",
+        "operationId": "deleteSchedule",
+        "description": "Can be accessed using API tokens having the scope 'restore'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "Schedule has been deleted"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/SpotifyUnavailable"
+          }
+        }
+      }
+    },
+    "/playerStates": {
+      "get": {
+        "summary": "List the slots",
+        "operationId": "fetchPlayerStates",
+        "description": "Can be accessed using API tokens having the scope 'read'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "parameters": [
+          {
+            "name": "sort",
+            "in": "query",
+            "required": false,
+            "description": "'manual' keeps the order chosen by the user, 'recent' lists the slots suspended most recently first, 'title' sorts alphabetically by the custom title resp. the name of the context, 'progress' lists the slots listened to the most first. Pinned slots always come first.",
+            "schema": {
+              "type": "string",
+              "enum": [
+                "manual",
+                "recent",
+                "title",
+                "progress"
+              ],
+              "default": "manual"
+            }
+          },
+          {
+            "name": "tag",
+            "in": "query",
+            "required": false,
+            "description": "Only list the slots having this tag, compared case-insensitively. Can be given multiple times to require all of the tags.",
+            "schema": {
+              "type": "array",
+              "items": {
+                "type": "string"
+              }
+            },
+            "style": "form",
+            "explode": true
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The slots",
+            "headers": {
+              "ETag": {
+                "$ref": "#/components/headers/ETag"
+              }
+            },
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/PlayerState"
+                  }
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "post": {
+        "summary": "Suspend the current playback into a new slot, resp. the slot saved in the background for its context",
+        "operationId": "storePlayerState",
+        "description": "Can be accessed using API tokens having the scope 'suspend'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/IfMatch"
+          }
+        ],
+        "responses": {
+          "201": {
+            "$ref": "#/components/responses/SlotCreated"
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "412": {
+            "$ref": "#/components/responses/PreconditionFailed"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/SpotifyUnavailable"
+          }
+        }
+      }
+    },
+    "/playerStates/order": {
+      "put": {
+        "summary": "Replace the manual order of the slots at once",
+        "operationId": "reorderPlayerStates",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/IfMatch"
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/OrderRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "$ref": "#/components/responses/Saved"
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "412": {
+            "$ref": "#/components/responses/PreconditionFailed"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/playerStates/{slot}": {
+      "parameters": [
+        {
+          "$ref": "#/components/parameters/Slot"
+        }
+      ],
+      "put": {
+        "summary": "Suspend the current playback into the slot, its labels are kept, its bookmarks only for the same context",
+        "operationId": "updatePlayerState",
+        "description": "Can be accessed using API tokens having the scope 'suspend'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/IfMatch"
+          }
+        ],
+        "responses": {
+          "201": {
+            "$ref": "#/components/responses/SlotCreated"
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "412": {
+            "$ref": "#/components/responses/PreconditionFailed"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/SpotifyUnavailable"
+          }
+        }
+      },
+      "patch": {
+        "summary": "Edit the custom title, the notes, the tags resp. pin the slot",
+        "operationId": "editPlayerState",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/IfMatch"
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/LabelsRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "$ref": "#/components/responses/Saved"
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "412": {
+            "$ref": "#/components/responses/PreconditionFailed"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "delete": {
+        "summary": "Delete the slot, it is moved to the trash unless the trash is disabled",
+        "operationId": "deletePlayerState",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/IfMatch"
+          }
+        ],
+        "responses": {
+          "200": {
+            "$ref": "#/components/responses/Saved"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "412": {
+            "$ref": "#/components/responses/PreconditionFailed"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/playerStates/{slot}/restore": {
+      "parameters": [
+        {
+          "$ref": "#/components/parameters/Slot"
+        }
+      ],
+      "post": {
+        "summary": "Restore the playback from the slot",
+        "operationId": "restoreFromPlayerState",
+        "description": "Can be accessed using API tokens having the scope 'restore'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/DeviceID"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Playback has been restored"
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/SpotifyUnavailable"
+          }
+        }
+      }
+    },
+    "/playerStates/{slot}/jumpBack": {
+      "parameters": [
+        {
+          "$ref": "#/components/parameters/Slot"
+        }
+      ],
+      "put": {
+        "summary": "Set how far to jump back when restoring the slot, overriding the preferences",
+        "operationId": "setJumpBackOfPlayerState",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/IfMatch"
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/JumpBackRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "$ref": "#/components/responses/Saved"
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "412": {
+            "$ref": "#/components/responses/PreconditionFailed"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "delete": {
+        "summary": "Drop the jump-back of the slot, the one of the preferences applies again",
+        "operationId": "dropJumpBackOfPlayerState",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/IfMatch"
+          }
+        ],
+        "responses": {
+          "200": {
+            "$ref": "#/components/responses/Saved"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "412": {
+            "$ref": "#/components/responses/PreconditionFailed"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/playerStates/{slot}/bookmarks": {
+      "parameters": [
+        {
+          "$ref": "#/components/parameters/Slot"
+        }
+      ],
+      "get": {
+        "summary": "List the bookmarks of the slot",
+        "operationId": "fetchBookmarks",
+        "description": "Can be accessed using API tokens having the scope 'read'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The bookmarks",
+            "headers": {
+              "ETag": {
+                "$ref": "#/components/headers/ETag"
+              }
+            },
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/Bookmark"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "post": {
+        "summary": "Create a bookmark, without a trackURI the current position of the playback is used",
+        "operationId": "storeBookmark",
+        "description": "Can be accessed using API tokens having the scope 'suspend'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/IfMatch"
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/BookmarkRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Bookmark has been created",
+            "headers": {
+              "ETag": {
+                "$ref": "#/components/headers/ETag"
+              },
+              "Location": {
+                "required": true,
+                "description": "The new bookmark",
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "412": {
+            "$ref": "#/components/responses/PreconditionFailed"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/SpotifyUnavailable"
+          }
+        }
+      }
+    },
+    "/playerStates/{slot}/bookmarks/{bookmark}": {
+      "parameters": [
+        {
+          "$ref": "#/components/parameters/Slot"
+        },
+        {
+          "$ref": "#/components/parameters/Bookmark"
+        }
+      ],
+      "put": {
+        "summary": "Rename the bookmark resp. change its note, its position cannot be changed",
+        "operationId": "updateBookmark",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/IfMatch"
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/BookmarkRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "$ref": "#/components/responses/Saved"
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "412": {
+            "$ref": "#/components/responses/PreconditionFailed"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "delete": {
+        "summary": "Delete the bookmark",
+        "operationId": "deleteBookmark",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/IfMatch"
+          }
+        ],
+        "responses": {
+          "200": {
+            "$ref": "#/components/responses/Saved"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "412": {
+            "$ref": "#/components/responses/PreconditionFailed"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/playerStates/{slot}/bookmarks/{bookmark}/restore": {
+      "parameters": [
+        {
+          "$ref": "#/components/parameters/Slot"
+        },
+        {
+          "$ref": "#/components/parameters/Bookmark"
+        }
+      ],
+      "post": {
+        "summary": "Restore the playback from the bookmark",
+        "operationId": "restoreFromBookmark",
+        "description": "Can be accessed using API tokens having the scope 'restore'.",
+        "security": [
+          {
+            "session": []
+          },
+          {
+            "apiToken": []
+          }
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/DeviceID"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Playback has been restored"
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/SpotifyUnavailable"
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "session": {
+        "type": "apiKey",
+        "in": "cookie",
+        "name": "cassette_session"
+      },
+      "apiToken": {
+        "type": "http",
+        "scheme": "bearer",
+        "description": "Personal access token created at /you/apiTokens"
+      }
+    },
+    "parameters": {
+      "Slot": {
+        "name": "slot",
+        "in": "path",
+        "required": true,
+        "description": "ID of the slot. Its index is accepted as well, this is deprecated.",
+        "schema": {
+          "type": "string"
+        }
+      },
+      "Bookmark": {
+        "name": "bookmark",
+        "in": "path",
+        "required": true,
+        "description": "ID of the bookmark",
+        "schema": {
+          "type": "string"
+        }
+      },
+      "IfMatch": {
+        "name": "If-Match",
+        "in": "header",
+        "required": false,
+        "description": "ETag of the slots known to the client, the request fails with 412 in case they have been modified in the meantime",
+        "schema": {
+          "type": "string"
+        }
+      },
+      "DeviceID": {
+        "name": "deviceID",
+        "in": "query",
+        "required": false,
+        "description": "ID of the device to restore the playback on, defaults to the active device",
+        "schema": {
+          "type": "string"
+        }
+      },
+      "APIToken": {
+        "name": "apiToken",
+        "in": "path",
+        "required": true,
+        "description": "ID of the API token",
+        "schema": {
+          "type": "string"
+        }
+      },
+      "Schedule": {
+        "name": "schedule",
+        "in": "path",
+        "required": true,
+        "description": "ID of the schedule",
+        "schema": {
+          "type": "string"
+        }
+      },
+      "ArchivedSlot": {
+        "name": "slot",
+        "in": "path",
+        "required": true,
+        "description": "ID of the archived slot",
+        "schema": {
+          "type": "string"
+        }
+      },
+      "TrashedSlot": {
+        "name": "slot",
+        "in": "path",
+        "required": true,
+        "description": "ID of the slot in the trash",
+        "schema": {
+          "type": "string"
+        }
+      }
+    },
+    "headers": {
+      "ETag": {
+        "required": true,
+        "description": "Revision of the slots",
+        "schema": {
+          "type": "string"
+        }
+      }
+    },
+    "responses": {
+      "Saved": {
+        "description": "Slots have been saved",
+        "headers": {
+          "ETag": {
+            "$ref": "#/components/headers/ETag"
+          }
+        }
+      },
+      "SlotCreated": {
+        "description": "Playback has been suspended",
+        "headers": {
+          "ETag": {
+            "$ref": "#/components/headers/ETag"
+          },
+          "Location": {
+            "required": true,
+            "description": "The slot the playback has been suspended into, resp. the archived slot in case the playback has been at the end of its last track",
+            "schema": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "BadRequest": {
+        "description": "The request cannot be processed, e.g., because of code 'no_active_device', 'context_not_suspendable' or 'slot_out_of_range'",
+        "content": {
+          "application/problem+json": {
+            "schema": {
+              "$ref": "#/components/schemas/Problem"
+            }
+          }
+        }
+      },
+      "Forbidden": {
+        "description": "Not logged in (code 'not_logged_in'), the CSRF token is missing (code 'invalid_csrf_token') resp. the API token lacks the scope required (code 'insufficient_scope')",
+        "content": {
+          "application/problem+json": {
+            "schema": {
+              "$ref": "#/components/schemas/Problem"
+            }
+          }
+        }
+      },
+      "NotFound": {
+        "description": "The slot, the bookmark, the API token resp. the schedule does not exist",
+        "content": {
+          "application/problem+json": {
+            "schema": {
+              "$ref": "#/components/schemas/Problem"
+            }
+          }
+        }
+      },
+      "Conflict": {
+        "description": "The slots have been modified concurrently",
+        "content": {
+          "application/problem+json": {
+            "schema": {
+              "$ref": "#/components/schemas/Problem"
+            }
+          }
+        }
+      },
+      "PreconditionFailed": {
+        "description": "The slots have been modified since the client fetched them",
+        "content": {
+          "application/problem+json": {
+            "schema": {
+              "$ref": "#/components/schemas/Problem"
+            }
+          }
+        }
+      },
+      "InternalError": {
+        "description": "Cassette resp. Spotify failed",
+        "content": {
+          "application/problem+json": {
+            "schema": {
+              "$ref": "#/components/schemas/Problem"
+            }
+          }
+        }
+      },
+      "SpotifyUnavailable": {
+        "description": "Spotify is not available for the time being, e.g., because of rate limiting",
+        "headers": {
+          "Retry-After": {
+            "required": true,
+            "description": "Seconds to wait before trying again",
+            "schema": {
+              "type": "integer"
+            }
+          }
+        },
+        "content": {
+          "application/problem+json": {
+            "schema": {
+              "$ref": "#/components/schemas/Problem"
+            }
+          }
+        }
+      },
+      "TooManyRequests": {
+        "description": "The user has sent too many requests to Spotify and has to wait before trying again",
+        "headers": {
+          "Retry-After": {
+            "required": true,
+            "description": "Seconds to wait before trying again",
+            "schema": {
+              "type": "integer"
+            }
+          }
+        },
+        "content": {
+          "application/problem+json": {
+            "schema": {
+              "$ref": "#/components/schemas/Problem"
+            }
+          }
+        }
+      },
+      "Unauthorized": {
+        "description": "The API token is invalid resp. has been revoked (code 'invalid_api_token')",
+        "headers": {
+          "WWW-Authenticate": {
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        },
+        "content": {
+          "application/problem+json": {
+            "schema": {
+              "$ref": "#/components/schemas/Problem"
+            }
+          }
+        }
+      }
+    },
+    "schemas": {
+      "Problem": {
+        "type": "object",
+        "description": "RFC 7807 problem details",
+        "required": [
+          "type",
+          "title",
+          "status",
+          "code"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "type": {
+            "type": "string"
+          },
+          "title": {
+            "type": "string"
+          },
+          "status": {
+            "type": "integer"
+          },
+          "detail": {
+            "type": "string",
+            "description": "Meant to be shown to the user"
+          },
+          "code": {
+            "type": "string",
+            "description": "Identifies the kind of problem, it does not change",
+            "enum": [
+              "internal_error",
+              "invalid_request",
+              "not_found",
+              "method_not_allowed",
+              "invalid_session",
+              "not_logged_in",
+              "invalid_api_token",
+              "insufficient_scope",
+              "invalid_csrf_token",
+              "oauth_failed",
+              "no_data_stored",
+              "slot_out_of_range",
+              "bookmark_not_found",
+              "api_token_not_found",
+              "sleep_timer_not_found",
+              "schedule_not_found",
+              "archived_slot_not_found",
+              "trashed_slot_not_found",
+              "deletion_not_found",
+              "modified_concurrently",
+              "context_not_suspendable",
+              "context_mismatch",
+              "no_active_device",
+              "nothing_playing",
+              "playback_failed",
+              "request_budget_exceeded",
+              "spotify_unavailable",
+              "spotify_error"
+            ]
+          }
+        }
+      },
+      "Device": {
+        "type": "object",
+        "required": [
+          "id",
+          "name",
+          "active"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "active": {
+            "type": "boolean"
+          }
+        }
+      },
+      "Event": {
+        "type": "object",
+        "required": [
+          "type",
+          "slot",
+          "ts"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "type": {
+            "type": "string",
+            "enum": [
+              "slot-created",
+              "slot-updated",
+              "slot-deleted",
+              "slot-archived",
+              "playback-restored",
+              "slots-reordered"
+            ]
+          },
+          "slot": {
+            "type": "string",
+            "description": "ID of the slot affected, empty for 'slots-reordered'"
+          },
+          "bookmark": {
+            "type": "string",
+            "description": "ID of the bookmark restored, only given for 'playback-restored'"
+          },
+          "ts": {
+            "type": "integer",
+            "format": "int64"
+          }
+        }
+      },
+      "SleepTimer": {
+        "type": "object",
+        "required": [
+          "mode",
+          "firesAtTs",
+          "createdAtTs"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "mode": {
+            "type": "string",
+            "enum": [
+              "duration",
+              "trackEnd"
+            ]
+          },
+          "slot": {
+            "type": "string",
+            "description": "Slot the playback gets suspended into, a new one if not given"
+          },
+          "firesAtTs": {
+            "type": "integer",
+            "format": "int64",
+            "description": "Preliminary when mode is 'trackEnd', it gets postponed in case the track is still playing"
+          },
+          "createdAtTs": {
+            "type": "integer",
+            "format": "int64"
+          }
+        }
+      },
+      "SleepTimerRequest": {
+        "type": "object",
+        "required": [
+          "mode"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "mode": {
+            "type": "string",
+            "enum": [
+              "duration",
+              "trackEnd"
+            ],
+            "description": "Either fire after the given minutes or at the end of the track currently playing"
+          },
+          "minutes": {
+            "type": "integer",
+            "minimum": 1,
+            "maximum": 1440,
+            "description": "Required when mode is 'duration'"
+          },
+          "slot": {
+            "type": "string",
+            "description": "Slot to suspend the playback into, a new one if not given"
+          }
+        }
+      },
+      "Schedule": {
+        "type": "object",
+        "required": [
+          "id",
+          "slot",
+          "deviceID",
+          "deviceName",
+          "cron",
+          "missedRun",
+          "nextRunAtTs",
+          "createdAtTs"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "slot": {
+            "type": "string",
+            "description": "ID of the slot getting restored"
+          },
+          "deviceID": {
+            "type": "string"
+          },
+          "deviceName": {
+            "type": "string",
+            "description": "Name of the device when the schedule has been created"
+          },
+          "cron": {
+            "type": "string",
+            "description": "Minute, hour, day of month, month and day of week, e.g., '30 7 * * 1-5' for 07:30 on weekdays"
+          },
+          "missedRun": {
+            "type": "string",
+            "enum": [
+              "skip",
+              "catchUp"
+            ],
+            "description": "Whether a run missed, e.g., because of maintenance, gets skipped or caught up on as soon as possible"
+          },
+          "nextRunAtTs": {
+            "type": "integer",
+            "format": "int64",
+            "description": "0 in case the schedule does not run anymore"
+          },
+          "lastRunAtTs": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "createdAtTs": {
+            "type": "integer",
+            "format": "int64"
+          }
+        }
+      },
+      "ScheduleRequest": {
+        "type": "object",
+        "required": [
+          "slot",
+          "deviceID",
+          "cron"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "slot": {
+            "type": "string",
+            "description": "Slot to restore"
+          },
+          "deviceID": {
+            "type": "string",
+            "description": "Device to restore the slot onto, see /activeDevices"
+          },
+          "cron": {
+            "type": "string",
+            "description": "Minute, hour, day of month, month and day of week, e.g., '30 7 * * 1-5' for 07:30 on weekdays"
+          },
+          "missedRun": {
+            "type": "string",
+            "enum": [
+              "skip",
+              "catchUp"
+            ],
+            "description": "Defaults to 'skip'"
+          }
+        }
+      },
+      "Schedules": {
+        "type": "object",
+        "required": [
+          "timeZone",
+          "schedules"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "timeZone": {
+            "type": "string"
+          },
+          "schedules": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Schedule"
+            }
+          }
+        }
+      },
+      "AutoSaveSetting": {
+        "type": "object",
+        "required": [
+          "enabled"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "enabled": {
+            "type": "boolean"
+          }
+        }
+      },
+      "TimeZoneSetting": {
+        "type": "object",
+        "required": [
+          "timeZone"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "timeZone": {
+            "type": "string",
+            "description": "Name of a time zone like 'Europe/Berlin'"
+          }
+        }
+      },
+      "Preferences": {
+        "type": "object",
+        "required": [
+          "jumpBackSeconds",
+          "smartJumpBack",
+          "restoreDevice"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "jumpBackSeconds": {
+            "type": "integer",
+            "description": "Seconds to jump back when restoring a slot, slots might override it"
+          },
+          "smartJumpBack": {
+            "type": "boolean",
+            "description": "Jump back further the longer a slot has been suspended"
+          },
+          "restoreDevice": {
+            "type": "boolean",
+            "description": "Restore slots onto the device they have been suspended on unless a device is given"
+          }
+        }
+      },
+      "PreferencesRequest": {
+        "type": "object",
+        "required": [
+          "jumpBackSeconds"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "jumpBackSeconds": {
+            "type": "integer",
+            "minimum": 0,
+            "maximum": 300
+          },
+          "smartJumpBack": {
+            "type": "boolean",
+            "description": "Defaults to false"
+          },
+          "restoreDevice": {
+            "type": "boolean",
+            "description": "Defaults to false"
+          }
+        }
+      },
+      "JumpBackRequest": {
+        "type": "object",
+        "required": [
+          "seconds"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "seconds": {
+            "type": "integer",
+            "minimum": 0,
+            "maximum": 300,
+            "description": "0 for not jumping back at all, e.g., for music"
+          }
+        }
+      },
+      "LabelsRequest": {
+        "type": "object",
+        "additionalProperties": false,
+        "description": "Fields missing are left unchanged, empty ones clear the field",
+        "properties": {
+          "title": {
+            "type": "string",
+            "maxLength": 200
+          },
+          "notes": {
+            "type": "string",
+            "maxLength": 2000
+          },
+          "tags": {
+            "type": "array",
+            "maxItems": 20,
+            "items": {
+              "type": "string",
+              "minLength": 1,
+              "maxLength": 50
+            },
+            "description": "Replaces all tags of the slot, duplicates are dropped"
+          },
+          "pinned": {
+            "type": "boolean"
+          }
+        }
+      },
+      "OrderRequest": {
+        "type": "object",
+        "required": [
+          "slots"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "slots": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            },
+            "description": "IDs of all slots in the order chosen, every slot has to be contained exactly once"
+          }
+        }
+      },
+      "PlayerState": {
+        "type": "object",
+        "description": "A slot holding a suspended playback",
+        "required": [
+          "id",
+          "linkToContext",
+          "contextType",
+          "albumArtLargeURL",
+          "albumArtMediumURL",
+          "trackName",
+          "albumName",
+          "artistName",
+          "trackIndex",
+          "totalTracks",
+          "progress",
+          "duration",
+          "shuffleActivated",
+          "suspendedAtTs",
+          "autoSaved"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "linkToContext": {
+            "type": "string",
+            "description": "Opens the context (resp. the track when contextType is 'track') in Spotify"
+          },
+          "contextType": {
+            "type": "string",
+            "description": "Either 'album', 'playlist', 'show' or 'track' (a single track played without context)"
+          },
+          "playlistName": {
+            "type": "string"
+          },
+          "showName": {
+            "type": "string"
+          },
+          "albumArtLargeURL": {
+            "type": "string"
+          },
+          "albumArtMediumURL": {
+            "type": "string"
+          },
+          "trackName": {
+            "type": "string"
+          },
+          "albumName": {
+            "type": "string"
+          },
+          "artistName": {
+            "type": "string"
+          },
+          "trackIndex": {
+            "type": "integer"
+          },
+          "totalTracks": {
+            "type": "integer"
+          },
+          "progress": {
+            "type": "integer",
+            "description": "Position within the track in milliseconds"
+          },
+          "duration": {
+            "type": "integer",
+            "description": "Duration of the track in milliseconds"
+          },
+          "contextDuration": {
+            "type": "integer",
+            "description": "Sum of the durations of all tracks in the album resp. playlist"
+          },
+          "contextElapsed": {
+            "type": "integer",
+            "description": "Sum of the durations of all tracks in the album resp. playlist before the current one"
+          },
+          "percentComplete": {
+            "type": "number",
+            "description": "How far the whole album, playlist, episode resp. track has been listened to, omitted when unknown"
+          },
+          "timeRemaining": {
+            "type": "integer",
+            "description": "Milliseconds left of the whole album, playlist, episode resp. track, omitted when unknown"
+          },
+          "shuffleActivated": {
+            "type": "boolean"
+          },
+          "repeatState": {
+            "type": "string",
+            "enum": [
+              "off",
+              "track",
+              "context"
+            ],
+            "description": "Restored along with the playback, omitted for slots saved before it has been captured"
+          },
+          "volume": {
+            "type": "integer",
+            "minimum": 0,
+            "maximum": 100,
+            "description": "Volume in percent restored along with the playback, omitted if unknown"
+          },
+          "deviceName": {
+            "type": "string",
+            "description": "Name of the device the playback has been suspended on"
+          },
+          "suspendedAtTs": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "autoSaved": {
+            "type": "boolean",
+            "description": "The slot is maintained by saving the progress in the background"
+          },
+          "jumpBackSeconds": {
+            "type": "integer",
+            "description": "Overrides the jump-back of the preferences when restoring this slot, omitted unless set"
+          },
+          "title": {
+            "type": "string",
+            "description": "Custom title set by the user, replaces the name derived from Spotify when displaying the slot, omitted unless set"
+          },
+          "notes": {
+            "type": "string",
+            "description": "Notes of the user, omitted unless set"
+          },
+          "tags": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            },
+            "description": "Tags set by the user, omitted unless set"
+          },
+          "pinned": {
+            "type": "boolean",
+            "description": "Pinned slots are listed first regardless of the order, omitted unless pinned"
+          },
+          "bookmarks": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Bookmark"
+            }
+          }
+        }
+      },
+      "HistoryEvent": {
+        "type": "object",
+        "required": [
+          "type",
+          "slot",
+          "atTs",
+          "playerState"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "type": {
+            "type": "string",
+            "enum": [
+              "suspended",
+              "restored"
+            ]
+          },
+          "slot": {
+            "type": "string",
+            "description": "ID of the slot, it might have been deleted in the meantime"
+          },
+          "bookmark": {
+            "type": "string",
+            "description": "ID of the bookmark restored, omitted unless a bookmark has been restored"
+          },
+          "atTs": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "playerState": {
+            "$ref": "#/components/schemas/PlayerState",
+            "description": "Snapshot of the slot without its bookmarks"
+          }
+        }
+      },
+      "ContextStats": {
+        "type": "object",
+        "required": [
+          "latest",
+          "sessions",
+          "listenedMs",
+          "averageSessionMs",
+          "firstEventAtTs",
+          "lastEventAtTs"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "latest": {
+            "$ref": "#/components/schemas/PlayerState",
+            "description": "Snapshot of the latest event"
+          },
+          "sessions": {
+            "type": "integer",
+            "description": "Number of times the context has been suspended"
+          },
+          "listenedMs": {
+            "type": "integer",
+            "description": "Estimated by how far the progress advanced between consecutive suspends"
+          },
+          "averageSessionMs": {
+            "type": "integer"
+          },
+          "firstEventAtTs": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "lastEventAtTs": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "estimatedFinishAtTs": {
+            "type": "integer",
+            "format": "int64",
+            "description": "Extrapolated from the pace so far, omitted when the duration is unknown resp. nothing has been listened yet"
+          }
+        }
+      },
+      "ArchivedSlot": {
+        "type": "object",
+        "required": [
+          "playerState",
+          "completedAtTs"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "playerState": {
+            "$ref": "#/components/schemas/PlayerState"
+          },
+          "completedAtTs": {
+            "type": "integer",
+            "format": "int64",
+            "description": "When the slot has been suspended resp. saved at the end of its last track"
+          }
+        }
+      },
+      "TrashedSlot": {
+        "type": "object",
+        "required": [
+          "playerState",
+          "deletedAtTs",
+          "purgeAtTs"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "playerState": {
+            "$ref": "#/components/schemas/PlayerState"
+          },
+          "deletedAtTs": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "purgeAtTs": {
+            "type": "integer",
+            "format": "int64",
+            "description": "When the slot gets purged, fixed at the time of deletion"
+          }
+        }
+      },
+      "AccountDeletion": {
+        "type": "object",
+        "required": [
+          "requestedAtTs",
+          "deleteAtTs"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "requestedAtTs": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "deleteAtTs": {
+            "type": "integer",
+            "format": "int64",
+            "description": "When all data stored for the user gets deleted unless the deletion is cancelled before"
+          }
+        }
+      },
+      "Bookmark": {
+        "type": "object",
+        "required": [
+          "id",
+          "name",
+          "trackURI",
+          "trackName",
+          "progress",
+          "createdAtTs"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "note": {
+            "type": "string"
+          },
+          "trackURI": {
+            "type": "string"
+          },
+          "trackName": {
+            "type": "string"
+          },
+          "progress": {
+            "type": "integer"
+          },
+          "createdAtTs": {
+            "type": "integer",
+            "format": "int64"
+          }
+        }
+      },
+      "BookmarkRequest": {
+        "type": "object",
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "note": {
+            "type": "string"
+          },
+          "trackURI": {
+            "type": "string",
+            "description": "URI of a track resp. an episode, when creating a bookmark without it the current position of the playback is used"
+          },
+          "progress": {
+            "type": "integer",
+            "minimum": 0
+          }
+        }
+      },
+      "UserRecord": {
+        "type": "object",
+        "required": [
+          "version",
+          "revision",
+          "_id",
+          "playerStates"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "version": {
+            "type": "integer"
+          },
+          "revision": {
+            "type": "integer"
+          },
+          "_id": {
+            "type": "string",
+            "description": "Hash of the user's Spotify ID"
+          },
+          "playerStates": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/PlayerState"
+            }
+          }
+        }
+      },
+      "Scope": {
+        "type": "string",
+        "enum": [
+          "read",
+          "suspend",
+          "restore"
+        ]
+      },
+      "APIToken": {
+        "type": "object",
+        "required": [
+          "id",
+          "name",
+          "scopes",
+          "createdAtTs"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "scopes": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Scope"
+            }
+          },
+          "createdAtTs": {
+            "type": "integer",
+            "format": "int64"
+          }
+        }
+      },
+      "CreatedAPIToken": {
+        "type": "object",
+        "required": [
+          "id",
+          "name",
+          "scopes",
+          "createdAtTs",
+          "token"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "scopes": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Scope"
+            }
+          },
+          "createdAtTs": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "token": {
+            "type": "string",
+            "description": "The token to be sent as bearer token, it is only contained in this response"
+          }
+        }
+      },
+      "APITokenRequest": {
+        "type": "object",
+        "required": [
+          "name",
+          "scopes"
+        ],
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "scopes": {
+            "type": "array",
+            "minItems": 1,
+            "items": {
+              "$ref": "#/components/schemas/Scope"
+            }
+          }
+        }
+      }
+    }
+  }
+}
+`
@@ -0,0 +1,45 @@
+//go:build ignore
+// +build ignore
+
+// This program compiles the OpenAPI document kept in api/openapi.json into the binary, it is run by go generate.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+)
+
+const (
+	source      = "../../api/openapi.json"
+	destination = "openapiDocument.go"
+)
+
+func main() {
+	document, err := ioutil.ReadFile(source)
+	if err != nil {
+		log.Fatalf("Could not read OpenAPI document: %s", err)
+	}
+
+	if !json.Valid(document) {
+		log.Fatalf("OpenAPI document at '%s' is not valid JSON", source)
+	}
+
+	if bytes.ContainsRune(document, '`') {
+		log.Fatalf("OpenAPI document at '%s' must not contain backticks", source)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by openapi_gen.go from api/openapi.json; DO NOT EDIT.\n\n")
+	out.WriteString("package handler\n\n")
+	out.WriteString("// openAPIDocument is served at /api/openapi.json, it is edited in api/openapi.json\n")
+	out.WriteString("const openAPIDocument = `")
+	out.Write(document)
+	out.WriteString("`\n")
+
+	err = ioutil.WriteFile(destination, out.Bytes(), 0644)
+	if err != nil {
+		log.Fatalf("Could not write '%s': %s", destination, err)
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/events"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/rs/zerolog/hlog"
+	spotifyAPI "github.com/zmb3/spotify"
+)
+
+// orderRequest lists the IDs of all slots in the order chosen by the user
+type orderRequest struct {
+	Slots []string `json:"slots"`
+}
+
+// PlayerStatesOrderPutHandler replaces the manual order of the slots at once, so either all slots get moved or none
+func PlayerStatesOrderPutHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.PlayerStatesPersistor)
+
+	var body orderRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		hlog.FromRequest(r).Debug().Err(err).Msg("Could not decode order.")
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Could not process request. Please provide the order as JSON.")
+		return
+	}
+
+	playerStates, revision, ok := loadPlayerStates(w, r, dao, user.ID)
+	if !ok || !checkIfMatch(w, r, revision) {
+		return
+	}
+
+	reordered, ok := persistence.Reordered(playerStates, body.Slots)
+	if !ok {
+		hlog.FromRequest(r).Debug().Strs("slots", body.Slots).Msg("Order does not match the slots.")
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "'slots' has to contain the ID of every slot exactly once.")
+		return
+	}
+
+	if savePlayerStates(w, r, dao, user.ID, reordered, revision) {
+		publish(r, events.SlotsReordered, "")
+	}
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/events"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/rs/zerolog/hlog"
+	spotifyAPI "github.com/zmb3/spotify"
+)
+
+// preferencesRequest replaces the preferences of the user, pointers tell apart missing fields from zero values
+type preferencesRequest struct {
+	JumpBackSeconds *int  `json:"jumpBackSeconds"`
+	SmartJumpBack   *bool `json:"smartJumpBack"`
+	RestoreDevice   *bool `json:"restoreDevice"`
+}
+
+// jumpBackRequest sets the jump-back of a single slot
+type jumpBackRequest struct {
+	Seconds *int `json:"seconds"`
+}
+
+func PreferencesGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.PreferenceStore)
+
+	preferences, ok := loadPreferences(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	respondWithPreferences(w, r, preferences)
+}
+
+// PreferencesPutHandler replaces the preferences, they apply to all slots restored afterwards
+func PreferencesPutHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.PreferenceStore)
+
+	var body preferencesRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		hlog.FromRequest(r).Debug().Err(err).Msg("Could not decode preferences.")
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Could not process request. Please provide the preferences as JSON.")
+		return
+	}
+
+	if body.JumpBackSeconds == nil {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "'jumpBackSeconds' is required.")
+		return
+	}
+
+	if !validJumpBack(w, r, "jumpBackSeconds", *body.JumpBackSeconds) {
+		return
+	}
+
+	preferences := &persistence.Preferences{
+		JumpBackSeconds: *body.JumpBackSeconds,
+		SmartJumpBack:   body.SmartJumpBack != nil && *body.SmartJumpBack,
+		RestoreDevice:   body.RestoreDevice != nil && *body.RestoreDevice,
+	}
+
+	err = dao.StorePreferences(user.ID, preferences)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed storing preferences.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not persist preferences in DB.")
+		return
+	}
+
+	respondWithPreferences(w, r, preferences)
+}
+
+// JumpBackPutHandler overrides the jump-back of the user's preferences for the slot
+func JumpBackPutHandler(w http.ResponseWriter, r *http.Request) {
+	var body jumpBackRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		hlog.FromRequest(r).Debug().Err(err).Msg("Could not decode jump-back.")
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Could not process request. Please provide the jump-back as JSON.")
+		return
+	}
+
+	if body.Seconds == nil {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "'seconds' is required.")
+		return
+	}
+
+	if validJumpBack(w, r, "seconds", *body.Seconds) {
+		setJumpBack(w, r, body.Seconds)
+	}
+}
+
+// JumpBackDeleteHandler drops the jump-back of the slot, the one of the user's preferences applies again
+func JumpBackDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	setJumpBack(w, r, nil)
+}
+
+func setJumpBack(w http.ResponseWriter, r *http.Request, seconds *int) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.PlayerStatesPersistor)
+	slot := ctx.Value(constants.FieldKeySlot).(string)
+
+	playerStates, revision, ok := loadPlayerStates(w, r, dao, user.ID)
+	if !ok || !checkIfMatch(w, r, revision) {
+		return
+	}
+
+	idx := indexOfSlot(w, playerStates, slot)
+	if idx < 0 {
+		hlog.FromRequest(r).Debug().Str("slot", slot).Msg("Slot does not exist.")
+		problem.Respond(w, r, http.StatusNotFound, problem.CodeSlotOutOfRange, "'slot' does not refer to an existing slot.")
+		return
+	}
+
+	playerStates[idx].JumpBackSeconds = seconds
+
+	if savePlayerStates(w, r, dao, user.ID, playerStates, revision) {
+		publish(r, events.SlotUpdated, playerStates[idx].ID)
+	}
+}
+
+// validJumpBack responds with 400 in case the jump-back is out of range
+func validJumpBack(w http.ResponseWriter, r *http.Request, field string, seconds int) bool {
+	if seconds < 0 || seconds > constants.MaxJumpBackSeconds {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, fmt.Sprintf("'%s' has to be between 0 and %d.", field, constants.MaxJumpBackSeconds))
+		return false
+	}
+
+	return true
+}
+
+func loadPreferences(w http.ResponseWriter, r *http.Request, dao persistence.PreferenceStore, userID string) (*persistence.Preferences, bool) {
+	preferences, err := dao.LoadPreferences(userID)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed loading preferences from DB.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not retrieve preferences from DB.")
+		return nil, false
+	}
+
+	return preferences, true
+}
+
+func respondWithPreferences(w http.ResponseWriter, r *http.Request, preferences *persistence.Preferences) {
+	json, err := json.Marshal(preferences)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Could not serialize preferences.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Failed to provide preferences as JSON.")
+		return
+	}
+
+	respondWithJSON(w, r, json)
+}
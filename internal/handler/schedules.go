@@ -0,0 +1,354 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/florianloch/cassette/internal/scheduler"
+	"github.com/florianloch/cassette/internal/spotify"
+	"github.com/rs/zerolog/hlog"
+	spotifyAPI "github.com/zmb3/spotify"
+)
+
+// scheduleRequest creates resp. replaces a schedule restoring the given slot onto the given device.
+// If no policy for missed runs is given they get skipped.
+type scheduleRequest struct {
+	Name      string                      `json:"name"`
+	Slot      string                      `json:"slot"`
+	DeviceID  string                      `json:"deviceID"`
+	Cron      string                      `json:"cron"`
+	MissedRun persistence.MissedRunPolicy `json:"missedRun"`
+}
+
+type timeZoneSetting struct {
+	TimeZone string `json:"timeZone"`
+}
+
+func SchedulesGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.ScheduleStore)
+
+	schedules, ok := loadSchedules(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	schedules.TimeZone = timeZoneOf(schedules)
+
+	respondWithScheduleJSON(w, r, http.StatusOK, schedules)
+}
+
+func SchedulesPostHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.Persistor)
+
+	schedules, ok := loadSchedules(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	if len(schedules.Schedules) >= constants.ScheduleMaxPerUser {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, fmt.Sprintf("There must not be more than %d schedules.", constants.ScheduleMaxPerUser))
+		return
+	}
+
+	schedule := &persistence.Schedule{CreatedAtTs: time.Now().Unix()}
+	if !applyScheduleRequest(w, r, dao, user.ID, schedules, schedule) {
+		return
+	}
+
+	schedules.Schedules = append(schedules.Schedules, schedule)
+
+	if !saveSchedules(w, r, dao, schedules) {
+		return
+	}
+
+	// The ID has been assigned when saving
+	w.Header().Set("Location", "/api/schedules/"+schedule.ID)
+	respondWithScheduleJSON(w, r, http.StatusCreated, schedule)
+}
+
+func ScheduleGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.ScheduleStore)
+
+	schedules, ok := loadSchedules(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	schedule := findSchedule(w, r, schedules)
+	if schedule == nil {
+		return
+	}
+
+	respondWithScheduleJSON(w, r, http.StatusOK, schedule)
+}
+
+// SchedulePutHandler replaces the schedule, the next run gets planned anew
+func SchedulePutHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.Persistor)
+
+	schedules, ok := loadSchedules(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	schedule := findSchedule(w, r, schedules)
+	if schedule == nil {
+		return
+	}
+
+	if !applyScheduleRequest(w, r, dao, user.ID, schedules, schedule) {
+		return
+	}
+
+	if saveSchedules(w, r, dao, schedules) {
+		respondWithScheduleJSON(w, r, http.StatusOK, schedule)
+	}
+}
+
+func ScheduleDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.ScheduleStore)
+
+	schedules, ok := loadSchedules(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	schedule := findSchedule(w, r, schedules)
+	if schedule == nil {
+		return
+	}
+
+	kept := make([]*persistence.Schedule, 0, len(schedules.Schedules)-1)
+	for _, cur := range schedules.Schedules {
+		if cur != schedule {
+			kept = append(kept, cur)
+		}
+	}
+	schedules.Schedules = kept
+
+	if saveSchedules(w, r, dao, schedules) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TimeZoneGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.ScheduleStore)
+
+	schedules, ok := loadSchedules(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	respondWithScheduleJSON(w, r, http.StatusOK, timeZoneSetting{timeZoneOf(schedules)})
+}
+
+// TimeZonePutHandler sets the time zone the schedules of the user are evaluated in, their next runs get planned anew
+func TimeZonePutHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.ScheduleStore)
+
+	var body timeZoneSetting
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		hlog.FromRequest(r).Debug().Err(err).Msg("Could not decode time zone.")
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Could not process request. Please provide the time zone as JSON.")
+		return
+	}
+
+	// "Local" would refer to the time zone of the server
+	loc, err := time.LoadLocation(body.TimeZone)
+	if err != nil || body.TimeZone == "" || body.TimeZone == "Local" {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "'timeZone' has to be the name of a time zone like 'Europe/Berlin'.")
+		return
+	}
+
+	schedules, ok := loadSchedules(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	schedules.TimeZone = body.TimeZone
+
+	now := time.Now()
+	for _, schedule := range schedules.Schedules {
+		err = scheduler.Plan(schedule, loc, now)
+		if err != nil {
+			hlog.FromRequest(r).Error().Err(err).Str("schedule", schedule.ID).Msg("Could not plan next run of schedule.")
+			schedule.NextRunAtTs = 0
+		}
+	}
+
+	if saveSchedules(w, r, dao, schedules) {
+		respondWithScheduleJSON(w, r, http.StatusOK, timeZoneSetting{body.TimeZone})
+	}
+}
+
+// applyScheduleRequest validates the request and takes it over into the schedule, the slot is resolved to its ID
+// and the name of the device is looked up. Responds with 400 in case the request is invalid.
+func applyScheduleRequest(w http.ResponseWriter, r *http.Request, dao persistence.Persistor, userID string, schedules *persistence.UserSchedules, schedule *persistence.Schedule) bool {
+	spotifyClient := r.Context().Value(constants.FieldKeySpotifyClient).(spotify.SpotClient)
+
+	var body scheduleRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		hlog.FromRequest(r).Debug().Err(err).Msg("Could not decode schedule.")
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Could not process request. Please provide the schedule as JSON.")
+		return false
+	}
+
+	if body.MissedRun == "" {
+		body.MissedRun = persistence.MissedRunSkip
+	}
+
+	if body.MissedRun != persistence.MissedRunSkip && body.MissedRun != persistence.MissedRunCatchUp {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "'missedRun' has to be either 'skip' or 'catchUp'.")
+		return false
+	}
+
+	candidate := &persistence.Schedule{
+		Name:      strings.TrimSpace(body.Name),
+		Cron:      strings.Join(strings.Fields(body.Cron), " "),
+		MissedRun: body.MissedRun,
+	}
+
+	loc, err := time.LoadLocation(schedules.TimeZone)
+	if err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Str("timeZone", schedules.TimeZone).Msg("Unknown time zone, using UTC instead.")
+		loc = time.UTC
+	}
+
+	err = scheduler.Plan(candidate, loc, time.Now())
+	if err != nil {
+		hlog.FromRequest(r).Debug().Err(err).Str("cron", body.Cron).Msg("Invalid cron expression.")
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, fmt.Sprintf("'cron' is not a valid cron expression: %s.", err))
+		return false
+	}
+
+	playerStates, _, ok := loadPlayerStates(w, r, dao, userID)
+	if !ok {
+		return false
+	}
+
+	idx := indexOfSlot(w, playerStates, body.Slot)
+	if idx < 0 {
+		hlog.FromRequest(r).Debug().Str("slot", body.Slot).Msg("Slot does not exist.")
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeSlotOutOfRange, "'slot' does not refer to an existing slot.")
+		return false
+	}
+
+	devices, err := spotify.ActiveSpotifyDevices(spotifyClient)
+	if err != nil {
+		respondWithSpotifyError(w, r, err, "Could not fetch list of active devices from Spotify!")
+		return false
+	}
+
+	for _, device := range devices {
+		if device.ID == body.DeviceID {
+			candidate.DeviceID = device.ID
+			candidate.DeviceName = device.Name
+		}
+	}
+
+	if candidate.DeviceID == "" {
+		hlog.FromRequest(r).Debug().Str("deviceID", body.DeviceID).Msg("Device is not available.")
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "'deviceID' does not refer to an available device. Please make sure the device is online.")
+		return false
+	}
+
+	schedule.Name = candidate.Name
+	schedule.Slot = playerStates[idx].ID
+	schedule.DeviceID = candidate.DeviceID
+	schedule.DeviceName = candidate.DeviceName
+	schedule.Cron = candidate.Cron
+	schedule.MissedRun = candidate.MissedRun
+	schedule.NextRunAtTs = candidate.NextRunAtTs
+
+	return true
+}
+
+func loadSchedules(w http.ResponseWriter, r *http.Request, dao persistence.ScheduleStore, userID string) (*persistence.UserSchedules, bool) {
+	schedules, err := dao.LoadSchedules(userID)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed loading schedules from DB.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not retrieve schedules from DB.")
+		return nil, false
+	}
+
+	return schedules, true
+}
+
+// findSchedule responds with 404 in case the schedule requested does not exist
+func findSchedule(w http.ResponseWriter, r *http.Request, schedules *persistence.UserSchedules) *persistence.Schedule {
+	id := r.Context().Value(constants.FieldKeySchedule).(string)
+
+	schedule := schedules.Find(id)
+	if schedule == nil {
+		hlog.FromRequest(r).Debug().Str("schedule", id).Msg("Schedule does not exist.")
+		problem.Respond(w, r, http.StatusNotFound, problem.CodeScheduleNotFound, "'schedule' does not refer to an existing schedule.")
+	}
+
+	return schedule
+}
+
+// saveSchedules responds with 409 in case the schedules have been modified concurrently, e.g., by the worker
+func saveSchedules(w http.ResponseWriter, r *http.Request, dao persistence.ScheduleStore, schedules *persistence.UserSchedules) bool {
+	err := dao.SaveSchedules(schedules)
+	if err != nil {
+		if err == persistence.ErrRevisionMismatch {
+			hlog.FromRequest(r).Debug().Msg("Schedules have been modified concurrently.")
+			problem.Respond(w, r, http.StatusConflict, problem.CodeModifiedConcurrently, "Schedules have been modified concurrently. Please try again.")
+			return false
+		}
+
+		hlog.FromRequest(r).Error().Err(err).Msg("Could not persist schedules in DB.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not persist schedules in DB.")
+		return false
+	}
+
+	return true
+}
+
+// timeZoneOf tells the time zone the schedules are evaluated in
+func timeZoneOf(schedules *persistence.UserSchedules) string {
+	if schedules.TimeZone == "" {
+		return "UTC"
+	}
+
+	return schedules.TimeZone
+}
+
+func respondWithScheduleJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	json, err := json.Marshal(v)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Could not serialize schedules.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Failed to provide schedules as JSON.")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_, err = w.Write(json)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed to write JSON response.")
+	}
+}
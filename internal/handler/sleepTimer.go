@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/florianloch/cassette/internal/sleeptimer"
+	"github.com/florianloch/cassette/internal/spotify"
+	"github.com/rs/zerolog/hlog"
+	spotifyAPI "github.com/zmb3/spotify"
+)
+
+// sleepTimerRequest sets a timer firing after the given minutes resp. at the end of the current track,
+// depending on the mode. The playback gets suspended into the given slot, a new one if none is given.
+type sleepTimerRequest struct {
+	Mode    persistence.SleepTimerMode `json:"mode"`
+	Minutes int                        `json:"minutes"`
+	Slot    string                     `json:"slot"`
+}
+
+func SleepTimerGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.SleepTimerStore)
+
+	timer, err := dao.LoadSleepTimer(user.ID)
+	if err != nil {
+		if err == persistence.ErrSleepTimerNotFound {
+			problem.Respond(w, r, http.StatusNotFound, problem.CodeSleepTimerNotFound, "No sleep timer is set.")
+			return
+		}
+
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed loading sleep timer from DB.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not retrieve sleep timer from DB.")
+		return
+	}
+
+	respondWithSleepTimer(w, r, timer)
+}
+
+func SleepTimerPutHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	spotifyClient := ctx.Value(constants.FieldKeySpotifyClient).(spotify.SpotClient)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.Persistor)
+
+	var body sleepTimerRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		hlog.FromRequest(r).Debug().Err(err).Msg("Could not decode sleep timer.")
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Could not process request. Please provide the sleep timer as JSON.")
+		return
+	}
+
+	switch body.Mode {
+	case persistence.SleepTimerAfterDuration:
+		if body.Minutes < 1 || body.Minutes > constants.SleepTimerMaxMinutes {
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "'minutes' has to be between 1 and 1440.")
+			return
+		}
+	case persistence.SleepTimerAtTrackEnd:
+	default:
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "'mode' has to be either 'duration' or 'trackEnd'.")
+		return
+	}
+
+	if body.Slot != "" {
+		playerStates, _, ok := loadPlayerStates(w, r, dao, user.ID)
+		if !ok {
+			return
+		}
+
+		idx := indexOfSlot(w, playerStates, body.Slot)
+		if idx < 0 {
+			hlog.FromRequest(r).Debug().Str("slot", body.Slot).Msg("Slot does not exist.")
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeSlotOutOfRange, "'slot' does not refer to an existing slot.")
+			return
+		}
+
+		body.Slot = playerStates[idx].ID
+	}
+
+	timer, err := sleeptimer.New(spotifyClient, user.ID, body.Mode, body.Minutes, body.Slot, time.Now())
+	if err != nil {
+		if err == sleeptimer.ErrNothingPlaying {
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeNothingPlaying, "Nothing is playing at the moment, so there is no track to wait for.")
+			return
+		}
+
+		respondWithSpotifyError(w, r, err, "Could not retrieve player state from Spotify. Please make sure your device is playing and online.")
+		return
+	}
+
+	err = dao.StoreSleepTimer(timer)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed storing sleep timer.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not persist sleep timer in DB.")
+		return
+	}
+
+	respondWithSleepTimer(w, r, timer)
+}
+
+// SleepTimerDeleteHandler cancels the timer
+func SleepTimerDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.SleepTimerStore)
+
+	err := dao.DeleteSleepTimer(user.ID)
+	if err != nil {
+		if err == persistence.ErrSleepTimerNotFound {
+			problem.Respond(w, r, http.StatusNotFound, problem.CodeSleepTimerNotFound, "No sleep timer is set.")
+			return
+		}
+
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed deleting sleep timer.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not delete sleep timer from DB.")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func respondWithSleepTimer(w http.ResponseWriter, r *http.Request, timer *persistence.SleepTimer) {
+	json, err := json.Marshal(timer)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Could not serialize sleep timer.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Failed to provide sleep timer as JSON.")
+		return
+	}
+
+	respondWithJSON(w, r, json)
+}
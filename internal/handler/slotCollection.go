@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/events"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/rs/zerolog/hlog"
+	spotifyAPI "github.com/zmb3/spotify"
+)
+
+// slotCollection provides the handlers shared by the archive and the trash, both keep slots having been moved out
+// of the player states
+type slotCollection struct {
+	// name is used in the messages, e.g., "archive"
+	name           string
+	notFoundCode   problem.Code
+	notFoundDetail string
+
+	load func(dao persistence.Persistor, userID string) (slotList, int, error)
+	save func(dao persistence.Persistor, userID string, slots slotList, revision int) error
+}
+
+// slotList is implemented by the slices of slots kept in a slot collection, they sort the most recent slots first
+type slotList interface {
+	sort.Interface
+	// at returns the slot at idx as provided to the client
+	at(idx int) interface{}
+	playerState(idx int) *persistence.PlayerState
+	// without returns the slots without the one at idx, the slice given gets modified
+	without(idx int) slotList
+	// cleared returns an empty list of the same type
+	cleared() slotList
+}
+
+// listHandler lists the slots of the collection, the most recent ones come first
+func (c *slotCollection) listHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.Persistor)
+
+	slots, _, ok := c.loadSlots(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	sort.Stable(slots)
+
+	c.respondWithSlots(w, r, slots)
+}
+
+// clearHandler purges all slots of the collection right away
+func (c *slotCollection) clearHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.Persistor)
+
+	slots, revision, ok := c.loadSlots(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	if c.saveSlots(w, r, dao, user.ID, slots.cleared(), revision) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// slotGetHandler provides a single slot of the collection
+func (c *slotCollection) slotGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.Persistor)
+	slot := ctx.Value(constants.FieldKeySlot).(string)
+
+	slots, _, ok := c.loadSlots(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	idx := c.indexOfSlot(w, r, slots, slot)
+	if idx < 0 {
+		return
+	}
+
+	c.respondWithSlots(w, r, slots.at(idx))
+}
+
+// slotDeleteHandler purges a single slot of the collection right away
+func (c *slotCollection) slotDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.Persistor)
+	slot := ctx.Value(constants.FieldKeySlot).(string)
+
+	slots, revision, ok := c.loadSlots(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	idx := c.indexOfSlot(w, r, slots, slot)
+	if idx < 0 {
+		return
+	}
+
+	if c.saveSlots(w, r, dao, user.ID, slots.without(idx), revision) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// restoreHandler moves the slot back to the end of the player states. The slot is added to the player states before
+// being removed from the collection, so trying again after a failure does not lose it.
+func (c *slotCollection) restoreHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value(constants.FieldKeyUser).(*spotifyAPI.PrivateUser)
+	dao := ctx.Value(constants.FieldKeyDao).(persistence.Persistor)
+	slot := ctx.Value(constants.FieldKeySlot).(string)
+
+	slots, slotsRevision, ok := c.loadSlots(w, r, dao, user.ID)
+	if !ok {
+		return
+	}
+
+	idx := c.indexOfSlot(w, r, slots, slot)
+	if idx < 0 {
+		return
+	}
+
+	playerStates, revision, ok := loadPlayerStates(w, r, dao, user.ID)
+	if !ok || !checkIfMatch(w, r, revision) {
+		return
+	}
+
+	restored := slots.playerState(idx)
+
+	alreadyRestored := false
+	for _, playerState := range playerStates {
+		alreadyRestored = alreadyRestored || playerState.ID == restored.ID
+	}
+
+	if !alreadyRestored {
+		if !savePlayerStates(w, r, dao, user.ID, append(playerStates, restored), revision) {
+			return
+		}
+
+		publish(r, events.SlotCreated, restored.ID)
+	}
+
+	err := c.save(dao, user.ID, slots.without(idx), slotsRevision)
+	if err != nil {
+		// The slot is contained in both now, which is told apart when trying again
+		hlog.FromRequest(r).Error().Err(err).Str("slot", restored.ID).Msgf("Failed removing restored slot from %s.", c.name)
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, fmt.Sprintf("Could not remove slot from %s. Please try again.", c.name))
+		return
+	}
+
+	w.Header().Set("Location", "/api/playerStates/"+restored.ID)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (c *slotCollection) loadSlots(w http.ResponseWriter, r *http.Request, dao persistence.Persistor, userID string) (slotList, int, bool) {
+	slots, revision, err := c.load(dao, userID)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msgf("Failed loading %s from DB.", c.name)
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, fmt.Sprintf("Could not retrieve %s from DB.", c.name))
+		return nil, 0, false
+	}
+
+	return slots, revision, true
+}
+
+// saveSlots responds with 409 in case the collection has been modified concurrently
+func (c *slotCollection) saveSlots(w http.ResponseWriter, r *http.Request, dao persistence.Persistor, userID string, slots slotList, revision int) bool {
+	err := c.save(dao, userID, slots, revision)
+	if err != nil {
+		if err == persistence.ErrRevisionMismatch {
+			hlog.FromRequest(r).Debug().Int("revision", revision).Msgf("The %s has been modified concurrently.", c.name)
+			problem.Respond(w, r, http.StatusConflict, problem.CodeModifiedConcurrently, fmt.Sprintf("The %s has been modified concurrently. Please reload it and try again.", c.name))
+			return false
+		}
+
+		hlog.FromRequest(r).Error().Err(err).Msgf("Could not persist %s in DB.", c.name)
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, fmt.Sprintf("Could not persist %s in DB.", c.name))
+		return false
+	}
+
+	return true
+}
+
+// indexOfSlot responds with 404 in case there is no slot with the given ID in the collection
+func (c *slotCollection) indexOfSlot(w http.ResponseWriter, r *http.Request, slots slotList, slot string) int {
+	for idx := 0; idx < slots.Len(); idx++ {
+		if slots.playerState(idx).ID == slot {
+			return idx
+		}
+	}
+
+	hlog.FromRequest(r).Debug().Str("slot", slot).Msgf("Slot does not exist in %s.", c.name)
+	problem.Respond(w, r, http.StatusNotFound, c.notFoundCode, c.notFoundDetail)
+
+	return -1
+}
+
+func (c *slotCollection) respondWithSlots(w http.ResponseWriter, r *http.Request, slots interface{}) {
+	json, err := json.Marshal(slots)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msgf("Could not serialize %s.", c.name)
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, fmt.Sprintf("Failed to provide %s as JSON.", c.name))
+		return
+	}
+
+	respondWithJSON(w, r, json)
+}
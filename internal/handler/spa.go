@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 
 	"github.com/rs/zerolog/hlog"
+
+	"github.com/florianloch/cassette/internal/problem"
 )
 
 // NOTICE:
@@ -46,7 +48,7 @@ func (h *spaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		// if we failed to get the absolute path respond with a 400 bad request
 		// and stop
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "The requested path is invalid.")
 		return
 	}
 
@@ -64,7 +66,8 @@ func (h *spaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	} else if err != nil {
 		// if we got an error (that wasn't that the file doesn't exist) stating the
 		// file, return a 500 internal server error and stop
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		hlog.FromRequest(r).Error().Err(err).Str("path", path).Msg("Could not access static asset.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not access the requested file.")
 		return
 	}
 
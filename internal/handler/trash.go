@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/rs/zerolog/hlog"
+)
+
+// Retention tells how long deleted data can be recovered by the user, zero deletes it for good right away
+type Retention struct {
+	Trash           time.Duration // deleted slots are kept in the trash for this long
+	AccountDeletion time.Duration // grace period before the deletion of a user record is carried out
+}
+
+var trashCollection = &slotCollection{
+	name:           "trash",
+	notFoundCode:   problem.CodeTrashedSlotNotFound,
+	notFoundDetail: "'slot' does not refer to a slot in the trash.",
+	load: func(dao persistence.Persistor, userID string) (slotList, int, error) {
+		trash, revision, err := dao.LoadTrash(userID)
+		return trashedSlots(trash), revision, err
+	},
+	save: func(dao persistence.Persistor, userID string, slots slotList, revision int) error {
+		_, err := dao.SaveTrash(userID, slots.(trashedSlots), revision)
+		return err
+	},
+}
+
+var (
+	// TrashGetHandler lists the slots deleted but not purged yet, the ones deleted most recently come first
+	TrashGetHandler = trashCollection.listHandler
+	// TrashDeleteHandler purges all trashed slots right away
+	TrashDeleteHandler = trashCollection.clearHandler
+	// TrashedSlotGetHandler provides the trashed slot along with the times it has been deleted at resp. gets purged at
+	TrashedSlotGetHandler = trashCollection.slotGetHandler
+	// TrashedSlotDeleteHandler purges the trashed slot right away
+	TrashedSlotDeleteHandler = trashCollection.slotDeleteHandler
+	// UndeleteHandler moves the trashed slot back to the end of the player states
+	UndeleteHandler = trashCollection.restoreHandler
+)
+
+// trashSlot moves the slot about to be deleted to the trash unless the trash is disabled, it tells whether the slot
+// has been trashed
+func trashSlot(w http.ResponseWriter, r *http.Request, dao persistence.TrashStore, userID string, playerState *persistence.PlayerState) (bool, bool) {
+	retention := r.Context().Value(constants.FieldKeyRetention).(Retention)
+
+	trashed, err := persistence.TrashDeleted(dao, userID, playerState, time.Now(), retention.Trash)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed moving slot to trash.")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not move slot to trash.")
+		return false, false
+	}
+
+	return trashed, true
+}
+
+// undoTrashing is called in case the player states could not be saved after trashing the slot, failing to undo it
+// the slot is contained in both
+func undoTrashing(r *http.Request, dao persistence.TrashStore, userID string, playerState *persistence.PlayerState) {
+	err := persistence.UndoTrashing(dao, userID, playerState)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Str("slot", playerState.ID).Msg("Failed removing slot from trash again.")
+	}
+}
+
+type trashedSlots []*persistence.TrashedSlot
+
+func (s trashedSlots) Len() int {
+	return len(s)
+}
+
+func (s trashedSlots) Less(i, j int) bool {
+	return s[i].DeletedAtTs > s[j].DeletedAtTs
+}
+
+func (s trashedSlots) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s trashedSlots) at(idx int) interface{} {
+	return s[idx]
+}
+
+func (s trashedSlots) playerState(idx int) *persistence.PlayerState {
+	return s[idx].PlayerState
+}
+
+func (s trashedSlots) without(idx int) slotList {
+	return append(s[:idx], s[idx+1:]...)
+}
+
+func (s trashedSlots) cleared() slotList {
+	return make(trashedSlots, 0)
+}
@@ -9,13 +9,19 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
-	"strconv"
+	"strings"
 	"time"
 
+	"github.com/florianloch/cassette/internal/autosave"
 	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/events"
 	"github.com/florianloch/cassette/internal/handler"
 	"github.com/florianloch/cassette/internal/middleware"
 	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/problem"
+	"github.com/florianloch/cassette/internal/purger"
+	"github.com/florianloch/cassette/internal/scheduler"
+	"github.com/florianloch/cassette/internal/sleeptimer"
 	"github.com/florianloch/cassette/internal/spotify"
 	"github.com/florianloch/cassette/internal/util"
 
@@ -32,15 +38,24 @@ import (
 )
 
 var (
+	errNotLoggedIn       = errors.New("could not read Spotify token from session. User probably did not log in")
+	errInvalidAPIToken   = errors.New("API token is unknown resp. has been revoked")
+	errInsufficientScope = errors.New("API token has not been granted the scope required")
+
 	auth  spotify.SpotAuthenticator
 	store *sessions.CookieStore
-	dao   persistence.PlayerStatesPersistor
+	dao   persistence.Persistor
+	bus   events.Bus
+	// retention tells how long deleted slots resp. user records can be recovered
+	retention handler.Retention
 	// createSpotClient is required to use different initilisation code for testing
 	// and for production environment
 	createSpotClient spotClientCreator
 )
 
-type spotClientCreator func(token *oauth2.Token) spotify.SpotClient
+// spotClientCreator returns a client persisting refreshed tokens for the given user, userID is empty
+// as long as the user is not known yet
+type spotClientCreator func(userID string, token *oauth2.Token) spotify.SpotClient
 type m map[string]interface{}
 
 func RunInProduction() {
@@ -51,14 +66,20 @@ func RunInProduction() {
 	port := util.Env(constants.EnvPort, os.Getenv("PORT"))
 	appURL := util.Env(constants.EnvAppURL, "http://"+networkInterface+":"+port+"/")
 
-	mongoDBURI := util.Env(constants.EnvMongoURI, "")
-	if mongoDBURI == "" {
-		log.Fatal().Msg("No URI for connecting to MongoDB given. Aborting.")
+	// We also have to check for the former name of this variable in order to not break existing setups
+	dbURI := util.Env(constants.EnvDBURI, os.Getenv(constants.EnvMongoURI))
+	if dbURI == "" {
+		log.Fatal().Msgf("No URI for connecting to the database given. Please set '%s', e.g., to 'bolt://cassette.db'. Aborting.", constants.EnvDBURI)
+	}
+	secret, err := util.Make32ByteSecret(util.Env(constants.EnvSecret, ""))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not generate secret. Aborting.")
 	}
-	var err error
-	dao, err = persistence.Connect(mongoDBURI)
+
+	// Without a fixed secret the stored tokens cannot be decrypted after restarting, users have to log in again then
+	dao, err = persistence.Open(dbURI, util.DeriveKey(secret, "oauth-tokens"))
 	if err != nil {
-		log.Fatal().Err(err).Str("mongoDBURI", mongoDBURI).Msg("Failed connecting to MongoDB.")
+		log.Fatal().Err(err).Str("dbURI", dbURI).Msg("Failed connecting to database.")
 	}
 
 	redirectURL, err := url.Parse(appURL)
@@ -79,11 +100,31 @@ func RunInProduction() {
 
 	auth.SetAuthInfo(clientID, clientSecret)
 
-	createSpotClient = func(token *oauth2.Token) spotify.SpotClient {
-		client := auth.NewClient(token)
-		return &client
+	oauthConfig := spotify.NewOAuthConfig(clientID, clientSecret)
+	budgets := spotify.NewRequestBudgets(constants.SpotifyRequestBudget)
+	createSpotClient = func(userID string, token *oauth2.Token) spotify.SpotClient {
+		var onRefresh spotify.TokenRefreshedFunc
+		if userID != "" {
+			onRefresh = func(token *oauth2.Token) error {
+				return dao.StoreToken(userID, token)
+			}
+		}
+
+		client := spotify.NewRefreshingSpotClient(oauthConfig, token, onRefresh)
+
+		return spotify.NewRetryingSpotClient(client, userID, budgets)
 	}
 
+	// Events are only delivered to clients connected to this instance
+	bus = events.NewInProcessBus()
+
+	setupTrackListingCache()
+	setupRetention()
+	startAutoSaveWorker()
+	go sleeptimer.NewWorker(dao, bus, sleeptimer.ClientCreator(createSpotClient), util.SystemClock, constants.SleepTimerInterval*time.Second).Run(nil)
+	go scheduler.NewWorker(dao, bus, scheduler.ClientCreator(createSpotClient), util.SystemClock, constants.ScheduleInterval*time.Second).Run(nil)
+	go purger.NewWorker(dao, util.SystemClock, constants.PurgeInterval*time.Second).Run(nil)
+
 	cwd, err := os.Getwd()
 	if err != nil {
 		log.Fatal().Err(err).Msg("Could not get current working directory.")
@@ -97,8 +138,69 @@ func RunInProduction() {
 	log.Fatal().Err(err).Msg("Server terminated.")
 }
 
+// startAutoSaveWorker runs the worker saving the progress of users having opted in to this in the background.
+// Setting the interval to "0" disables auto saving for this instance.
+func startAutoSaveWorker() {
+	rawInterval := util.Env(constants.EnvAutoSaveInterval, constants.AutoSaveInterval)
+	interval, err := time.ParseDuration(rawInterval)
+	if err != nil {
+		log.Fatal().Err(err).Str("interval", rawInterval).Msgf("'%s' variable is not set to a valid duration.", constants.EnvAutoSaveInterval)
+	}
+
+	if interval == 0 {
+		log.Info().Msg("Auto saving progress of users is disabled.")
+		return
+	}
+
+	go autosave.NewWorker(dao, bus, autosave.ClientCreator(createSpotClient), interval, retention.Trash).Run(nil)
+}
+
+// setupTrackListingCache configures where the track listings of albums and playlists get cached.
+// Sharing them via the database makes sense when running multiple instances.
+func setupTrackListingCache() {
+	rawTTL := util.Env(constants.EnvTrackListingTTL, constants.TrackListingCacheTTL)
+	ttl, err := time.ParseDuration(rawTTL)
+	if err != nil {
+		log.Fatal().Err(err).Str("ttl", rawTTL).Msgf("'%s' variable is not set to a valid duration.", constants.EnvTrackListingTTL)
+	}
+
+	switch kind := util.Env(constants.EnvTrackListingCache, constants.TrackListingCache); kind {
+	case "memory":
+		spotify.UseTrackListingCache(spotify.NewMemoryTrackListingCache(ttl, constants.TrackListingCacheSize))
+	case "shared":
+		cache := spotify.NewSharedTrackListingCache(dao, ttl, constants.TrackListingCacheSize)
+		spotify.UseTrackListingCache(cache)
+
+		go cache.Run(constants.TrackListingPruning*time.Second, nil)
+	case "off":
+		log.Info().Msg("Caching track listings is disabled.")
+		spotify.UseTrackListingCache(nil)
+	default:
+		log.Fatal().Str("cache", kind).Msgf("'%s' variable has to be one of 'memory', 'shared' or 'off'.", constants.EnvTrackListingCache)
+	}
+}
+
+// setupRetention configures how long deleted slots are kept in the trash and how long the deletion of a user record
+// can be cancelled. Setting either to "0" deletes right away.
+func setupRetention() {
+	retention = handler.Retention{
+		Trash:           durationFromEnv(constants.EnvTrashRetention, constants.TrashRetention),
+		AccountDeletion: durationFromEnv(constants.EnvDeletionGrace, constants.AccountDeletionGrace),
+	}
+}
+
+func durationFromEnv(envName, defaultValue string) time.Duration {
+	raw := util.Env(envName, defaultValue)
+	duration, err := time.ParseDuration(raw)
+	if err != nil || duration < 0 {
+		log.Fatal().Err(err).Str("duration", raw).Msgf("'%s' variable is not set to a valid duration.", envName)
+	}
+
+	return duration
+}
+
 func SetupForTest(
-	daoMock persistence.PlayerStatesPersistor,
+	daoMock persistence.Persistor,
 	authMock spotify.SpotAuthenticator,
 	spotClientMockCreator spotClientCreator,
 	webRoot string) http.Handler {
@@ -111,6 +213,13 @@ func SetupForTest(
 
 	createSpotClient = spotClientMockCreator
 
+	bus = events.NewInProcessBus()
+
+	setupRetention()
+
+	// Every test starts with an empty cache
+	spotify.UseTrackListingCache(spotify.NewMemoryTrackListingCache(time.Hour, constants.TrackListingCacheSize))
+
 	return setupAPI(webRoot, true)
 }
 
@@ -175,31 +284,124 @@ func setupAPI(webRoot string, isDevMode bool) http.Handler {
 	r.Get(constants.OAuthCallbackRoute, spotOAuthCBHandler)
 
 	r.Route("/api", func(r chi.Router) {
+		r.Use(skipCSRFCheckForAPITokens)
 		r.Use(csrfMiddleware)
+		r.Use(attachEventBus)
+		r.Use(attachRetention)
 
 		r.Head("/csrfToken", func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set(constants.CSRFHeaderName, csrf.Token(r))
 			w.WriteHeader(http.StatusOK)
 		})
 
-		r.With(attachDAO).With(attachUser).Route("/you", func(r chi.Router) {
-			r.Get("/", handler.UserExportHandler)
-			r.Delete("/", handler.UserDeleteHandler)
+		r.Get("/openapi.json", handler.OpenAPIHandler)
+
+		// Routes not being attached to a scope cannot be accessed using API tokens
+		read := attachUserWithScope(persistence.ScopeRead)
+		suspend := attachUserWithScope(persistence.ScopeSuspend)
+		restore := attachUserWithScope(persistence.ScopeRestore)
+
+		r.With(attachDAO).Route("/you", func(r chi.Router) {
+			r.With(read).Get("/", handler.UserExportHandler)
+
+			r.With(attachUser).Group(func(r chi.Router) {
+				r.Delete("/", handler.UserDeleteHandler)
+				r.Get("/deletion", handler.AccountDeletionGetHandler)
+				r.Delete("/deletion", handler.AccountDeletionDeleteHandler)
+
+				r.Delete("/token", handler.TokenDeleteHandler)
+
+				r.Route("/autoSave", func(r chi.Router) {
+					r.Get("/", handler.AutoSaveGetHandler)
+					r.Put("/", handler.AutoSavePutHandler)
+					r.Delete("/", handler.AutoSaveDeleteHandler)
+				})
+
+				r.Get("/timeZone", handler.TimeZoneGetHandler)
+				r.Put("/timeZone", handler.TimeZonePutHandler)
+
+				r.Get("/preferences", handler.PreferencesGetHandler)
+				r.Put("/preferences", handler.PreferencesPutHandler)
+
+				r.Route("/apiTokens", func(r chi.Router) {
+					r.Get("/", handler.APITokensGetHandler)
+					r.Post("/", handler.APITokensPostHandler)
+					r.With(attachAPITokenID).Delete("/{apiToken}", handler.APITokensDeleteHandler)
+				})
+			})
+		})
+
+		r.With(read).Get("/activeDevices", handler.ActiveDevicesHandler)
+
+		r.With(read).Get("/events", handler.EventsHandler)
+
+		r.With(attachDAO).Route("/archive", func(r chi.Router) {
+			r.With(read).Get("/", handler.ArchiveGetHandler)
+			r.With(attachUser).Delete("/", handler.ArchiveDeleteHandler)
+			r.With(attachSlot).Route("/{slot}", func(r chi.Router) {
+				r.With(read).Get("/", handler.ArchivedSlotGetHandler)
+				r.With(attachUser).Delete("/", handler.ArchivedSlotDeleteHandler)
+				r.With(attachUser).Post("/unarchive", handler.UnarchiveHandler)
+			})
 		})
 
-		r.With(attachSpotifyClient).Get("/activeDevices", handler.ActiveDevicesHandler)
+		r.With(attachDAO).Route("/history", func(r chi.Router) {
+			r.With(read).Get("/", handler.HistoryGetHandler)
+			r.With(read).Get("/stats", handler.HistoryStatsGetHandler)
+		})
 
-		r.With(attachSpotifyClient).With(attachDAO).With(attachUser).Route("/playerStates", func(r chi.Router) {
-			r.Post("/", handler.PlayerStatesPostHandler)
-			r.Get("/", handler.PlayerStatesGetHandler)
+		r.With(attachDAO).Route("/trash", func(r chi.Router) {
+			r.With(read).Get("/", handler.TrashGetHandler)
+			r.With(attachUser).Delete("/", handler.TrashDeleteHandler)
 			r.With(attachSlot).Route("/{slot}", func(r chi.Router) {
-				r.Put("/", handler.PlayerStatesPostHandler)
-				r.Delete("/", handler.PlayerStatesDeleteHandler)
-				r.Post("/restore", handler.PlayerStatesRestoreHandler)
+				r.With(read).Get("/", handler.TrashedSlotGetHandler)
+				r.With(attachUser).Delete("/", handler.TrashedSlotDeleteHandler)
+				r.With(attachUser).Post("/undelete", handler.UndeleteHandler)
 			})
 		})
 
-		r.NotFound(http.NotFound)
+		r.With(attachDAO).Route("/sleepTimer", func(r chi.Router) {
+			r.With(read).Get("/", handler.SleepTimerGetHandler)
+			r.With(suspend).Put("/", handler.SleepTimerPutHandler)
+			r.With(suspend).Delete("/", handler.SleepTimerDeleteHandler)
+		})
+
+		r.With(attachDAO).Route("/schedules", func(r chi.Router) {
+			r.With(read).Get("/", handler.SchedulesGetHandler)
+			r.With(restore).Post("/", handler.SchedulesPostHandler)
+			r.With(attachSchedule).Route("/{schedule}", func(r chi.Router) {
+				r.With(read).Get("/", handler.ScheduleGetHandler)
+				r.With(restore).Put("/", handler.SchedulePutHandler)
+				r.With(restore).Delete("/", handler.ScheduleDeleteHandler)
+			})
+		})
+
+		r.With(attachDAO).Route("/playerStates", func(r chi.Router) {
+			r.With(suspend).Post("/", handler.PlayerStatesPostHandler)
+			r.With(read).Get("/", handler.PlayerStatesGetHandler)
+			r.With(attachUser).Put("/order", handler.PlayerStatesOrderPutHandler)
+			r.With(attachSlot).Route("/{slot}", func(r chi.Router) {
+				r.With(suspend).Put("/", handler.PlayerStatesPostHandler)
+				r.With(attachUser).Patch("/", handler.PlayerStatesPatchHandler)
+				r.With(attachUser).Delete("/", handler.PlayerStatesDeleteHandler)
+				r.With(restore).Post("/restore", handler.PlayerStatesRestoreHandler)
+				r.With(attachUser).Put("/jumpBack", handler.JumpBackPutHandler)
+				r.With(attachUser).Delete("/jumpBack", handler.JumpBackDeleteHandler)
+
+				r.Route("/bookmarks", func(r chi.Router) {
+					r.With(read).Get("/", handler.BookmarksGetHandler)
+					r.With(suspend).Post("/", handler.BookmarksPostHandler)
+					r.With(attachBookmark).Route("/{bookmark}", func(r chi.Router) {
+						r.With(attachUser).Put("/", handler.BookmarksPutHandler)
+						r.With(attachUser).Delete("/", handler.BookmarksDeleteHandler)
+						r.With(restore).Post("/restore", handler.BookmarksRestoreHandler)
+					})
+				})
+			})
+		})
+
+		r.NotFound(problem.NotFound)
+		r.MethodNotAllowed(problem.MethodNotAllowed)
 	})
 
 	// r.Use(middleware.CreateConsentMiddleware(spaHandler))
@@ -222,7 +424,7 @@ func attachSession(next http.Handler) http.Handler {
 		if err != nil {
 			// This should not never happen except some client tampers with his session.
 			hlog.FromRequest(r).Error().Err(err).Msg("Could not access session storage!")
-			http.Error(w, "Session is invalid. Please delete your session cookie and try again.", http.StatusBadRequest)
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidSession, "Session is invalid. Please delete your session cookie and try again.")
 			return
 		}
 
@@ -232,81 +434,187 @@ func attachSession(next http.Handler) http.Handler {
 	})
 }
 
+// attachUser attaches the user of the session as well as a client for accessing Spotify on her/his behalf.
+// API tokens are not accepted, see attachUserWithScope.
 func attachUser(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-		session := ctx.Value(constants.FieldKeySession).(*sessions.Session)
+	return attachUserWithScope("")(next)
+}
 
-		rawUser, exists := session.Values[constants.SessionKeyUser]
-		if !exists {
-			hlog.FromRequest(r).Debug().Msg("'user' not yet set in session. Going to add it.")
+// attachUserWithScope additionally accepts API tokens having been granted the given scope
+func attachUserWithScope(scope persistence.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			session := ctx.Value(constants.FieldKeySession).(*sessions.Session)
+
+			var user *spotifyAPI.PrivateUser
+			var spotifyClient spotify.SpotClient
+			var err error
+			if token := bearerToken(r); token != "" {
+				user, spotifyClient, err = userFromAPIToken(token, scope)
+			} else {
+				user, spotifyClient, err = userFromSession(w, r, session)
+			}
 
-			// Once per session-lifetime we have to get the user ID from the spotifyClient.
-			// We then cache it in the session.
-			spotifyClient, err := spotifyClientFromSession(session)
 			if err != nil {
+				if err == errNotLoggedIn {
+					hlog.FromRequest(r).Debug().Err(err).Msg("Request is not authenticated.")
+					problem.Respond(w, r, http.StatusForbidden, problem.CodeNotLoggedIn, "Not logged in. Please log in with Spotify first.")
+					return
+				}
+
+				if err == errInvalidAPIToken {
+					hlog.FromRequest(r).Debug().Err(err).Msg("Request is not authenticated.")
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					problem.Respond(w, r, http.StatusUnauthorized, problem.CodeInvalidAPIToken, "The API token is invalid resp. has been revoked.")
+					return
+				}
+
+				if err == errInsufficientScope {
+					hlog.FromRequest(r).Debug().Err(err).Str("scope", string(scope)).Msg("API token lacks scope.")
+					w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope))
+					problem.Respond(w, r, http.StatusForbidden, problem.CodeInsufficientScope, "The API token has not been granted the scope required for this request.")
+					return
+				}
+
+				if retryAfter, temporary := spotify.RetryAfter(err); temporary {
+					hlog.FromRequest(r).Warn().Err(err).Msg("Could not identify user as Spotify is not available.")
+					problem.RespondRetryLater(w, r, problem.CodeSpotifyUnavailable, "Spotify is not available at the moment. Please try again later.", retryAfter)
+					return
+				}
+
 				hlog.FromRequest(r).Error().Err(err).Msg("Could not initialize Spotify client for user!")
-				http.Error(w, err.Error(), http.StatusForbidden)
+				problem.Respond(w, r, http.StatusInternalServerError, problem.CodeSpotifyError, "Could not initialize Spotify client. Please try again.")
 				return
 			}
 
-			rawUser, err = spotifyClient.CurrentUser()
-			if err != nil {
-				hlog.FromRequest(r).Panic().Err(err).Msg("Could not fetch information on user from Spotify!")
-				return
-			}
+			newCtx := context.WithValue(ctx, constants.FieldKeyUser, user)
+			newCtx = context.WithValue(newCtx, constants.FieldKeySpotifyClient, spotifyClient)
 
-			session.Values[constants.SessionKeyUser] = rawUser
+			next.ServeHTTP(w, r.WithContext(newCtx))
+		})
+	}
+}
 
-			err = session.Save(r, w)
-			if err != nil {
-				// This should not happen. We can continue processing the request, the next call to this function
-				// will try again to attach the user to the session.
-				hlog.FromRequest(r).Error().Err(err).Msg("Could not update user's session.")
-			}
+// userFromAPIToken identifies the user the API token belongs to, the client uses the user's token from the token store
+func userFromAPIToken(token string, scope persistence.Scope) (*spotifyAPI.PrivateUser, spotify.SpotClient, error) {
+	apiToken, err := dao.LookupAPIToken(token)
+	if err != nil {
+		if err == persistence.ErrAPITokenNotFound {
+			return nil, nil, errInvalidAPIToken
 		}
 
-		user, ok := rawUser.(*spotifyAPI.PrivateUser)
-		if !ok {
-			// This should never happen
-			hlog.FromRequest(r).Panic().Msg("Could not read current user from session!")
-			return
+		return nil, nil, err
+	}
+
+	if scope == "" || !apiToken.HasScope(scope) {
+		return nil, nil, errInsufficientScope
+	}
+
+	oauthToken, err := dao.LoadToken(apiToken.UserID)
+	if err != nil {
+		if err == persistence.ErrTokenNotFound {
+			// Access to Spotify has been revoked, the user has to log in again using the web app
+			return nil, nil, errNotLoggedIn
 		}
 
-		newCtx := context.WithValue(ctx, constants.FieldKeyUser, user)
+		return nil, nil, err
+	}
 
-		next.ServeHTTP(w, r.WithContext(newCtx))
-	})
+	user := &spotifyAPI.PrivateUser{User: spotifyAPI.User{ID: apiToken.UserID}}
+
+	return user, createSpotClient(user.ID, oauthToken), nil
 }
 
-func attachSpotifyClient(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-		session := ctx.Value(constants.FieldKeySession).(*sessions.Session)
+// bearerToken returns the API token the request is authenticated with, empty if there is none
+func bearerToken(r *http.Request) string {
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) < len("Bearer ") || !strings.EqualFold(authorization[:len("Bearer ")], "Bearer ") {
+		return ""
+	}
 
-		client, err := spotifyClientFromSession(session)
-		if err != nil {
-			hlog.FromRequest(r).Error().Err(err).Msg("Could not initialize Spotify client for user!")
-			http.Error(w, err.Error(), http.StatusForbidden)
-			return
-		}
+	return strings.TrimSpace(authorization[len("Bearer "):])
+}
 
-		newCtx := context.WithValue(ctx, constants.FieldKeySpotifyClient, client)
+// skipCSRFCheckForAPITokens lets requests authenticated by API tokens pass without CSRF token. Browsers do not
+// attach the Authorization header on their own, so these requests cannot be forged.
+func skipCSRFCheckForAPITokens(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if bearerToken(r) != "" {
+			r = csrf.UnsafeSkipCheck(r)
+		}
 
-		next.ServeHTTP(w, r.WithContext(newCtx))
+		next.ServeHTTP(w, r)
 	})
 }
 
-func spotifyClientFromSession(session *sessions.Session) (spotify.SpotClient, error) {
-	rawToken := session.Values[constants.SessionKeySpotifyToken]
+// userFromSession identifies the user of the session and creates a client using the user's token.
+// Right after logging in the session contains the token. It gets moved to the token store as soon as
+// the user is known, from then on the token store is the only source of the token - so access can
+// be revoked by deleting the token.
+func userFromSession(w http.ResponseWriter, r *http.Request, session *sessions.Session) (*spotifyAPI.PrivateUser, spotify.SpotClient, error) {
+	if rawUser, exists := session.Values[constants.SessionKeyUser]; exists {
+		user, ok := rawUser.(*spotifyAPI.PrivateUser)
+		if !ok {
+			// This should never happen
+			return nil, nil, errors.New("could not read current user from session")
+		}
+
+		token, err := dao.LoadToken(user.ID)
+		if err != nil {
+			if err == persistence.ErrTokenNotFound {
+				// Access has been revoked (resp. the session dates back to tokens being kept in the session),
+				// the user has to log in again
+				clearSession(w, r, session)
+				return nil, nil, errNotLoggedIn
+			}
+
+			return nil, nil, err
+		}
 
-	tok, ok := rawToken.(*oauth2.Token)
+		return user, createSpotClient(user.ID, token), nil
+	}
+
+	token, ok := session.Values[constants.SessionKeySpotifyToken].(*oauth2.Token)
 	if !ok {
 		// This happens in case a user requests the /api routes without being signed in via Spotify
-		return nil, errors.New("Could not read Spotify token from session. User probably did not log in.")
+		return nil, nil, errNotLoggedIn
+	}
+
+	hlog.FromRequest(r).Debug().Msg("'user' not yet set in session. Going to add it.")
+
+	// Once per session-lifetime we have to get the user ID from Spotify
+	user, err := createSpotClient("", token).CurrentUser()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not fetch information on user from Spotify: %w", err)
+	}
+
+	err = dao.StoreToken(user.ID, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session.Values[constants.SessionKeyUser] = user
+	delete(session.Values, constants.SessionKeySpotifyToken)
+
+	err = session.Save(r, w)
+	if err != nil {
+		// This should not happen. We can continue processing the request, the next call to this function
+		// will try again to attach the user to the session.
+		hlog.FromRequest(r).Error().Err(err).Msg("Could not update user's session.")
 	}
 
-	return createSpotClient(tok), nil
+	return user, createSpotClient(user.ID, token), nil
+}
+
+func clearSession(w http.ResponseWriter, r *http.Request, session *sessions.Session) {
+	delete(session.Values, constants.SessionKeyUser)
+	delete(session.Values, constants.SessionKeySpotifyToken)
+
+	err := session.Save(r, w)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Could not clear user's session.")
+	}
 }
 
 func attachDAO(next http.Handler) http.Handler {
@@ -317,12 +625,28 @@ func attachDAO(next http.Handler) http.Handler {
 	})
 }
 
+func attachEventBus(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		newCtx := context.WithValue(r.Context(), constants.FieldKeyEventBus, bus)
+
+		next.ServeHTTP(w, r.WithContext(newCtx))
+	})
+}
+
+func attachRetention(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		newCtx := context.WithValue(r.Context(), constants.FieldKeyRetention, retention)
+
+		next.ServeHTTP(w, r.WithContext(newCtx))
+	})
+}
+
 func attachSlot(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		slot, err := checkSlotParameter(r)
 		if err != nil {
 			hlog.FromRequest(r).Debug().Err(err).Msg("Could not retrieve slot from request.")
-			http.Error(w, fmt.Sprintf("Could not process request. Please make sure the given slot is valid: %s", err), http.StatusBadRequest)
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, fmt.Sprintf("Could not process request. Please make sure the given slot is valid: %s", err))
 			return
 		}
 
@@ -332,19 +656,57 @@ func attachSlot(next http.Handler) http.Handler {
 	})
 }
 
-func checkSlotParameter(r *http.Request) (int, error) {
-	var slotStr = chi.URLParam(r, "slot")
+func attachAPITokenID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenID := chi.URLParam(r, "apiToken")
+		if tokenID == "" {
+			hlog.FromRequest(r).Debug().Msg("Could not retrieve API token from request.")
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Could not process request. Please make sure the given API token is valid.")
+			return
+		}
 
-	if slotStr == "" {
-		return -1, errors.New("query parameter 'slot' not found")
-	}
+		newCtx := context.WithValue(r.Context(), constants.FieldKeyAPITokenID, tokenID)
 
-	var slot, err = strconv.Atoi(slotStr)
-	if err != nil {
-		return -1, errors.New("query parameter 'slot' is not a valid integer")
-	}
-	if slot < 0 {
-		return -1, errors.New("query parameter 'slot' has to be >= 0")
+		next.ServeHTTP(w, r.WithContext(newCtx))
+	})
+}
+
+func attachBookmark(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bookmark := chi.URLParam(r, "bookmark")
+		if bookmark == "" {
+			hlog.FromRequest(r).Debug().Msg("Could not retrieve bookmark from request.")
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Could not process request. Please make sure the given bookmark is valid.")
+			return
+		}
+
+		newCtx := context.WithValue(r.Context(), constants.FieldKeyBookmark, bookmark)
+
+		next.ServeHTTP(w, r.WithContext(newCtx))
+	})
+}
+
+func attachSchedule(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		schedule := chi.URLParam(r, "schedule")
+		if schedule == "" {
+			hlog.FromRequest(r).Debug().Msg("Could not retrieve schedule from request.")
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Could not process request. Please make sure the given schedule is valid.")
+			return
+		}
+
+		newCtx := context.WithValue(r.Context(), constants.FieldKeySchedule, schedule)
+
+		next.ServeHTTP(w, r.WithContext(newCtx))
+	})
+}
+
+// checkSlotParameter only checks for presence of the slot, it gets resolved to a player state by the handlers
+func checkSlotParameter(r *http.Request) (string, error) {
+	var slot = chi.URLParam(r, "slot")
+
+	if slot == "" {
+		return "", errors.New("query parameter 'slot' not found")
 	}
 
 	return slot, nil
@@ -352,17 +714,10 @@ func checkSlotParameter(r *http.Request) (int, error) {
 
 type csrfErrorHandler struct{}
 
+// ServeHTTP does not tell the token expected, otherwise the protection could be bypassed
 func (csrfErrorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	failureReason := csrf.FailureReason(r)
-	csrfToken := r.Header.Get(constants.CSRFHeaderName)
-	csrfCookie, err := r.Cookie(constants.CSRFCookieName)
-	csrfCookieContent := "ERROR: cookie not present."
-	if err == nil {
-		csrfCookieContent = csrfCookie.Value
-	}
+	hlog.FromRequest(r).Debug().Err(csrf.FailureReason(r)).Msg("Failed verifying CSRF token.")
 
-	http.Error(w,
-		fmt.Sprintf("Failed verifying CSRF token. Supplied token: '%s'; Error: '%s'. Expect token to be contained in header '%s'. Cookie named '%s' contains '%s'",
-			csrfToken, failureReason, constants.CSRFHeaderName, constants.CSRFCookieName, csrfCookieContent),
-		http.StatusUnauthorized)
+	problem.Respond(w, r, http.StatusForbidden, problem.CodeInvalidCSRFToken,
+		fmt.Sprintf("Failed verifying CSRF token. Expect token to be contained in header '%s'.", constants.CSRFHeaderName))
 }
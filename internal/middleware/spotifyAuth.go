@@ -8,6 +8,7 @@ import (
 	"github.com/rs/zerolog/hlog"
 
 	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/problem"
 	"github.com/florianloch/cassette/internal/spotify"
 	"github.com/florianloch/cassette/internal/util"
 )
@@ -17,7 +18,7 @@ func CreateSpotifyAuthMiddleware(auth spotify.SpotAuthenticator) (func(http.Hand
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			session := r.Context().Value(constants.FieldKeySession).(*sessions.Session)
 
-			if _, ok := session.Values[constants.SessionKeySpotifyToken]; ok {
+			if loggedIn(session) {
 				hlog.FromRequest(r).Debug().Msg("OAuth token already present. Nothing to do.")
 
 				next.ServeHTTP(w, r)
@@ -62,7 +63,7 @@ func CreateSpotifyAuthMiddleware(auth spotify.SpotAuthenticator) (func(http.Hand
 	spotOAuthCBHandler := func(w http.ResponseWriter, r *http.Request) {
 		session := r.Context().Value(constants.FieldKeySession).(*sessions.Session)
 
-		if _, ok := session.Values[constants.SessionKeySpotifyToken]; ok {
+		if loggedIn(session) {
 			hlog.FromRequest(r).Debug().Msg("OAuth token already present. Forwarding to entry page.")
 
 			http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
@@ -76,7 +77,7 @@ func CreateSpotifyAuthMiddleware(auth spotify.SpotAuthenticator) (func(http.Hand
 			// successfully initialized the session already (because of pruning randomState
 			// from session after successful initialization)
 			hlog.FromRequest(r).Error().Msg("Failed to retrieve randomState from session.")
-			http.Error(w, "Session does not contain OAuth state", http.StatusBadRequest)
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeOAuthFailed, "Session does not contain OAuth state")
 			return
 		}
 		randomState := rawRandomState.(string)
@@ -86,14 +87,14 @@ func CreateSpotifyAuthMiddleware(auth spotify.SpotAuthenticator) (func(http.Hand
 				Str("stateGiven", state).
 				Str("stateExpected", randomState).
 				Msg("State mismatch in OAuth callback.")
-			http.Error(w, "State mismatch in OAuth callback", http.StatusBadRequest)
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeOAuthFailed, "State mismatch in OAuth callback")
 			return
 		}
 
 		token, err := auth.Token(randomState, r)
 		if err != nil {
 			hlog.FromRequest(r).Error().Err(err).Msg("Could not get auth token for Spotify.")
-			http.Error(w, "Could not get auth token for Spotify", http.StatusForbidden)
+			problem.Respond(w, r, http.StatusForbidden, problem.CodeOAuthFailed, "Could not get auth token for Spotify")
 			return
 		}
 
@@ -114,7 +115,7 @@ func CreateSpotifyAuthMiddleware(auth spotify.SpotAuthenticator) (func(http.Hand
 		err = session.Save(r, w)
 		if err != nil {
 			hlog.FromRequest(r).Error().Err(err).Msg("Could not update user's session.")
-			http.Error(w, "Could not update user's session", http.StatusInternalServerError)
+			problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "Could not update user's session")
 			return
 		}
 
@@ -126,6 +127,15 @@ func CreateSpotifyAuthMiddleware(auth spotify.SpotAuthenticator) (func(http.Hand
 	return spotAuthMiddleware, spotOAuthCBHandler
 }
 
+// loggedIn checks whether the user completed the OAuth flow already. The token is only kept in the session
+// until the user is known, then it gets moved to the token store.
+func loggedIn(session *sessions.Session) bool {
+	_, hasToken := session.Values[constants.SessionKeySpotifyToken]
+	_, hasUser := session.Values[constants.SessionKeyUser]
+
+	return hasToken || hasUser
+}
+
 func generateRandomState() (string, error) {
 	// This state is used during OAuth negotiation in order to prevent CSRF
 	randomSecret, err := util.Make32ByteSecret("") // Returns a random secret
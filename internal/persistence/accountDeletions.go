@@ -0,0 +1,94 @@
+package persistence
+
+import (
+	"fmt"
+)
+
+const (
+	accountDeletionCollectionName = "account_deletions"
+)
+
+// AccountDeletion is a deletion of everything stored for a user requested by her/him. It is carried out once the
+// grace period is over, until then the user can cancel it.
+type AccountDeletion struct {
+	UserID        string `json:"-" bson:"userID"` // the worker needs the actual ID in order to delete the user record
+	RequestedAtTs int64  `json:"requestedAtTs" bson:"requestedAtTs"`
+	DeleteAtTs    int64  `json:"deleteAtTs" bson:"deleteAtTs"`
+}
+
+type accountDeletionItem struct {
+	Key             string `bson:"_id"`
+	AccountDeletion `bson:"inline"`
+}
+
+// ScheduleAccountDeletion replaces the deletion scheduled for the user the deletion belongs to
+func (p *PlayerStatesDAO) ScheduleAccountDeletion(deletion *AccountDeletion) error {
+	key := hashUserID(deletion.UserID)
+
+	err := p.backend.store(accountDeletionCollectionName, key, &accountDeletionItem{Key: key, AccountDeletion: *deletion}, anyRevision)
+	if err != nil {
+		return fmt.Errorf("could not store account deletion: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PlayerStatesDAO) LoadAccountDeletion(userID string) (*AccountDeletion, error) {
+	deletion, err := p.loadAccountDeletion(hashUserID(userID))
+	if err != nil {
+		if err == errDocumentNotFound {
+			return nil, ErrAccountDeletionNotFound
+		}
+
+		return nil, fmt.Errorf("could not load account deletion: %w", err)
+	}
+
+	return deletion, nil
+}
+
+func (p *PlayerStatesDAO) LoadAccountDeletions() ([]*AccountDeletion, error) {
+	keys, err := p.backend.keys(accountDeletionCollectionName)
+	if err != nil {
+		return nil, fmt.Errorf("could not list account deletions: %w", err)
+	}
+
+	deletions := make([]*AccountDeletion, 0, len(keys))
+	for _, key := range keys {
+		deletion, err := p.loadAccountDeletion(key)
+		if err != nil {
+			if err == errDocumentNotFound {
+				// Cancelled in the meantime
+				continue
+			}
+
+			return nil, fmt.Errorf("could not load account deletion: %w", err)
+		}
+
+		deletions = append(deletions, deletion)
+	}
+
+	return deletions, nil
+}
+
+func (p *PlayerStatesDAO) CancelAccountDeletion(userID string) error {
+	err := p.backend.remove(accountDeletionCollectionName, hashUserID(userID))
+	if err != nil {
+		if err == errDocumentNotFound {
+			return ErrAccountDeletionNotFound
+		}
+
+		return fmt.Errorf("could not cancel account deletion: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PlayerStatesDAO) loadAccountDeletion(key string) (*AccountDeletion, error) {
+	var item accountDeletionItem
+	err := p.backend.load(accountDeletionCollectionName, key, &item)
+	if err != nil {
+		return nil, err
+	}
+
+	return &item.AccountDeletion, nil
+}
@@ -0,0 +1,226 @@
+package persistence
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/florianloch/cassette/internal/util"
+)
+
+const (
+	apiTokenCollectionName = "api_tokens"
+	// apiTokenAttempts limits how often modifying the tokens of a user is retried when they get modified concurrently
+	apiTokenAttempts = 3
+	// apiTokenPrefix makes the tokens recognizable, e.g., for secret scanners
+	apiTokenPrefix = "cassette_"
+)
+
+// Scope limits what an API token can be used for
+type Scope string
+
+const (
+	// ScopeRead allows listing slots, bookmarks and devices
+	ScopeRead Scope = "read"
+	// ScopeSuspend allows suspending the playback into a slot resp. a bookmark
+	ScopeSuspend Scope = "suspend"
+	// ScopeRestore allows restoring the playback from a slot resp. a bookmark
+	ScopeRestore Scope = "restore"
+)
+
+// Scopes lists all scopes an API token can be granted
+var Scopes = []Scope{ScopeRead, ScopeSuspend, ScopeRestore}
+
+// APIToken is a personal access token allowing scripts, shortcuts etc. to access the API on behalf of the user.
+// The token itself is only shown once when creating it, just its hash is stored.
+type APIToken struct {
+	ID          string  `json:"id" bson:"id"`
+	UserID      string  `json:"-" bson:"userID"` // requests authenticated by the token need the actual ID in order to access the user's player states
+	Name        string  `json:"name" bson:"name"`
+	Scopes      []Scope `json:"scopes" bson:"scopes"`
+	CreatedAtTs int64   `json:"createdAtTs" bson:"createdAtTs"`
+}
+
+// HasScope checks whether the token has been granted the given scope
+func (t *APIToken) HasScope(scope Scope) bool {
+	for _, granted := range t.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// apiTokensItem keeps all tokens of a user, this way listing and revoking them only touches the user's data
+type apiTokensItem struct {
+	Key      string            `bson:"_id"`
+	Revision int               `bson:"revision"`
+	Tokens   []*storedAPIToken `bson:"tokens"`
+}
+
+type storedAPIToken struct {
+	APIToken `bson:",inline"`
+	// SecretHash is the SHA-256 of the token's secret part, the secret is random so there is no need for a slow hash
+	SecretHash string `bson:"secretHash"`
+}
+
+// CreateAPIToken hands out a token consisting of the key of the user's tokens, the token's ID and a secret, this way
+// it can be looked up without scanning the tokens of all users. The token cannot be retrieved later on.
+func (p *PlayerStatesDAO) CreateAPIToken(userID string, token *APIToken) (string, error) {
+	id, err := util.RandomID()
+	if err != nil {
+		return "", fmt.Errorf("could not generate ID of API token: %w", err)
+	}
+
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return "", fmt.Errorf("could not generate API token: %w", err)
+	}
+
+	token.ID = id
+	token.UserID = userID
+
+	key := hashUserID(userID)
+	stored := &storedAPIToken{
+		APIToken:   *token,
+		SecretHash: hashSecret(hex.EncodeToString(secret)),
+	}
+
+	err = p.modifyAPITokens(key, func(tokens []*storedAPIToken) ([]*storedAPIToken, error) {
+		return append(tokens, stored), nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return apiTokenPrefix + key + "_" + id + "_" + hex.EncodeToString(secret), nil
+}
+
+func (p *PlayerStatesDAO) LoadAPITokens(userID string) ([]*APIToken, error) {
+	item, err := p.loadAPITokens(hashUserID(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]*APIToken, len(item.Tokens))
+	for i, stored := range item.Tokens {
+		tokens[i] = &stored.APIToken
+	}
+
+	sort.SliceStable(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAtTs < tokens[j].CreatedAtTs
+	})
+
+	return tokens, nil
+}
+
+func (p *PlayerStatesDAO) LookupAPIToken(token string) (*APIToken, error) {
+	splits := strings.Split(strings.TrimPrefix(token, apiTokenPrefix), "_")
+	if !strings.HasPrefix(token, apiTokenPrefix) || len(splits) != 3 {
+		return nil, ErrAPITokenNotFound
+	}
+
+	item, err := p.loadAPITokens(splits[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stored := range item.Tokens {
+		if stored.ID != splits[1] {
+			continue
+		}
+
+		if subtle.ConstantTimeCompare([]byte(stored.SecretHash), []byte(hashSecret(splits[2]))) != 1 {
+			return nil, ErrAPITokenNotFound
+		}
+
+		return &stored.APIToken, nil
+	}
+
+	return nil, ErrAPITokenNotFound
+}
+
+func (p *PlayerStatesDAO) DeleteAPIToken(userID, tokenID string) error {
+	return p.modifyAPITokens(hashUserID(userID), func(tokens []*storedAPIToken) ([]*storedAPIToken, error) {
+		remaining := make([]*storedAPIToken, 0, len(tokens))
+		for _, stored := range tokens {
+			if stored.ID != tokenID {
+				remaining = append(remaining, stored)
+			}
+		}
+
+		if len(remaining) == len(tokens) {
+			return nil, ErrAPITokenNotFound
+		}
+
+		return remaining, nil
+	})
+}
+
+// deleteAPITokens revokes all tokens of the user
+func (p *PlayerStatesDAO) deleteAPITokens(userID string) error {
+	err := p.backend.remove(apiTokenCollectionName, hashUserID(userID))
+	if err != nil && err != errDocumentNotFound {
+		return fmt.Errorf("could not delete API tokens: %w", err)
+	}
+
+	return nil
+}
+
+// modifyAPITokens applies modify to the tokens of a user and retries in case they get modified concurrently. Errors
+// returned by modify are passed on without writing anything.
+func (p *PlayerStatesDAO) modifyAPITokens(key string, modify func([]*storedAPIToken) ([]*storedAPIToken, error)) error {
+	for attempt := 0; attempt < apiTokenAttempts; attempt++ {
+		item, err := p.loadAPITokens(key)
+		if err != nil {
+			return err
+		}
+
+		revision := item.Revision
+		item.Revision++
+		item.Tokens, err = modify(item.Tokens)
+		if err != nil {
+			return err
+		}
+
+		err = p.backend.store(apiTokenCollectionName, key, item, revision)
+		if err == nil {
+			return nil
+		}
+
+		if err != errRevisionMismatch {
+			return fmt.Errorf("could not store API tokens: %w", err)
+		}
+	}
+
+	return fmt.Errorf("could not store API tokens: %w", ErrRevisionMismatch)
+}
+
+func (p *PlayerStatesDAO) loadAPITokens(key string) (*apiTokensItem, error) {
+	var item apiTokensItem
+	err := p.backend.load(apiTokenCollectionName, key, &item)
+	if err != nil {
+		if err == errDocumentNotFound {
+			return &apiTokensItem{Key: key, Tokens: make([]*storedAPIToken, 0)}, nil
+		}
+
+		return nil, fmt.Errorf("could not load API tokens: %w", err)
+	}
+
+	if item.Tokens == nil {
+		item.Tokens = make([]*storedAPIToken, 0)
+	}
+
+	return &item, nil
+}
+
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,114 @@
+package persistence
+
+import (
+	"fmt"
+	"time"
+)
+
+// finishedSlackMs is the time before the end of the last track a slot is considered finished at, Spotify does not
+// necessarily report the exact end
+const finishedSlackMs = 3000
+
+// ArchivedSlot is a slot having been moved out of the player states as it has been listened to completely
+type ArchivedSlot struct {
+	PlayerState   *PlayerState `json:"playerState" bson:"playerState"`
+	CompletedAtTs int64        `json:"completedAtTs" bson:"completedAtTs"`
+}
+
+// Finished tells whether the slot is at resp. past the end of the last track of its album resp. playlist. Shows are
+// never finished as further episodes might get published, single tracks are meant to be kept. The position within
+// the context is unknown for some slots.
+func (p *PlayerState) Finished() bool {
+	if p.ContextType != "album" && p.ContextType != "playlist" {
+		return false
+	}
+
+	if p.TotalTracks <= 0 || p.TrackIndex < p.TotalTracks {
+		return false
+	}
+
+	return p.Duration > 0 && p.Progress >= p.Duration-finishedSlackMs
+}
+
+// ArchiveFinished moves the slot to the archive of the user in case it is finished, it replaces an archived slot
+// with the same ID. An ID gets assigned to the slot if it has none yet. It is up to the caller to remove the slot from
+// the player states afterwards. In case they cannot be saved UndoArchiving has to be called, otherwise the slot would
+// be contained in both.
+func ArchiveFinished(dao ArchiveStore, userID string, playerState *PlayerState, completedAt time.Time) (bool, error) {
+	if !playerState.Finished() {
+		return false, nil
+	}
+
+	err := dao.ArchiveSlot(userID, &ArchivedSlot{PlayerState: playerState, CompletedAtTs: completedAt.Unix()})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// UndoArchiving removes the slot archived by ArchiveFinished from the archive again
+func UndoArchiving(dao ArchiveStore, userID string, playerState *PlayerState) error {
+	return dao.RemoveArchivedSlot(userID, playerState.ID)
+}
+
+// WithoutSlot returns the player states without the one given
+func WithoutSlot(playerStates []*PlayerState, playerState *PlayerState) []*PlayerState {
+	remaining := make([]*PlayerState, 0, len(playerStates))
+	for _, cur := range playerStates {
+		if cur != playerState {
+			remaining = append(remaining, cur)
+		}
+	}
+
+	return remaining
+}
+
+var archiveCollection = &slotCollection{
+	name:    "archive",
+	newSlot: func() collectedSlot { return &ArchivedSlot{} },
+}
+
+func (s *ArchivedSlot) slotID() string {
+	return s.PlayerState.ID
+}
+
+func (p *PlayerStatesDAO) ArchiveSlot(userID string, slot *ArchivedSlot) error {
+	err := assignID(&slot.PlayerState.ID)
+	if err != nil {
+		return fmt.Errorf("could not assign ID to archived slot: %w", err)
+	}
+
+	return p.putSlot(archiveCollection, userID, slot)
+}
+
+func (p *PlayerStatesDAO) RemoveArchivedSlot(userID, slotID string) error {
+	return p.removeSlot(archiveCollection, userID, slotID)
+}
+
+func (p *PlayerStatesDAO) LoadArchive(userID string) ([]*ArchivedSlot, int, error) {
+	slots, revision, err := p.loadSlots(archiveCollection, hashUserID(userID))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return archivedSlots(slots), revision, nil
+}
+
+func (p *PlayerStatesDAO) SaveArchive(userID string, archive []*ArchivedSlot, revision int) (int, error) {
+	slots := make([]collectedSlot, len(archive))
+	for idx, slot := range archive {
+		slots[idx] = slot
+	}
+
+	return p.saveSlots(archiveCollection, hashUserID(userID), slots, revision)
+}
+
+func archivedSlots(slots []collectedSlot) []*ArchivedSlot {
+	archive := make([]*ArchivedSlot, len(slots))
+	for idx, slot := range slots {
+		archive[idx] = slot.(*ArchivedSlot)
+	}
+
+	return archive
+}
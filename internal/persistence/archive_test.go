@@ -0,0 +1,32 @@
+package persistence_test
+
+import (
+	"testing"
+
+	"github.com/florianloch/cassette/internal/persistence"
+)
+
+func TestFinished(t *testing.T) {
+	cases := []struct {
+		state    persistence.PlayerState
+		expected bool
+	}{
+		{persistence.PlayerState{ContextType: "album", TrackIndex: 12, TotalTracks: 12, Progress: 180000, Duration: 180000}, true},
+		// Spotify does not necessarily report the exact end
+		{persistence.PlayerState{ContextType: "album", TrackIndex: 12, TotalTracks: 12, Progress: 178000, Duration: 180000}, true},
+		{persistence.PlayerState{ContextType: "album", TrackIndex: 12, TotalTracks: 12, Progress: 170000, Duration: 180000}, false},
+		{persistence.PlayerState{ContextType: "playlist", TrackIndex: 11, TotalTracks: 12, Progress: 180000, Duration: 180000}, false},
+		// Single tracks are kept
+		{persistence.PlayerState{ContextType: "track", TrackIndex: 1, TotalTracks: 1, Progress: 180000, Duration: 180000}, false},
+		// The position within the context could not be determined
+		{persistence.PlayerState{ContextType: "album", TrackIndex: -1, TotalTracks: -1, Progress: 180000, Duration: 180000}, false},
+		// Further episodes might get published
+		{persistence.PlayerState{ContextType: "show", Progress: 180000, Duration: 180000}, false},
+	}
+
+	for _, c := range cases {
+		if finished := c.state.Finished(); finished != c.expected {
+			t.Errorf("expected %+v to be finished: %t, got %t", c.state, c.expected, finished)
+		}
+	}
+}
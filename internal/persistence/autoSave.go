@@ -0,0 +1,86 @@
+package persistence
+
+import (
+	"fmt"
+)
+
+const (
+	autoSaveCollectionName = "auto_save"
+)
+
+// AutoSaveUser is a user having opted in to automatically saving her/his progress
+type AutoSaveUser struct {
+	UserID string `bson:"userID"` // the worker needs the actual ID in order to access the user's player states
+}
+
+type autoSaveItem struct {
+	Key          string `bson:"_id"`
+	AutoSaveUser `bson:"inline"`
+}
+
+func (p *PlayerStatesDAO) EnableAutoSave(userID string) error {
+	key := hashUserID(userID)
+
+	err := p.backend.store(autoSaveCollectionName, key, &autoSaveItem{Key: key, AutoSaveUser: AutoSaveUser{userID}}, anyRevision)
+	if err != nil {
+		return fmt.Errorf("could not store user having opted in to auto save: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PlayerStatesDAO) DisableAutoSave(userID string) error {
+	err := p.backend.remove(autoSaveCollectionName, hashUserID(userID))
+	if err != nil && err != errDocumentNotFound {
+		return fmt.Errorf("could not disable auto save: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PlayerStatesDAO) AutoSaveEnabled(userID string) (bool, error) {
+	_, err := p.loadAutoSaveUser(hashUserID(userID))
+	if err != nil {
+		if err == errDocumentNotFound {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("could not check whether auto save is enabled: %w", err)
+	}
+
+	return true, nil
+}
+
+func (p *PlayerStatesDAO) LoadAutoSaveUsers() ([]*AutoSaveUser, error) {
+	keys, err := p.backend.keys(autoSaveCollectionName)
+	if err != nil {
+		return nil, fmt.Errorf("could not list users having opted in to auto save: %w", err)
+	}
+
+	users := make([]*AutoSaveUser, 0, len(keys))
+	for _, key := range keys {
+		user, err := p.loadAutoSaveUser(key)
+		if err != nil {
+			if err == errDocumentNotFound {
+				// Opted out in the meantime
+				continue
+			}
+
+			return nil, fmt.Errorf("could not load user having opted in to auto save: %w", err)
+		}
+
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+func (p *PlayerStatesDAO) loadAutoSaveUser(key string) (*AutoSaveUser, error) {
+	var item autoSaveItem
+	err := p.backend.load(autoSaveCollectionName, key, &item)
+	if err != nil {
+		return nil, err
+	}
+
+	return &item.AutoSaveUser, nil
+}
@@ -0,0 +1,60 @@
+package persistence
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// anyRevision can be passed to store in order to skip the revision check
+	anyRevision = -1
+)
+
+var (
+	errDocumentNotFound = errors.New("document not found")
+	errRevisionMismatch = errors.New("revision of stored document does not match")
+)
+
+// backend has to be implemented by every storage engine. Documents are addressed by the
+// collection they belong to and a key being unique within this collection.
+// All backends (de)serialize documents according to their bson tags.
+type backend interface {
+	load(collection, key string, document interface{}) error
+	// store replaces the document atomically, but only in case the revision currently stored matches
+	// expectedRevision. Otherwise errRevisionMismatch is returned. The revision is read from the
+	// document's "revision" field, a missing document resp. field is considered as revision 0.
+	// Incrementing the revision is up to the caller.
+	store(collection, key string, document interface{}, expectedRevision int) error
+	remove(collection, key string) error
+	// keys lists the keys of all documents stored in the collection
+	keys(collection string) ([]string, error)
+	close() error
+}
+
+// revisionOnly is used to decode just the revision of a stored document
+type revisionOnly struct {
+	Revision int `bson:"revision"`
+}
+
+// checkRevision is used by backends not being able to check the revision when writing by themselves.
+// stored is the raw BSON of the currently stored document, nil if there is none.
+func checkRevision(stored []byte, expectedRevision int) error {
+	if expectedRevision == anyRevision {
+		return nil
+	}
+
+	var current revisionOnly
+	if stored != nil {
+		err := bson.Unmarshal(stored, &current)
+		if err != nil {
+			return err
+		}
+	}
+
+	if current.Revision != expectedRevision {
+		return errRevisionMismatch
+	}
+
+	return nil
+}
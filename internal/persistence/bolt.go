@@ -0,0 +1,103 @@
+package persistence
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// boltBackend stores every collection in its own bucket of an embedded BoltDB file.
+// Documents are stored BSON encoded.
+type boltBackend struct {
+	db *bbolt.DB
+}
+
+func openBolt(path string) (*boltBackend, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no path for BoltDB file given")
+	}
+
+	// BoltDB locks the file, so do not wait forever in case another instance is using it
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open BoltDB file at '%s': %w", path, err)
+	}
+
+	log.Info().Msgf("Opened BoltDB backend! Will use '%s' as db.", path)
+
+	return &boltBackend{db}, nil
+}
+
+func (b *boltBackend) load(collection, key string, document interface{}) error {
+	return b.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(collection))
+		if bucket == nil {
+			return errDocumentNotFound
+		}
+
+		raw := bucket.Get([]byte(key))
+		if raw == nil {
+			return errDocumentNotFound
+		}
+
+		return bson.Unmarshal(raw, document)
+	})
+}
+
+func (b *boltBackend) store(collection, key string, document interface{}, expectedRevision int) error {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return fmt.Errorf("could not encode document: %w", err)
+	}
+
+	// BoltDB allows only one read-write transaction at a time, so checking and writing is atomic
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(collection))
+		if err != nil {
+			return err
+		}
+
+		err = checkRevision(bucket.Get([]byte(key)), expectedRevision)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(key), raw)
+	})
+}
+
+func (b *boltBackend) remove(collection, key string) error {
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(collection))
+		if bucket == nil || bucket.Get([]byte(key)) == nil {
+			return errDocumentNotFound
+		}
+
+		return bucket.Delete([]byte(key))
+	})
+}
+
+func (b *boltBackend) keys(collection string) ([]string, error) {
+	keys := make([]string, 0)
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(collection))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(key, _ []byte) error {
+			keys = append(keys, string(key))
+			return nil
+		})
+	})
+
+	return keys, err
+}
+
+func (b *boltBackend) close() error {
+	return b.db.Close()
+}
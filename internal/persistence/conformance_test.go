@@ -0,0 +1,1028 @@
+package persistence_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/florianloch/cassette/internal/persistence"
+	"golang.org/x/oauth2"
+)
+
+var tokenKey = []byte("key for encrypting tokens 32 b..")
+
+// All storage engines have to pass this very same suite.
+// MongoDB is only tested in case a server is available, set CASSETTE_TEST_MONGODB_URI for this.
+
+func TestBoltBackend(t *testing.T) {
+	uri := "bolt://" + filepath.Join(t.TempDir(), "cassette.db")
+
+	runConformanceSuite(t, uri)
+}
+
+func TestFileBackend(t *testing.T) {
+	uri := "file://" + filepath.Join(t.TempDir(), "cassette.json")
+
+	runConformanceSuite(t, uri)
+}
+
+func TestMongoBackend(t *testing.T) {
+	uri := os.Getenv("CASSETTE_TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("CASSETTE_TEST_MONGODB_URI not set, skipping tests against MongoDB.")
+	}
+
+	runConformanceSuite(t, uri)
+}
+
+func TestUnsupportedBackend(t *testing.T) {
+	_, err := persistence.Open("redis://localhost:6379", tokenKey)
+	if err == nil {
+		t.Fatal("expected an error for an unsupported storage engine")
+	}
+}
+
+func TestTokensAreEncrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	dao := openDAO(t, "file://"+path)
+	defer dao.Close()
+
+	err := dao.StoreToken("gopher", &oauth2.Token{AccessToken: "secret-access-token", RefreshToken: "secret-refresh-token"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The worker saving progress in the background reads the token from the TokenStore, it is not kept along
+	err = dao.EnableAutoSave("gopher")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read JSON file: %s", err)
+	}
+
+	if strings.Contains(string(content), "secret-") {
+		t.Fatalf("token is stored in plain text: %s", content)
+	}
+
+	// Using another key the token cannot be read anymore
+	otherDAO, err := persistence.Open("file://"+path, []byte("another key, 32 bytes long......"))
+	if err != nil {
+		t.Fatalf("failed to open: %s", err)
+	}
+
+	if _, err := otherDAO.LoadToken("gopher"); err != persistence.ErrTokenNotFound {
+		t.Fatalf("expected ErrTokenNotFound, got %v", err)
+	}
+}
+
+func runConformanceSuite(t *testing.T, uri string) {
+	// Use distinct users per run, this allows running against a shared MongoDB
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 10)
+	userA := "gopher_a_" + suffix
+	userB := "gopher_b_" + suffix
+
+	dao := openDAO(t, uri)
+	defer func() {
+		_ = dao.DeleteUserRecord(userA)
+		_ = dao.DeleteUserRecord(userB)
+		_ = dao.Close()
+	}()
+
+	t.Run("load unknown user", func(t *testing.T) {
+		playerStates, revision, err := dao.LoadPlayerStates(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if playerStates == nil || len(playerStates) != 0 {
+			t.Fatalf("expected empty, non-nil slice, got %v", playerStates)
+		}
+
+		if revision != 0 {
+			t.Fatalf("expected revision 0 for unknown user, got %d", revision)
+		}
+	})
+
+	t.Run("fetch dump of unknown user", func(t *testing.T) {
+		_, err := dao.FetchJSONDump(userA)
+		if err != persistence.ErrUserNotFound {
+			t.Fatalf("expected ErrUserNotFound, got %v", err)
+		}
+	})
+
+	t.Run("delete unknown user", func(t *testing.T) {
+		err := dao.DeleteUserRecord(userA)
+		if err != persistence.ErrUserNotFound {
+			t.Fatalf("expected ErrUserNotFound, got %v", err)
+		}
+	})
+
+	t.Run("save and load", func(t *testing.T) {
+		expected := []*persistence.PlayerState{fullPlayerState("book 1"), fullPlayerState("book 2")}
+
+		mustSave(t, dao, userA, expected)
+
+		assertPlayerStates(t, mustLoad(t, dao, userA), expected)
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		expected := []*persistence.PlayerState{fullPlayerState("book 3")}
+
+		mustSave(t, dao, userA, expected)
+
+		assertPlayerStates(t, mustLoad(t, dao, userA), expected)
+	})
+
+	t.Run("assigns stable IDs", func(t *testing.T) {
+		playerState := fullPlayerState("book 6")
+		playerState.ID = ""
+		playerState.Bookmarks[0].ID = ""
+
+		mustSave(t, dao, userB, []*persistence.PlayerState{playerState})
+
+		loaded := mustLoad(t, dao, userB)
+		if len(loaded) != 1 || loaded[0].ID == "" || loaded[0].Bookmarks[0].ID == "" {
+			t.Fatalf("expected player state and bookmark to get an ID assigned, got %+v", loaded)
+		}
+
+		if reloaded := mustLoad(t, dao, userB); reloaded[0].ID != loaded[0].ID || reloaded[0].Bookmarks[0].ID != loaded[0].Bookmarks[0].ID {
+			t.Fatalf("IDs changed from '%s' to '%s'", loaded[0].ID, reloaded[0].ID)
+		}
+	})
+
+	t.Run("increments revision", func(t *testing.T) {
+		_, revision, err := dao.LoadPlayerStates(userB)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		newRevision, err := dao.SavePlayerStates(userB, mustLoad(t, dao, userB), revision)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if newRevision != revision+1 {
+			t.Fatalf("expected revision %d, got %d", revision+1, newRevision)
+		}
+
+		if _, loadedRevision, _ := dao.LoadPlayerStates(userB); loadedRevision != newRevision {
+			t.Fatalf("expected loaded revision %d, got %d", newRevision, loadedRevision)
+		}
+	})
+
+	t.Run("rejects stale revision", func(t *testing.T) {
+		_, revision, err := dao.LoadPlayerStates(userB)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		_, err = dao.SavePlayerStates(userB, []*persistence.PlayerState{fullPlayerState("book 7")}, revision-1)
+		if err != persistence.ErrRevisionMismatch {
+			t.Fatalf("expected ErrRevisionMismatch, got %v", err)
+		}
+
+		_, err = dao.SavePlayerStates(userB, []*persistence.PlayerState{fullPlayerState("book 7")}, revision+1)
+		if err != persistence.ErrRevisionMismatch {
+			t.Fatalf("expected ErrRevisionMismatch, got %v", err)
+		}
+
+		if playerStates := mustLoad(t, dao, userB); len(playerStates) != 1 || playerStates[0].AlbumName != "book 6" {
+			t.Fatalf("player states must not have been modified, got %+v", playerStates)
+		}
+	})
+
+	t.Run("users are isolated", func(t *testing.T) {
+		expected := []*persistence.PlayerState{fullPlayerState("book 4"), fullPlayerState("book 5")}
+
+		mustSave(t, dao, userB, expected)
+
+		assertPlayerStates(t, mustLoad(t, dao, userB), expected)
+		assertPlayerStates(t, mustLoad(t, dao, userA), []*persistence.PlayerState{fullPlayerState("book 3")})
+	})
+
+	t.Run("auto save", func(t *testing.T) {
+		enabled, err := dao.AutoSaveEnabled(userA)
+		if err != nil || enabled {
+			t.Fatalf("expected auto save to be disabled by default, got %t (%v)", enabled, err)
+		}
+
+		err = dao.EnableAutoSave(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		enabled, err = dao.AutoSaveEnabled(userA)
+		if err != nil || !enabled {
+			t.Fatalf("expected auto save to be enabled, got %t (%v)", enabled, err)
+		}
+
+		if users := mustLoadAutoSaveUsers(t, dao, userA); len(users) != 1 {
+			t.Fatalf("expected user to have opted in, got %+v", users)
+		}
+
+		err = dao.DisableAutoSave(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if users := mustLoadAutoSaveUsers(t, dao, userA); len(users) != 0 {
+			t.Fatalf("expected user to have opted out, got %+v", users)
+		}
+
+		// Gets deleted together with the user's record
+		err = dao.EnableAutoSave(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	t.Run("token store", func(t *testing.T) {
+		_, err := dao.LoadToken(userA)
+		if err != persistence.ErrTokenNotFound {
+			t.Fatalf("expected ErrTokenNotFound, got %v", err)
+		}
+
+		token := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer", Expiry: time.Unix(1615000000, 0)}
+		err = dao.StoreToken(userA, token)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		token.AccessToken = "refreshed"
+		err = dao.StoreToken(userA, token)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		loaded, err := dao.LoadToken(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if loaded.AccessToken != "refreshed" || loaded.RefreshToken != "refresh" || loaded.TokenType != "Bearer" || !loaded.Expiry.Equal(token.Expiry) {
+			t.Fatalf("token differs, got %+v", loaded)
+		}
+
+		if _, err := dao.LoadToken(userB); err != persistence.ErrTokenNotFound {
+			t.Fatalf("expected ErrTokenNotFound for another user, got %v", err)
+		}
+
+		err = dao.DeleteToken(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if _, err := dao.LoadToken(userA); err != persistence.ErrTokenNotFound {
+			t.Fatalf("expected ErrTokenNotFound after revocation, got %v", err)
+		}
+
+		// Gets deleted together with the user's record
+		err = dao.StoreToken(userA, token)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	t.Run("track listings", func(t *testing.T) {
+		_, err := dao.LoadTrackListing("spotify:album:1")
+		if err != persistence.ErrTrackListingNotFound {
+			t.Fatalf("expected ErrTrackListingNotFound, got %v", err)
+		}
+
+		for i, ts := range []int64{1000, 3000, 2000, 4000} {
+			listing := &persistence.TrackListing{
+				TrackIDs:   []string{"a", "b"},
+				Durations:  []int{60000, 120000},
+				CachedAtTs: ts,
+			}
+
+			err = dao.StoreTrackListing(fmt.Sprintf("spotify:album:%d", i), listing)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+
+		loaded, err := dao.LoadTrackListing("spotify:album:1")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if !reflect.DeepEqual(loaded, &persistence.TrackListing{TrackIDs: []string{"a", "b"}, Durations: []int{60000, 120000}, CachedAtTs: 3000}) {
+			t.Fatalf("track listing differs, got %+v", loaded)
+		}
+
+		// Album 0 is expired, album 2 is the oldest one left
+		err = dao.PruneTrackListings(1500, 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		for i, expected := range []error{persistence.ErrTrackListingNotFound, nil, persistence.ErrTrackListingNotFound, nil} {
+			if _, err := dao.LoadTrackListing(fmt.Sprintf("spotify:album:%d", i)); err != expected {
+				t.Fatalf("expected %v for album %d, got %v", expected, i, err)
+			}
+		}
+	})
+
+	t.Run("api tokens", func(t *testing.T) {
+		if _, err := dao.LookupAPIToken("cassette_unknown_secret"); err != persistence.ErrAPITokenNotFound {
+			t.Fatalf("expected ErrAPITokenNotFound, got %v", err)
+		}
+
+		first, err := dao.CreateAPIToken(userA, &persistence.APIToken{Name: "shortcut", Scopes: []persistence.Scope{persistence.ScopeRead}, CreatedAtTs: 1000})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		second, err := dao.CreateAPIToken(userA, &persistence.APIToken{Name: "speaker", Scopes: persistence.Scopes, CreatedAtTs: 2000})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		_, err = dao.CreateAPIToken(userB, &persistence.APIToken{Name: "other", CreatedAtTs: 3000})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		tokens, err := dao.LoadAPITokens(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(tokens) != 2 || tokens[0].Name != "shortcut" || tokens[1].Name != "speaker" || tokens[1].ID == "" {
+			t.Fatalf("unexpected tokens: %+v", tokens)
+		}
+
+		looked, err := dao.LookupAPIToken(second)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if looked.ID != tokens[1].ID || looked.UserID != userA || !looked.HasScope(persistence.ScopeRestore) {
+			t.Fatalf("unexpected token: %+v", looked)
+		}
+
+		// Only the hash of the token is known, so a token with a wrong secret is unknown
+		forged := second[:len(second)-1] + "x"
+		if _, err := dao.LookupAPIToken(forged); err != persistence.ErrAPITokenNotFound {
+			t.Fatalf("expected ErrAPITokenNotFound for forged token, got %v", err)
+		}
+
+		if err := dao.DeleteAPIToken(userB, tokens[0].ID); err != persistence.ErrAPITokenNotFound {
+			t.Fatalf("expected ErrAPITokenNotFound when revoking token of another user, got %v", err)
+		}
+
+		err = dao.DeleteAPIToken(userA, tokens[0].ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if _, err := dao.LookupAPIToken(first); err != persistence.ErrAPITokenNotFound {
+			t.Fatalf("expected ErrAPITokenNotFound after revocation, got %v", err)
+		}
+	})
+
+	t.Run("sleep timers", func(t *testing.T) {
+		if _, err := dao.LoadSleepTimer(userA); err != persistence.ErrSleepTimerNotFound {
+			t.Fatalf("expected ErrSleepTimerNotFound, got %v", err)
+		}
+
+		if err := dao.DeleteSleepTimer(userA); err != persistence.ErrSleepTimerNotFound {
+			t.Fatalf("expected ErrSleepTimerNotFound, got %v", err)
+		}
+
+		for _, timer := range []*persistence.SleepTimer{
+			{UserID: userA, Mode: persistence.SleepTimerAfterDuration, FiresAtTs: 1000},
+			{UserID: userA, Mode: persistence.SleepTimerAtTrackEnd, Slot: "slot", TrackURI: "spotify:track:1", FiresAtTs: 2000},
+			{UserID: userB, Mode: persistence.SleepTimerAfterDuration, FiresAtTs: 3000},
+		} {
+			err := dao.StoreSleepTimer(timer)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+
+		// Setting a timer replaces the previous one
+		timer, err := dao.LoadSleepTimer(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if timer.UserID != userA || timer.Mode != persistence.SleepTimerAtTrackEnd || timer.Slot != "slot" || timer.TrackURI != "spotify:track:1" || timer.FiresAtTs != 2000 {
+			t.Fatalf("unexpected sleep timer: %+v", timer)
+		}
+
+		timers, err := dao.LoadSleepTimers()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(timers) != 2 {
+			t.Fatalf("expected a timer per user, got %+v", timers)
+		}
+
+		err = dao.DeleteSleepTimer(userB)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if timers, _ := dao.LoadSleepTimers(); len(timers) != 1 || timers[0].UserID != userA {
+			t.Fatalf("expected only the timer of the other user to be left, got %+v", timers)
+		}
+	})
+
+	t.Run("schedules", func(t *testing.T) {
+		schedules, err := dao.LoadSchedules(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if schedules.UserID != userA || schedules.TimeZone != "" || len(schedules.Schedules) != 0 {
+			t.Fatalf("expected no schedules, got %+v", schedules)
+		}
+
+		schedules.TimeZone = "Europe/Berlin"
+		schedules.Schedules = append(schedules.Schedules, &persistence.Schedule{Slot: "slot", DeviceID: "kitchen", Cron: "30 7 * * 1-5", MissedRun: persistence.MissedRunSkip, NextRunAtTs: 1000})
+
+		err = dao.SaveSchedules(schedules)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		id := schedules.Schedules[0].ID
+		if id == "" {
+			t.Fatal("expected ID to be assigned to schedule")
+		}
+
+		stale, err := dao.LoadSchedules(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		schedules.Schedules[0].LastRunAtTs = 1000
+		schedules.Schedules[0].NextRunAtTs = 2000
+
+		err = dao.SaveSchedules(schedules)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if err := dao.SaveSchedules(stale); err != persistence.ErrRevisionMismatch {
+			t.Fatalf("expected ErrRevisionMismatch, got %v", err)
+		}
+
+		err = dao.SaveSchedules(&persistence.UserSchedules{UserID: userB, Schedules: []*persistence.Schedule{{Slot: "other", Cron: "0 8 * * *"}}})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		loaded, err := dao.LoadSchedules(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if loaded.TimeZone != "Europe/Berlin" || len(loaded.Schedules) != 1 || loaded.Find(id) == nil || loaded.Find(id).NextRunAtTs != 2000 || loaded.Find(id).LastRunAtTs != 1000 {
+			t.Fatalf("unexpected schedules: %+v", loaded)
+		}
+
+		all, err := dao.LoadAllSchedules()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(all) != 2 {
+			t.Fatalf("expected schedules of both users, got %+v", all)
+		}
+
+		for _, schedules := range all {
+			if schedules.UserID != userA && schedules.UserID != userB {
+				t.Fatalf("expected the user to be kept along with the schedules, got %+v", schedules)
+			}
+		}
+	})
+
+	t.Run("preferences", func(t *testing.T) {
+		preferences, err := dao.LoadPreferences(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if *preferences != (persistence.Preferences{JumpBackSeconds: persistence.DefaultJumpBackSeconds}) {
+			t.Fatalf("expected default preferences, got %+v", preferences)
+		}
+
+		for _, preferences := range []*persistence.Preferences{{JumpBackSeconds: 30, SmartJumpBack: true}, {JumpBackSeconds: 0}} {
+			err = dao.StorePreferences(userA, preferences)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			loaded, err := dao.LoadPreferences(userA)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if *loaded != *preferences {
+				t.Fatalf("expected %+v, got %+v", preferences, loaded)
+			}
+		}
+
+		if preferences, _ := dao.LoadPreferences(userB); preferences.JumpBackSeconds != persistence.DefaultJumpBackSeconds {
+			t.Fatalf("expected default preferences for other user, got %+v", preferences)
+		}
+	})
+
+	t.Run("history", func(t *testing.T) {
+		history, _, err := dao.LoadHistory(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(history) != 0 {
+			t.Fatalf("expected empty history, got %+v", history)
+		}
+
+		expected := []*persistence.HistoryEvent{
+			persistence.NewHistoryEvent(persistence.HistorySuspended, fullPlayerState("book 1"), time.Unix(1615000000, 0)),
+			persistence.NewHistoryEvent(persistence.HistoryRestored, fullPlayerState("book 1"), time.Unix(1615000060, 0)),
+		}
+		expected[1].Bookmark = "bookmark of book 1"
+
+		for _, event := range expected {
+			err = dao.AppendHistoryEvent(userA, event)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+
+		history, _, err = dao.LoadHistory(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if !reflect.DeepEqual(history, expected) || history[0].PlayerState.Bookmarks != nil {
+			actualJSON, _ := json.Marshal(history)
+			expectedJSON, _ := json.Marshal(expected)
+			t.Fatalf("history differs, expected %s, got %s", expectedJSON, actualJSON)
+		}
+
+		if history, _, _ := dao.LoadHistory(userB); len(history) != 0 {
+			t.Fatalf("expected empty history for other user, got %+v", history)
+		}
+
+		// Bookmarks and notes are not kept, even when given
+		withBookmarks := &persistence.HistoryEvent{Type: persistence.HistorySuspended, Slot: "slot", AtTs: 1615000120, PlayerState: fullPlayerState("book 1")}
+		err = dao.AppendHistoryEvent(userA, withBookmarks)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if history, _, _ := dao.LoadHistory(userA); len(history) != 3 || history[2].PlayerState.Bookmarks != nil || history[2].PlayerState.Notes != "" {
+			t.Fatalf("expected bookmarks and notes to be left out, got %+v", history[len(history)-1].PlayerState)
+		}
+
+		// The oldest events get folded into the aggregates of their contexts. The book is listened to 10 minutes, then
+		// it is started over and listened to a second each time.
+		book := func(progress int) *persistence.PlayerState {
+			return &persistence.PlayerState{ID: "slot", PlaybackContextURI: "spotify:album:long", ContextType: "album", Progress: progress}
+		}
+
+		for i := 0; i < persistence.HistoryMaxEvents; i++ {
+			progress := 600000
+			if i > 0 {
+				progress = (i - 1) * 1000
+			}
+
+			err = dao.AppendHistoryEvent(userB, persistence.NewHistoryEvent(persistence.HistorySuspended, book(progress), time.Unix(int64(i), 0)))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+
+		err = dao.AppendHistoryEvent(userB, persistence.NewHistoryEvent(persistence.HistoryRestored, book(0), time.Unix(1615000000, 0)))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		history, aggregates, err := dao.LoadHistory(userB)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(history) != persistence.HistoryMaxEvents || history[0].AtTs != 1 || history[len(history)-1].AtTs != 1615000000 {
+			t.Fatalf("expected the oldest event to be dropped, got %d events from %d", len(history), history[0].AtTs)
+		}
+
+		if len(aggregates) != 1 || aggregates[0].Sessions != 1 || aggregates[0].LastSuspendedMs != 600000 {
+			t.Fatalf("expected the oldest event to be folded, got %+v", aggregates)
+		}
+
+		stats := persistence.HistoryStats(aggregates, history)
+		if len(stats) != 1 || stats[0].Sessions != persistence.HistoryMaxEvents || stats[0].FirstEventAtTs != 0 {
+			t.Fatalf("expected stats to cover the events dropped, got %+v", stats)
+		}
+
+		if expected := 600000 + (persistence.HistoryMaxEvents-2)*1000; stats[0].ListenedMs != expected {
+			t.Fatalf("expected %d ms listened, got %d", expected, stats[0].ListenedMs)
+		}
+	})
+
+	t.Run("archive", func(t *testing.T) {
+		archive, revision, err := dao.LoadArchive(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(archive) != 0 || revision != 0 {
+			t.Fatalf("expected empty archive, got %+v at revision %d", archive, revision)
+		}
+
+		finished := fullPlayerState("book 6")
+		finished.ID = ""
+
+		err = dao.ArchiveSlot(userA, &persistence.ArchivedSlot{PlayerState: finished, CompletedAtTs: 1615000000})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if finished.ID == "" {
+			t.Fatal("expected an ID to be assigned to the archived slot")
+		}
+
+		// Archiving the same slot again replaces it
+		err = dao.ArchiveSlot(userA, &persistence.ArchivedSlot{PlayerState: finished, CompletedAtTs: 1615000060})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		archive, revision, err = dao.LoadArchive(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(archive) != 1 || archive[0].CompletedAtTs != 1615000060 || revision != 2 {
+			t.Fatalf("unexpected archive %+v at revision %d", archive, revision)
+		}
+
+		assertPlayerStates(t, []*persistence.PlayerState{archive[0].PlayerState}, []*persistence.PlayerState{finished})
+
+		_, err = dao.SaveArchive(userA, archive, revision-1)
+		if err != persistence.ErrRevisionMismatch {
+			t.Fatalf("expected ErrRevisionMismatch, got %v", err)
+		}
+
+		if archive, _, _ := dao.LoadArchive(userB); len(archive) != 0 {
+			t.Fatalf("expected empty archive for other user, got %+v", archive)
+		}
+
+		// Removing a slot not being archived is fine
+		for _, id := range []string{"unknown", finished.ID} {
+			err = dao.RemoveArchivedSlot(userA, id)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+
+		if archive, _, _ := dao.LoadArchive(userA); len(archive) != 0 {
+			t.Fatalf("expected archived slot to be removed, got %+v", archive)
+		}
+
+		err = dao.ArchiveSlot(userA, &persistence.ArchivedSlot{PlayerState: finished, CompletedAtTs: 1615000060})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	t.Run("trash", func(t *testing.T) {
+		trash, revision, err := dao.LoadTrash(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(trash) != 0 || revision != 0 {
+			t.Fatalf("expected empty trash, got %+v at revision %d", trash, revision)
+		}
+
+		for _, trashed := range []*persistence.TrashedSlot{
+			{PlayerState: fullPlayerState("book 7"), DeletedAtTs: 1615000000, PurgeAtTs: 1615000100},
+			{PlayerState: fullPlayerState("book 8"), DeletedAtTs: 1615000000, PurgeAtTs: 1615000100},
+			// Trashing the same slot again replaces it
+			{PlayerState: fullPlayerState("book 8"), DeletedAtTs: 1615000050, PurgeAtTs: 1615000200},
+		} {
+			err := dao.TrashSlot(userA, trashed)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+
+		err = dao.TrashSlot(userB, &persistence.TrashedSlot{PlayerState: fullPlayerState("book 9"), DeletedAtTs: 1615000000, PurgeAtTs: 1615000100})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		trash, revision, err = dao.LoadTrash(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(trash) != 2 || trash[1].DeletedAtTs != 1615000050 || trash[1].PurgeAtTs != 1615000200 || revision != 3 {
+			t.Fatalf("unexpected trash %+v at revision %d", trash, revision)
+		}
+
+		assertPlayerStates(t, []*persistence.PlayerState{trash[0].PlayerState, trash[1].PlayerState}, []*persistence.PlayerState{fullPlayerState("book 7"), fullPlayerState("book 8")})
+
+		_, err = dao.SaveTrash(userA, trash, revision-1)
+		if err != persistence.ErrRevisionMismatch {
+			t.Fatalf("expected ErrRevisionMismatch, got %v", err)
+		}
+
+		// Only slots being due get purged
+		purged, err := dao.PurgeTrash(time.Unix(1615000100, 0))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if purged != 2 {
+			t.Fatalf("expected 2 slots to be purged, got %d", purged)
+		}
+
+		if trash, _, _ := dao.LoadTrash(userA); len(trash) != 1 || trash[0].PlayerState.ID != "id of book 8" {
+			t.Fatalf("expected only the slot not being due to be kept, got %+v", trash)
+		}
+
+		if trash, _, _ := dao.LoadTrash(userB); len(trash) != 0 {
+			t.Fatalf("expected trash of other user to be purged, got %+v", trash)
+		}
+
+		err = dao.TrashSlot(userA, &persistence.TrashedSlot{PlayerState: fullPlayerState("book 10"), DeletedAtTs: 1615000100, PurgeAtTs: 1615000300})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		// Removing a slot not being trashed is fine
+		for _, id := range []string{"unknown", "id of book 10"} {
+			err = dao.RemoveTrashedSlot(userA, id)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+
+		if trash, _, _ := dao.LoadTrash(userA); len(trash) != 1 || trash[0].PlayerState.ID != "id of book 8" {
+			t.Fatalf("expected trashed slot to be removed, got %+v", trash)
+		}
+	})
+
+	t.Run("account deletions", func(t *testing.T) {
+		if _, err := dao.LoadAccountDeletion(userA); err != persistence.ErrAccountDeletionNotFound {
+			t.Fatalf("expected ErrAccountDeletionNotFound, got %v", err)
+		}
+
+		if err := dao.CancelAccountDeletion(userA); err != persistence.ErrAccountDeletionNotFound {
+			t.Fatalf("expected ErrAccountDeletionNotFound, got %v", err)
+		}
+
+		for _, deletion := range []*persistence.AccountDeletion{
+			{UserID: userA, RequestedAtTs: 1615000000, DeleteAtTs: 1615604800},
+			{UserID: userA, RequestedAtTs: 1615000100, DeleteAtTs: 1615604900},
+			{UserID: userB, RequestedAtTs: 1615000000, DeleteAtTs: 1615604800},
+		} {
+			err := dao.ScheduleAccountDeletion(deletion)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+
+		// Scheduling a deletion replaces the previous one
+		deletion, err := dao.LoadAccountDeletion(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if deletion.UserID != userA || deletion.RequestedAtTs != 1615000100 || deletion.DeleteAtTs != 1615604900 {
+			t.Fatalf("unexpected account deletion: %+v", deletion)
+		}
+
+		deletions, err := dao.LoadAccountDeletions()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(deletions) != 2 {
+			t.Fatalf("expected a deletion per user, got %+v", deletions)
+		}
+
+		err = dao.CancelAccountDeletion(userB)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if deletions, _ := dao.LoadAccountDeletions(); len(deletions) != 1 || deletions[0].UserID != userA {
+			t.Fatalf("expected only the deletion of the other user to be left, got %+v", deletions)
+		}
+	})
+
+	t.Run("fetch dump", func(t *testing.T) {
+		dump, err := dao.FetchJSONDump(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		var decoded struct {
+			PlayerStates []map[string]interface{} `json:"playerStates"`
+		}
+		err = json.Unmarshal(dump, &decoded)
+		if err != nil {
+			t.Fatalf("dump is not valid JSON: %s", err)
+		}
+
+		if len(decoded.PlayerStates) != 1 || decoded.PlayerStates[0]["albumName"] != "book 3" {
+			t.Fatalf("dump does not contain the expected player states: %s", dump)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		err := dao.DeleteUserRecord(userA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if playerStates := mustLoad(t, dao, userA); len(playerStates) != 0 {
+			t.Fatalf("expected no player states after deletion, got %v", playerStates)
+		}
+
+		if enabled, _ := dao.AutoSaveEnabled(userA); enabled {
+			t.Fatal("expected auto save to be disabled after deletion")
+		}
+
+		if _, err := dao.LoadToken(userA); err != persistence.ErrTokenNotFound {
+			t.Fatalf("expected token to be deleted, got %v", err)
+		}
+
+		if tokens, _ := dao.LoadAPITokens(userA); len(tokens) != 0 {
+			t.Fatalf("expected API tokens to be deleted, got %+v", tokens)
+		}
+
+		if tokens, _ := dao.LoadAPITokens(userB); len(tokens) != 1 {
+			t.Fatalf("expected API tokens of other users to be kept, got %+v", tokens)
+		}
+
+		if _, err := dao.LoadSleepTimer(userA); err != persistence.ErrSleepTimerNotFound {
+			t.Fatalf("expected sleep timer to be deleted, got %v", err)
+		}
+
+		if schedules, _ := dao.LoadSchedules(userA); len(schedules.Schedules) != 0 || schedules.TimeZone != "" {
+			t.Fatalf("expected schedules to be deleted, got %+v", schedules)
+		}
+
+		if all, _ := dao.LoadAllSchedules(); len(all) != 1 || all[0].UserID != userB {
+			t.Fatalf("expected schedules of other users to be kept, got %+v", all)
+		}
+
+		if preferences, _ := dao.LoadPreferences(userA); preferences.JumpBackSeconds != persistence.DefaultJumpBackSeconds {
+			t.Fatalf("expected preferences to be deleted, got %+v", preferences)
+		}
+
+		if history, _, _ := dao.LoadHistory(userA); len(history) != 0 {
+			t.Fatalf("expected history to be deleted, got %+v", history)
+		}
+
+		if archive, _, _ := dao.LoadArchive(userA); len(archive) != 0 {
+			t.Fatalf("expected archive to be deleted, got %+v", archive)
+		}
+
+		if trash, _, _ := dao.LoadTrash(userA); len(trash) != 0 {
+			t.Fatalf("expected trash to be deleted, got %+v", trash)
+		}
+
+		if _, err := dao.LoadAccountDeletion(userA); err != persistence.ErrAccountDeletionNotFound {
+			t.Fatalf("expected account deletion to be cancelled, got %v", err)
+		}
+
+		assertPlayerStates(t, mustLoad(t, dao, userB), []*persistence.PlayerState{fullPlayerState("book 4"), fullPlayerState("book 5")})
+	})
+
+	t.Run("survives reopening", func(t *testing.T) {
+		err := dao.Close()
+		if err != nil {
+			t.Fatalf("failed to close: %s", err)
+		}
+
+		dao = openDAO(t, uri)
+
+		assertPlayerStates(t, mustLoad(t, dao, userB), []*persistence.PlayerState{fullPlayerState("book 4"), fullPlayerState("book 5")})
+	})
+}
+
+func openDAO(t *testing.T, uri string) *persistence.PlayerStatesDAO {
+	dao, err := persistence.Open(uri, tokenKey)
+	if err != nil {
+		t.Fatalf("failed to open '%s': %s", uri, err)
+	}
+
+	return dao
+}
+
+// mustSave overwrites whatever revision is currently stored
+func mustSave(t *testing.T, dao persistence.PlayerStatesPersistor, userID string, playerStates []*persistence.PlayerState) {
+	_, revision, err := dao.LoadPlayerStates(userID)
+	if err != nil {
+		t.Fatalf("failed to load revision: %s", err)
+	}
+
+	_, err = dao.SavePlayerStates(userID, playerStates, revision)
+	if err != nil {
+		t.Fatalf("failed to save player states: %s", err)
+	}
+}
+
+func mustLoad(t *testing.T, dao persistence.PlayerStatesPersistor, userID string) []*persistence.PlayerState {
+	playerStates, _, err := dao.LoadPlayerStates(userID)
+	if err != nil {
+		t.Fatalf("failed to load player states: %s", err)
+	}
+
+	return playerStates
+}
+
+// mustLoadAutoSaveUsers only returns the given user, there might be others when running against a shared MongoDB
+func mustLoadAutoSaveUsers(t *testing.T, dao persistence.AutoSavePersistor, userID string) []*persistence.AutoSaveUser {
+	users, err := dao.LoadAutoSaveUsers()
+	if err != nil {
+		t.Fatalf("failed to load users having opted in to auto save: %s", err)
+	}
+
+	filtered := make([]*persistence.AutoSaveUser, 0)
+	for _, user := range users {
+		if user.UserID == userID {
+			filtered = append(filtered, user)
+		}
+	}
+
+	return filtered
+}
+
+func assertPlayerStates(t *testing.T, actual, expected []*persistence.PlayerState) {
+	if !reflect.DeepEqual(actual, expected) {
+		actualJSON, _ := json.Marshal(actual)
+		expectedJSON, _ := json.Marshal(expected)
+		t.Fatalf("player states differ, expected %s, got %s", expectedJSON, actualJSON)
+	}
+}
+
+// fullPlayerState sets every field, also those not contained in the JSON representation
+func fullPlayerState(albumName string) *persistence.PlayerState {
+	// Zero has to be kept as it tells the slot must not jump back at all
+	jumpBackSeconds := 0
+
+	return &persistence.PlayerState{
+		ID:                 "id of " + albumName,
+		PlaybackContextURI: "spotify:album:" + albumName,
+		PlaybackItemURI:    "spotify:track:" + albumName,
+		LinkToContext:      "https://open.spotify.com/album/" + albumName,
+		ContextType:        "album",
+		PlaylistName:       "playlist of " + albumName,
+		ShowName:           "show of " + albumName,
+		AlbumArtLargeURL:   "https://i.scdn.co/image/large",
+		AlbumArtMediumURL:  "https://i.scdn.co/image/medium",
+		TrackName:          "chapter of " + albumName,
+		AlbumName:          albumName,
+		ArtistName:         "Gopher",
+		TrackIndex:         3,
+		TotalTracks:        42,
+		Progress:           1337,
+		Duration:           180000,
+		ContextDuration:    7560000,
+		ContextElapsed:     360000,
+		ShuffleActivated:   true,
+		RepeatState:        "context",
+		Volume:             42,
+		DeviceName:         "Kitchen",
+		SuspendedAtTs:      1615000000,
+		AutoSaved:          true,
+		JumpBackSeconds:    &jumpBackSeconds,
+		Title:              albumName + " (unabridged)",
+		Notes:              "recommended by a friend",
+		Tags:               []string{"fantasy", "favorite"},
+		Pinned:             true,
+		Bookmarks: []*persistence.Bookmark{{
+			ID:          "bookmark of " + albumName,
+			Name:        "favourite passage",
+			Note:        "the one with the dragon",
+			TrackURI:    "spotify:track:" + albumName,
+			TrackName:   "chapter of " + albumName,
+			Progress:    4242,
+			CreatedAtTs: 1615000042,
+		}},
+	}
+}
@@ -0,0 +1,164 @@
+package persistence
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// fileBackend keeps all documents in memory and writes them to a single JSON file on every change.
+// This is only intended for setups with very few users, e.g., self-hosting Cassette.
+type fileBackend struct {
+	path        string
+	mutex       sync.Mutex
+	collections map[string]map[string]bson.Raw
+}
+
+func openFile(path string) (*fileBackend, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no path for JSON file given")
+	}
+
+	f := &fileBackend{
+		path:        path,
+		collections: make(map[string]map[string]bson.Raw),
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read JSON file at '%s': %w", path, err)
+	}
+
+	if len(content) > 0 {
+		// Using extended JSON the bson tags get used, just like for all the other backends
+		err = bson.UnmarshalExtJSON(content, false, &f.collections)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JSON file at '%s': %w", path, err)
+		}
+	}
+
+	log.Info().Msgf("Opened JSON file backend! Will use '%s' as db.", path)
+
+	return f, nil
+}
+
+func (f *fileBackend) load(collection, key string, document interface{}) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	raw, ok := f.collections[collection][key]
+	if !ok {
+		return errDocumentNotFound
+	}
+
+	return bson.Unmarshal(raw, document)
+}
+
+func (f *fileBackend) store(collection, key string, document interface{}, expectedRevision int) error {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return fmt.Errorf("could not encode document: %w", err)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.collections[collection] == nil {
+		f.collections[collection] = make(map[string]bson.Raw)
+	}
+
+	previous, existed := f.collections[collection][key]
+
+	err = checkRevision(previous, expectedRevision)
+	if err != nil {
+		return err
+	}
+
+	f.collections[collection][key] = raw
+
+	err = f.flush()
+	if err != nil {
+		// Keep memory and file consistent
+		if existed {
+			f.collections[collection][key] = previous
+		} else {
+			delete(f.collections[collection], key)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (f *fileBackend) remove(collection, key string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	previous, ok := f.collections[collection][key]
+	if !ok {
+		return errDocumentNotFound
+	}
+
+	delete(f.collections[collection], key)
+
+	err := f.flush()
+	if err != nil {
+		f.collections[collection][key] = previous
+
+		return err
+	}
+
+	return nil
+}
+
+func (f *fileBackend) keys(collection string) ([]string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	keys := make([]string, 0, len(f.collections[collection]))
+	for key := range f.collections[collection] {
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (f *fileBackend) close() error {
+	return nil
+}
+
+// flush writes all documents to a temporary file which then replaces the previous one.
+// By this the file does not get corrupted in case the process dies while writing.
+// Has to be called while holding the mutex.
+func (f *fileBackend) flush() error {
+	content, err := bson.MarshalExtJSON(f.collections, false, false)
+	if err != nil {
+		return fmt.Errorf("could not encode documents: %w", err)
+	}
+
+	var indented bytes.Buffer
+	err = json.Indent(&indented, content, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode documents: %w", err)
+	}
+
+	tmpPath := f.path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, indented.Bytes(), 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write JSON file at '%s': %w", tmpPath, err)
+	}
+
+	err = os.Rename(tmpPath, f.path)
+	if err != nil {
+		return fmt.Errorf("failed to replace JSON file at '%s': %w", f.path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,273 @@
+package persistence
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	historyCollectionName = "history"
+
+	// historyAppendAttempts limits how often appending is retried when the history gets modified concurrently
+	historyAppendAttempts = 3
+
+	// HistoryMaxEvents limits the events kept in the history of a user, the oldest ones get folded into the
+	// aggregates of their contexts. This keeps the document rewritten with every event small, MongoDB does not allow
+	// documents to grow beyond 16 MB anyway.
+	HistoryMaxEvents = 500
+)
+
+// HistoryEventType tells what happened to a slot
+type HistoryEventType string
+
+const (
+	// HistorySuspended is recorded whenever the playback gets suspended into a slot, e.g., by a sleep timer
+	HistorySuspended HistoryEventType = "suspended"
+	// HistoryRestored is recorded whenever a slot resp. one of its bookmarks gets restored, e.g., by a schedule
+	HistoryRestored HistoryEventType = "restored"
+)
+
+// HistoryEvent records a snapshot of a slot at the time it has been suspended resp. restored
+type HistoryEvent struct {
+	Type HistoryEventType `json:"type" bson:"type"`
+	Slot string           `json:"slot" bson:"slot"`
+	// Bookmark is the ID of the bookmark restored, empty unless a bookmark has been restored
+	Bookmark    string       `json:"bookmark,omitempty" bson:"bookmark,omitempty"`
+	AtTs        int64        `json:"atTs" bson:"atTs"`
+	PlayerState *PlayerState `json:"playerState" bson:"playerState"`
+}
+
+// NewHistoryEvent snapshots the given slot, its bookmarks and notes are left out as they are not part of the playback
+func NewHistoryEvent(eventType HistoryEventType, playerState *PlayerState, at time.Time) *HistoryEvent {
+	snapshot := *playerState
+	snapshot.Bookmarks = nil
+	snapshot.Notes = ""
+
+	return &HistoryEvent{
+		Type:        eventType,
+		Slot:        playerState.ID,
+		AtTs:        at.Unix(),
+		PlayerState: &snapshot,
+	}
+}
+
+// ContextStats summarize the history of an album, playlist, episode resp. single track. Listened time is
+// estimated by how far the progress advanced between consecutive suspends, jumping back does not count.
+type ContextStats struct {
+	// Latest is the snapshot of the latest event, it tells the name, the artwork and the progress
+	Latest           *PlayerState `json:"latest"`
+	Sessions         int          `json:"sessions"` // number of times the context has been suspended
+	ListenedMs       int          `json:"listenedMs"`
+	AverageSessionMs int          `json:"averageSessionMs"`
+	FirstEventAtTs   int64        `json:"firstEventAtTs"`
+	LastEventAtTs    int64        `json:"lastEventAtTs"`
+	// EstimatedFinishAtTs extrapolates the pace so far, omitted when the duration is unknown resp. nothing has been listened yet
+	EstimatedFinishAtTs int64 `json:"estimatedFinishAtTs,omitempty"`
+}
+
+// HistoryAggregate sums up the events of a context, events dropped from the history are folded into it so the
+// stats still cover them
+type HistoryAggregate struct {
+	// Key identifies the context, see historyKey
+	Key            string       `json:"key" bson:"key"`
+	Latest         *PlayerState `json:"latest" bson:"latest"`
+	Sessions       int          `json:"sessions" bson:"sessions"`
+	ListenedMs     int          `json:"listenedMs" bson:"listenedMs"`
+	FirstEventAtTs int64        `json:"firstEventAtTs" bson:"firstEventAtTs"`
+	LastEventAtTs  int64        `json:"lastEventAtTs" bson:"lastEventAtTs"`
+	// LastSuspendedMs is the overall progress at the latest suspend, the next one counts from there
+	LastSuspendedMs int `json:"lastSuspendedMs" bson:"lastSuspendedMs"`
+}
+
+// HistoryStats computes the stats of every context in the history, taking into account the aggregates of the events
+// dropped from it. The contexts touched most recently come first.
+func HistoryStats(aggregates []*HistoryAggregate, history []*HistoryEvent) []*ContextStats {
+	stats := make([]*ContextStats, 0, len(aggregates))
+
+	for _, aggregate := range foldHistory(aggregates, history) {
+		contextStats := &ContextStats{
+			Latest:         aggregate.Latest,
+			Sessions:       aggregate.Sessions,
+			ListenedMs:     aggregate.ListenedMs,
+			FirstEventAtTs: aggregate.FirstEventAtTs,
+			LastEventAtTs:  aggregate.LastEventAtTs,
+		}
+
+		if contextStats.Sessions > 0 {
+			contextStats.AverageSessionMs = contextStats.ListenedMs / contextStats.Sessions
+		}
+
+		contextStats.EstimatedFinishAtTs = contextStats.estimatedFinish()
+
+		stats = append(stats, contextStats)
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].LastEventAtTs > stats[j].LastEventAtTs
+	})
+
+	return stats
+}
+
+// foldHistory adds the events to the aggregates of their contexts, the aggregates given are not modified
+func foldHistory(aggregates []*HistoryAggregate, history []*HistoryEvent) []*HistoryAggregate {
+	byContext := make(map[string]*HistoryAggregate)
+	folded := make([]*HistoryAggregate, 0, len(aggregates))
+
+	for _, aggregate := range aggregates {
+		copied := *aggregate
+		byContext[copied.Key] = &copied
+		folded = append(folded, &copied)
+	}
+
+	for _, event := range history {
+		key := event.PlayerState.historyKey()
+
+		aggregate, ok := byContext[key]
+		if !ok {
+			aggregate = &HistoryAggregate{Key: key, FirstEventAtTs: event.AtTs}
+			byContext[key] = aggregate
+			folded = append(folded, aggregate)
+		}
+
+		aggregate.Latest = event.PlayerState
+		aggregate.LastEventAtTs = event.AtTs
+
+		if event.Type != HistorySuspended {
+			continue
+		}
+
+		// The first suspend counts from the beginning of the context
+		elapsed, _, _ := event.PlayerState.OverallProgress()
+		if advanced := elapsed - aggregate.LastSuspendedMs; advanced > 0 {
+			aggregate.ListenedMs += advanced
+		}
+
+		aggregate.LastSuspendedMs = elapsed
+		aggregate.Sessions++
+	}
+
+	return folded
+}
+
+// estimatedFinish assumes the context keeps being listened to at the pace since the first event, which is at
+// least one day in order not to extrapolate a single binge
+func (c *ContextStats) estimatedFinish() int64 {
+	elapsed, total, known := c.Latest.OverallProgress()
+	if !known || c.ListenedMs == 0 || elapsed >= total {
+		return 0
+	}
+
+	period := time.Duration(c.LastEventAtTs-c.FirstEventAtTs) * time.Second
+	if period < 24*time.Hour {
+		period = 24 * time.Hour
+	}
+
+	remaining := time.Duration(total-elapsed) * time.Millisecond
+	pace := float64(c.ListenedMs) / float64(period.Milliseconds())
+
+	return c.LastEventAtTs + int64(math.Round(remaining.Seconds()/pace))
+}
+
+// historyKey identifies the context stats are computed for, episodes are considered on their own as listening
+// to a show does not advance through it like through an audiobook
+func (p *PlayerState) historyKey() string {
+	if p.ContextType == "track" || p.ContextType == "show" {
+		return p.PlaybackItemURI
+	}
+
+	return p.PlaybackContextURI
+}
+
+type historyItem struct {
+	Key      string          `bson:"_id"`
+	Revision int             `bson:"revision"`
+	Events   []*HistoryEvent `bson:"events"`
+	// Aggregates sum up the events dropped from Events
+	Aggregates []*HistoryAggregate `bson:"aggregates"`
+}
+
+// AppendHistoryEvent adds the event to the end of the user's history, events are never modified afterwards.
+// Only the latest HistoryMaxEvents are kept, older ones get folded into the aggregates of their contexts.
+func (p *PlayerStatesDAO) AppendHistoryEvent(userID string, event *HistoryEvent) error {
+	key := hashUserID(userID)
+
+	// Bookmarks are not part of the playback, events not created by NewHistoryEvent must not carry them either
+	if event.PlayerState != nil && (len(event.PlayerState.Bookmarks) > 0 || event.PlayerState.Notes != "") {
+		snapshot := *event.PlayerState
+		snapshot.Bookmarks = nil
+		snapshot.Notes = ""
+
+		trimmed := *event
+		trimmed.PlayerState = &snapshot
+		event = &trimmed
+	}
+
+	for attempt := 0; attempt < historyAppendAttempts; attempt++ {
+		item, err := p.loadHistory(key)
+		if err != nil {
+			return fmt.Errorf("could not load history: %w", err)
+		}
+
+		revision := item.Revision
+		item.Revision++
+		item.Events = append(item.Events, event)
+		if excess := len(item.Events) - HistoryMaxEvents; excess > 0 {
+			item.Aggregates = foldHistory(item.Aggregates, item.Events[:excess])
+			item.Events = item.Events[excess:]
+		}
+
+		err = p.backend.store(historyCollectionName, key, item, revision)
+		if err == nil {
+			return nil
+		}
+
+		if err != errRevisionMismatch {
+			return fmt.Errorf("could not store history: %w", err)
+		}
+	}
+
+	return fmt.Errorf("could not store history: %w", ErrRevisionMismatch)
+}
+
+func (p *PlayerStatesDAO) LoadHistory(userID string) ([]*HistoryEvent, []*HistoryAggregate, error) {
+	item, err := p.loadHistory(hashUserID(userID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not load history: %w", err)
+	}
+
+	return item.Events, item.Aggregates, nil
+}
+
+func (p *PlayerStatesDAO) loadHistory(key string) (*historyItem, error) {
+	var item historyItem
+	err := p.backend.load(historyCollectionName, key, &item)
+	if err != nil {
+		if err == errDocumentNotFound {
+			return &historyItem{Key: key, Events: make([]*HistoryEvent, 0), Aggregates: make([]*HistoryAggregate, 0)}, nil
+		}
+
+		return nil, err
+	}
+
+	if item.Events == nil {
+		item.Events = make([]*HistoryEvent, 0)
+	}
+
+	if item.Aggregates == nil {
+		item.Aggregates = make([]*HistoryAggregate, 0)
+	}
+
+	return &item, nil
+}
+
+func (p *PlayerStatesDAO) deleteHistory(userID string) error {
+	err := p.backend.remove(historyCollectionName, hashUserID(userID))
+	if err != nil && err != errDocumentNotFound {
+		return fmt.Errorf("could not delete history: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package persistence_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/florianloch/cassette/internal/persistence"
+)
+
+func TestHistoryStats(t *testing.T) {
+	start := time.Date(2021, 3, 1, 20, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	book := func(elapsed time.Duration) *persistence.PlayerState {
+		return &persistence.PlayerState{
+			ID:                 "book",
+			PlaybackContextURI: "spotify:album:book",
+			ContextType:        "album",
+			Progress:           int(elapsed.Milliseconds()),
+			ContextDuration:    int((10 * time.Hour).Milliseconds()),
+		}
+	}
+
+	track := &persistence.PlayerState{ID: "track", PlaybackItemURI: "spotify:track:track", ContextType: "track", Progress: 60000}
+
+	history := []*persistence.HistoryEvent{
+		// The first suspend counts from the beginning of the book
+		persistence.NewHistoryEvent(persistence.HistorySuspended, book(time.Hour), start),
+		persistence.NewHistoryEvent(persistence.HistoryRestored, book(time.Hour-10*time.Second), start.Add(day)),
+		persistence.NewHistoryEvent(persistence.HistorySuspended, book(2*time.Hour), start.Add(day+time.Hour)),
+		// Jumping back does not count as listening
+		persistence.NewHistoryEvent(persistence.HistoryRestored, book(time.Hour), start.Add(2*day)),
+		persistence.NewHistoryEvent(persistence.HistorySuspended, book(90*time.Minute), start.Add(2*day+30*time.Minute)),
+		persistence.NewHistoryEvent(persistence.HistoryRestored, book(90*time.Minute), start.Add(2*day+time.Hour)),
+		persistence.NewHistoryEvent(persistence.HistorySuspended, book(3*time.Hour), start.Add(2*day+2*time.Hour)),
+		persistence.NewHistoryEvent(persistence.HistorySuspended, track, start.Add(3*day)),
+	}
+
+	stats := persistence.HistoryStats(nil, history)
+	if len(stats) != 2 {
+		t.Fatalf("expected stats of 2 contexts, got %d", len(stats))
+	}
+
+	// Without a known duration nothing can be estimated
+	if s := stats[0]; s.Latest.ID != "track" || s.Sessions != 1 || s.ListenedMs != 60000 || s.EstimatedFinishAtTs != 0 {
+		t.Fatalf("unexpected stats of track: %+v", s)
+	}
+
+	s := stats[1]
+	if s.Latest.ID != "book" || s.Sessions != 4 || s.FirstEventAtTs != start.Unix() || s.LastEventAtTs != start.Add(2*day+2*time.Hour).Unix() {
+		t.Fatalf("unexpected stats of book: %+v", s)
+	}
+
+	if s.ListenedMs != int((7*time.Hour/2).Milliseconds()) || s.AverageSessionMs != s.ListenedMs/4 {
+		t.Fatalf("expected 3.5 hours listened in 4 sessions, got %+v", s)
+	}
+
+	// 3.5 hours within 50 hours, so the remaining 7 hours take another 100 hours
+	if expected := start.Add(2*day + 2*time.Hour + 100*time.Hour).Unix(); s.EstimatedFinishAtTs != expected {
+		t.Fatalf("expected to finish at %d, got %d", expected, s.EstimatedFinishAtTs)
+	}
+}
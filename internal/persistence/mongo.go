@@ -0,0 +1,115 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+type mongoBackend struct {
+	client *mongo.Client
+	db     *mongo.Database
+}
+
+func connectMongo(connectionString string) (*mongoBackend, error) {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(connectionString))
+	if err == nil {
+		err = client.Ping(context.Background(), readpref.Primary())
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB at '%s': %w", connectionString, err)
+	}
+
+	u, err := url.Parse(connectionString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse given connection string '%s': %w", connectionString, err)
+	}
+
+	dbName := strings.Trim(u.Path, "/")
+	if dbName == "" {
+		return nil, fmt.Errorf("given database name is empty '%s'", connectionString)
+	}
+
+	log.Info().Msgf("Connected to mongo db backend! Will use '%s' as db.", dbName)
+
+	return &mongoBackend{client, client.Database(dbName)}, nil
+}
+
+func (m *mongoBackend) load(collection, key string, document interface{}) error {
+	err := m.db.Collection(collection).FindOne(context.TODO(), bson.D{{Key: "_id", Value: key}}).Decode(document)
+	if err == mongo.ErrNoDocuments {
+		return errDocumentNotFound
+	}
+
+	return err
+}
+
+func (m *mongoBackend) store(collection, key string, document interface{}, expectedRevision int) error {
+	filter := bson.D{{Key: "_id", Value: key}}
+	if expectedRevision == 0 {
+		// Matches documents created before revisions have been introduced, too
+		filter = append(filter, bson.E{Key: "revision", Value: bson.D{{Key: "$in", Value: bson.A{0, nil}}}})
+	} else if expectedRevision != anyRevision {
+		filter = append(filter, bson.E{Key: "revision", Value: expectedRevision})
+	}
+
+	opts := options.Replace().SetUpsert(true)
+
+	_, err := m.db.Collection(collection).ReplaceOne(context.TODO(), filter, document, opts)
+	if mongo.IsDuplicateKeyError(err) {
+		// The filter did not match because of the revision, so the upsert tried to insert
+		// another document with the same ID
+		return errRevisionMismatch
+	}
+
+	return err
+}
+
+func (m *mongoBackend) remove(collection, key string) error {
+	res, err := m.db.Collection(collection).DeleteOne(context.TODO(), bson.D{{Key: "_id", Value: key}})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return errDocumentNotFound
+	}
+
+	return nil
+}
+
+func (m *mongoBackend) keys(collection string) ([]string, error) {
+	opts := options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}})
+
+	cursor, err := m.db.Collection(collection).Find(context.TODO(), bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var documents []struct {
+		Key string `bson:"_id"`
+	}
+	err = cursor.All(context.TODO(), &documents)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(documents))
+	for _, document := range documents {
+		keys = append(keys, document.Key)
+	}
+
+	return keys, nil
+}
+
+func (m *mongoBackend) close() error {
+	return m.client.Disconnect(context.Background())
+}
@@ -0,0 +1,108 @@
+package persistence
+
+import (
+	"sort"
+	"strings"
+)
+
+// SortOrder tells how to sort slots when listing them, pinned slots always come first
+type SortOrder string
+
+const (
+	// SortManual keeps the order chosen by the user, slots get appended when being created
+	SortManual SortOrder = "manual"
+	// SortRecent lists the slots suspended most recently first
+	SortRecent SortOrder = "recent"
+	// SortTitle lists the slots alphabetically by their custom title resp. the name of their context
+	SortTitle SortOrder = "title"
+	// SortProgress lists the slots listened to the most first, slots with an unknown progress come last
+	SortProgress SortOrder = "progress"
+)
+
+// Valid checks whether the order is one of the known ones
+func (o SortOrder) Valid() bool {
+	return o == SortManual || o == SortRecent || o == SortTitle || o == SortProgress
+}
+
+// DisplayTitle is the custom title of the slot if set, otherwise the name of its context resp. the track when played
+// without context
+func (p *PlayerState) DisplayTitle() string {
+	if p.Title != "" {
+		return p.Title
+	}
+
+	switch p.ContextType {
+	case "playlist":
+		return p.PlaylistName
+	case "show":
+		return p.ShowName
+	case "track":
+		return p.TrackName
+	default:
+		return p.AlbumName
+	}
+}
+
+// SortPlayerStates returns the slots in the given order, the slots passed are left untouched. Sorting is stable,
+// so slots being equal keep their manual order.
+func SortPlayerStates(playerStates []*PlayerState, order SortOrder) []*PlayerState {
+	sorted := make([]*PlayerState, len(playerStates))
+	copy(sorted, playerStates)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+
+		if a.Pinned != b.Pinned {
+			return a.Pinned
+		}
+
+		switch order {
+		case SortRecent:
+			return a.SuspendedAtTs > b.SuspendedAtTs
+		case SortTitle:
+			return strings.ToLower(a.DisplayTitle()) < strings.ToLower(b.DisplayTitle())
+		case SortProgress:
+			return a.fractionComplete() > b.fractionComplete()
+		default:
+			return false
+		}
+	})
+
+	return sorted
+}
+
+// Reordered arranges the slots in the order of the IDs given, which have to contain the ID of every slot exactly once
+func Reordered(playerStates []*PlayerState, order []string) ([]*PlayerState, bool) {
+	if len(order) != len(playerStates) {
+		return nil, false
+	}
+
+	byID := make(map[string]*PlayerState, len(playerStates))
+	for _, playerState := range playerStates {
+		byID[playerState.ID] = playerState
+	}
+
+	reordered := make([]*PlayerState, 0, len(order))
+	for _, id := range order {
+		playerState, ok := byID[id]
+		if !ok {
+			return nil, false
+		}
+
+		// Dropping the slot detects duplicate IDs
+		delete(byID, id)
+		reordered = append(reordered, playerState)
+	}
+
+	return reordered, true
+}
+
+// fractionComplete is -1 in case the overall progress is unknown
+func (p *PlayerState) fractionComplete() float64 {
+	elapsed, total, known := p.OverallProgress()
+	if !known {
+		return -1
+	}
+
+	return float64(elapsed) / float64(total)
+}
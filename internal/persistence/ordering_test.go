@@ -0,0 +1,60 @@
+package persistence_test
+
+import (
+	"testing"
+
+	"github.com/florianloch/cassette/internal/persistence"
+)
+
+func TestSortPlayerStates(t *testing.T) {
+	playerStates := []*persistence.PlayerState{
+		{ID: "b", ContextType: "album", AlbumName: "Beta", SuspendedAtTs: 300, Progress: 10, ContextDuration: 100},
+		{ID: "c", ContextType: "playlist", PlaylistName: "gamma", SuspendedAtTs: 100},
+		{ID: "a", ContextType: "album", AlbumName: "Zeta", Title: "alpha", SuspendedAtTs: 200, Progress: 50, ContextDuration: 100},
+		{ID: "p", ContextType: "track", TrackName: "Pinned", SuspendedAtTs: 50, Pinned: true},
+	}
+
+	cases := []struct {
+		order    persistence.SortOrder
+		expected string
+	}{
+		{persistence.SortManual, "pbca"},
+		{persistence.SortRecent, "pbac"},
+		// The custom title takes precedence over the name of the context
+		{persistence.SortTitle, "pabc"},
+		// Slots with an unknown progress come last, keeping their manual order
+		{persistence.SortProgress, "pabc"},
+	}
+
+	for _, c := range cases {
+		sorted := persistence.SortPlayerStates(playerStates, c.order)
+
+		actual := ""
+		for _, playerState := range sorted {
+			actual += playerState.ID
+		}
+
+		if actual != c.expected {
+			t.Errorf("expected slots sorted by %s to be %q, got %q", c.order, c.expected, actual)
+		}
+	}
+
+	if playerStates[0].ID != "b" {
+		t.Fatal("slots passed have been modified")
+	}
+}
+
+func TestReordered(t *testing.T) {
+	playerStates := []*persistence.PlayerState{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	reordered, ok := persistence.Reordered(playerStates, []string{"c", "a", "b"})
+	if !ok || reordered[0].ID != "c" || reordered[1].ID != "a" || reordered[2].ID != "b" {
+		t.Fatalf("slots have not been reordered properly: %+v", reordered)
+	}
+
+	for _, order := range [][]string{{"a", "b"}, {"a", "b", "c", "d"}, {"a", "b", "b"}, {"a", "b", "d"}} {
+		if _, ok := persistence.Reordered(playerStates, order); ok {
+			t.Errorf("expected order %v to be rejected", order)
+		}
+	}
+}
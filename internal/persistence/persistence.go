@@ -5,103 +5,330 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/url"
 	"strings"
+	"time"
 
-	"context"
-
-	"github.com/rs/zerolog/log"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"github.com/florianloch/cassette/internal/util"
+	"golang.org/x/oauth2"
 )
 
 const (
 	collectionName = "player_states"
-	currentVersion = 3
+	currentVersion = 4
 )
 
 var (
-	ErrUserNotFound = errors.New("user not found in db")
+	ErrUserNotFound       = errors.New("user not found in db")
+	ErrRevisionMismatch   = errors.New("player states have been modified concurrently")
+	ErrTokenNotFound      = errors.New("no token stored for user")
+	ErrAPITokenNotFound   = errors.New("API token not found")
+	ErrSleepTimerNotFound = errors.New("no sleep timer set for user")
+
+	ErrAccountDeletionNotFound = errors.New("no account deletion scheduled for user")
+
+	ErrTrackListingNotFound = errors.New("no track listing cached for context")
 )
 
 type PlayerStatesPersistor interface {
-	LoadPlayerStates(userID string) ([]*PlayerState, error)
-	SavePlayerStates(userID string, playerStates []*PlayerState) error
+	// LoadPlayerStates also returns the revision of the player states, it gets incremented with every write
+	LoadPlayerStates(userID string) ([]*PlayerState, int, error)
+	// SavePlayerStates only writes in case the stored player states are still at the given revision,
+	// otherwise ErrRevisionMismatch is returned. On success the new revision is returned.
+	// An ID gets assigned to all given player states and bookmarks not having one yet.
+	SavePlayerStates(userID string, playerStates []*PlayerState, revision int) (int, error)
 	FetchJSONDump(userID string) ([]byte, error)
+	// DeleteUserRecord deletes everything stored for the user
 	DeleteUserRecord(userID string) error
 }
 
+// AutoSavePersistor keeps track of the users having opted in to their progress getting saved in the background.
+// As this happens outside of requests the worker relies on the tokens in the TokenStore.
+type AutoSavePersistor interface {
+	EnableAutoSave(userID string) error
+	DisableAutoSave(userID string) error
+	AutoSaveEnabled(userID string) (bool, error)
+	LoadAutoSaveUsers() ([]*AutoSaveUser, error)
+}
+
+// TokenStore keeps the users' OAuth tokens encrypted, so Spotify can be accessed without the session
+// and refreshed tokens survive. Revoking access is done by deleting the token.
+type TokenStore interface {
+	// LoadToken returns ErrTokenNotFound in case there is no (readable) token
+	LoadToken(userID string) (*oauth2.Token, error)
+	StoreToken(userID string, token *oauth2.Token) error
+	DeleteToken(userID string) error
+}
+
+// TrackListingPersistor allows sharing cached track listings between instances resp. keeping them across restarts
+type TrackListingPersistor interface {
+	// LoadTrackListing returns ErrTrackListingNotFound in case nothing is cached for the key
+	LoadTrackListing(key string) (*TrackListing, error)
+	StoreTrackListing(key string, listing *TrackListing) error
+	// PruneTrackListings removes the listings cached before the given timestamp, afterwards the oldest
+	// ones get removed until at most maxEntries are left. Only a small index is read, not the listings themselves.
+	PruneTrackListings(cachedBeforeTs int64, maxEntries int) error
+}
+
+// APITokenStore keeps the personal access tokens of the users, only hashes of the tokens are stored
+type APITokenStore interface {
+	// CreateAPIToken assigns an ID to the given token and returns the token to be handed out to the user
+	CreateAPIToken(userID string, token *APIToken) (string, error)
+	LoadAPITokens(userID string) ([]*APIToken, error)
+	// LookupAPIToken returns ErrAPITokenNotFound in case the token is unknown resp. has been revoked
+	LookupAPIToken(token string) (*APIToken, error)
+	// DeleteAPIToken returns ErrAPITokenNotFound in case the user has no token with the given ID
+	DeleteAPIToken(userID, tokenID string) error
+}
+
+// SleepTimerStore keeps the sleep timers of the users, so they survive restarts
+type SleepTimerStore interface {
+	// StoreSleepTimer replaces the timer of the user the timer belongs to
+	StoreSleepTimer(timer *SleepTimer) error
+	// LoadSleepTimer returns ErrSleepTimerNotFound in case no timer is set for the user
+	LoadSleepTimer(userID string) (*SleepTimer, error)
+	LoadSleepTimers() ([]*SleepTimer, error)
+	// DeleteSleepTimer returns ErrSleepTimerNotFound in case no timer is set for the user
+	DeleteSleepTimer(userID string) error
+}
+
+// ScheduleStore keeps the schedules restoring slots at given times, along with the time zone of the user
+type ScheduleStore interface {
+	// LoadSchedules returns empty schedules in case the user has none
+	LoadSchedules(userID string) (*UserSchedules, error)
+	// SaveSchedules only writes in case the stored schedules are still at the revision of the given ones,
+	// otherwise ErrRevisionMismatch is returned. On success the revision gets incremented.
+	// An ID gets assigned to all schedules not having one yet.
+	SaveSchedules(schedules *UserSchedules) error
+	LoadAllSchedules() ([]*UserSchedules, error)
+}
+
+// PreferenceStore keeps the settings of the users applied when restoring slots
+type PreferenceStore interface {
+	// LoadPreferences returns the default preferences in case the user did not set any
+	LoadPreferences(userID string) (*Preferences, error)
+	StorePreferences(userID string, preferences *Preferences) error
+}
+
+// HistoryStore keeps an append-only log of the slots suspended and restored by the users
+type HistoryStore interface {
+	// AppendHistoryEvent folds the oldest events into the aggregates of their contexts in case there are more than
+	// HistoryMaxEvents afterwards
+	AppendHistoryEvent(userID string, event *HistoryEvent) error
+	// LoadHistory returns the events in the order they have been recorded along with the aggregates of the events
+	// folded, both are empty if there are none
+	LoadHistory(userID string) ([]*HistoryEvent, []*HistoryAggregate, error)
+}
+
+// ArchiveStore keeps the slots having been listened to completely, they are not among the player states anymore
+type ArchiveStore interface {
+	// ArchiveSlot adds the slot to the archive resp. replaces the archived slot with the same ID.
+	// An ID gets assigned to the slot if it has none yet.
+	ArchiveSlot(userID string, slot *ArchivedSlot) error
+	// RemoveArchivedSlot drops the archived slot with the given ID, nothing happens in case there is none
+	RemoveArchivedSlot(userID, slotID string) error
+	// LoadArchive also returns the revision of the archive, it gets incremented with every write
+	LoadArchive(userID string) ([]*ArchivedSlot, int, error)
+	// SaveArchive only writes in case the stored archive is still at the given revision,
+	// otherwise ErrRevisionMismatch is returned. On success the new revision is returned.
+	SaveArchive(userID string, archive []*ArchivedSlot, revision int) (int, error)
+}
+
+// TrashStore keeps the slots deleted by the users until they get purged
+type TrashStore interface {
+	// TrashSlot adds the slot to the trash resp. replaces the trashed slot with the same ID
+	TrashSlot(userID string, slot *TrashedSlot) error
+	// RemoveTrashedSlot drops the trashed slot with the given ID, nothing happens in case there is none
+	RemoveTrashedSlot(userID, slotID string) error
+	// LoadTrash also returns the revision of the trash, it gets incremented with every write
+	LoadTrash(userID string) ([]*TrashedSlot, int, error)
+	// SaveTrash only writes in case the stored trash is still at the given revision,
+	// otherwise ErrRevisionMismatch is returned. On success the new revision is returned.
+	SaveTrash(userID string, trash []*TrashedSlot, revision int) (int, error)
+	// PurgeTrash drops the slots due at the given time from the trash of every user and returns how many got purged
+	PurgeTrash(now time.Time) (int, error)
+}
+
+// AccountDeletionStore keeps the deletions of user records requested, every user has at most one
+type AccountDeletionStore interface {
+	ScheduleAccountDeletion(deletion *AccountDeletion) error
+	// LoadAccountDeletion returns ErrAccountDeletionNotFound in case no deletion is scheduled for the user
+	LoadAccountDeletion(userID string) (*AccountDeletion, error)
+	LoadAccountDeletions() ([]*AccountDeletion, error)
+	// CancelAccountDeletion returns ErrAccountDeletionNotFound in case no deletion is scheduled for the user
+	CancelAccountDeletion(userID string) error
+}
+
+// Persistor combines everything provided by the PlayerStatesDAO
+type Persistor interface {
+	PlayerStatesPersistor
+	AutoSavePersistor
+	TokenStore
+	TrackListingPersistor
+	APITokenStore
+	SleepTimerStore
+	ScheduleStore
+	PreferenceStore
+	HistoryStore
+	ArchiveStore
+	TrashStore
+	AccountDeletionStore
+}
+
 type PlayerStatesDAO struct {
-	collection *mongo.Collection
+	backend backend
+	// tokenKey is used for encrypting the OAuth tokens
+	tokenKey []byte
 }
 
-func Connect(connectionString string) (*PlayerStatesDAO, error) {
-	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(connectionString))
-	if err == nil {
-		err = client.Ping(context.Background(), readpref.Primary())
+// Open connects to resp. opens the storage engine selected by the scheme of the given URI:
+//   - "mongodb://" and "mongodb+srv://" for connecting to a MongoDB server
+//   - "bolt://" followed by a path for using an embedded BoltDB file
+//   - "file://" followed by a path for using a plain JSON file
+//
+// tokenKey has to be 32 bytes long, it is used for encrypting the stored OAuth tokens.
+func Open(uri string, tokenKey []byte) (*PlayerStatesDAO, error) {
+	if len(tokenKey) != 32 {
+		return nil, fmt.Errorf("key for encrypting tokens has to be 32 bytes long, got %d bytes", len(tokenKey))
 	}
 
+	u, err := url.Parse(uri)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to MongoDB at '%s': %w", connectionString, err)
+		return nil, fmt.Errorf("failed to parse given URI '%s': %w", uri, err)
 	}
 
-	u, err := url.Parse(connectionString)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse given connection string '%s': %w", connectionString, err)
+	var b backend
+	switch u.Scheme {
+	case "mongodb", "mongodb+srv":
+		b, err = connectMongo(uri)
+	case "bolt":
+		b, err = openBolt(pathFromURI(u))
+	case "file":
+		b, err = openFile(pathFromURI(u))
+	default:
+		return nil, fmt.Errorf("unsupported storage engine '%s', use one of 'mongodb', 'mongodb+srv', 'bolt' or 'file'", u.Scheme)
 	}
 
-	dbName := strings.Trim(u.Path, "/")
-	if dbName == "" {
-		return nil, fmt.Errorf("given database name is empty '%s'", connectionString)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Info().Msgf("Connected to mongo db backend! Will use '%s' as db.", dbName)
+	return &PlayerStatesDAO{b, tokenKey}, nil
+}
 
-	collection := client.Database(dbName).Collection(collectionName)
+// pathFromURI allows giving absolute ("bolt:///var/lib/cassette.db") as well as
+// relative paths ("bolt://cassette.db" or "bolt:cassette.db").
+func pathFromURI(u *url.URL) string {
+	if u.Opaque != "" {
+		return u.Opaque
+	}
 
-	return &PlayerStatesDAO{collection}, nil
+	return u.Host + u.Path
 }
 
-func (p *PlayerStatesDAO) LoadPlayerStates(userID string) ([]*PlayerState, error) {
-	hashedUserID := hashUserID(userID)
+func (p *PlayerStatesDAO) Close() error {
+	return p.backend.close()
+}
 
-	var item persistenceItem
-	err := p.collection.FindOne(context.TODO(), bson.D{{Key: "_id", Value: hashedUserID}}).Decode(&item)
+func (p *PlayerStatesDAO) LoadPlayerStates(userID string) ([]*PlayerState, int, error) {
+	item, err := p.loadItem(userID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return make([]*PlayerState, 0), nil
+		if err == ErrUserNotFound {
+			return make([]*PlayerState, 0), 0, nil
 		}
 
-		return nil, err
+		return nil, 0, err
+	}
+
+	if item.PlayerStates == nil {
+		return make([]*PlayerState, 0), item.Revision, nil
+	}
+
+	if item.Version < 4 {
+		// Prior to version 4 player states did not have an ID, they were addressed by their position.
+		// The IDs get assigned when saving, this has to be done right away - otherwise they would
+		// change with every request.
+		revision, err := p.SavePlayerStates(userID, item.PlayerStates, item.Revision)
+		if err == ErrRevisionMismatch {
+			// Someone else has been faster in migrating
+			return p.LoadPlayerStates(userID)
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not persist IDs assigned to player states: %w", err)
+		}
+
+		item.Revision = revision
 	}
 
-	return item.PlayerStates, nil
+	return item.PlayerStates, item.Revision, nil
 }
 
-func (p *PlayerStatesDAO) SavePlayerStates(userID string, playerStates []*PlayerState) error {
-	hashedUserID := hashUserID(userID)
+func assignIDs(playerStates []*PlayerState) error {
+	for _, playerState := range playerStates {
+		err := assignID(&playerState.ID)
+		if err != nil {
+			return err
+		}
 
-	opts := options.Update().SetUpsert(true)
+		for _, bookmark := range playerState.Bookmarks {
+			err = assignID(&bookmark.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
 
-	_, err := p.collection.UpdateOne(context.TODO(), bson.D{{Key: "_id", Value: hashedUserID}}, bson.D{{Key: "$set", Value: bson.D{{Key: "playerStates", Value: playerStates}, {Key: "version", Value: currentVersion}}}}, opts)
+func assignID(id *string) error {
+	if *id != "" {
+		return nil
+	}
 
+	randomID, err := util.RandomID()
 	if err != nil {
 		return err
 	}
 
+	*id = randomID
+
 	return nil
 }
 
-func (p *PlayerStatesDAO) FetchJSONDump(userID string) ([]byte, error) {
+func (p *PlayerStatesDAO) SavePlayerStates(userID string, playerStates []*PlayerState, revision int) (int, error) {
+	err := assignIDs(playerStates)
+	if err != nil {
+		return 0, fmt.Errorf("could not assign IDs to player states: %w", err)
+	}
+
 	hashedUserID := hashUserID(userID)
 
-	var item persistenceItem
-	err := p.collection.FindOne(context.TODO(), bson.D{{Key: "_id", Value: hashedUserID}}).Decode(&item)
+	item := &persistenceItem{
+		Version:      currentVersion,
+		Revision:     revision + 1,
+		UserID:       hashedUserID,
+		PlayerStates: playerStates,
+	}
+
+	err = p.backend.store(collectionName, hashedUserID, item, revision)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, ErrUserNotFound
+		if err == errRevisionMismatch {
+			return 0, ErrRevisionMismatch
+		}
+
+		return 0, err
+	}
+
+	return item.Revision, nil
+}
+
+func (p *PlayerStatesDAO) FetchJSONDump(userID string) ([]byte, error) {
+	item, err := p.loadItem(userID)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return nil, err
 		}
 
 		return nil, fmt.Errorf("could not load previous player states from db: %w", err)
@@ -116,31 +343,95 @@ func (p *PlayerStatesDAO) FetchJSONDump(userID string) ([]byte, error) {
 }
 
 func (p *PlayerStatesDAO) DeleteUserRecord(userID string) error {
-	hashedUserID := hashUserID(userID)
+	err := p.DisableAutoSave(userID)
+	if err != nil {
+		return fmt.Errorf("could not delete user record: %w", err)
+	}
 
-	res, err := p.collection.DeleteOne(context.TODO(), bson.D{{Key: "_id", Value: hashedUserID}})
+	err = p.DeleteToken(userID)
 	if err != nil {
 		return fmt.Errorf("could not delete user record: %w", err)
 	}
 
-	if res.DeletedCount == 0 {
-		return ErrUserNotFound
+	err = p.deleteAPITokens(userID)
+	if err != nil {
+		return fmt.Errorf("could not delete user record: %w", err)
+	}
+
+	err = p.DeleteSleepTimer(userID)
+	if err != nil && err != ErrSleepTimerNotFound {
+		return fmt.Errorf("could not delete user record: %w", err)
+	}
+
+	err = p.deleteSchedules(userID)
+	if err != nil {
+		return fmt.Errorf("could not delete user record: %w", err)
+	}
+
+	err = p.deletePreferences(userID)
+	if err != nil {
+		return fmt.Errorf("could not delete user record: %w", err)
+	}
+
+	err = p.deleteHistory(userID)
+	if err != nil {
+		return fmt.Errorf("could not delete user record: %w", err)
+	}
+
+	err = p.deleteSlots(archiveCollection, userID)
+	if err != nil {
+		return fmt.Errorf("could not delete user record: %w", err)
+	}
+
+	err = p.deleteSlots(trashCollection, userID)
+	if err != nil {
+		return fmt.Errorf("could not delete user record: %w", err)
+	}
+
+	err = p.CancelAccountDeletion(userID)
+	if err != nil && err != ErrAccountDeletionNotFound {
+		return fmt.Errorf("could not delete user record: %w", err)
+	}
+
+	err = p.backend.remove(collectionName, hashUserID(userID))
+	if err != nil {
+		if err == errDocumentNotFound {
+			return ErrUserNotFound
+		}
+
+		return fmt.Errorf("could not delete user record: %w", err)
 	}
 
 	return nil
 }
 
+func (p *PlayerStatesDAO) loadItem(userID string) (*persistenceItem, error) {
+	var item persistenceItem
+	err := p.backend.load(collectionName, hashUserID(userID), &item)
+	if err != nil {
+		if err == errDocumentNotFound {
+			return nil, ErrUserNotFound
+		}
+
+		return nil, err
+	}
+
+	return &item, nil
+}
+
 func hashUserID(userID string) string {
 	hash := sha256.Sum256([]byte(userID))
 	return fmt.Sprintf("%X", hash)
 }
 
 type PlayerState struct {
-	PlaybackContextURI string `json:"-" bson:"playbackContextURI"`
+	ID                 string `json:"id" bson:"id"`                // stable identifier of the slot this state is stored in
+	PlaybackContextURI string `json:"-" bson:"playbackContextURI"` // empty when ContextType is "track"
 	PlaybackItemURI    string `json:"-" bson:"playbackItemURI"`
-	LinkToContext      string `json:"linkToContext" bson:"linkToContext"`                   // link to open context in Spotify
-	ContextType        string `json:"contextType" bson:"contextType"`                       // either "album" or "playlist"
+	LinkToContext      string `json:"linkToContext" bson:"linkToContext"`                   // link to open context (resp. the track when ContextType is "track") in Spotify
+	ContextType        string `json:"contextType" bson:"contextType"`                       // either "album", "playlist", "show" or "track" (a single track played without context)
 	PlaylistName       string `json:"playlistName,omitempty" bson:"playlistName,omitempty"` // only populated when ContextType is "playlist"
+	ShowName           string `json:"showName,omitempty" bson:"showName,omitempty"`         // only populated when ContextType is "show"
 	AlbumArtLargeURL   string `json:"albumArtLargeURL" bson:"albumArtLargeURL"`             // should be 640px
 	AlbumArtMediumURL  string `json:"albumArtMediumURL" bson:"albumArtMediumURL"`           // should be 300px
 	TrackName          string `json:"trackName" bson:"trackName"`
@@ -150,12 +441,142 @@ type PlayerState struct {
 	TotalTracks        int    `json:"totalTracks" bson:"totalTracks"`
 	Progress           int    `json:"progress" bson:"progress"`
 	Duration           int    `json:"duration" bson:"duration"`
+	ContextDuration    int    `json:"contextDuration,omitempty" bson:"contextDuration,omitempty"` // sum of the durations of all tracks in the album resp. playlist
+	ContextElapsed     int    `json:"contextElapsed,omitempty" bson:"contextElapsed,omitempty"`   // sum of the durations of all tracks in the album resp. playlist before the current one
 	ShuffleActivated   bool   `json:"shuffleActivated" bson:"shuffleActivated"`
+	RepeatState        string `json:"repeatState,omitempty" bson:"repeatState,omitempty"` // either "off", "track" or "context", empty for slots saved before it has been captured
+	Volume             int    `json:"volume,omitempty" bson:"volume,omitempty"`           // percent, 0 if unknown
+	DeviceName         string `json:"deviceName,omitempty" bson:"deviceName,omitempty"`   // device the playback has been suspended on
 	SuspendedAtTs      int64  `json:"suspendedAtTs" bson:"suspendedAtTs"`
+	AutoSaved          bool   `json:"autoSaved" bson:"autoSaved,omitempty"` // slot is maintained by the background worker instead of the user
+	// JumpBackSeconds overrides the jump-back of the user's preferences for this slot, e.g., 0 for music
+	JumpBackSeconds *int `json:"jumpBackSeconds,omitempty" bson:"jumpBackSeconds,omitempty"`
+	// Title replaces the name derived from Spotify when displaying the slot, e.g., to tell apart audiobooks by the same narrator
+	Title string   `json:"title,omitempty" bson:"title,omitempty"`
+	Notes string   `json:"notes,omitempty" bson:"notes,omitempty"`
+	Tags  []string `json:"tags,omitempty" bson:"tags,omitempty"`
+	// Pinned slots are listed first regardless of the order
+	Pinned bool `json:"pinned,omitempty" bson:"pinned,omitempty"`
+	// Bookmarks are additional positions within the context marked by the user, they are kept when the slot gets overwritten
+	Bookmarks []*Bookmark `json:"bookmarks,omitempty" bson:"bookmarks,omitempty"`
+}
+
+// Bookmark is a named position within the context of the slot it belongs to
+type Bookmark struct {
+	ID          string `json:"id" bson:"id"`
+	Name        string `json:"name" bson:"name"`
+	Note        string `json:"note,omitempty" bson:"note,omitempty"`
+	TrackURI    string `json:"trackURI" bson:"trackURI"` // resp. the URI of the episode when the slot's ContextType is "show"
+	TrackName   string `json:"trackName" bson:"trackName"`
+	Progress    int    `json:"progress" bson:"progress"`
+	CreatedAtTs int64  `json:"createdAtTs" bson:"createdAtTs"`
+}
+
+// OverallProgress returns how far the whole album, playlist, episode resp. single track has been listened to.
+// This is unknown for albums and playlists suspended before their durations got stored.
+func (p *PlayerState) OverallProgress() (elapsed int, total int, known bool) {
+	if p.ContextType == "album" || p.ContextType == "playlist" {
+		return p.ContextElapsed + p.Progress, p.ContextDuration, p.ContextDuration > 0
+	}
+
+	return p.Progress, p.Duration, p.Duration > 0
+}
+
+// MarshalJSON adds the overall progress to the state, this way clients do not have to compute it on their own
+func (p PlayerState) MarshalJSON() ([]byte, error) {
+	// Converting to a type without methods prevents endless recursion
+	type plainPlayerState PlayerState
+
+	withProgress := struct {
+		plainPlayerState
+		PercentComplete *float64 `json:"percentComplete,omitempty"`
+		TimeRemaining   *int     `json:"timeRemaining,omitempty"`
+	}{plainPlayerState: plainPlayerState(p)}
+
+	if elapsed, total, known := p.OverallProgress(); known {
+		if elapsed > total {
+			elapsed = total
+		}
+
+		percentComplete := math.Round(float64(elapsed)/float64(total)*1000) / 10
+		timeRemaining := total - elapsed
+
+		withProgress.PercentComplete = &percentComplete
+		withProgress.TimeRemaining = &timeRemaining
+	}
+
+	return json.Marshal(withProgress)
+}
+
+// SameContext checks whether both states belong to the same album, playlist, show resp. single track
+func (p *PlayerState) SameContext(other *PlayerState) bool {
+	if p.ContextType != other.ContextType {
+		return false
+	}
+
+	if p.ContextType == "track" {
+		return p.PlaybackItemURI == other.PlaybackItemURI
+	}
+
+	return p.PlaybackContextURI == other.PlaybackContextURI
+}
+
+// HasTags checks whether the slot is tagged with all of the given tags, tags are compared case-insensitively
+func (p *PlayerState) HasTags(tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, cur := range p.Tags {
+			found = found || strings.EqualFold(cur, tag)
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// TakeOver replaces the given slot, keeping its identity along with everything set by the user. Bookmarks are only
+// kept in case the slot refers to the same context, as they point into it.
+func (p *PlayerState) TakeOver(previous *PlayerState) {
+	p.ID = previous.ID
+	if p.SameContext(previous) {
+		p.Bookmarks = previous.Bookmarks
+	}
+	p.JumpBackSeconds = previous.JumpBackSeconds
+	p.Title = previous.Title
+	p.Notes = previous.Notes
+	p.Tags = previous.Tags
+	p.Pinned = previous.Pinned
+}
+
+// MergeSuspended places a state suspended explicitly by the user among the player states. It replaces the slot at
+// the given index, if the index is negative it takes over the slot saved automatically for the same context.
+// Otherwise it gets appended, this is told by the bool returned. Replaced slots keep their identity and settings.
+func MergeSuspended(playerStates []*PlayerState, state *PlayerState, idx int) ([]*PlayerState, bool) {
+	if idx < 0 {
+		for cur := range playerStates {
+			if playerStates[cur].AutoSaved && playerStates[cur].SameContext(state) {
+				idx = cur
+				break
+			}
+		}
+	}
+
+	if idx < 0 {
+		return append(playerStates, state), true
+	}
+
+	state.TakeOver(playerStates[idx])
+	playerStates[idx] = state
+
+	return playerStates, false
 }
 
 type persistenceItem struct {
 	Version      int            `bson:"version" json:"version"`
+	Revision     int            `bson:"revision" json:"revision"`
 	UserID       string         `bson:"_id" json:"_id"`
 	PlayerStates []*PlayerState `bson:"playerStates" json:"playerStates"`
 }
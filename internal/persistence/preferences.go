@@ -0,0 +1,98 @@
+package persistence
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	preferencesCollectionName = "preferences"
+
+	// DefaultJumpBackSeconds is used unless the user set a jump-back of her/his own
+	DefaultJumpBackSeconds = 10
+)
+
+// smartJumpBackSteps tell how many seconds are added to the jump-back when the slot has been suspended for at
+// least the given duration, the last step matching applies
+var smartJumpBackSteps = []struct {
+	suspendedFor time.Duration
+	extraSeconds int
+}{
+	{time.Hour, 5},
+	{24 * time.Hour, 15},
+	{7 * 24 * time.Hour, 30},
+	{30 * 24 * time.Hour, 60},
+}
+
+// Preferences are the settings of a user applied when restoring slots
+type Preferences struct {
+	// JumpBackSeconds is subtracted from the progress of a slot when restoring it, slots might override it
+	JumpBackSeconds int `json:"jumpBackSeconds" bson:"jumpBackSeconds"`
+	// SmartJumpBack jumps back further the longer a slot has been suspended, see smartJumpBackSteps
+	SmartJumpBack bool `json:"smartJumpBack" bson:"smartJumpBack"`
+	// RestoreDevice restores slots onto the device they have been suspended on unless another one is requested
+	RestoreDevice bool `json:"restoreDevice" bson:"restoreDevice"`
+}
+
+// JumpBack tells how far to jump back when restoring the slot after it has been suspended for the given duration.
+// The jump-back set for the slot takes precedence over the one of the user. Slots not jumping back at all, e.g.,
+// music, are not affected by the smart mode either.
+func (p *Preferences) JumpBack(playerState *PlayerState, suspendedFor time.Duration) time.Duration {
+	seconds := p.JumpBackSeconds
+	if playerState.JumpBackSeconds != nil {
+		seconds = *playerState.JumpBackSeconds
+	}
+
+	if p.SmartJumpBack && seconds > 0 {
+		extra := 0
+		for _, step := range smartJumpBackSteps {
+			if suspendedFor >= step.suspendedFor {
+				extra = step.extraSeconds
+			}
+		}
+
+		seconds += extra
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+type preferencesItem struct {
+	Key         string `bson:"_id"`
+	Preferences `bson:"inline"`
+}
+
+// LoadPreferences returns the default preferences in case the user did not set any
+func (p *PlayerStatesDAO) LoadPreferences(userID string) (*Preferences, error) {
+	var item preferencesItem
+	err := p.backend.load(preferencesCollectionName, hashUserID(userID), &item)
+	if err != nil {
+		if err == errDocumentNotFound {
+			return &Preferences{JumpBackSeconds: DefaultJumpBackSeconds}, nil
+		}
+
+		return nil, fmt.Errorf("could not load preferences: %w", err)
+	}
+
+	return &item.Preferences, nil
+}
+
+func (p *PlayerStatesDAO) StorePreferences(userID string, preferences *Preferences) error {
+	key := hashUserID(userID)
+
+	err := p.backend.store(preferencesCollectionName, key, &preferencesItem{Key: key, Preferences: *preferences}, anyRevision)
+	if err != nil {
+		return fmt.Errorf("could not store preferences: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PlayerStatesDAO) deletePreferences(userID string) error {
+	err := p.backend.remove(preferencesCollectionName, hashUserID(userID))
+	if err != nil && err != errDocumentNotFound {
+		return fmt.Errorf("could not delete preferences: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package persistence_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/florianloch/cassette/internal/persistence"
+)
+
+func TestJumpBack(t *testing.T) {
+	zero, twenty := 0, 20
+
+	cases := []struct {
+		preferences  persistence.Preferences
+		slot         *int
+		suspendedFor time.Duration
+		expected     time.Duration
+	}{
+		{persistence.Preferences{JumpBackSeconds: 10}, nil, 48 * time.Hour, 10 * time.Second},
+		{persistence.Preferences{JumpBackSeconds: 10}, &twenty, 48 * time.Hour, 20 * time.Second},
+		{persistence.Preferences{JumpBackSeconds: 10}, &zero, 48 * time.Hour, 0},
+		{persistence.Preferences{JumpBackSeconds: 10, SmartJumpBack: true}, nil, 10 * time.Minute, 10 * time.Second},
+		{persistence.Preferences{JumpBackSeconds: 10, SmartJumpBack: true}, nil, 2 * time.Hour, 15 * time.Second},
+		{persistence.Preferences{JumpBackSeconds: 10, SmartJumpBack: true}, nil, 48 * time.Hour, 25 * time.Second},
+		{persistence.Preferences{JumpBackSeconds: 10, SmartJumpBack: true}, &twenty, 10 * 24 * time.Hour, 50 * time.Second},
+		{persistence.Preferences{JumpBackSeconds: 10, SmartJumpBack: true}, nil, 365 * 24 * time.Hour, 70 * time.Second},
+		// Slots not jumping back at all stay where they are
+		{persistence.Preferences{JumpBackSeconds: 10, SmartJumpBack: true}, &zero, 365 * 24 * time.Hour, 0},
+	}
+
+	for _, c := range cases {
+		jumpBack := c.preferences.JumpBack(&persistence.PlayerState{JumpBackSeconds: c.slot}, c.suspendedFor)
+		if jumpBack != c.expected {
+			t.Errorf("expected to jump back %s with %+v after %s, got %s", c.expected, c.preferences, c.suspendedFor, jumpBack)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package persistence
+
+import (
+	"fmt"
+)
+
+const (
+	scheduleCollectionName = "schedules"
+)
+
+// MissedRunPolicy tells what happens to a run of a schedule having been missed, e.g., because of a restart
+type MissedRunPolicy string
+
+const (
+	// MissedRunSkip drops runs being overdue, the schedule continues with its next run
+	MissedRunSkip MissedRunPolicy = "skip"
+	// MissedRunCatchUp runs the schedule once as soon as possible, no matter how many runs have been missed
+	MissedRunCatchUp MissedRunPolicy = "catchUp"
+)
+
+// Schedule restores a slot onto a device at the times given by a cron expression. The expression is
+// evaluated in the time zone of the user the schedule belongs to.
+type Schedule struct {
+	ID   string `json:"id" bson:"id"`
+	Name string `json:"name,omitempty" bson:"name,omitempty"`
+	Slot string `json:"slot" bson:"slot"`
+	// DeviceID is used for restoring, DeviceName is kept for showing the device as Spotify might not list it when it is off
+	DeviceID    string          `json:"deviceID" bson:"deviceID"`
+	DeviceName  string          `json:"deviceName" bson:"deviceName"`
+	Cron        string          `json:"cron" bson:"cron"` // minute, hour, day of month, month and day of week, e.g., "30 7 * * 1-5"
+	MissedRun   MissedRunPolicy `json:"missedRun" bson:"missedRun"`
+	NextRunAtTs int64           `json:"nextRunAtTs" bson:"nextRunAtTs"`
+	LastRunAtTs int64           `json:"lastRunAtTs,omitempty" bson:"lastRunAtTs,omitempty"`
+	CreatedAtTs int64           `json:"createdAtTs" bson:"createdAtTs"`
+}
+
+// UserSchedules are all schedules of a user along with the time zone they are evaluated in
+type UserSchedules struct {
+	UserID   string `json:"-" bson:"userID"` // the worker needs the actual ID in order to access the user's player states
+	Revision int    `json:"-" bson:"revision"`
+	// TimeZone is an IANA name like "Europe/Berlin", UTC is used if empty
+	TimeZone  string      `json:"timeZone" bson:"timeZone"`
+	Schedules []*Schedule `json:"schedules" bson:"schedules"`
+}
+
+// Find returns the schedule with the given ID, nil if there is none
+func (s *UserSchedules) Find(id string) *Schedule {
+	for _, schedule := range s.Schedules {
+		if schedule.ID == id {
+			return schedule
+		}
+	}
+
+	return nil
+}
+
+type scheduleItem struct {
+	Key           string `bson:"_id"`
+	UserSchedules `bson:"inline"`
+}
+
+func (p *PlayerStatesDAO) LoadSchedules(userID string) (*UserSchedules, error) {
+	schedules, err := p.loadSchedules(hashUserID(userID))
+	if err != nil {
+		if err == errDocumentNotFound {
+			return &UserSchedules{UserID: userID, Schedules: make([]*Schedule, 0)}, nil
+		}
+
+		return nil, fmt.Errorf("could not load schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+func (p *PlayerStatesDAO) SaveSchedules(schedules *UserSchedules) error {
+	for _, schedule := range schedules.Schedules {
+		err := assignID(&schedule.ID)
+		if err != nil {
+			return fmt.Errorf("could not assign ID to schedule: %w", err)
+		}
+	}
+
+	key := hashUserID(schedules.UserID)
+
+	item := &scheduleItem{Key: key, UserSchedules: *schedules}
+	item.Revision++
+
+	err := p.backend.store(scheduleCollectionName, key, item, schedules.Revision)
+	if err != nil {
+		if err == errRevisionMismatch {
+			return ErrRevisionMismatch
+		}
+
+		return fmt.Errorf("could not store schedules: %w", err)
+	}
+
+	schedules.Revision = item.Revision
+
+	return nil
+}
+
+func (p *PlayerStatesDAO) LoadAllSchedules() ([]*UserSchedules, error) {
+	keys, err := p.backend.keys(scheduleCollectionName)
+	if err != nil {
+		return nil, fmt.Errorf("could not list schedules: %w", err)
+	}
+
+	all := make([]*UserSchedules, 0, len(keys))
+	for _, key := range keys {
+		schedules, err := p.loadSchedules(key)
+		if err != nil {
+			if err == errDocumentNotFound {
+				// User has been deleted in the meantime
+				continue
+			}
+
+			return nil, fmt.Errorf("could not load schedules: %w", err)
+		}
+
+		all = append(all, schedules)
+	}
+
+	return all, nil
+}
+
+// deleteSchedules drops all schedules of the user along with her/his time zone
+func (p *PlayerStatesDAO) deleteSchedules(userID string) error {
+	err := p.backend.remove(scheduleCollectionName, hashUserID(userID))
+	if err != nil && err != errDocumentNotFound {
+		return fmt.Errorf("could not delete schedules: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PlayerStatesDAO) loadSchedules(key string) (*UserSchedules, error) {
+	var item scheduleItem
+	err := p.backend.load(scheduleCollectionName, key, &item)
+	if err != nil {
+		return nil, err
+	}
+
+	if item.Schedules == nil {
+		item.Schedules = make([]*Schedule, 0)
+	}
+
+	return &item.UserSchedules, nil
+}
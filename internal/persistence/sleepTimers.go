@@ -0,0 +1,108 @@
+package persistence
+
+import (
+	"fmt"
+)
+
+const (
+	sleepTimerCollectionName = "sleep_timers"
+)
+
+// SleepTimerMode tells when a sleep timer fires
+type SleepTimerMode string
+
+const (
+	// SleepTimerAfterDuration fires once the duration given when setting the timer has elapsed
+	SleepTimerAfterDuration SleepTimerMode = "duration"
+	// SleepTimerAtTrackEnd fires when the track playing when setting the timer has ended, e.g., at the end of a chapter
+	SleepTimerAtTrackEnd SleepTimerMode = "trackEnd"
+)
+
+// SleepTimer suspends the playback into a slot and pauses it when firing. Every user has at most one timer.
+type SleepTimer struct {
+	UserID string         `json:"-" bson:"userID"` // the worker needs the actual ID in order to access the user's player states
+	Mode   SleepTimerMode `json:"mode" bson:"mode"`
+	// Slot is the slot to overwrite, if empty the playback gets suspended like a new slot
+	Slot string `json:"slot,omitempty" bson:"slot,omitempty"`
+	// TrackURI is the track whose end is awaited, only populated when Mode is SleepTimerAtTrackEnd
+	TrackURI    string `json:"-" bson:"trackURI,omitempty"`
+	FiresAtTs   int64  `json:"firesAtTs" bson:"firesAtTs"` // preliminary when Mode is SleepTimerAtTrackEnd, as playback might be paused or seeked
+	CreatedAtTs int64  `json:"createdAtTs" bson:"createdAtTs"`
+}
+
+type sleepTimerItem struct {
+	Key        string `bson:"_id"`
+	SleepTimer `bson:"inline"`
+}
+
+// StoreSleepTimer sets the timer of the user, replacing the previous one
+func (p *PlayerStatesDAO) StoreSleepTimer(timer *SleepTimer) error {
+	key := hashUserID(timer.UserID)
+
+	err := p.backend.store(sleepTimerCollectionName, key, &sleepTimerItem{Key: key, SleepTimer: *timer}, anyRevision)
+	if err != nil {
+		return fmt.Errorf("could not store sleep timer: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PlayerStatesDAO) LoadSleepTimer(userID string) (*SleepTimer, error) {
+	timer, err := p.loadSleepTimer(hashUserID(userID))
+	if err != nil {
+		if err == errDocumentNotFound {
+			return nil, ErrSleepTimerNotFound
+		}
+
+		return nil, fmt.Errorf("could not load sleep timer: %w", err)
+	}
+
+	return timer, nil
+}
+
+func (p *PlayerStatesDAO) LoadSleepTimers() ([]*SleepTimer, error) {
+	keys, err := p.backend.keys(sleepTimerCollectionName)
+	if err != nil {
+		return nil, fmt.Errorf("could not list sleep timers: %w", err)
+	}
+
+	timers := make([]*SleepTimer, 0, len(keys))
+	for _, key := range keys {
+		timer, err := p.loadSleepTimer(key)
+		if err != nil {
+			if err == errDocumentNotFound {
+				// Cancelled in the meantime
+				continue
+			}
+
+			return nil, fmt.Errorf("could not load sleep timer: %w", err)
+		}
+
+		timers = append(timers, timer)
+	}
+
+	return timers, nil
+}
+
+func (p *PlayerStatesDAO) DeleteSleepTimer(userID string) error {
+	err := p.backend.remove(sleepTimerCollectionName, hashUserID(userID))
+	if err != nil {
+		if err == errDocumentNotFound {
+			return ErrSleepTimerNotFound
+		}
+
+		return fmt.Errorf("could not delete sleep timer: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PlayerStatesDAO) loadSleepTimer(key string) (*SleepTimer, error) {
+	var item sleepTimerItem
+	err := p.backend.load(sleepTimerCollectionName, key, &item)
+	if err != nil {
+		return nil, err
+	}
+
+	return &item.SleepTimer, nil
+}
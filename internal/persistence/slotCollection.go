@@ -0,0 +1,146 @@
+package persistence
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// slotCollectionAttempts limits how often modifications of a slot collection are retried when it gets modified
+// concurrently
+const slotCollectionAttempts = 3
+
+// slotCollection keeps the slots of a user having been moved out of the player states, one document per user along
+// with its revision. It is shared by the archive and the trash.
+type slotCollection struct {
+	// name of the collection, also used for the errors returned
+	name string
+	// newSlot returns an empty slot to decode a stored one into
+	newSlot func() collectedSlot
+}
+
+// collectedSlot is implemented by the slots kept in a slot collection
+type collectedSlot interface {
+	slotID() string
+}
+
+type slotCollectionItem struct {
+	Key      string          `bson:"_id"`
+	Revision int             `bson:"revision"`
+	Slots    []collectedSlot `bson:"slots"`
+}
+
+// rawSlotCollectionItem is used for decoding as the type of the slots is only known to the collection
+type rawSlotCollectionItem struct {
+	Key      string     `bson:"_id"`
+	Revision int        `bson:"revision"`
+	Slots    []bson.Raw `bson:"slots"`
+}
+
+// putSlot adds the slot to the collection of the user resp. replaces the slot with the same ID
+func (p *PlayerStatesDAO) putSlot(c *slotCollection, userID string, slot collectedSlot) error {
+	return p.modifySlots(c, hashUserID(userID), func(slots []collectedSlot) ([]collectedSlot, bool) {
+		replaced := false
+		for idx, cur := range slots {
+			if cur.slotID() == slot.slotID() {
+				slots[idx] = slot
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			slots = append(slots, slot)
+		}
+
+		return slots, true
+	})
+}
+
+// removeSlot drops the slot with the given ID from the collection of the user, nothing happens in case there is none
+func (p *PlayerStatesDAO) removeSlot(c *slotCollection, userID, slotID string) error {
+	return p.modifySlots(c, hashUserID(userID), func(slots []collectedSlot) ([]collectedSlot, bool) {
+		remaining := make([]collectedSlot, 0, len(slots))
+		for _, cur := range slots {
+			if cur.slotID() != slotID {
+				remaining = append(remaining, cur)
+			}
+		}
+
+		return remaining, len(remaining) != len(slots)
+	})
+}
+
+// modifySlots applies modify to the slots stored and retries in case they get modified concurrently. Nothing gets
+// written in case modify reports no change.
+func (p *PlayerStatesDAO) modifySlots(c *slotCollection, key string, modify func([]collectedSlot) ([]collectedSlot, bool)) error {
+	for attempt := 0; attempt < slotCollectionAttempts; attempt++ {
+		slots, revision, err := p.loadSlots(c, key)
+		if err != nil {
+			return err
+		}
+
+		slots, changed := modify(slots)
+		if !changed {
+			return nil
+		}
+
+		_, err = p.saveSlots(c, key, slots, revision)
+		if err != ErrRevisionMismatch {
+			return err
+		}
+	}
+
+	return fmt.Errorf("could not store %s: %w", c.name, ErrRevisionMismatch)
+}
+
+func (p *PlayerStatesDAO) loadSlots(c *slotCollection, key string) ([]collectedSlot, int, error) {
+	var item rawSlotCollectionItem
+	err := p.backend.load(c.name, key, &item)
+	if err != nil {
+		if err == errDocumentNotFound {
+			return make([]collectedSlot, 0), 0, nil
+		}
+
+		return nil, 0, fmt.Errorf("could not load %s: %w", c.name, err)
+	}
+
+	slots := make([]collectedSlot, len(item.Slots))
+	for idx, raw := range item.Slots {
+		slots[idx] = c.newSlot()
+
+		err = bson.Unmarshal(raw, slots[idx])
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not decode %s: %w", c.name, err)
+		}
+	}
+
+	return slots, item.Revision, nil
+}
+
+func (p *PlayerStatesDAO) saveSlots(c *slotCollection, key string, slots []collectedSlot, revision int) (int, error) {
+	item := &slotCollectionItem{
+		Key:      key,
+		Revision: revision + 1,
+		Slots:    slots,
+	}
+
+	err := p.backend.store(c.name, key, item, revision)
+	if err != nil {
+		if err == errRevisionMismatch {
+			return 0, ErrRevisionMismatch
+		}
+
+		return 0, fmt.Errorf("could not store %s: %w", c.name, err)
+	}
+
+	return item.Revision, nil
+}
+
+func (p *PlayerStatesDAO) deleteSlots(c *slotCollection, userID string) error {
+	err := p.backend.remove(c.name, hashUserID(userID))
+	if err != nil && err != errDocumentNotFound {
+		return fmt.Errorf("could not delete %s: %w", c.name, err)
+	}
+
+	return nil
+}
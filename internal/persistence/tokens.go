@@ -0,0 +1,123 @@
+package persistence
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+const (
+	tokenCollectionName = "tokens"
+)
+
+type tokenItem struct {
+	Key string `bson:"_id"`
+	// Ciphertext contains the token JSON encoded and encrypted using AES-GCM, prefixed with the nonce
+	Ciphertext []byte `bson:"ciphertext"`
+}
+
+func (p *PlayerStatesDAO) LoadToken(userID string) (*oauth2.Token, error) {
+	var item tokenItem
+	err := p.backend.load(tokenCollectionName, hashUserID(userID), &item)
+	if err != nil {
+		if err == errDocumentNotFound {
+			return nil, ErrTokenNotFound
+		}
+
+		return nil, fmt.Errorf("could not load token: %w", err)
+	}
+
+	token, err := p.decryptToken(item.Ciphertext)
+	if err != nil {
+		// Most likely the secret changed, the user simply has to log in again
+		log.Warn().Err(err).Msg("Could not decrypt stored token, treating it as not present.")
+		return nil, ErrTokenNotFound
+	}
+
+	return token, nil
+}
+
+func (p *PlayerStatesDAO) StoreToken(userID string, token *oauth2.Token) error {
+	ciphertext, err := p.encryptToken(token)
+	if err != nil {
+		return fmt.Errorf("could not encrypt token: %w", err)
+	}
+
+	key := hashUserID(userID)
+
+	err = p.backend.store(tokenCollectionName, key, &tokenItem{key, ciphertext}, anyRevision)
+	if err != nil {
+		return fmt.Errorf("could not store token: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PlayerStatesDAO) DeleteToken(userID string) error {
+	err := p.backend.remove(tokenCollectionName, hashUserID(userID))
+	if err != nil && err != errDocumentNotFound {
+		return fmt.Errorf("could not delete token: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PlayerStatesDAO) encryptToken(token *oauth2.Token) ([]byte, error) {
+	aead, err := p.tokenCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (p *PlayerStatesDAO) decryptToken(ciphertext []byte) (*oauth2.Token, error) {
+	aead, err := p.tokenCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+
+	nonce := ciphertext[:aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, ciphertext[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var token oauth2.Token
+	err = json.Unmarshal(plaintext, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (p *PlayerStatesDAO) tokenCipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(p.tokenKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
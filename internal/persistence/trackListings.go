@@ -0,0 +1,123 @@
+package persistence
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	trackListingCollectionName      = "track_listings"
+	trackListingIndexCollectionName = "track_listing_index"
+)
+
+// TrackListing contains the tracks of an album resp. playlist in their order, it is cached as paging
+// through long audiobooks takes a while
+type TrackListing struct {
+	TrackIDs   []string `bson:"trackIDs"`
+	Durations  []int    `bson:"durations"` // in milliseconds, in the same order as TrackIDs
+	CachedAtTs int64    `bson:"cachedAtTs"`
+}
+
+type trackListingItem struct {
+	Key     string        `bson:"_id"`
+	Listing *TrackListing `bson:"listing"`
+}
+
+// trackListingIndexItem tells when a listing has been cached, so pruning does not have to load the listings themselves
+type trackListingIndexItem struct {
+	Key        string `bson:"_id"`
+	CachedAtTs int64  `bson:"cachedAtTs"`
+}
+
+func (p *PlayerStatesDAO) LoadTrackListing(key string) (*TrackListing, error) {
+	var item trackListingItem
+	err := p.backend.load(trackListingCollectionName, key, &item)
+	if err != nil {
+		if err == errDocumentNotFound {
+			return nil, ErrTrackListingNotFound
+		}
+
+		return nil, fmt.Errorf("could not load track listing: %w", err)
+	}
+
+	return item.Listing, nil
+}
+
+// StoreTrackListing adds the listing to the index first, this way no listing is left behind without being pruned
+func (p *PlayerStatesDAO) StoreTrackListing(key string, listing *TrackListing) error {
+	err := p.backend.store(trackListingIndexCollectionName, key, &trackListingIndexItem{key, listing.CachedAtTs}, anyRevision)
+	if err != nil {
+		return fmt.Errorf("could not index track listing: %w", err)
+	}
+
+	err = p.backend.store(trackListingCollectionName, key, &trackListingItem{key, listing}, anyRevision)
+	if err != nil {
+		return fmt.Errorf("could not store track listing: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PlayerStatesDAO) PruneTrackListings(cachedBeforeTs int64, maxEntries int) error {
+	keys, err := p.backend.keys(trackListingIndexCollectionName)
+	if err != nil {
+		return fmt.Errorf("could not list track listings: %w", err)
+	}
+
+	kept := make([]*trackListingIndexItem, 0, len(keys))
+	for _, key := range keys {
+		var item trackListingIndexItem
+		err := p.backend.load(trackListingIndexCollectionName, key, &item)
+		if err == errDocumentNotFound {
+			// Pruned concurrently
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not load track listing index: %w", err)
+		}
+
+		if item.CachedAtTs < cachedBeforeTs {
+			err = p.removeTrackListing(key)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		kept = append(kept, &item)
+	}
+
+	if len(kept) <= maxEntries {
+		return nil
+	}
+
+	// Drop the oldest ones until the limit is met
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].CachedAtTs < kept[j].CachedAtTs
+	})
+
+	for _, item := range kept[:len(kept)-maxEntries] {
+		err = p.removeTrackListing(item.Key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeTrackListing drops the listing before its index entry, so the listing gets pruned again in case of a failure
+func (p *PlayerStatesDAO) removeTrackListing(key string) error {
+	err := p.backend.remove(trackListingCollectionName, key)
+	if err != nil && err != errDocumentNotFound {
+		return fmt.Errorf("could not delete track listing: %w", err)
+	}
+
+	err = p.backend.remove(trackListingIndexCollectionName, key)
+	if err != nil && err != errDocumentNotFound {
+		return fmt.Errorf("could not delete track listing from index: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package persistence
+
+import (
+	"fmt"
+	"time"
+)
+
+// TrashedSlot is a slot deleted by the user, it can be undeleted until it gets purged
+type TrashedSlot struct {
+	PlayerState *PlayerState `json:"playerState" bson:"playerState"`
+	DeletedAtTs int64        `json:"deletedAtTs" bson:"deletedAtTs"`
+	// PurgeAtTs is fixed when deleting the slot, so changing the retention only affects slots deleted afterwards
+	PurgeAtTs int64 `json:"purgeAtTs" bson:"purgeAtTs"`
+}
+
+// TrashDeleted moves the slot about to be deleted to the trash of the user, where it is kept for the given retention.
+// Nothing happens in case the retention is not positive, the trash is disabled then. It is up to the caller to remove
+// the slot from the player states afterwards. In case they cannot be saved UndoTrashing has to be called, otherwise
+// the slot would be contained in both.
+func TrashDeleted(dao TrashStore, userID string, playerState *PlayerState, deletedAt time.Time, retention time.Duration) (bool, error) {
+	if retention <= 0 {
+		return false, nil
+	}
+
+	err := dao.TrashSlot(userID, &TrashedSlot{
+		PlayerState: playerState,
+		DeletedAtTs: deletedAt.Unix(),
+		PurgeAtTs:   deletedAt.Add(retention).Unix(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// UndoTrashing removes the slot trashed by TrashDeleted from the trash again
+func UndoTrashing(dao TrashStore, userID string, playerState *PlayerState) error {
+	return dao.RemoveTrashedSlot(userID, playerState.ID)
+}
+
+var trashCollection = &slotCollection{
+	name:    "trash",
+	newSlot: func() collectedSlot { return &TrashedSlot{} },
+}
+
+func (s *TrashedSlot) slotID() string {
+	return s.PlayerState.ID
+}
+
+func (p *PlayerStatesDAO) TrashSlot(userID string, slot *TrashedSlot) error {
+	return p.putSlot(trashCollection, userID, slot)
+}
+
+func (p *PlayerStatesDAO) RemoveTrashedSlot(userID, slotID string) error {
+	return p.removeSlot(trashCollection, userID, slotID)
+}
+
+func (p *PlayerStatesDAO) LoadTrash(userID string) ([]*TrashedSlot, int, error) {
+	slots, revision, err := p.loadSlots(trashCollection, hashUserID(userID))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return trashedSlots(slots), revision, nil
+}
+
+func (p *PlayerStatesDAO) SaveTrash(userID string, trash []*TrashedSlot, revision int) (int, error) {
+	slots := make([]collectedSlot, len(trash))
+	for idx, slot := range trash {
+		slots[idx] = slot
+	}
+
+	return p.saveSlots(trashCollection, hashUserID(userID), slots, revision)
+}
+
+// PurgeTrash drops the slots being due from the trash of every user. The trash of a user being modified
+// concurrently is skipped, its slots get purged the next time.
+func (p *PlayerStatesDAO) PurgeTrash(now time.Time) (int, error) {
+	keys, err := p.backend.keys(trashCollection.name)
+	if err != nil {
+		return 0, fmt.Errorf("could not list trash: %w", err)
+	}
+
+	purged := 0
+	for _, key := range keys {
+		slots, revision, err := p.loadSlots(trashCollection, key)
+		if err != nil {
+			return purged, err
+		}
+
+		remaining := make([]collectedSlot, 0, len(slots))
+		for _, slot := range trashedSlots(slots) {
+			if slot.PurgeAtTs > now.Unix() {
+				remaining = append(remaining, slot)
+			}
+		}
+
+		if len(remaining) == len(slots) {
+			continue
+		}
+
+		_, err = p.saveSlots(trashCollection, key, remaining, revision)
+		if err != nil {
+			if err == ErrRevisionMismatch {
+				continue
+			}
+
+			return purged, err
+		}
+
+		purged += len(slots) - len(remaining)
+	}
+
+	return purged, nil
+}
+
+func trashedSlots(slots []collectedSlot) []*TrashedSlot {
+	trash := make([]*TrashedSlot, len(slots))
+	for idx, slot := range slots {
+		trash[idx] = slot.(*TrashedSlot)
+	}
+
+	return trash
+}
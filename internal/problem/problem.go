@@ -0,0 +1,115 @@
+// Package problem provides the error responses of the API. They follow RFC 7807 ("Problem Details for HTTP APIs")
+// and carry a stable, machine-readable code, so clients do not have to parse the human-readable detail.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+const (
+	ContentType = "application/problem+json"
+)
+
+// Code identifies the kind of a problem, once introduced a code must not change
+type Code string
+
+const (
+	CodeInternal              Code = "internal_error"
+	CodeInvalidRequest        Code = "invalid_request"
+	CodeNotFound              Code = "not_found"
+	CodeMethodNotAllowed      Code = "method_not_allowed"
+	CodeInvalidSession        Code = "invalid_session"
+	CodeNotLoggedIn           Code = "not_logged_in"
+	CodeInvalidAPIToken       Code = "invalid_api_token"
+	CodeInsufficientScope     Code = "insufficient_scope"
+	CodeInvalidCSRFToken      Code = "invalid_csrf_token"
+	CodeOAuthFailed           Code = "oauth_failed"
+	CodeNoDataStored          Code = "no_data_stored"
+	CodeSlotOutOfRange        Code = "slot_out_of_range"
+	CodeBookmarkNotFound      Code = "bookmark_not_found"
+	CodeAPITokenNotFound      Code = "api_token_not_found"
+	CodeSleepTimerNotFound    Code = "sleep_timer_not_found"
+	CodeScheduleNotFound      Code = "schedule_not_found"
+	CodeArchivedSlotNotFound  Code = "archived_slot_not_found"
+	CodeTrashedSlotNotFound   Code = "trashed_slot_not_found"
+	CodeDeletionNotFound      Code = "deletion_not_found"
+	CodeModifiedConcurrently  Code = "modified_concurrently"
+	CodeContextNotSuspendable Code = "context_not_suspendable"
+	CodeContextMismatch       Code = "context_mismatch"
+	CodeNoActiveDevice        Code = "no_active_device"
+	CodeNothingPlaying        Code = "nothing_playing"
+	CodePlaybackFailed        Code = "playback_failed"
+	CodeRequestBudgetExceeded Code = "request_budget_exceeded"
+	CodeSpotifyUnavailable    Code = "spotify_unavailable"
+	CodeSpotifyError          Code = "spotify_error"
+)
+
+// Problem is the body of every error response
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   Code   `json:"code"`
+}
+
+// Respond writes the problem, detail is meant to be shown to the user.
+// Handlers have to return afterwards.
+func Respond(w http.ResponseWriter, r *http.Request, status int, code Code, detail string) {
+	body, err := json.Marshal(&Problem{
+		// The code already tells the kind of problem, so no URI identifying it is given
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	})
+	if err != nil {
+		// Cannot happen, the problem consists of strings and numbers only
+		hlog.FromRequest(r).Error().Err(err).Msg("Could not serialize problem.")
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	_, err = w.Write(body)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed to write problem response.")
+	}
+}
+
+// RespondRetryLater responds with 503 and tells the client when to try again
+func RespondRetryLater(w http.ResponseWriter, r *http.Request, code Code, detail string, retryAfter time.Duration) {
+	setRetryAfter(w, retryAfter)
+
+	Respond(w, r, http.StatusServiceUnavailable, code, detail)
+}
+
+// RespondTooManyRequests responds with 429 and tells the client when to try again
+func RespondTooManyRequests(w http.ResponseWriter, r *http.Request, code Code, detail string, retryAfter time.Duration) {
+	setRetryAfter(w, retryAfter)
+
+	Respond(w, r, http.StatusTooManyRequests, code, detail)
+}
+
+// setRetryAfter rounds up to whole seconds, so clients do not try again too early
+func setRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int((retryAfter + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
+
+// NotFound can be used for routes not being found
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Respond(w, r, http.StatusNotFound, CodeNotFound, "The requested resource does not exist.")
+}
+
+// MethodNotAllowed can be used for routes not supporting the requested method
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Respond(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "The requested resource does not support this method.")
+}
@@ -0,0 +1,78 @@
+package purger
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/util"
+)
+
+// Worker periodically purges the slots whose retention in the trash is over and carries out the deletions of user
+// records whose grace period is over. Both are kept in the database, so nothing is missed across restarts.
+type Worker struct {
+	dao      persistence.Persistor
+	clock    util.Clock
+	interval time.Duration
+}
+
+func NewWorker(dao persistence.Persistor, clock util.Clock, interval time.Duration) *Worker {
+	return &Worker{
+		dao:      dao,
+		clock:    clock,
+		interval: interval,
+	}
+}
+
+// Run blocks until stop gets closed
+func (w *Worker) Run(stop <-chan struct{}) {
+	log.Info().Msgf("Purging trash and deleting user records being due every %s.", w.interval)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.RunOnce()
+		}
+	}
+}
+
+// RunOnce purges everything being due, failures are retried the next time
+func (w *Worker) RunOnce() {
+	now := w.clock.Now()
+
+	purged, err := w.dao.PurgeTrash(now)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not purge trash.")
+	}
+
+	if purged > 0 {
+		log.Info().Int("slots", purged).Msg("Purged slots from trash.")
+	}
+
+	deletions, err := w.dao.LoadAccountDeletions()
+	if err != nil {
+		log.Error().Err(err).Msg("Could not load account deletions.")
+		return
+	}
+
+	for _, deletion := range deletions {
+		if deletion.DeleteAtTs > now.Unix() {
+			continue
+		}
+
+		// The deletion is dropped along with the user record, users having nothing stored but the deletion are fine
+		err = w.dao.DeleteUserRecord(deletion.UserID)
+		if err != nil && err != persistence.ErrUserNotFound {
+			log.Error().Err(err).Msg("Could not delete user record, trying again.")
+			continue
+		}
+
+		log.Info().Msg("Deleted user record as requested.")
+	}
+}
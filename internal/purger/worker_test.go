@@ -0,0 +1,57 @@
+package purger_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/florianloch/cassette/internal/e2e_test/mocks"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/purger"
+	"github.com/florianloch/cassette/internal/util/clocktest"
+)
+
+var dummyNow = time.Date(2021, 3, 4, 6, 30, 0, 0, time.UTC)
+
+func TestPurgesTrashAndDeletesDueUserRecords(t *testing.T) {
+	worker, _, ctrl, daoMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	daoMock.EXPECT().PurgeTrash(dummyNow).Times(1).Return(3, nil)
+	daoMock.EXPECT().LoadAccountDeletions().Times(1).Return([]*persistence.AccountDeletion{
+		{UserID: "due", RequestedAtTs: dummyNow.Add(-7 * 24 * time.Hour).Unix(), DeleteAtTs: dummyNow.Unix()},
+		{UserID: "pending", RequestedAtTs: dummyNow.Unix(), DeleteAtTs: dummyNow.Add(7 * 24 * time.Hour).Unix()},
+		{UserID: "gone", RequestedAtTs: dummyNow.Add(-8 * 24 * time.Hour).Unix(), DeleteAtTs: dummyNow.Add(-time.Hour).Unix()},
+	}, nil)
+	daoMock.EXPECT().DeleteUserRecord("due").Times(1).Return(nil)
+	daoMock.EXPECT().DeleteUserRecord("gone").Times(1).Return(persistence.ErrUserNotFound)
+	daoMock.EXPECT().DeleteUserRecord("pending").Times(0)
+
+	worker.RunOnce()
+}
+
+func TestDeletesUserRecordsDespiteFailingPurge(t *testing.T) {
+	worker, clock, ctrl, daoMock := beforeEach(t)
+	defer ctrl.Finish()
+
+	clock.Set(dummyNow.Add(7 * 24 * time.Hour))
+
+	daoMock.EXPECT().PurgeTrash(clock.Now()).Times(1).Return(0, errors.New("db down"))
+	daoMock.EXPECT().LoadAccountDeletions().Times(1).Return([]*persistence.AccountDeletion{
+		{UserID: "pending", RequestedAtTs: dummyNow.Unix(), DeleteAtTs: dummyNow.Add(7 * 24 * time.Hour).Unix()},
+	}, nil)
+	daoMock.EXPECT().DeleteUserRecord("pending").Times(1).Return(nil)
+
+	worker.RunOnce()
+}
+
+func beforeEach(t *testing.T) (*purger.Worker, *clocktest.Clock, *gomock.Controller, *mocks.MockPersistor) {
+	ctrl := gomock.NewController(t)
+
+	daoMock := mocks.NewMockPersistor(ctrl)
+	clock := clocktest.New(dummyNow)
+
+	return purger.NewWorker(daoMock, clock, time.Minute), clock, ctrl, daoMock
+}
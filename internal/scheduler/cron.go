@@ -0,0 +1,175 @@
+// Package scheduler restores slots at times given by cron expressions, e.g., for resuming an audiobook every
+// morning on the kitchen speaker.
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchLimit bounds the search for the next run, expressions like "0 0 31 2 *" never match
+const searchLimit = 5 * 366 * 24 * time.Hour
+
+// Cron is a parsed cron expression consisting of the fields minute, hour, day of month, month and day of week.
+// Every field is either "*", a number, a range like "1-5", a list like "1,3,5" or a step like "*/15" resp. "8-18/2".
+// Days of week are numbered from 0 (Sunday) to 6, 7 is accepted for Sunday as well. As usual, in case both the day
+// of month and the day of week are restricted a day matching any of them matches.
+type Cron struct {
+	minutes     []bool
+	hours       []bool
+	daysOfMonth []bool
+	months      []bool
+	daysOfWeek  []bool
+	// Set when the day of month resp. the day of week is "*", only the other one is considered then
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+type cronField struct {
+	name string
+	min  int
+	max  int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseCron parses an expression like "30 7 * * 1-5" (07:30 on weekdays)
+func ParseCron(expression string) (*Cron, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("expected %d fields (minute, hour, day of month, month and day of week), got %d", len(cronFields), len(fields))
+	}
+
+	parsed := make([][]bool, len(fields))
+	for i, field := range fields {
+		var err error
+		parsed[i], err = parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Sunday can be given as 0 as well as 7
+	daysOfWeek := parsed[4]
+	daysOfWeek[0] = daysOfWeek[0] || daysOfWeek[7]
+
+	return &Cron{
+		minutes:       parsed[0],
+		hours:         parsed[1],
+		daysOfMonth:   parsed[2],
+		months:        parsed[3],
+		daysOfWeek:    daysOfWeek[:7],
+		anyDayOfMonth: fields[2] == "*",
+		anyDayOfWeek:  fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, spec cronField) ([]bool, error) {
+	matches := make([]bool, spec.max+1)
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step in %s field '%s'", spec.name, field)
+			}
+			rangePart = part[:idx]
+		}
+
+		from, to := spec.min, spec.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value in %s field '%s'", spec.name, field)
+			}
+
+			to = from
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid value in %s field '%s'", spec.name, field)
+				}
+			} else if step > 1 {
+				// "5/15" is short for "5-59/15"
+				to = spec.max
+			}
+		}
+
+		if from < spec.min || to > spec.max || from > to {
+			return nil, fmt.Errorf("%s field '%s' is out of range %d-%d", spec.name, field, spec.min, spec.max)
+		}
+
+		for value := from; value <= to; value += step {
+			matches[value] = true
+		}
+	}
+
+	return matches, nil
+}
+
+// Next returns the first time after the given one matching the expression, in the location of the given time.
+// The zero time is returned in case the expression never matches, e.g., for the 31st of February.
+// Times skipped when switching to daylight saving time do not match.
+func (c *Cron) Next(after time.Time) time.Time {
+	loc := after.Location()
+	limit := after.Add(searchLimit)
+
+	t := after.Truncate(time.Minute).Add(time.Minute)
+
+	for t.Before(limit) {
+		if !c.months[t.Month()] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !c.hours[t.Hour()] {
+			// Adding to the time instead of using time.Date keeps this working on the day switching to resp.
+			// from daylight saving time
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			continue
+		}
+
+		if !c.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (c *Cron) matchesDay(t time.Time) bool {
+	dayOfMonth := c.daysOfMonth[t.Day()]
+	dayOfWeek := c.daysOfWeek[t.Weekday()]
+
+	switch {
+	case c.anyDayOfMonth && c.anyDayOfWeek:
+		return true
+	case c.anyDayOfMonth:
+		return dayOfWeek
+	case c.anyDayOfWeek:
+		return dayOfMonth
+	default:
+		return dayOfMonth || dayOfWeek
+	}
+}
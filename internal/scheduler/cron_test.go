@@ -0,0 +1,68 @@
+package scheduler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/florianloch/cassette/internal/scheduler"
+)
+
+func TestCronNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("could not load time zone: %s", err)
+	}
+
+	cases := []struct {
+		expression string
+		after      time.Time
+		expected   time.Time
+	}{
+		// Thursday
+		{"30 7 * * *", time.Date(2021, 3, 4, 7, 29, 59, 0, time.UTC), time.Date(2021, 3, 4, 7, 30, 0, 0, time.UTC)},
+		{"30 7 * * *", time.Date(2021, 3, 4, 7, 30, 0, 0, time.UTC), time.Date(2021, 3, 5, 7, 30, 0, 0, time.UTC)},
+		{"30 7 * * 1-5", time.Date(2021, 3, 5, 8, 0, 0, 0, time.UTC), time.Date(2021, 3, 8, 7, 30, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2021, 3, 7, 9, 0, 0, 0, time.UTC)},
+		{"*/15 8-18/2 * * *", time.Date(2021, 3, 4, 9, 50, 0, 0, time.UTC), time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Day of month and day of week are restricted, any of them has to match
+		{"0 12 15 * 1", time.Date(2021, 3, 9, 0, 0, 0, 0, time.UTC), time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{"0 12 10,20 * 1", time.Date(2021, 3, 9, 0, 0, 0, 0, time.UTC), time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)},
+		// 02:30 does not exist on the day switching to daylight saving time
+		{"30 2 * * *", time.Date(2021, 3, 27, 3, 0, 0, 0, berlin), time.Date(2021, 3, 29, 2, 30, 0, 0, berlin)},
+		{"0 7 * * *", time.Date(2021, 3, 27, 8, 0, 0, 0, berlin), time.Date(2021, 3, 28, 7, 0, 0, 0, berlin)},
+	}
+
+	for _, c := range cases {
+		cron, err := scheduler.ParseCron(c.expression)
+		if err != nil {
+			t.Errorf("could not parse '%s': %s", c.expression, err)
+			continue
+		}
+
+		next := cron.Next(c.after)
+		if !next.Equal(c.expected) {
+			t.Errorf("expected '%s' to run next at %s after %s, got %s", c.expression, c.expected, c.after, next)
+		}
+	}
+}
+
+func TestCronNeverMatching(t *testing.T) {
+	cron, err := scheduler.ParseCron("0 0 31 2 *")
+	if err != nil {
+		t.Fatalf("could not parse expression: %s", err)
+	}
+
+	if next := cron.Next(time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)); !next.IsZero() {
+		t.Fatalf("expected expression to never match, got %s", next)
+	}
+}
+
+func TestInvalidCron(t *testing.T) {
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *", "1,,2 * * * *"} {
+		_, err := scheduler.ParseCron(expression)
+		if err == nil {
+			t.Errorf("expected '%s' to be rejected", expression)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/events"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/spotify"
+	"github.com/florianloch/cassette/internal/util"
+)
+
+var (
+	// ErrNeverRuns is returned when planning a schedule whose cron expression never matches
+	ErrNeverRuns = errors.New("cron expression never matches")
+
+	// errNotRunnable tells the schedule cannot be run at all, so there is no point in trying again
+	errNotRunnable = errors.New("schedule cannot be run")
+)
+
+// ClientCreator has to return a client persisting refreshed tokens for the given user
+type ClientCreator func(userID string, token *oauth2.Token) spotify.SpotClient
+
+// Plan sets when the schedule runs next after the given time, the cron expression is evaluated in the given location
+func Plan(schedule *persistence.Schedule, loc *time.Location, after time.Time) error {
+	cron, err := ParseCron(schedule.Cron)
+	if err != nil {
+		return err
+	}
+
+	next := cron.Next(after.In(loc))
+	if next.IsZero() {
+		return ErrNeverRuns
+	}
+
+	schedule.NextRunAtTs = next.Unix()
+
+	return nil
+}
+
+// finishedRun remembers when a run has been due, this way it can be told whether the user re-planned the schedule
+// in the meantime
+type finishedRun struct {
+	dueAtTs     int64
+	nextRunAtTs int64
+	lastRunAtTs int64
+}
+
+// Worker periodically runs the schedules being due. As the schedules are kept in the database they survive restarts,
+// runs missed in the meantime are handled according to the MissedRunPolicy of the schedule. Runs failing are retried
+// until they are overdue.
+type Worker struct {
+	dao          persistence.Persistor
+	bus          events.Bus
+	createClient ClientCreator
+	clock        util.Clock
+	interval     time.Duration
+}
+
+func NewWorker(dao persistence.Persistor, bus events.Bus, createClient ClientCreator, clock util.Clock, interval time.Duration) *Worker {
+	return &Worker{
+		dao:          dao,
+		bus:          bus,
+		createClient: createClient,
+		clock:        clock,
+		interval:     interval,
+	}
+}
+
+// Run blocks until stop gets closed
+func (w *Worker) Run(stop <-chan struct{}) {
+	log.Info().Msgf("Checking for schedules being due every %s.", w.interval)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.RunOnce()
+		}
+	}
+}
+
+// RunOnce runs all schedules being due
+func (w *Worker) RunOnce() {
+	all, err := w.dao.LoadAllSchedules()
+	if err != nil {
+		log.Error().Err(err).Msg("Could not load schedules.")
+		return
+	}
+
+	now := w.clock.Now()
+
+	for _, schedules := range all {
+		err = w.runDue(schedules, now)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to run schedules of user.")
+		}
+	}
+}
+
+// runDue runs the schedules of the user being due and plans their next runs
+func (w *Worker) runDue(schedules *persistence.UserSchedules, now time.Time) error {
+	loc, err := time.LoadLocation(schedules.TimeZone)
+	if err != nil {
+		// Time zones are checked when being set, but the zone database of this instance might lack it
+		log.Warn().Err(err).Str("timeZone", schedules.TimeZone).Msg("Unknown time zone, using UTC instead.")
+		loc = time.UTC
+	}
+
+	done := make(map[string]*finishedRun)
+
+	for _, schedule := range schedules.Schedules {
+		if schedule.NextRunAtTs == 0 || schedule.NextRunAtTs > now.Unix() {
+			continue
+		}
+
+		overdue := now.Unix() - schedule.NextRunAtTs
+
+		if overdue > constants.ScheduleRunSlack && schedule.MissedRun != persistence.MissedRunCatchUp {
+			log.Info().Str("schedule", schedule.ID).Msg("Run of schedule has been missed, skipping it.")
+		} else {
+			err = w.run(schedules.UserID, schedule, now)
+			switch {
+			case err == nil:
+				schedule.LastRunAtTs = now.Unix()
+			case errors.Is(err, errNotRunnable):
+				log.Info().Err(err).Str("schedule", schedule.ID).Msg("Skipping run of schedule.")
+			case overdue < constants.ScheduleMaxDelay:
+				log.Warn().Err(err).Str("schedule", schedule.ID).Msg("Failed to run schedule, trying again.")
+				continue
+			default:
+				log.Error().Err(err).Str("schedule", schedule.ID).Msg("Failed to run schedule, giving up.")
+			}
+		}
+
+		run := &finishedRun{dueAtTs: schedule.NextRunAtTs}
+
+		err = Plan(schedule, loc, now)
+		if err != nil {
+			// Cannot happen as the cron expression has been checked when storing the schedule
+			log.Error().Err(err).Str("schedule", schedule.ID).Msg("Could not plan next run of schedule, disabling it.")
+			schedule.NextRunAtTs = 0
+		}
+
+		run.nextRunAtTs = schedule.NextRunAtTs
+		run.lastRunAtTs = schedule.LastRunAtTs
+		done[schedule.ID] = run
+	}
+
+	if len(done) == 0 {
+		return nil
+	}
+
+	return w.store(schedules, done)
+}
+
+// store persists the next runs planned. In case the user modified the schedules concurrently the runs get applied
+// to the modified schedules, otherwise they would be run once again.
+func (w *Worker) store(schedules *persistence.UserSchedules, done map[string]*finishedRun) error {
+	err := w.dao.SaveSchedules(schedules)
+	if err != persistence.ErrRevisionMismatch {
+		return err
+	}
+
+	current, err := w.dao.LoadSchedules(schedules.UserID)
+	if err != nil {
+		return fmt.Errorf("could not reload schedules: %w", err)
+	}
+
+	for _, schedule := range current.Schedules {
+		run, ok := done[schedule.ID]
+		if !ok || schedule.NextRunAtTs != run.dueAtTs {
+			// Deleted resp. re-planned by the user
+			continue
+		}
+
+		schedule.NextRunAtTs = run.nextRunAtTs
+		schedule.LastRunAtTs = run.lastRunAtTs
+	}
+
+	return w.dao.SaveSchedules(current)
+}
+
+// run restores the slot of the schedule onto the device of the schedule
+func (w *Worker) run(userID string, schedule *persistence.Schedule, now time.Time) error {
+	token, err := w.dao.LoadToken(userID)
+	if err != nil {
+		if err == persistence.ErrTokenNotFound {
+			return fmt.Errorf("%w: user revoked access to Cassette", errNotRunnable)
+		}
+
+		return fmt.Errorf("could not load token: %w", err)
+	}
+
+	playerStates, _, err := w.dao.LoadPlayerStates(userID)
+	if err != nil {
+		return fmt.Errorf("could not load player states: %w", err)
+	}
+
+	var stateToRestore *persistence.PlayerState
+	for _, playerState := range playerStates {
+		if playerState.ID == schedule.Slot {
+			stateToRestore = playerState
+		}
+	}
+
+	if stateToRestore == nil {
+		return fmt.Errorf("%w: slot '%s' does not exist anymore", errNotRunnable, schedule.Slot)
+	}
+
+	preferences, err := w.dao.LoadPreferences(userID)
+	if err != nil {
+		return fmt.Errorf("could not load preferences: %w", err)
+	}
+
+	jumpBack := preferences.JumpBack(stateToRestore, now.Sub(time.Unix(stateToRestore.SuspendedAtTs, 0)))
+
+	err = spotify.RestorePlayerState(w.createClient(userID, token), stateToRestore, nil, schedule.DeviceID, jumpBack)
+	if err != nil {
+		return fmt.Errorf("could not restore player state: %w", err)
+	}
+
+	w.bus.Publish(events.New(events.PlaybackRestored, userID, stateToRestore.ID))
+
+	err = w.dao.AppendHistoryEvent(userID, persistence.NewHistoryEvent(persistence.HistoryRestored, stateToRestore, now))
+	if err != nil {
+		// Failing here must not lead to restoring the slot again
+		log.Error().Err(err).Str("schedule", schedule.ID).Msg("Could not record history.")
+	}
+
+	return nil
+}
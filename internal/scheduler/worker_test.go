@@ -0,0 +1,364 @@
+package scheduler_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	spotifyAPI "github.com/zmb3/spotify"
+	"golang.org/x/oauth2"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/e2e_test/mocks"
+	"github.com/florianloch/cassette/internal/events"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/scheduler"
+	"github.com/florianloch/cassette/internal/spotify"
+	"github.com/florianloch/cassette/internal/util/clocktest"
+)
+
+const (
+	dummyUserID   = "early_gopher"
+	dummyDeviceID = "kitchen"
+)
+
+var (
+	dummyToken = &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}
+	// Thursday, 2021-03-04 07:30 in Berlin
+	dummyNow = time.Date(2021, 3, 4, 6, 30, 0, 0, time.UTC)
+)
+
+func TestPlan(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("could not load time zone: %s", err)
+	}
+
+	schedule := &persistence.Schedule{Cron: "30 7 * * 1-5"}
+
+	err = scheduler.Plan(schedule, berlin, dummyNow)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// 07:30 has just passed, so it is Friday next
+	if expected := dummyNow.Add(24 * time.Hour).Unix(); schedule.NextRunAtTs != expected {
+		t.Fatalf("expected next run at %d, got %d", expected, schedule.NextRunAtTs)
+	}
+
+	schedule.Cron = "0 0 31 2 *"
+	if err = scheduler.Plan(schedule, berlin, dummyNow); err != scheduler.ErrNeverRuns {
+		t.Fatalf("expected ErrNeverRuns, got %v", err)
+	}
+}
+
+func TestScheduleRestoresSlotOntoDevice(t *testing.T) {
+	worker, _, ctrl, daoMock, clientMock, subscription := beforeEach(t)
+	defer ctrl.Finish()
+
+	schedules := userSchedules(&persistence.Schedule{
+		ID:          "s1",
+		Slot:        "book",
+		DeviceID:    dummyDeviceID,
+		Cron:        "30 7 * * 1-5",
+		MissedRun:   persistence.MissedRunSkip,
+		NextRunAtTs: dummyNow.Unix(),
+	}, &persistence.Schedule{
+		ID:          "s2",
+		Slot:        "book",
+		DeviceID:    dummyDeviceID,
+		Cron:        "0 20 * * *",
+		NextRunAtTs: dummyNow.Add(time.Hour).Unix(),
+	})
+
+	daoMock.EXPECT().LoadAllSchedules().Times(1).Return([]*persistence.UserSchedules{schedules}, nil)
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{{ID: "other"}, dummyPlayerState()}, 1, nil)
+	clientMock.EXPECT().Shuffle(false).Times(1)
+	clientMock.EXPECT().PlayOpt(gomock.Any()).Times(1).DoAndReturn(func(opt *spotifyAPI.PlayOptions) error {
+		if opt.DeviceID == nil || *opt.DeviceID != dummyDeviceID || string(*opt.PlaybackContext) != "spotify:album:1" {
+			t.Fatalf("expected slot to be restored onto device of schedule, got %+v", opt)
+		}
+
+		if opt.PositionMs != 60000-persistence.DefaultJumpBackSeconds*1000 {
+			t.Fatalf("expected playback to jump back, got position %d", opt.PositionMs)
+		}
+
+		return nil
+	})
+	daoMock.EXPECT().AppendHistoryEvent(dummyUserID, gomock.Any()).Times(1).DoAndReturn(func(_ string, event *persistence.HistoryEvent) error {
+		if event.Type != persistence.HistoryRestored || event.Slot != "book" || event.AtTs != dummyNow.Unix() {
+			t.Fatalf("expected restore to be recorded, got %+v", event)
+		}
+
+		// Jumping back only applies to the playback, the history keeps the position of the slot
+		if event.PlayerState.Progress != 60000 {
+			t.Fatalf("expected position of slot to be recorded, got %d", event.PlayerState.Progress)
+		}
+
+		return nil
+	})
+	daoMock.EXPECT().SaveSchedules(schedules).Times(1).DoAndReturn(func(schedules *persistence.UserSchedules) error {
+		scheduled := schedules.Schedules[0]
+		if scheduled.LastRunAtTs != dummyNow.Unix() || scheduled.NextRunAtTs != dummyNow.Add(24*time.Hour).Unix() {
+			t.Fatalf("expected schedule to be planned for the next day, got %+v", scheduled)
+		}
+
+		if schedules.Schedules[1].NextRunAtTs != dummyNow.Add(time.Hour).Unix() {
+			t.Fatalf("expected schedule not being due to be kept, got %+v", schedules.Schedules[1])
+		}
+
+		return nil
+	})
+
+	worker.RunOnce()
+
+	expectEvent(t, subscription, events.PlaybackRestored, "book")
+}
+
+func TestMissedRunGetsSkipped(t *testing.T) {
+	worker, clock, ctrl, daoMock, _, subscription := beforeEach(t)
+	defer ctrl.Finish()
+
+	schedules := userSchedules(&persistence.Schedule{
+		ID:          "s1",
+		Slot:        "book",
+		DeviceID:    dummyDeviceID,
+		Cron:        "30 7 * * *",
+		MissedRun:   persistence.MissedRunSkip,
+		NextRunAtTs: dummyNow.Unix(),
+	})
+
+	clock.Advance(time.Hour)
+
+	daoMock.EXPECT().LoadAllSchedules().Times(1).Return([]*persistence.UserSchedules{schedules}, nil)
+	daoMock.EXPECT().SaveSchedules(schedules).Times(1).DoAndReturn(func(schedules *persistence.UserSchedules) error {
+		scheduled := schedules.Schedules[0]
+		if scheduled.LastRunAtTs != 0 || scheduled.NextRunAtTs != dummyNow.Add(24*time.Hour).Unix() {
+			t.Fatalf("expected run to be skipped, got %+v", scheduled)
+		}
+
+		return nil
+	})
+
+	worker.RunOnce()
+
+	if len(subscription) != 0 {
+		t.Fatalf("unexpected event: %+v", <-subscription)
+	}
+}
+
+func TestMissedRunGetsCaughtUpOn(t *testing.T) {
+	worker, clock, ctrl, daoMock, clientMock, subscription := beforeEach(t)
+	defer ctrl.Finish()
+
+	schedules := userSchedules(&persistence.Schedule{
+		ID:          "s1",
+		Slot:        "book",
+		DeviceID:    dummyDeviceID,
+		Cron:        "30 7 * * *",
+		MissedRun:   persistence.MissedRunCatchUp,
+		NextRunAtTs: dummyNow.Add(-48 * time.Hour).Unix(),
+	})
+
+	clock.Advance(time.Hour)
+
+	daoMock.EXPECT().LoadAllSchedules().Times(1).Return([]*persistence.UserSchedules{schedules}, nil)
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState()}, 1, nil)
+	clientMock.EXPECT().Shuffle(false).Times(1)
+	clientMock.EXPECT().PlayOpt(gomock.Any()).Times(1)
+	daoMock.EXPECT().AppendHistoryEvent(dummyUserID, gomock.Any()).Times(1)
+	daoMock.EXPECT().SaveSchedules(schedules).Times(1).DoAndReturn(func(schedules *persistence.UserSchedules) error {
+		// The runs missed are caught up on only once
+		scheduled := schedules.Schedules[0]
+		if scheduled.LastRunAtTs != clock.Now().Unix() || scheduled.NextRunAtTs != dummyNow.Add(24*time.Hour).Unix() {
+			t.Fatalf("expected run to be caught up on, got %+v", scheduled)
+		}
+
+		return nil
+	})
+
+	worker.RunOnce()
+
+	expectEvent(t, subscription, events.PlaybackRestored, "book")
+}
+
+func TestFailingRunIsRetriedUntilOverdue(t *testing.T) {
+	worker, clock, ctrl, daoMock, clientMock, _ := beforeEach(t)
+	defer ctrl.Finish()
+
+	schedules := userSchedules(&persistence.Schedule{
+		ID:          "s1",
+		Slot:        "book",
+		DeviceID:    dummyDeviceID,
+		Cron:        "30 7 * * *",
+		MissedRun:   persistence.MissedRunCatchUp,
+		NextRunAtTs: dummyNow.Unix(),
+	})
+
+	daoMock.EXPECT().LoadAllSchedules().Times(2).Return([]*persistence.UserSchedules{schedules}, nil)
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(2).Return([]*persistence.PlayerState{dummyPlayerState()}, 1, nil)
+	clientMock.EXPECT().Shuffle(false).Times(2).Return(errors.New("spotify is down"))
+
+	worker.RunOnce()
+
+	clock.Advance(constants.ScheduleMaxDelay * time.Second)
+
+	daoMock.EXPECT().SaveSchedules(schedules).Times(1).DoAndReturn(func(schedules *persistence.UserSchedules) error {
+		scheduled := schedules.Schedules[0]
+		if scheduled.LastRunAtTs != 0 || scheduled.NextRunAtTs != dummyNow.Add(24*time.Hour).Unix() {
+			t.Fatalf("expected run to be given up, got %+v", scheduled)
+		}
+
+		return nil
+	})
+
+	worker.RunOnce()
+}
+
+func TestRunOfDeletedSlotIsSkipped(t *testing.T) {
+	worker, _, ctrl, daoMock, _, _ := beforeEach(t)
+	defer ctrl.Finish()
+
+	schedules := userSchedules(&persistence.Schedule{
+		ID:          "s1",
+		Slot:        "deleted",
+		DeviceID:    dummyDeviceID,
+		Cron:        "30 7 * * *",
+		NextRunAtTs: dummyNow.Unix(),
+	})
+
+	daoMock.EXPECT().LoadAllSchedules().Times(1).Return([]*persistence.UserSchedules{schedules}, nil)
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{dummyPlayerState()}, 1, nil)
+	daoMock.EXPECT().SaveSchedules(schedules).Times(1)
+
+	worker.RunOnce()
+
+	if schedules.Schedules[0].NextRunAtTs != dummyNow.Add(24*time.Hour).Unix() {
+		t.Fatalf("expected next run to be planned, got %+v", schedules.Schedules[0])
+	}
+}
+
+func TestRunsAreAppliedToSchedulesModifiedConcurrently(t *testing.T) {
+	worker, _, ctrl, daoMock, clientMock, _ := beforeEach(t)
+	defer ctrl.Finish()
+
+	schedules := userSchedules(&persistence.Schedule{
+		ID:          "s1",
+		Slot:        "book",
+		DeviceID:    dummyDeviceID,
+		Cron:        "30 7 * * *",
+		NextRunAtTs: dummyNow.Unix(),
+	}, &persistence.Schedule{
+		ID:          "s2",
+		Slot:        "book",
+		DeviceID:    dummyDeviceID,
+		Cron:        "30 7 * * *",
+		NextRunAtTs: dummyNow.Unix(),
+	})
+
+	// Meanwhile, the user re-planned the second schedule and created a third one
+	modified := userSchedules(&persistence.Schedule{
+		ID:          "s1",
+		Slot:        "book",
+		DeviceID:    dummyDeviceID,
+		Cron:        "30 7 * * *",
+		NextRunAtTs: dummyNow.Unix(),
+	}, &persistence.Schedule{
+		ID:          "s2",
+		Slot:        "book",
+		DeviceID:    dummyDeviceID,
+		Cron:        "0 8 * * *",
+		NextRunAtTs: dummyNow.Add(30 * time.Minute).Unix(),
+	}, &persistence.Schedule{
+		ID:          "s3",
+		Slot:        "book",
+		DeviceID:    dummyDeviceID,
+		Cron:        "0 9 * * *",
+		NextRunAtTs: dummyNow.Add(90 * time.Minute).Unix(),
+	})
+
+	daoMock.EXPECT().LoadAllSchedules().Times(1).Return([]*persistence.UserSchedules{schedules}, nil)
+	daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(2).Return([]*persistence.PlayerState{dummyPlayerState()}, 1, nil)
+	clientMock.EXPECT().Shuffle(false).Times(2)
+	clientMock.EXPECT().PlayOpt(gomock.Any()).Times(2)
+	daoMock.EXPECT().AppendHistoryEvent(dummyUserID, gomock.Any()).Times(2)
+
+	gomock.InOrder(
+		daoMock.EXPECT().SaveSchedules(schedules).Times(1).Return(persistence.ErrRevisionMismatch),
+		daoMock.EXPECT().LoadSchedules(dummyUserID).Times(1).Return(modified, nil),
+		daoMock.EXPECT().SaveSchedules(modified).Times(1),
+	)
+
+	worker.RunOnce()
+
+	if s := modified.Schedules[0]; s.LastRunAtTs != dummyNow.Unix() || s.NextRunAtTs != dummyNow.Add(24*time.Hour).Unix() {
+		t.Fatalf("expected run to be applied, got %+v", s)
+	}
+
+	if s := modified.Schedules[1]; s.LastRunAtTs != 0 || s.NextRunAtTs != dummyNow.Add(30*time.Minute).Unix() {
+		t.Fatalf("expected schedule re-planned by the user to be kept, got %+v", s)
+	}
+
+	if s := modified.Schedules[2]; s.NextRunAtTs != dummyNow.Add(90*time.Minute).Unix() {
+		t.Fatalf("expected new schedule to be kept, got %+v", s)
+	}
+}
+
+// dummyPlayerState returns a new slot every time as restoring modifies the slot given
+func dummyPlayerState() *persistence.PlayerState {
+	return &persistence.PlayerState{
+		ID:                 "book",
+		ContextType:        "album",
+		PlaybackContextURI: "spotify:album:1",
+		PlaybackItemURI:    "spotify:track:1",
+		Progress:           60000,
+	}
+}
+
+func userSchedules(schedules ...*persistence.Schedule) *persistence.UserSchedules {
+	return &persistence.UserSchedules{
+		UserID:    dummyUserID,
+		Revision:  1,
+		TimeZone:  "Europe/Berlin",
+		Schedules: schedules,
+	}
+}
+
+func expectEvent(t *testing.T, subscription <-chan events.Event, eventType events.Type, slot string) {
+	t.Helper()
+
+	select {
+	case event := <-subscription:
+		if event.Type != eventType || event.Slot != slot {
+			t.Fatalf("expected %s event for slot '%s', got %+v", eventType, slot, event)
+		}
+	default:
+		t.Fatalf("expected %s event for slot '%s'", eventType, slot)
+	}
+}
+
+func beforeEach(t *testing.T) (*scheduler.Worker, *clocktest.Clock, *gomock.Controller, *mocks.MockPersistor, *mocks.MockSpotClient, <-chan events.Event) {
+	ctrl := gomock.NewController(t)
+
+	daoMock := mocks.NewMockPersistor(ctrl)
+	clientMock := mocks.NewMockSpotClient(ctrl)
+	daoMock.EXPECT().LoadToken(dummyUserID).AnyTimes().Return(dummyToken, nil)
+	daoMock.EXPECT().LoadPreferences(dummyUserID).AnyTimes().Return(&persistence.Preferences{JumpBackSeconds: persistence.DefaultJumpBackSeconds}, nil)
+
+	bus := events.NewInProcessBus()
+	subscription, unsubscribe := bus.Subscribe(dummyUserID)
+	t.Cleanup(unsubscribe)
+
+	clock := clocktest.New(dummyNow)
+
+	worker := scheduler.NewWorker(daoMock, bus, func(userID string, token *oauth2.Token) spotify.SpotClient {
+		if userID != dummyUserID || token != dummyToken {
+			t.Fatalf("worker uses unexpected token %+v for user '%s'", token, userID)
+		}
+
+		return clientMock
+	}, clock, 0)
+
+	return worker, clock, ctrl, daoMock, clientMock, subscription
+}
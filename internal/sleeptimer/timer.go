@@ -0,0 +1,85 @@
+// Package sleeptimer suspends the playback of users having fallen asleep, either after a given duration or
+// at the end of the current track resp. chapter.
+package sleeptimer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	spotifyAPI "github.com/zmb3/spotify"
+
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/spotify"
+)
+
+// ErrNothingPlaying is returned when a timer should fire at the end of the current track but nothing is playing
+var ErrNothingPlaying = errors.New("nothing is playing")
+
+// New creates a timer for the user firing after the given number of minutes resp., when mode is
+// persistence.SleepTimerAtTrackEnd, at the end of the track currently playing. The client is only used in the latter case.
+func New(client spotify.SpotClient, userID string, mode persistence.SleepTimerMode, minutes int, slot string, now time.Time) (*persistence.SleepTimer, error) {
+	timer := &persistence.SleepTimer{
+		UserID:      userID,
+		Mode:        mode,
+		Slot:        slot,
+		CreatedAtTs: now.Unix(),
+	}
+
+	if mode == persistence.SleepTimerAfterDuration {
+		timer.FiresAtTs = now.Add(time.Duration(minutes) * time.Minute).Unix()
+
+		return timer, nil
+	}
+
+	playerState, err := client.PlayerState()
+	if err != nil {
+		return nil, fmt.Errorf("could not read whats currently playing: %w", err)
+	}
+
+	item, err := playingItemOf(client, playerState)
+	if err != nil {
+		return nil, err
+	}
+
+	timer.TrackURI = item.uri
+	timer.FiresAtTs = item.endsAt(now).Unix()
+
+	return timer, nil
+}
+
+type playingItem struct {
+	uri      string
+	progress int
+	duration int
+}
+
+// endsAt rounds up, this way the track has ended for sure when the timer fires
+func (i *playingItem) endsAt(now time.Time) time.Time {
+	remaining := time.Duration(i.duration-i.progress) * time.Millisecond
+
+	return now.Add(remaining).Truncate(time.Second).Add(time.Second)
+}
+
+// playingItemOf tells the track resp. episode playing, returns ErrNothingPlaying if playback is paused
+func playingItemOf(client spotify.SpotClient, playerState *spotifyAPI.PlayerState) (*playingItem, error) {
+	if !playerState.Playing {
+		return nil, ErrNothingPlaying
+	}
+
+	if playerState.Item != nil {
+		return &playingItem{string(playerState.Item.URI), playerState.Progress, playerState.Item.Duration}, nil
+	}
+
+	// Spotify does not provide an item in case a podcast episode is playing
+	episode, err := client.CurrentlyPlayingEpisode()
+	if err != nil {
+		if errors.Is(err, spotify.ErrNoEpisodePlaying) {
+			return nil, ErrNothingPlaying
+		}
+
+		return nil, fmt.Errorf("could not read which episode is currently playing: %w", err)
+	}
+
+	return &playingItem{string(episode.URI), playerState.Progress, episode.Duration_ms}, nil
+}
@@ -0,0 +1,225 @@
+package sleeptimer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/events"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/spotify"
+	"github.com/florianloch/cassette/internal/util"
+)
+
+// ClientCreator has to return a client persisting refreshed tokens for the given user
+type ClientCreator func(userID string, token *oauth2.Token) spotify.SpotClient
+
+// Worker periodically fires the sleep timers being due. As the timers are kept in the database they survive restarts,
+// timers having been due in the meantime fire late. Timers failing to fire are retried until they are overdue.
+type Worker struct {
+	dao          persistence.Persistor
+	bus          events.Bus
+	createClient ClientCreator
+	clock        util.Clock
+	interval     time.Duration
+}
+
+func NewWorker(dao persistence.Persistor, bus events.Bus, createClient ClientCreator, clock util.Clock, interval time.Duration) *Worker {
+	return &Worker{
+		dao:          dao,
+		bus:          bus,
+		createClient: createClient,
+		clock:        clock,
+		interval:     interval,
+	}
+}
+
+// Run blocks until stop gets closed
+func (w *Worker) Run(stop <-chan struct{}) {
+	log.Info().Msgf("Checking for sleep timers being due every %s.", w.interval)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.RunOnce()
+		}
+	}
+}
+
+// RunOnce fires all timers being due
+func (w *Worker) RunOnce() {
+	timers, err := w.dao.LoadSleepTimers()
+	if err != nil {
+		log.Error().Err(err).Msg("Could not load sleep timers.")
+		return
+	}
+
+	now := w.clock.Now()
+
+	for _, timer := range timers {
+		if timer.FiresAtTs > now.Unix() {
+			continue
+		}
+
+		err = w.fire(timer, now)
+		if err == nil {
+			continue
+		}
+
+		if now.Unix()-timer.FiresAtTs < int64(constants.SleepTimerMaxDelay) {
+			log.Warn().Err(err).Msg("Failed to fire sleep timer, trying again.")
+			continue
+		}
+
+		log.Error().Err(err).Msg("Failed to fire sleep timer, giving up.")
+
+		err = w.dao.DeleteSleepTimer(timer.UserID)
+		if err != nil && err != persistence.ErrSleepTimerNotFound {
+			log.Error().Err(err).Msg("Could not delete sleep timer.")
+		}
+	}
+}
+
+// fire suspends the playback and pauses it, timers waiting for the end of a track get postponed
+// when the track is still playing, e.g., because it has been paused in the meantime.
+func (w *Worker) fire(timer *persistence.SleepTimer, now time.Time) error {
+	token, err := w.dao.LoadToken(timer.UserID)
+	if err != nil {
+		if err == persistence.ErrTokenNotFound {
+			log.Info().Msg("User revoked access to Cassette, dropping sleep timer.")
+			return w.delete(timer)
+		}
+
+		return fmt.Errorf("could not load token: %w", err)
+	}
+
+	client := w.createClient(timer.UserID, token)
+
+	playerState, err := client.PlayerState()
+	if err != nil {
+		return fmt.Errorf("could not read whats currently playing: %w", err)
+	}
+
+	item, err := playingItemOf(client, playerState)
+	if err != nil {
+		if err == ErrNothingPlaying {
+			// The user stopped listening on her/his own
+			return w.delete(timer)
+		}
+
+		return err
+	}
+
+	if timer.Mode == persistence.SleepTimerAtTrackEnd && item.uri == timer.TrackURI && item.endsAt(now).Unix() > now.Unix()+constants.SleepTimerTrackEndSlack {
+		timer.FiresAtTs = item.endsAt(now).Unix()
+
+		return w.dao.StoreSleepTimer(timer)
+	}
+
+	state, err := spotify.PlayerStateOf(client, playerState)
+	if err != nil {
+		if !errors.Is(err, spotify.ErrContextNotSuspendable) {
+			return fmt.Errorf("could not get suspendable player state: %w", err)
+		}
+
+		// There is nothing to suspend, but the user still wants the playback to stop
+		log.Debug().Msg("Context cannot be suspended, just pausing.")
+		state = nil
+	} else {
+		err = w.suspend(timer, state)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Pause()
+	if err != nil {
+		if state != nil {
+			// Trying again must not suspend the playback into yet another slot
+			timer.Slot = state.ID
+
+			storeErr := w.dao.StoreSleepTimer(timer)
+			if storeErr != nil {
+				log.Error().Err(storeErr).Msg("Could not update sleep timer.")
+			}
+		}
+
+		return fmt.Errorf("could not pause player: %w", err)
+	}
+
+	return w.delete(timer)
+}
+
+// suspend stores the state in the slot of the timer resp. like a slot suspended by the user
+func (w *Worker) suspend(timer *persistence.SleepTimer, state *persistence.PlayerState) error {
+	playerStates, revision, err := w.dao.LoadPlayerStates(timer.UserID)
+	if err != nil {
+		return fmt.Errorf("could not load player states: %w", err)
+	}
+
+	idx := -1
+	for cur := range playerStates {
+		if timer.Slot != "" && playerStates[cur].ID == timer.Slot {
+			idx = cur
+		}
+	}
+
+	playerStates, added := persistence.MergeSuspended(playerStates, state, idx)
+
+	archived, err := persistence.ArchiveFinished(w.dao, timer.UserID, state, time.Unix(state.SuspendedAtTs, 0))
+	if err != nil {
+		return fmt.Errorf("could not archive finished slot: %w", err)
+	}
+
+	if archived {
+		playerStates = persistence.WithoutSlot(playerStates, state)
+	}
+
+	_, err = w.dao.SavePlayerStates(timer.UserID, playerStates, revision)
+	if err != nil {
+		if archived {
+			undoErr := persistence.UndoArchiving(w.dao, timer.UserID, state)
+			if undoErr != nil {
+				log.Error().Err(undoErr).Msg("Could not remove slot from archive again.")
+			}
+		}
+
+		// In case the user modified the player states in the meantime the next run will try again
+		return fmt.Errorf("could not persist player states: %w", err)
+	}
+
+	// The ID has been assigned when saving
+	if archived {
+		w.bus.Publish(events.New(events.SlotArchived, timer.UserID, state.ID))
+	} else if added {
+		w.bus.Publish(events.New(events.SlotCreated, timer.UserID, state.ID))
+	} else {
+		w.bus.Publish(events.New(events.SlotUpdated, timer.UserID, state.ID))
+	}
+
+	err = w.dao.AppendHistoryEvent(timer.UserID, persistence.NewHistoryEvent(persistence.HistorySuspended, state, time.Unix(state.SuspendedAtTs, 0)))
+	if err != nil {
+		// The slot has been suspended after all
+		log.Error().Err(err).Msg("Could not record history.")
+	}
+
+	return nil
+}
+
+func (w *Worker) delete(timer *persistence.SleepTimer) error {
+	err := w.dao.DeleteSleepTimer(timer.UserID)
+	if err != nil && err != persistence.ErrSleepTimerNotFound {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,269 @@
+package sleeptimer_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	spotifyAPI "github.com/zmb3/spotify"
+	"golang.org/x/oauth2"
+
+	"github.com/florianloch/cassette/internal/constants"
+	"github.com/florianloch/cassette/internal/e2e_test/mocks"
+	"github.com/florianloch/cassette/internal/events"
+	"github.com/florianloch/cassette/internal/persistence"
+	"github.com/florianloch/cassette/internal/sleeptimer"
+	"github.com/florianloch/cassette/internal/spotify"
+	"github.com/florianloch/cassette/internal/util/clocktest"
+)
+
+const (
+	dummyUserID = "sleepy_gopher"
+)
+
+var (
+	dummyToken  = &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}
+	dummyNow    = time.Unix(1615000000, 0)
+	dummyTrackA = &spotifyAPI.FullTrack{
+		SimpleTrack: spotifyAPI.SimpleTrack{
+			Name:     "Chapter 1",
+			URI:      "spotify:track:1",
+			Duration: 60000,
+		},
+	}
+	dummyTrackB = &spotifyAPI.FullTrack{
+		SimpleTrack: spotifyAPI.SimpleTrack{
+			Name:     "Chapter 2",
+			URI:      "spotify:track:2",
+			Duration: 60000,
+		},
+	}
+)
+
+func TestTimerFiresAfterDuration(t *testing.T) {
+	worker, clock, ctrl, daoMock, clientMock, subscription := beforeEach(t)
+	defer ctrl.Finish()
+
+	timer := &persistence.SleepTimer{
+		UserID:    dummyUserID,
+		Mode:      persistence.SleepTimerAfterDuration,
+		FiresAtTs: dummyNow.Add(30 * time.Minute).Unix(),
+	}
+
+	daoMock.EXPECT().LoadSleepTimers().Times(2).Return([]*persistence.SleepTimer{timer}, nil)
+
+	// Not yet due
+	worker.RunOnce()
+
+	clock.Advance(30 * time.Minute)
+
+	gomock.InOrder(
+		clientMock.EXPECT().PlayerState().Times(1).Return(playing(dummyTrackA, 42000), nil),
+		daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{{ID: "other"}}, 1, nil),
+		daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+			if len(playerStates) != 2 || playerStates[1].PlaybackItemURI != string(dummyTrackA.URI) || playerStates[1].Progress != 42000 {
+				t.Fatalf("expected playback to be suspended into new slot, got %+v", playerStates)
+			}
+
+			playerStates[1].ID = "new"
+
+			return 2, nil
+		}),
+		daoMock.EXPECT().AppendHistoryEvent(dummyUserID, gomock.Any()).Times(1).DoAndReturn(func(_ string, event *persistence.HistoryEvent) error {
+			if event.Type != persistence.HistorySuspended || event.Slot != "new" || event.PlayerState.Progress != 42000 {
+				t.Fatalf("expected suspend to be recorded, got %+v", event)
+			}
+
+			return nil
+		}),
+		clientMock.EXPECT().Pause().Times(1),
+		daoMock.EXPECT().DeleteSleepTimer(dummyUserID).Times(1),
+	)
+
+	worker.RunOnce()
+
+	expectEvent(t, subscription, events.SlotCreated, "new")
+}
+
+func TestTimerWaitsForEndOfTrack(t *testing.T) {
+	worker, clock, ctrl, daoMock, clientMock, subscription := beforeEach(t)
+	defer ctrl.Finish()
+
+	timer := &persistence.SleepTimer{
+		UserID:    dummyUserID,
+		Mode:      persistence.SleepTimerAtTrackEnd,
+		Slot:      "book",
+		TrackURI:  string(dummyTrackA.URI),
+		FiresAtTs: dummyNow.Unix(),
+	}
+
+	daoMock.EXPECT().LoadSleepTimers().Times(2).Return([]*persistence.SleepTimer{timer}, nil)
+
+	// Playback has been paused in the meantime, so the track is still playing
+	clientMock.EXPECT().PlayerState().Times(1).Return(playing(dummyTrackA, 30000), nil)
+	daoMock.EXPECT().StoreSleepTimer(gomock.Any()).Times(1).DoAndReturn(func(timer *persistence.SleepTimer) error {
+		if timer.FiresAtTs != dummyNow.Add(31*time.Second).Unix() {
+			t.Fatalf("expected timer to be postponed to the end of the track, got %+v", timer)
+		}
+
+		return nil
+	})
+
+	worker.RunOnce()
+
+	clock.Advance(31 * time.Second)
+
+	// The next track of the album has just started
+	nextTrack := playing(dummyTrackB, 500)
+	nextTrack.PlaybackContext = spotifyAPI.PlaybackContext{Type: "album", URI: "spotify:album:book"}
+
+	gomock.InOrder(
+		clientMock.EXPECT().PlayerState().Times(1).Return(nextTrack, nil),
+		clientMock.EXPECT().GetAlbumTracksOpt(spotifyAPI.ID("book"), gomock.Any()).Times(1).DoAndReturn(func(_ spotifyAPI.ID, _ *spotifyAPI.Options) (*spotifyAPI.SimpleTrackPage, error) {
+			page := &spotifyAPI.SimpleTrackPage{Tracks: []spotifyAPI.SimpleTrack{dummyTrackA.SimpleTrack, dummyTrackB.SimpleTrack}}
+			page.Total = 2
+
+			return page, nil
+		}),
+		daoMock.EXPECT().LoadPlayerStates(dummyUserID).Times(1).Return([]*persistence.PlayerState{{
+			ID:                 "book",
+			PlaybackContextURI: "spotify:album:book",
+			ContextType:        "album",
+			Bookmarks:          []*persistence.Bookmark{{ID: "b1"}},
+		}, {ID: "other"}}, 1, nil),
+		daoMock.EXPECT().SavePlayerStates(dummyUserID, gomock.Any(), 1).Times(1).DoAndReturn(func(_ string, playerStates []*persistence.PlayerState, _ int) (int, error) {
+			if len(playerStates) != 2 || playerStates[0].ID != "book" || playerStates[0].PlaybackItemURI != string(dummyTrackB.URI) || len(playerStates[0].Bookmarks) != 1 {
+				t.Fatalf("expected playback to be suspended into the slot of the timer, got %+v", playerStates)
+			}
+
+			return 2, nil
+		}),
+		daoMock.EXPECT().AppendHistoryEvent(dummyUserID, gomock.Any()).Times(1),
+		clientMock.EXPECT().Pause().Times(1),
+		daoMock.EXPECT().DeleteSleepTimer(dummyUserID).Times(1),
+	)
+
+	worker.RunOnce()
+
+	expectEvent(t, subscription, events.SlotUpdated, "book")
+}
+
+func TestTimerIsDroppedWhenNothingIsPlaying(t *testing.T) {
+	worker, _, ctrl, daoMock, clientMock, subscription := beforeEach(t)
+	defer ctrl.Finish()
+
+	daoMock.EXPECT().LoadSleepTimers().Times(1).Return([]*persistence.SleepTimer{{
+		UserID:    dummyUserID,
+		Mode:      persistence.SleepTimerAfterDuration,
+		FiresAtTs: dummyNow.Unix(),
+	}}, nil)
+
+	paused := playing(dummyTrackA, 42000)
+	paused.Playing = false
+
+	clientMock.EXPECT().PlayerState().Times(1).Return(paused, nil)
+	daoMock.EXPECT().DeleteSleepTimer(dummyUserID).Times(1)
+
+	worker.RunOnce()
+
+	if len(subscription) != 0 {
+		t.Fatalf("unexpected event: %+v", <-subscription)
+	}
+}
+
+func TestFailingTimerIsRetriedUntilOverdue(t *testing.T) {
+	worker, clock, ctrl, daoMock, clientMock, _ := beforeEach(t)
+	defer ctrl.Finish()
+
+	daoMock.EXPECT().LoadSleepTimers().Times(2).Return([]*persistence.SleepTimer{{
+		UserID:    dummyUserID,
+		Mode:      persistence.SleepTimerAfterDuration,
+		FiresAtTs: dummyNow.Unix(),
+	}}, nil)
+
+	clientMock.EXPECT().PlayerState().Times(2).Return(nil, errors.New("spotify is down"))
+
+	worker.RunOnce()
+
+	clock.Advance(constants.SleepTimerMaxDelay * time.Second)
+
+	daoMock.EXPECT().DeleteSleepTimer(dummyUserID).Times(1)
+
+	worker.RunOnce()
+}
+
+func TestNewTimerAtTrackEnd(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clientMock := mocks.NewMockSpotClient(ctrl)
+	clientMock.EXPECT().PlayerState().Times(1).Return(playing(dummyTrackA, 15500), nil)
+
+	timer, err := sleeptimer.New(clientMock, dummyUserID, persistence.SleepTimerAtTrackEnd, 0, "", dummyNow)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// 44.5 seconds are left, rounded up
+	if timer.TrackURI != string(dummyTrackA.URI) || timer.FiresAtTs != dummyNow.Unix()+45 || timer.CreatedAtTs != dummyNow.Unix() {
+		t.Fatalf("unexpected timer: %+v", timer)
+	}
+
+	paused := playing(dummyTrackA, 15500)
+	paused.Playing = false
+	clientMock.EXPECT().PlayerState().Times(1).Return(paused, nil)
+
+	_, err = sleeptimer.New(clientMock, dummyUserID, persistence.SleepTimerAtTrackEnd, 0, "", dummyNow)
+	if err != sleeptimer.ErrNothingPlaying {
+		t.Fatalf("expected ErrNothingPlaying, got %v", err)
+	}
+}
+
+// playing returns the state of a player playing the track without any context
+func playing(track *spotifyAPI.FullTrack, progress int) *spotifyAPI.PlayerState {
+	return &spotifyAPI.PlayerState{
+		CurrentlyPlaying: spotifyAPI.CurrentlyPlaying{
+			Progress: progress,
+			Playing:  true,
+			Item:     track,
+		},
+	}
+}
+
+func expectEvent(t *testing.T, subscription <-chan events.Event, eventType events.Type, slot string) {
+	t.Helper()
+
+	select {
+	case event := <-subscription:
+		if event.Type != eventType || event.Slot != slot {
+			t.Fatalf("expected %s event for slot '%s', got %+v", eventType, slot, event)
+		}
+	default:
+		t.Fatalf("expected %s event for slot '%s'", eventType, slot)
+	}
+}
+
+func beforeEach(t *testing.T) (*sleeptimer.Worker, *clocktest.Clock, *gomock.Controller, *mocks.MockPersistor, *mocks.MockSpotClient, <-chan events.Event) {
+	ctrl := gomock.NewController(t)
+
+	daoMock := mocks.NewMockPersistor(ctrl)
+	clientMock := mocks.NewMockSpotClient(ctrl)
+	daoMock.EXPECT().LoadToken(dummyUserID).AnyTimes().Return(dummyToken, nil)
+
+	bus := events.NewInProcessBus()
+	subscription, unsubscribe := bus.Subscribe(dummyUserID)
+	t.Cleanup(unsubscribe)
+
+	clock := clocktest.New(dummyNow)
+
+	worker := sleeptimer.NewWorker(daoMock, bus, func(userID string, token *oauth2.Token) spotify.SpotClient {
+		if userID != dummyUserID || token != dummyToken {
+			t.Fatalf("worker uses unexpected token %+v for user '%s'", token, userID)
+		}
+
+		return clientMock
+	}, clock, 0)
+
+	return worker, clock, ctrl, daoMock, clientMock, subscription
+}
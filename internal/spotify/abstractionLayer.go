@@ -15,6 +15,7 @@ type SpotAuthenticator interface {
 }
 
 type SpotClient interface {
+	CurrentlyPlayingEpisode() (*spotifyAPI.EpisodePage, error)
 	CurrentUser() (*spotifyAPI.PrivateUser, error)
 	GetAlbumTracksOpt(id spotifyAPI.ID, opt *spotifyAPI.Options) (*spotifyAPI.SimpleTrackPage, error)
 	GetPlaylistOpt(playlistID spotifyAPI.ID, fields string) (*spotifyAPI.FullPlaylist, error)
@@ -23,5 +24,9 @@ type SpotClient interface {
 	PlayerState() (*spotifyAPI.PlayerState, error)
 	PlayerDevices() ([]spotifyAPI.PlayerDevice, error)
 	PlayOpt(opt *spotifyAPI.PlayOptions) error
+	Repeat(state string) error
 	Shuffle(shuffle bool) error
+	Token() (*oauth2.Token, error)
+	TransferPlayback(deviceID spotifyAPI.ID, play bool) error
+	Volume(percent int) error
 }
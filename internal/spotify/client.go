@@ -0,0 +1,88 @@
+package spotify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	spotifyAPI "github.com/zmb3/spotify"
+)
+
+const (
+	baseURL = "https://api.spotify.com/v1/"
+)
+
+var (
+	ErrNoEpisodePlaying = errors.New("no podcast episode is currently playing")
+)
+
+// client complements the client provided by the Spotify library with the calls the library
+// does not support yet.
+type client struct {
+	spotifyAPI.Client
+}
+
+// NewSpotClient wraps the given client of the Spotify library so it satisfies SpotClient.
+func NewSpotClient(c spotifyAPI.Client) SpotClient {
+	return &client{c}
+}
+
+// CurrentlyPlayingEpisode returns the podcast episode currently being played.
+// Spotify only includes episodes in the player's state when explicitly asking for them
+// which the library does not support - without this we only get an empty item.
+func (c *client) CurrentlyPlayingEpisode() (*spotifyAPI.EpisodePage, error) {
+	// The token source of the underlying client takes care of refreshing the token if required
+	token, err := c.Token()
+	if err != nil {
+		return nil, fmt.Errorf("could not obtain token for requesting Spotify: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"me/player/currently-playing?additional_types=episode", nil)
+	if err != nil {
+		return nil, err
+	}
+	token.SetAuthHeader(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, ErrNoEpisodePlaying
+	}
+
+	err = errorOfResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			E spotifyAPI.Error `json:"error"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&e)
+		if err != nil || e.E.Message == "" {
+			return nil, fmt.Errorf("spotify: HTTP %d: %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+		}
+
+		return nil, e.E
+	}
+
+	var result struct {
+		CurrentlyPlayingType string                  `json:"currently_playing_type"`
+		Item                 *spotifyAPI.EpisodePage `json:"item"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.CurrentlyPlayingType != "episode" || result.Item == nil {
+		return nil, ErrNoEpisodePlaying
+	}
+
+	return result.Item, nil
+}
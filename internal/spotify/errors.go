@@ -0,0 +1,107 @@
+package spotify
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultRetryAfter is used in case Spotify does not tell when to try again
+	defaultRetryAfter = 10 * time.Second
+)
+
+// RateLimitedError is returned when Spotify rejects requests because too many have been sent
+type RateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("spotify: rate limit exceeded, retry after %s", e.RetryAfter)
+}
+
+// BudgetExceededError is returned when the user has used up her/his budget of requests, they are not sent to
+// Spotify at all then
+type BudgetExceededError struct {
+	RetryAfter time.Duration
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("request budget of user exceeded, retry after %s", e.RetryAfter)
+}
+
+// UnavailableError is returned when Spotify fails with a server error, this is expected to be temporary
+type UnavailableError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("spotify: HTTP %d: %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// RetryAfter checks whether the error is only temporary, if so it returns when to try again
+func RetryAfter(err error) (time.Duration, bool) {
+	var rateLimited *RateLimitedError
+	if errors.As(err, &rateLimited) {
+		return rateLimited.RetryAfter, true
+	}
+
+	var unavailable *UnavailableError
+	if errors.As(err, &unavailable) {
+		return unavailable.RetryAfter, true
+	}
+
+	var budgetExceeded *BudgetExceededError
+	if errors.As(err, &budgetExceeded) {
+		return budgetExceeded.RetryAfter, true
+	}
+
+	return 0, false
+}
+
+// errorOfResponse returns a typed error in case the response indicates a temporary failure, nil otherwise
+func errorOfResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return &RateLimitedError{retryAfterOf(resp)}
+	}
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return &UnavailableError{resp.StatusCode, retryAfterOf(resp)}
+	}
+
+	return nil
+}
+
+// retryAfterOf reads the Retry-After header, Spotify only uses the variant giving the delay in seconds
+func retryAfterOf(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return defaultRetryAfter
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// errorMappingTransport turns responses indicating a temporary failure into typed errors. The Spotify library
+// does not expose the Retry-After header, so this has to be done before the response reaches it.
+type errorMappingTransport struct {
+	base http.RoundTripper
+}
+
+func (e *errorMappingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := e.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = errorOfResponse(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,260 @@
+package spotify
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	spotifyAPI "github.com/zmb3/spotify"
+	"golang.org/x/oauth2"
+)
+
+const (
+	maxAttempts = 3
+	// initialBackoff gets doubled with every attempt, it is used in case Spotify fails with a server error
+	initialBackoff = 250 * time.Millisecond
+	// maxRetryWait limits how long a request is held back, when Spotify asks for waiting longer the
+	// error is passed on instead - the user should not wait for a response that long
+	maxRetryWait = 5 * time.Second
+)
+
+// retryingClient decorates a SpotClient, retrying requests failing temporarily and ensuring
+// the user does not exceed her/his budget of requests
+type retryingClient struct {
+	inner   SpotClient
+	userID  string
+	budgets *RequestBudgets
+	sleep   func(d time.Duration)
+}
+
+// NewRetryingSpotClient wraps the given client, requests get retried in case Spotify is rate limiting or fails with
+// a server error. Requests modifying the player are only retried when Spotify is rate limiting, after a server error
+// it is unknown whether they have been carried out. Failures persisting are returned as RateLimitedError resp.
+// UnavailableError. The requests are accounted to the given user, userID may be empty as long as the user is not
+// known yet. Requests exceeding the user's budget fail with BudgetExceededError.
+func NewRetryingSpotClient(inner SpotClient, userID string, budgets *RequestBudgets) SpotClient {
+	return &retryingClient{
+		inner:   inner,
+		userID:  userID,
+		budgets: budgets,
+		sleep:   time.Sleep,
+	}
+}
+
+// read sends a request not modifying anything, so it can be retried after any temporary failure
+func (r *retryingClient) read(request func() error) error {
+	return r.do(request, true)
+}
+
+// write sends a request modifying the player, it is only retried when Spotify is rate limiting
+func (r *retryingClient) write(request func() error) error {
+	return r.do(request, false)
+}
+
+func (r *retryingClient) do(request func() error, retryServerErrors bool) error {
+	backoff := initialBackoff
+
+	for attempt := 1; ; attempt++ {
+		if r.userID != "" && r.budgets != nil {
+			if wait, ok := r.budgets.take(r.userID); !ok {
+				return &BudgetExceededError{wait}
+			}
+		}
+
+		err := request()
+
+		retryAfter, temporary := RetryAfter(err)
+		if !temporary || attempt == maxAttempts {
+			return err
+		}
+
+		var rateLimited *RateLimitedError
+		if !errors.As(err, &rateLimited) {
+			if !retryServerErrors {
+				return err
+			}
+
+			retryAfter = backoff
+			backoff *= 2
+		}
+
+		if retryAfter > maxRetryWait {
+			return err
+		}
+
+		r.sleep(retryAfter)
+	}
+}
+
+func (r *retryingClient) CurrentlyPlayingEpisode() (*spotifyAPI.EpisodePage, error) {
+	var episode *spotifyAPI.EpisodePage
+	err := r.read(func() (err error) {
+		episode, err = r.inner.CurrentlyPlayingEpisode()
+		return err
+	})
+
+	return episode, err
+}
+
+func (r *retryingClient) CurrentUser() (*spotifyAPI.PrivateUser, error) {
+	var user *spotifyAPI.PrivateUser
+	err := r.read(func() (err error) {
+		user, err = r.inner.CurrentUser()
+		return err
+	})
+
+	return user, err
+}
+
+func (r *retryingClient) GetAlbumTracksOpt(id spotifyAPI.ID, opt *spotifyAPI.Options) (*spotifyAPI.SimpleTrackPage, error) {
+	var page *spotifyAPI.SimpleTrackPage
+	err := r.read(func() (err error) {
+		page, err = r.inner.GetAlbumTracksOpt(id, opt)
+		return err
+	})
+
+	return page, err
+}
+
+func (r *retryingClient) GetPlaylistOpt(playlistID spotifyAPI.ID, fields string) (*spotifyAPI.FullPlaylist, error) {
+	var playlist *spotifyAPI.FullPlaylist
+	err := r.read(func() (err error) {
+		playlist, err = r.inner.GetPlaylistOpt(playlistID, fields)
+		return err
+	})
+
+	return playlist, err
+}
+
+func (r *retryingClient) GetPlaylistTracksOpt(playlistID spotifyAPI.ID, opt *spotifyAPI.Options, fields string) (*spotifyAPI.PlaylistTrackPage, error) {
+	var page *spotifyAPI.PlaylistTrackPage
+	err := r.read(func() (err error) {
+		page, err = r.inner.GetPlaylistTracksOpt(playlistID, opt, fields)
+		return err
+	})
+
+	return page, err
+}
+
+func (r *retryingClient) Pause() error {
+	return r.write(r.inner.Pause)
+}
+
+func (r *retryingClient) PlayerState() (*spotifyAPI.PlayerState, error) {
+	var playerState *spotifyAPI.PlayerState
+	err := r.read(func() (err error) {
+		playerState, err = r.inner.PlayerState()
+		return err
+	})
+
+	return playerState, err
+}
+
+func (r *retryingClient) PlayerDevices() ([]spotifyAPI.PlayerDevice, error) {
+	var devices []spotifyAPI.PlayerDevice
+	err := r.read(func() (err error) {
+		devices, err = r.inner.PlayerDevices()
+		return err
+	})
+
+	return devices, err
+}
+
+func (r *retryingClient) PlayOpt(opt *spotifyAPI.PlayOptions) error {
+	return r.write(func() error {
+		return r.inner.PlayOpt(opt)
+	})
+}
+
+func (r *retryingClient) Repeat(state string) error {
+	return r.write(func() error {
+		return r.inner.Repeat(state)
+	})
+}
+
+func (r *retryingClient) Shuffle(shuffle bool) error {
+	return r.write(func() error {
+		return r.inner.Shuffle(shuffle)
+	})
+}
+
+// Token does not access the API, so it is neither retried nor accounted
+func (r *retryingClient) Token() (*oauth2.Token, error) {
+	return r.inner.Token()
+}
+
+func (r *retryingClient) TransferPlayback(deviceID spotifyAPI.ID, play bool) error {
+	return r.write(func() error {
+		return r.inner.TransferPlayback(deviceID, play)
+	})
+}
+
+func (r *retryingClient) Volume(percent int) error {
+	return r.write(func() error {
+		return r.inner.Volume(percent)
+	})
+}
+
+// RequestBudgets limits the number of requests sent to Spotify per user. The rate limit applies to the whole
+// app, so a single user must not be able to use it up. Every user has a bucket holding up to a minute's worth of
+// requests, it gets refilled continuously.
+type RequestBudgets struct {
+	perMinute int
+	now       func() time.Time
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// NewRequestBudgets allows every user to send the given number of requests per minute
+func NewRequestBudgets(perMinute int) *RequestBudgets {
+	return &RequestBudgets{
+		perMinute: perMinute,
+		now:       time.Now,
+		buckets:   make(map[string]*bucket),
+	}
+}
+
+// take accounts a request to the user, in case the budget is used up it returns how long to wait
+func (b *RequestBudgets) take(userID string) (time.Duration, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.now()
+	capacity := float64(b.perMinute)
+	perSecond := capacity / 60
+
+	userBucket, ok := b.buckets[userID]
+	if !ok {
+		b.forgetFullBuckets(now)
+
+		userBucket = &bucket{capacity, now}
+		b.buckets[userID] = userBucket
+	}
+
+	userBucket.tokens = math.Min(capacity, userBucket.tokens+now.Sub(userBucket.updatedAt).Seconds()*perSecond)
+	userBucket.updatedAt = now
+
+	if userBucket.tokens < 1 {
+		return time.Duration((1 - userBucket.tokens) / perSecond * float64(time.Second)), false
+	}
+
+	userBucket.tokens--
+
+	return 0, true
+}
+
+// forgetFullBuckets removes the buckets of users not having sent requests recently, they are
+// equivalent to new ones. Has to be called while holding the mutex.
+func (b *RequestBudgets) forgetFullBuckets(now time.Time) {
+	for userID, userBucket := range b.buckets {
+		if now.Sub(userBucket.updatedAt) >= time.Minute {
+			delete(b.buckets, userID)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package spotify
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/florianloch/cassette/internal/e2e_test/mocks"
+)
+
+func retryingClientForTest(t *testing.T, budgets *RequestBudgets) (*retryingClient, *mocks.MockSpotClient, *[]time.Duration, func()) {
+	ctrl := gomock.NewController(t)
+	inner := mocks.NewMockSpotClient(ctrl)
+
+	var slept []time.Duration
+	client := NewRetryingSpotClient(inner, "user", budgets).(*retryingClient)
+	client.sleep = func(d time.Duration) {
+		slept = append(slept, d)
+	}
+
+	return client, inner, &slept, ctrl.Finish
+}
+
+func TestServerErrorsGetRetriedWithBackoff(t *testing.T) {
+	client, inner, slept, finish := retryingClientForTest(t, nil)
+	defer finish()
+
+	unavailable := &UnavailableError{StatusCode: http.StatusBadGateway, RetryAfter: defaultRetryAfter}
+	gomock.InOrder(
+		inner.EXPECT().PlayerDevices().Times(2).Return(nil, unavailable),
+		inner.EXPECT().PlayerDevices().Times(1).Return(nil, nil),
+	)
+
+	_, err := client.PlayerDevices()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !reflect.DeepEqual(*slept, []time.Duration{initialBackoff, 2 * initialBackoff}) {
+		t.Fatalf("unexpected backoff: %v", *slept)
+	}
+}
+
+func TestWritesAreNotRetriedAfterServerErrors(t *testing.T) {
+	client, inner, slept, finish := retryingClientForTest(t, nil)
+	defer finish()
+
+	// The volume might have been set nevertheless
+	unavailable := &UnavailableError{StatusCode: http.StatusBadGateway, RetryAfter: defaultRetryAfter}
+	inner.EXPECT().Volume(42).Times(1).Return(unavailable)
+
+	if err := client.Volume(42); err != unavailable {
+		t.Fatalf("expected error to be passed on, got %v", err)
+	}
+
+	if len(*slept) != 0 {
+		t.Fatalf("did not expect to wait, waited %v", *slept)
+	}
+}
+
+func TestRetryAfterGetsHonoured(t *testing.T) {
+	client, inner, slept, finish := retryingClientForTest(t, nil)
+	defer finish()
+
+	gomock.InOrder(
+		inner.EXPECT().Shuffle(true).Times(1).Return(&RateLimitedError{RetryAfter: 2 * time.Second}),
+		inner.EXPECT().Shuffle(true).Times(1).Return(nil),
+	)
+
+	err := client.Shuffle(true)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !reflect.DeepEqual(*slept, []time.Duration{2 * time.Second}) {
+		t.Fatalf("expected to wait as told by Spotify, waited %v", *slept)
+	}
+}
+
+func TestLongRetryAfterIsPassedOn(t *testing.T) {
+	client, inner, slept, finish := retryingClientForTest(t, nil)
+	defer finish()
+
+	inner.EXPECT().PlayerDevices().Times(1).Return(nil, &RateLimitedError{RetryAfter: time.Minute})
+
+	_, err := client.PlayerDevices()
+
+	if retryAfter, temporary := RetryAfter(err); !temporary || retryAfter != time.Minute {
+		t.Fatalf("expected rate limited error, got %v", err)
+	}
+
+	if len(*slept) != 0 {
+		t.Fatalf("did not expect to wait, waited %v", *slept)
+	}
+}
+
+func TestPermanentErrorsAreNotRetried(t *testing.T) {
+	client, inner, _, finish := retryingClientForTest(t, nil)
+	defer finish()
+
+	permanent := errors.New("no active device")
+	inner.EXPECT().PlayOpt(gomock.Any()).Times(1).Return(permanent)
+
+	if err := client.PlayOpt(nil); err != permanent {
+		t.Fatalf("expected error to be passed on, got %v", err)
+	}
+}
+
+func TestRequestBudgetOfUser(t *testing.T) {
+	now := time.Unix(1615000000, 0)
+	budgets := NewRequestBudgets(2)
+	budgets.now = func() time.Time { return now }
+
+	client, inner, _, finish := retryingClientForTest(t, budgets)
+	defer finish()
+
+	inner.EXPECT().Pause().Times(3).Return(nil)
+
+	for i := 0; i < 2; i++ {
+		if err := client.Pause(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	err := client.Pause()
+	var budgetExceeded *BudgetExceededError
+	if !errors.As(err, &budgetExceeded) || budgetExceeded.RetryAfter != 30*time.Second {
+		t.Fatalf("expected budget to be used up, got %v", err)
+	}
+
+	if retryAfter, temporary := RetryAfter(err); !temporary || retryAfter != 30*time.Second {
+		t.Fatalf("expected exceeding the budget to be temporary, got %v", err)
+	}
+
+	// Other users have their own budget
+	if _, ok := budgets.take("another user"); !ok {
+		t.Fatal("expected budget of another user to be available")
+	}
+
+	now = now.Add(30 * time.Second)
+	if err := client.Pause(); err != nil {
+		t.Fatalf("expected budget to be refilled, got %s", err)
+	}
+}
+
+func TestTemporaryFailuresGetMappedToTypedErrors(t *testing.T) {
+	status := http.StatusTooManyRequests
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	httpClient := &http.Client{Transport: &errorMappingTransport{http.DefaultTransport}}
+
+	_, err := httpClient.Get(server.URL)
+	var rateLimited *RateLimitedError
+	if !errors.As(err, &rateLimited) || rateLimited.RetryAfter != 7*time.Second {
+		t.Fatalf("expected rate limited error, got %v", err)
+	}
+
+	status = http.StatusServiceUnavailable
+	_, err = httpClient.Get(server.URL)
+	var unavailable *UnavailableError
+	if !errors.As(err, &unavailable) || unavailable.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected unavailable error, got %v", err)
+	}
+
+	status = http.StatusNotFound
+	resp, err := httpClient.Get(server.URL)
+	if err != nil {
+		t.Fatalf("did not expect an error for permanent failures, got %v", err)
+	}
+	resp.Body.Close()
+}
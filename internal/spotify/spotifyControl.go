@@ -3,13 +3,13 @@ package spotify
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	spotifyAPI "github.com/zmb3/spotify"
 
-	"github.com/florianloch/cassette/internal/constants"
 	"github.com/florianloch/cassette/internal/persistence"
 )
 
@@ -20,117 +20,239 @@ const (
 var (
 	ErrTrackNotFoundInContext    = errors.New("could not find track in context")
 	ErrNoActiveDeviceForPlayback = errors.New("no (active) device available for playback")
-	ErrContextNotSuspendable     = errors.New("the current context cannot be restored! It is only possible to store playing positions in albums and playlists")
+	ErrContextNotSuspendable     = errors.New("the current context cannot be restored! It is only possible to store playing positions in albums, playlists, podcasts and tracks played without context")
 )
 
 func isContextSuspendable(playbackContext spotifyAPI.PlaybackContext) bool {
 	t := playbackContext.Type
 
-	return t == "album" || t == "playlist"
+	return t == "album" || t == "playlist" || t == "show"
 }
 
 func CurrentPlayerState(client SpotClient) (*persistence.PlayerState, error) {
 	playerState, err := client.PlayerState()
-	var shuffleActivated bool
 	if err != nil {
 		return nil, fmt.Errorf("could not read whats currently playing: %w", err)
 	}
-	shuffleActivated = playerState.ShuffleState
 
-	currentlyPlaying := &playerState.CurrentlyPlaying
+	return PlayerStateOf(client, playerState)
+}
 
-	//Check whether this position could possibly restored afterwards
-	if !isContextSuspendable(currentlyPlaying.PlaybackContext) {
-		return nil, ErrContextNotSuspendable
+// PlayerStateOf converts the player's state as reported by Spotify to a suspendable one, the client is
+// used to fetch the information missing in the player's state.
+func PlayerStateOf(client SpotClient, playerState *spotifyAPI.PlayerState) (*persistence.PlayerState, error) {
+	state, err := positionOf(client, playerState)
+	if err != nil {
+		return nil, err
 	}
 
-	item := currentlyPlaying.Item
-	joinedArtists := ""
-	for idx, artist := range item.Artists {
-		joinedArtists += artist.Name
-		if idx < len(item.Artists)-1 {
-			joinedArtists += ", "
-		}
-	}
+	// Settings of the player are kept regardless of what is playing
+	state.RepeatState = playerState.RepeatState
+	state.Volume = playerState.Device.Volume
+	state.DeviceName = playerState.Device.Name
 
-	// Ensure there are two URLs in the 'Images' slice
-	images := item.Album.Images
-	if len(images) == 0 {
-		// Kind of an assert, should not happen. In case it does it's not too important though
-		log.Error().Interface("item", item).Msg("No image URL provided for currently playing item.")
+	return state, nil
+}
 
-		item.Album.Images = append(images, spotifyAPI.Image{
-			URL: "",
-		})
+// positionOf tells what is playing and the position within it
+func positionOf(client SpotClient, playerState *spotifyAPI.PlayerState) (*persistence.PlayerState, error) {
+	shuffleActivated := playerState.ShuffleState
+
+	currentlyPlaying := &playerState.CurrentlyPlaying
+
+	// Spotify does not provide an item in case a podcast episode is playing, when playing a show the item does not
+	// describe the episode properly either
+	if currentlyPlaying.Item == nil || currentlyPlaying.PlaybackContext.Type == "show" {
+		episode, err := client.CurrentlyPlayingEpisode()
+		if err != nil {
+			return nil, fmt.Errorf("could not read which episode is currently playing: %w", err)
+		}
+
+		return episodePlayerState(currentlyPlaying, episode, shuffleActivated), nil
 	}
-	if len(images) == 1 {
-		log.Error().Interface("item", item).Msg("Just one URL provided for currently playing item.")
 
-		item.Album.Images = append(images, images[0])
+	// Tracks played without any context, e.g. from the search results, get suspended on their own
+	if currentlyPlaying.PlaybackContext.URI == "" {
+		return singleTrackPlayerState(currentlyPlaying, shuffleActivated), nil
 	}
 
-	trackIndex, totalTracks, err := indexOfCurrentTrack(currentlyPlaying, client)
-	if err != nil {
-		// No need to stop processing this request because of this error...
-		log.Error().Err(err).Interface("item", item).Msg("Could not get index of track in context.")
+	//Check whether this position could possibly restored afterwards
+	if !isContextSuspendable(currentlyPlaying.PlaybackContext) {
+		return nil, ErrContextNotSuspendable
 	}
 
+	item := currentlyPlaying.Item
+	joinedArtists := joinArtistNames(item.Artists)
+
+	images := ensureTwoImages(item.Album.Images, item)
+
 	linkToContext, ok := currentlyPlaying.PlaybackContext.ExternalURLs["spotify"]
 	if !ok {
 		// No need to stop processing this request because of this error...
 		log.Error().
-			Err(err).
 			Interface("playbackContext", currentlyPlaying.PlaybackContext).
 			Msg("Could not get link to context from response.")
 	}
 
 	playlistName := ""
+	snapshotID := ""
 	if currentlyPlaying.PlaybackContext.Type == "playlist" {
 		playlistID := idOfContext(currentlyPlaying)
-		playlist, err := client.GetPlaylistOpt(playlistID, "name")
+		playlist, err := client.GetPlaylistOpt(playlistID, "name,snapshot_id")
 		if err != nil {
 			// No need to stop processing this request because of this error...
 			log.Error().Err(err).Str("playlistID", string(playlistID)).Msg("Could not get name of playlist.")
 		} else {
 			playlistName = playlist.Name
+			snapshotID = playlist.SnapshotID
 		}
 	}
 
+	position, err := indexOfCurrentTrack(currentlyPlaying, snapshotID, client)
+	if err != nil {
+		// No need to stop processing this request because of this error...
+		log.Error().Err(err).Interface("item", item).Msg("Could not get index of track in context.")
+
+		position = &positionInContext{index: -1, total: -1}
+	}
+
 	return &persistence.PlayerState{
 		PlaybackContextURI: string(currentlyPlaying.PlaybackContext.URI),
 		PlaybackItemURI:    string(item.URI),
 		LinkToContext:      linkToContext,
 		ContextType:        currentlyPlaying.PlaybackContext.Type,
 		PlaylistName:       playlistName,
-		AlbumArtLargeURL:   item.Album.Images[0].URL,
-		AlbumArtMediumURL:  item.Album.Images[1].URL,
+		AlbumArtLargeURL:   images[0].URL,
+		AlbumArtMediumURL:  images[1].URL,
 		TrackName:          item.Name,
 		AlbumName:          item.Album.Name,
 		ArtistName:         joinedArtists,
-		TrackIndex:         trackIndex,
-		TotalTracks:        totalTracks,
+		TrackIndex:         position.index,
+		TotalTracks:        position.total,
 		Progress:           currentlyPlaying.Progress,
 		Duration:           item.Duration,
+		ContextDuration:    position.duration,
+		ContextElapsed:     position.elapsed,
 		ShuffleActivated:   shuffleActivated,
 		SuspendedAtTs:      time.Now().Unix(),
 	}, nil
 }
 
-func RestorePlayerState(client SpotClient, stateToLoad *persistence.PlayerState, deviceID string) error {
-	err := client.Shuffle(stateToLoad.ShuffleActivated)
-	if err != nil {
-		return err
+// singleTrackPlayerState describes the position within a track played without any context.
+// As there is nothing to continue with afterwards, only this track will be played when restoring.
+func singleTrackPlayerState(currentlyPlaying *spotifyAPI.CurrentlyPlaying, shuffleActivated bool) *persistence.PlayerState {
+	item := currentlyPlaying.Item
+
+	images := ensureTwoImages(item.Album.Images, item)
+
+	linkToTrack, ok := item.ExternalURLs["spotify"]
+	if !ok {
+		// No need to stop processing this request because of this error...
+		log.Error().Interface("item", item).Msg("Could not get link to track from response.")
+	}
+
+	return &persistence.PlayerState{
+		PlaybackItemURI:   string(item.URI),
+		LinkToContext:     linkToTrack,
+		ContextType:       "track",
+		AlbumArtLargeURL:  images[0].URL,
+		AlbumArtMediumURL: images[1].URL,
+		TrackName:         item.Name,
+		AlbumName:         item.Album.Name,
+		ArtistName:        joinArtistNames(item.Artists),
+		TrackIndex:        1,
+		TotalTracks:       1,
+		Progress:          currentlyPlaying.Progress,
+		Duration:          item.Duration,
+		ShuffleActivated:  shuffleActivated,
+		SuspendedAtTs:     time.Now().Unix(),
 	}
+}
 
-	stateToLoad.Progress -= min(stateToLoad.Progress, constants.JumpBackNSeconds*1e3)
+// episodePlayerState describes the position within a podcast episode. The show the episode belongs to
+// is always used as context, regardless of where the episode got started from.
+func episodePlayerState(currentlyPlaying *spotifyAPI.CurrentlyPlaying, episode *spotifyAPI.EpisodePage, shuffleActivated bool) *persistence.PlayerState {
+	show := &episode.Show
 
-	contextURI := spotifyAPI.URI(stateToLoad.PlaybackContextURI)
-	itemURI := spotifyAPI.URI(stateToLoad.PlaybackItemURI)
-	spotifyPlayOptions := &spotifyAPI.PlayOptions{
-		PlaybackContext: &contextURI,
-		PlaybackOffset:  &spotifyAPI.PlaybackOffset{URI: itemURI},
-		PositionMs:      stateToLoad.Progress,
+	imageSource := episode.Images
+	if len(imageSource) == 0 {
+		imageSource = show.Images
 	}
+	images := ensureTwoImages(imageSource, episode)
+
+	linkToContext, ok := show.ExternalURLs["spotify"]
+	if !ok {
+		// No need to stop processing this request because of this error...
+		log.Error().Interface("show", show).Msg("Could not get link to show from response.")
+	}
+
+	return &persistence.PlayerState{
+		PlaybackContextURI: string(show.URI),
+		PlaybackItemURI:    string(episode.URI),
+		LinkToContext:      linkToContext,
+		ContextType:        "show",
+		ShowName:           show.Name,
+		AlbumArtLargeURL:   images[0].URL,
+		AlbumArtMediumURL:  images[1].URL,
+		TrackName:          episode.Name,
+		ArtistName:         show.Publisher,
+		Progress:           currentlyPlaying.Progress,
+		Duration:           episode.Duration_ms,
+		ShuffleActivated:   shuffleActivated,
+		SuspendedAtTs:      time.Now().Unix(),
+	}
+}
+
+func joinArtistNames(artists []spotifyAPI.SimpleArtist) string {
+	joinedArtists := ""
+	for idx, artist := range artists {
+		joinedArtists += artist.Name
+		if idx < len(artists)-1 {
+			joinedArtists += ", "
+		}
+	}
+
+	return joinedArtists
+}
+
+// ensureTwoImages ensures there are (at least) two URLs in the returned slice
+func ensureTwoImages(images []spotifyAPI.Image, item interface{}) []spotifyAPI.Image {
+	if len(images) == 0 {
+		// Kind of an assert, should not happen. In case it does it's not too important though
+		log.Error().Interface("item", item).Msg("No image URL provided for currently playing item.")
+
+		images = append(images, spotifyAPI.Image{
+			URL: "",
+		})
+	}
+	if len(images) == 1 {
+		log.Error().Interface("item", item).Msg("Just one URL provided for currently playing item.")
+
+		images = append(images, images[0])
+	}
+
+	return images
+}
+
+// RestorePlayerState continues playback at the position stored in the slot, resp. at the position of the
+// given bookmark in case it is not nil. Playback starts the given duration before that position, the slot itself
+// is not modified.
+func RestorePlayerState(client SpotClient, playerState *persistence.PlayerState, bookmark *persistence.Bookmark, deviceID string, jumpBack time.Duration) error {
+	err := client.Shuffle(playerState.ShuffleActivated)
+	if err != nil {
+		return noActiveDeviceOr(err)
+	}
+
+	// Only the position gets replaced, the slot is kept as it is for the callers
+	stateToLoad := *playerState
+	if bookmark != nil {
+		stateToLoad.PlaybackItemURI = bookmark.TrackURI
+		stateToLoad.Progress = bookmark.Progress
+	}
+
+	stateToLoad.Progress -= min(stateToLoad.Progress, int(jumpBack.Milliseconds()))
+
+	spotifyPlayOptions := playOptionsFor(&stateToLoad)
 
 	var id spotifyAPI.ID
 	if deviceID == "" {
@@ -147,12 +269,95 @@ func RestorePlayerState(client SpotClient, stateToLoad *persistence.PlayerState,
 
 	err = client.PlayOpt(spotifyPlayOptions)
 	if err != nil {
-		return err
+		return noActiveDeviceOr(err)
 	}
 
+	restorePlayerSettings(client, &stateToLoad)
+
 	return nil
 }
 
+// restorePlayerSettings restores repeat and volume once the playback has started, this way they apply to the
+// device playing. Failing to do so does not fail restoring, e.g., some devices do not allow controlling the volume.
+// Slots saved before these settings have been captured leave the player as it is.
+func restorePlayerSettings(client SpotClient, stateToLoad *persistence.PlayerState) {
+	if stateToLoad.RepeatState != "" {
+		err := client.Repeat(stateToLoad.RepeatState)
+		if err != nil {
+			log.Warn().Err(err).Str("repeatState", stateToLoad.RepeatState).Msg("Could not restore repeat state.")
+		}
+	}
+
+	// Zero is reported as well when the device does not tell its volume, restoring a muted player is pointless anyway
+	if stateToLoad.Volume > 0 {
+		err := client.Volume(stateToLoad.Volume)
+		if err != nil {
+			log.Warn().Err(err).Int("volume", stateToLoad.Volume).Msg("Could not restore volume.")
+		}
+	}
+}
+
+// TransferToOriginalDevice transfers the playback to the device the slot has been suspended on without starting it,
+// so settings restored afterwards apply to it. The device is looked up by its name as Spotify might assign new IDs to
+// devices. Returns an empty ID in case the device is not available.
+func TransferToOriginalDevice(client SpotClient, stateToLoad *persistence.PlayerState) (string, error) {
+	if stateToLoad.DeviceName == "" {
+		return "", nil
+	}
+
+	devices, err := client.PlayerDevices()
+	if err != nil {
+		return "", err
+	}
+
+	for _, device := range devices {
+		if device.Name != stateToLoad.DeviceName || device.Restricted {
+			continue
+		}
+
+		if !device.Active {
+			err = client.TransferPlayback(device.ID, false)
+			if err != nil {
+				return "", noActiveDeviceOr(err)
+			}
+		}
+
+		return string(device.ID), nil
+	}
+
+	return "", nil
+}
+
+// noActiveDeviceOr maps the error Spotify responds with when there is no device to control to ErrNoActiveDeviceForPlayback
+func noActiveDeviceOr(err error) error {
+	var spotifyErr spotifyAPI.Error
+	if errors.As(err, &spotifyErr) && spotifyErr.Status == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrNoActiveDeviceForPlayback, spotifyErr.Message)
+	}
+
+	return err
+}
+
+func playOptionsFor(stateToLoad *persistence.PlayerState) *spotifyAPI.PlayOptions {
+	itemURI := spotifyAPI.URI(stateToLoad.PlaybackItemURI)
+
+	// Spotify only supports an offset within albums and playlists, episodes and tracks
+	// without context have to be played directly
+	if stateToLoad.ContextType == "show" || stateToLoad.ContextType == "track" {
+		return &spotifyAPI.PlayOptions{
+			URIs:       []spotifyAPI.URI{itemURI},
+			PositionMs: stateToLoad.Progress,
+		}
+	}
+
+	contextURI := spotifyAPI.URI(stateToLoad.PlaybackContextURI)
+	return &spotifyAPI.PlayOptions{
+		PlaybackContext: &contextURI,
+		PlaybackOffset:  &spotifyAPI.PlaybackOffset{URI: itemURI},
+		PositionMs:      stateToLoad.Progress,
+	}
+}
+
 func currentDeviceForPlayback(client SpotClient) (spotifyAPI.ID, error) {
 	devices, err := client.PlayerDevices()
 
@@ -173,7 +378,18 @@ func currentDeviceForPlayback(client SpotClient) (spotifyAPI.ID, error) {
 	return devices[0].ID, nil
 }
 
-func indexOfCurrentTrack(currentlyPlaying *spotifyAPI.CurrentlyPlaying, client SpotClient) (int, int, error) {
+// positionInContext describes where the current track is located within its album resp. playlist
+type positionInContext struct {
+	index    int // one-based
+	total    int
+	elapsed  int // sum of the durations of all tracks before the current one
+	duration int // sum of the durations of all tracks
+}
+
+// indexOfCurrentTrack uses the durations of all tracks in the context, so it does not stop paging once the current
+// track has been found. The listing gets cached, playlists are only taken from the cache in case the given snapshot
+// ID is known.
+func indexOfCurrentTrack(currentlyPlaying *spotifyAPI.CurrentlyPlaying, snapshotID string, client SpotClient) (*positionInContext, error) {
 	typ := currentlyPlaying.PlaybackContext.Type
 
 	// Has to be "album" or "playlist" - this should be ensured upstream.
@@ -184,7 +400,48 @@ func indexOfCurrentTrack(currentlyPlaying *spotifyAPI.CurrentlyPlaying, client S
 		log.Panic().Str("type", typ).Msg("called with context neither being 'album' nor 'playlist'")
 	}
 
-	trackID := currentlyPlaying.Item.ID
+	cacheable := isAlbum || snapshotID != ""
+	key := trackListingKey(string(currentlyPlaying.PlaybackContext.URI), snapshotID)
+
+	var listing *persistence.TrackListing
+	if cacheable {
+		listing = trackListings.Get(key)
+	}
+
+	if listing == nil {
+		var err error
+		listing, err = fetchTrackListing(currentlyPlaying, isAlbum, client)
+		if err != nil {
+			return nil, err
+		}
+
+		if cacheable {
+			trackListings.Put(key, listing)
+		}
+	}
+
+	trackID := string(currentlyPlaying.Item.ID)
+	position := &positionInContext{index: -1, total: len(listing.TrackIDs)}
+
+	for i, duration := range listing.Durations {
+		// Only the first occurrence of a track counts
+		if position.index < 0 && listing.TrackIDs[i] == trackID {
+			position.index = i + 1 // because the user probably does not expect zero-based counting
+			position.elapsed = position.duration
+		}
+
+		position.duration += duration
+	}
+
+	if position.index < 0 {
+		return nil, ErrTrackNotFoundInContext
+	}
+
+	return position, nil
+}
+
+// fetchTrackListing pages through the whole album resp. playlist
+func fetchTrackListing(currentlyPlaying *spotifyAPI.CurrentlyPlaying, isAlbum bool, client SpotClient) (*persistence.TrackListing, error) {
 	contextID := idOfContext(currentlyPlaying)
 
 	offset := 0
@@ -193,36 +450,42 @@ func indexOfCurrentTrack(currentlyPlaying *spotifyAPI.CurrentlyPlaying, client S
 		Limit:  &limit,
 		Offset: &offset,
 	}
-	var index int
-	var total int
+	listing := &persistence.TrackListing{
+		TrackIDs:   make([]string, 0),
+		Durations:  make([]int, 0),
+		CachedAtTs: time.Now().Unix(),
+	}
 
 	for {
+		var total int
+
 		if isAlbum {
 			page, err := client.GetAlbumTracksOpt(contextID, &options)
 			if err != nil {
-				return -1, -1, err
+				return nil, err
 			}
 
-			index = findTrackInSimpleTrackPages(trackID, page)
+			for _, track := range page.Tracks {
+				listing.TrackIDs = append(listing.TrackIDs, string(track.ID))
+				listing.Durations = append(listing.Durations, track.Duration)
+			}
 			total = page.Total
 		} else {
-			page, err := client.GetPlaylistTracksOpt(contextID, &options, "total,limit,items(track(id))")
+			page, err := client.GetPlaylistTracksOpt(contextID, &options, "total,limit,items(track(id,duration_ms))")
 			if err != nil {
-				return -1, -1, err
+				return nil, err
 			}
 
-			index = findTrackInPlaylistTrackPage(trackID, page)
+			for _, track := range page.Tracks {
+				listing.TrackIDs = append(listing.TrackIDs, string(track.Track.ID))
+				listing.Durations = append(listing.Durations, track.Track.Duration)
+			}
 			total = page.Total
 		}
 
-		if index >= 0 {
-			index += offset + 1 // because the user probably does not expect zero-based counting
-			return index, total, nil
-		}
-
 		offset += limit
 		if offset >= total {
-			return -1, -1, ErrTrackNotFoundInContext
+			return listing, nil
 		}
 	}
 }
@@ -233,26 +496,6 @@ func idOfContext(currentlyPlaying *spotifyAPI.CurrentlyPlaying) spotifyAPI.ID {
 	return spotifyAPI.ID(splits[len(splits)-1])
 }
 
-func findTrackInSimpleTrackPages(trackID spotifyAPI.ID, page *spotifyAPI.SimpleTrackPage) int {
-	for i, track := range page.Tracks {
-		if track.ID == trackID {
-			return i
-		}
-	}
-
-	return -1
-}
-
-func findTrackInPlaylistTrackPage(trackID spotifyAPI.ID, page *spotifyAPI.PlaylistTrackPage) int {
-	for i, track := range page.Tracks {
-		if track.Track.ID == trackID {
-			return i
-		}
-	}
-
-	return -1
-}
-
 type CondensedPlayerDevice struct {
 	ID     string `json:"id"`
 	Name   string `json:"name"`
@@ -0,0 +1,79 @@
+package spotify
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	spotifyAPI "github.com/zmb3/spotify"
+	"golang.org/x/oauth2"
+)
+
+// TokenRefreshedFunc gets called with the new token whenever a token had to be refreshed
+type TokenRefreshedFunc func(token *oauth2.Token) error
+
+// NewOAuthConfig returns the configuration required for refreshing tokens issued by Spotify
+func NewOAuthConfig(clientID, clientSecret string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  spotifyAPI.AuthURL,
+			TokenURL: spotifyAPI.TokenURL,
+		},
+	}
+}
+
+// NewRefreshingSpotClient returns a client refreshing the given token when it expires, refreshed tokens
+// get passed to onRefresh. By this the tokens can be persisted, otherwise they would get lost.
+func NewRefreshingSpotClient(config *oauth2.Config, token *oauth2.Token, onRefresh TokenRefreshedFunc) SpotClient {
+	tokenSource := &persistingTokenSource{
+		source:    config.TokenSource(context.Background(), token),
+		current:   token,
+		onRefresh: onRefresh,
+	}
+
+	httpClient := &http.Client{
+		Transport: &oauth2.Transport{
+			Source: oauth2.ReuseTokenSource(nil, tokenSource),
+			Base:   &errorMappingTransport{http.DefaultTransport},
+		},
+	}
+
+	return NewSpotClient(spotifyAPI.NewClient(httpClient))
+}
+
+// persistingTokenSource wraps the token source refreshing the token, reporting every new token
+type persistingTokenSource struct {
+	source    oauth2.TokenSource
+	mutex     sync.Mutex
+	current   *oauth2.Token
+	onRefresh TokenRefreshedFunc
+}
+
+func (p *persistingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := p.source.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.current != nil && token.AccessToken == p.current.AccessToken {
+		return token, nil
+	}
+
+	p.current = token
+
+	if p.onRefresh != nil {
+		err = p.onRefresh(token)
+		if err != nil {
+			// The token can be used nevertheless, it will just be refreshed again next time
+			log.Error().Err(err).Msg("Could not persist refreshed token.")
+		}
+	}
+
+	return token, nil
+}
@@ -0,0 +1,62 @@
+package spotify
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestRefreshedTokensGetReported(t *testing.T) {
+	refreshes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes++
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"refreshed","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	config := NewOAuthConfig("id", "secret")
+	config.Endpoint.TokenURL = server.URL
+
+	expiredToken := &oauth2.Token{AccessToken: "expired", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)}
+
+	var reported []*oauth2.Token
+	client := NewRefreshingSpotClient(config, expiredToken, func(token *oauth2.Token) error {
+		reported = append(reported, token)
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		token, err := client.Token()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if token.AccessToken != "refreshed" || token.RefreshToken != "refresh" {
+			t.Fatalf("unexpected token: %+v", token)
+		}
+	}
+
+	// The token is only refreshed once, hence there has to be only one report
+	if refreshes != 1 || len(reported) != 1 || reported[0].AccessToken != "refreshed" {
+		t.Fatalf("expected exactly one refreshed token to be reported, got %d refreshes and %+v", refreshes, reported)
+	}
+}
+
+func TestValidTokensDoNotGetReported(t *testing.T) {
+	validToken := &oauth2.Token{AccessToken: "valid", Expiry: time.Now().Add(time.Hour)}
+
+	client := NewRefreshingSpotClient(NewOAuthConfig("id", "secret"), validToken, func(token *oauth2.Token) error {
+		t.Fatalf("valid token must not be reported: %+v", token)
+		return nil
+	})
+
+	token, err := client.Token()
+	if err != nil || token.AccessToken != "valid" {
+		t.Fatalf("expected the valid token, got %+v (%v)", token, err)
+	}
+}
@@ -0,0 +1,184 @@
+package spotify
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/florianloch/cassette/internal/persistence"
+)
+
+// TrackListingCache keeps the track listings of albums and playlists, so suspending does not have to page
+// through the whole context every time
+type TrackListingCache interface {
+	// Get returns nil in case nothing (valid) is cached for the key
+	Get(key string) *persistence.TrackListing
+	Put(key string, listing *persistence.TrackListing)
+}
+
+var trackListings TrackListingCache = noTrackListingCache{}
+
+// UseTrackListingCache replaces the cache used when looking up the position of a track in its context,
+// passing nil disables caching
+func UseTrackListingCache(cache TrackListingCache) {
+	if cache == nil {
+		cache = noTrackListingCache{}
+	}
+
+	trackListings = cache
+}
+
+// trackListingKey identifies the listing of a context. Playlists can change, so their snapshot ID is part of the key.
+func trackListingKey(contextURI, snapshotID string) string {
+	if snapshotID == "" {
+		return contextURI
+	}
+
+	return contextURI + "@" + snapshotID
+}
+
+type noTrackListingCache struct{}
+
+func (noTrackListingCache) Get(string) *persistence.TrackListing {
+	return nil
+}
+
+func (noTrackListingCache) Put(string, *persistence.TrackListing) {}
+
+type memoryTrackListingCache struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+	mutex      sync.Mutex
+	entries    map[string]*persistence.TrackListing
+}
+
+// NewMemoryTrackListingCache keeps the listings in memory for the given time, in case more than maxEntries
+// are cached the oldest ones get evicted
+func NewMemoryTrackListingCache(ttl time.Duration, maxEntries int) TrackListingCache {
+	return &memoryTrackListingCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    make(map[string]*persistence.TrackListing),
+	}
+}
+
+func (m *memoryTrackListingCache) Get(key string) *persistence.TrackListing {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	listing, ok := m.entries[key]
+	if !ok {
+		return nil
+	}
+
+	if m.expired(listing) {
+		delete(m.entries, key)
+		return nil
+	}
+
+	return listing
+}
+
+func (m *memoryTrackListingCache) Put(key string, listing *persistence.TrackListing) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.entries[key] = listing
+
+	if len(m.entries) <= m.maxEntries {
+		return
+	}
+
+	for key, listing := range m.entries {
+		if m.expired(listing) {
+			delete(m.entries, key)
+		}
+	}
+
+	for len(m.entries) > m.maxEntries {
+		oldestKey := ""
+		var oldestTs int64
+		for key, listing := range m.entries {
+			if oldestKey == "" || listing.CachedAtTs < oldestTs {
+				oldestKey, oldestTs = key, listing.CachedAtTs
+			}
+		}
+
+		delete(m.entries, oldestKey)
+	}
+}
+
+// expired has to be called while holding the mutex
+func (m *memoryTrackListingCache) expired(listing *persistence.TrackListing) bool {
+	return time.Unix(listing.CachedAtTs, 0).Add(m.ttl).Before(m.now())
+}
+
+// SharedTrackListingCache keeps the listings using the persistence layer, this way they are shared between all
+// instances using the same database
+type SharedTrackListingCache struct {
+	persistor  persistence.TrackListingPersistor
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+}
+
+// NewSharedTrackListingCache does not prune expired resp. superfluous listings by itself, Run has to be called for this
+func NewSharedTrackListingCache(persistor persistence.TrackListingPersistor, ttl time.Duration, maxEntries int) *SharedTrackListingCache {
+	return &SharedTrackListingCache{
+		persistor:  persistor,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+	}
+}
+
+func (s *SharedTrackListingCache) Get(key string) *persistence.TrackListing {
+	listing, err := s.persistor.LoadTrackListing(key)
+	if err != nil {
+		if err != persistence.ErrTrackListingNotFound {
+			// Not worth failing for, the listing simply gets fetched from Spotify
+			log.Error().Err(err).Str("key", key).Msg("Could not load cached track listing.")
+		}
+
+		return nil
+	}
+
+	if time.Unix(listing.CachedAtTs, 0).Add(s.ttl).Before(s.now()) {
+		return nil
+	}
+
+	return listing
+}
+
+func (s *SharedTrackListingCache) Put(key string, listing *persistence.TrackListing) {
+	err := s.persistor.StoreTrackListing(key, listing)
+	if err != nil {
+		log.Error().Err(err).Str("key", key).Msg("Could not cache track listing.")
+	}
+}
+
+// Run prunes the cached listings periodically until stop gets closed
+func (s *SharedTrackListingCache) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.Prune()
+		}
+	}
+}
+
+// Prune removes the expired listings and the oldest ones exceeding the maximum number of entries
+func (s *SharedTrackListingCache) Prune() {
+	err := s.persistor.PruneTrackListings(s.now().Add(-s.ttl).Unix(), s.maxEntries)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not prune cached track listings.")
+	}
+}
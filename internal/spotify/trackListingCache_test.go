@@ -0,0 +1,103 @@
+package spotify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/florianloch/cassette/internal/persistence"
+)
+
+func TestMemoryTrackListingCacheExpires(t *testing.T) {
+	now := time.Unix(1615000000, 0)
+
+	cache := NewMemoryTrackListingCache(time.Hour, 10).(*memoryTrackListingCache)
+	cache.now = func() time.Time { return now }
+
+	cache.Put("spotify:album:1", &persistence.TrackListing{CachedAtTs: now.Unix()})
+
+	now = now.Add(time.Hour)
+	if cache.Get("spotify:album:1") == nil {
+		t.Fatal("expected listing to be cached")
+	}
+
+	now = now.Add(time.Second)
+	if cache.Get("spotify:album:1") != nil {
+		t.Fatal("expected listing to be expired")
+	}
+}
+
+func TestMemoryTrackListingCacheEvictsOldest(t *testing.T) {
+	cache := NewMemoryTrackListingCache(time.Hour, 2)
+
+	ts := time.Now().Unix()
+	cache.Put("spotify:album:1", &persistence.TrackListing{CachedAtTs: ts - 2})
+	cache.Put("spotify:album:2", &persistence.TrackListing{CachedAtTs: ts})
+	cache.Put("spotify:album:3", &persistence.TrackListing{CachedAtTs: ts - 1})
+
+	if cache.Get("spotify:album:1") != nil {
+		t.Fatal("expected oldest listing to be evicted")
+	}
+
+	if cache.Get("spotify:album:2") == nil || cache.Get("spotify:album:3") == nil {
+		t.Fatal("expected newer listings to be kept")
+	}
+}
+
+func TestTrackListingKeyContainsSnapshot(t *testing.T) {
+	if key := trackListingKey("spotify:album:1", ""); key != "spotify:album:1" {
+		t.Fatalf("unexpected key for album: %s", key)
+	}
+
+	if trackListingKey("spotify:playlist:1", "snapshot1") == trackListingKey("spotify:playlist:1", "snapshot2") {
+		t.Fatal("expected different snapshots of a playlist to have different keys")
+	}
+}
+
+type fakeTrackListingPersistor struct {
+	listings       map[string]*persistence.TrackListing
+	cachedBeforeTs int64
+	maxEntries     int
+}
+
+func (f *fakeTrackListingPersistor) LoadTrackListing(key string) (*persistence.TrackListing, error) {
+	listing, ok := f.listings[key]
+	if !ok {
+		return nil, persistence.ErrTrackListingNotFound
+	}
+
+	return listing, nil
+}
+
+func (f *fakeTrackListingPersistor) StoreTrackListing(key string, listing *persistence.TrackListing) error {
+	f.listings[key] = listing
+	return nil
+}
+
+func (f *fakeTrackListingPersistor) PruneTrackListings(cachedBeforeTs int64, maxEntries int) error {
+	f.cachedBeforeTs, f.maxEntries = cachedBeforeTs, maxEntries
+	return nil
+}
+
+func TestSharedTrackListingCachePrunesSeparately(t *testing.T) {
+	now := time.Unix(1615000000, 0)
+
+	persistor := &fakeTrackListingPersistor{listings: make(map[string]*persistence.TrackListing)}
+	cache := NewSharedTrackListingCache(persistor, time.Hour, 10)
+	cache.now = func() time.Time { return now }
+
+	cache.Put("spotify:album:1", &persistence.TrackListing{CachedAtTs: now.Unix()})
+
+	if persistor.maxEntries != 0 {
+		t.Fatal("expected adding a listing not to prune")
+	}
+
+	if cache.Get("spotify:album:1") == nil {
+		t.Fatal("expected listing to be cached")
+	}
+
+	cache.Prune()
+
+	if persistor.cachedBeforeTs != now.Add(-time.Hour).Unix() || persistor.maxEntries != 10 {
+		t.Fatalf("unexpected pruning of listings cached before %d keeping %d", persistor.cachedBeforeTs, persistor.maxEntries)
+	}
+}
@@ -0,0 +1,19 @@
+package util
+
+import (
+	"time"
+)
+
+// Clock allows tests of the background workers to control the time
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock tells the actual time
+var SystemClock Clock = systemClock{}
@@ -0,0 +1,30 @@
+// Package clocktest provides a util.Clock for tests that only moves when told to.
+package clocktest
+
+import (
+	"time"
+)
+
+// Clock is a fake util.Clock whose time is controlled by the test
+type Clock struct {
+	now time.Time
+}
+
+// New returns a clock standing still at the given time
+func New(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	return c.now
+}
+
+// Advance moves the clock forward by the given duration
+func (c *Clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// Set moves the clock to the given time
+func (c *Clock) Set(now time.Time) {
+	c.now = now
+}
@@ -1,8 +1,10 @@
 package util
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/hex"
 	"os"
 	"strings"
 
@@ -25,6 +27,26 @@ func Make32ByteSecret(input string) ([]byte, error) {
 	return key, nil
 }
 
+// DeriveKey derives a 32 byte key for the given purpose from a secret, this allows using one secret for multiple purposes
+func DeriveKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose))
+
+	return mac.Sum(nil)
+}
+
+// RandomID returns a random identifier being safe to be used in URLs
+func RandomID() (string, error) {
+	var id = make([]byte, 12)
+
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
 func Env(envName, defaultValue string) string {
 	var val, exists = os.LookupEnv(envName)
 
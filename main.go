@@ -2,6 +2,8 @@ package main
 
 import (
 	"os"
+	// The image Cassette runs in lacks a time zone database, it is needed for evaluating schedules
+	_ "time/tzdata"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -3,14 +3,29 @@ import axios from "axios"
 const CSRF_HEADER_NAME = "X-Cassette-CSRF".toLowerCase()
 const API_PATH = "/api"
 const URL_DATA = API_PATH + "/you"
+const URL_AUTO_SAVE = URL_DATA + "/autoSave"
+const URL_TOKEN = URL_DATA + "/token"
+const URL_API_TOKENS = URL_DATA + "/apiTokens"
+const URL_TIME_ZONE = URL_DATA + "/timeZone"
+const URL_PREFERENCES = URL_DATA + "/preferences"
+const URL_DELETION = URL_DATA + "/deletion"
 const URL_CSRF_TOKEN = API_PATH + "/csrfToken"
 const URL_PLAYER_STATES = API_PATH + "/playerStates"
 const URL_ACTIVE_DEVICES = API_PATH + "/activeDevices"
+const URL_EVENTS = API_PATH + "/events"
+const URL_SLEEP_TIMER = API_PATH + "/sleepTimer"
+const URL_SCHEDULES = API_PATH + "/schedules"
+const URL_HISTORY = API_PATH + "/history"
+const URL_ARCHIVE = API_PATH + "/archive"
+const URL_TRASH = API_PATH + "/trash"
+const EVENT_TYPES = ["slot-created", "slot-updated", "slot-deleted", "slot-archived", "playback-restored", "slots-reordered"]
 const CONSENT_COOKIE_NAME = "cassette_consent"
 
 
 const API = function (options) {
   const client = (options) ? options.axios : null || axios.create()
+  // Revision of the player states last fetched, modifications get rejected in case they are outdated
+  let playerStatesETag
 
   this.fetchCSRFToken = () => {
     return client.head(URL_CSRF_TOKEN).then((res) => {
@@ -28,47 +43,302 @@ const API = function (options) {
     })
   }
 
-  this.fetchPlayerStates = () => {
-    return client.get(URL_PLAYER_STATES).then((res) => {
+  // Calls onEvent with the type and the data of every event streamed by the backend, the browser reconnects
+  // on its own. Close the returned source in order to stop listening.
+  this.subscribeToEvents = (onEvent) => {
+    const source = new EventSource(URL_EVENTS)
+
+    EVENT_TYPES.forEach((type) => {
+      source.addEventListener(type, (e) => {
+        onEvent(type, JSON.parse(e.data))
+      })
+    })
+
+    return source
+  }
+
+  // The slots are sorted by the server, sort is either "manual", "recent" (default), "title" or "progress".
+  // Given tags only the slots having all of them are fetched.
+  this.fetchPlayerStates = (tags, sort = "recent") => {
+    const query = new URLSearchParams({sort})
+    if (tags) {
+      tags.forEach((tag) => query.append("tag", tag))
+    }
+
+    return client.get(`${URL_PLAYER_STATES}?${query}`).then((res) => {
+      playerStatesETag = res.headers["etag"]
+
       return preparePlayerStates(res.data)
     })
   }
 
-  // Sorts the states by LRU and adds the original index as slotNumber
+  // Adds the ID of the slot as slotID, the order is kept as it is
   function preparePlayerStates (rawPlayerStates) {
-    const cookedPlayerStates = rawPlayerStates.map((cur, idx) => {
+    return rawPlayerStates.map((cur) => {
       return {
         state: cur,
-        slotNumber: idx
+        slotID: cur.id
       }
     })
+  }
 
-    cookedPlayerStates.sort((a, b) => {
-      return b.state.suspendedAtTs - a.state.suspendedAtTs
-    })
+  function ifMatch () {
+    return (playerStatesETag) ? {headers: {"If-Match": playerStatesETag}} : {}
+  }
+
+  this.updatePlayerState = (slotID) => {
+    return client.put(`${URL_PLAYER_STATES}/${slotID}`, null, ifMatch())
+  }
 
-    return cookedPlayerStates
+  // Fields missing from labels, i.e., title, notes, tags resp. pinned, are left unchanged
+  this.editPlayerState = (slotID, labels) => {
+    return client.patch(`${URL_PLAYER_STATES}/${slotID}`, labels, ifMatch())
   }
 
-  this.updatePlayerState = (slotNumber) => {
-    return client.put(`${URL_PLAYER_STATES}/${slotNumber}`)
+  // slotIDs has to contain the ID of every slot exactly once
+  this.reorderPlayerStates = (slotIDs) => {
+    return client.put(`${URL_PLAYER_STATES}/order`, {slots: slotIDs}, ifMatch())
+  }
+
+  this.setPinned = (slotID, pinned) => {
+    return this.editPlayerState(slotID, {pinned})
   }
 
   this.storePlayerState = () => {
     return client.post(URL_PLAYER_STATES)
   }
 
-  this.deletePlayerState = (slotNumber) => {
-    return client.delete(`${URL_PLAYER_STATES}/${slotNumber}`)
+  this.deletePlayerState = (slotID) => {
+    return client.delete(`${URL_PLAYER_STATES}/${slotID}`, ifMatch())
+  }
+
+  this.restoreFromPlayerState = (slotID, deviceID) => {
+    const url = `${URL_PLAYER_STATES}/${slotID}/restore${(deviceID) ? `?deviceID=${deviceID}` : ""}`
+    return client.post(url)
+  }
+
+  this.storeBookmark = (slotID, name) => {
+    return client.post(`${URL_PLAYER_STATES}/${slotID}/bookmarks`, {name: name}, ifMatch())
+  }
+
+  this.deleteBookmark = (slotID, bookmarkID) => {
+    return client.delete(`${URL_PLAYER_STATES}/${slotID}/bookmarks/${bookmarkID}`, ifMatch())
   }
 
-  this.restoreFromPlayerState = (slotNumber, deviceID) => {
-    const url = `${URL_PLAYER_STATES}/${slotNumber}/restore${(deviceID) ? `?deviceID=${deviceID}` : ""}`
+  this.restoreFromBookmark = (slotID, bookmarkID, deviceID) => {
+    const url = `${URL_PLAYER_STATES}/${slotID}/bookmarks/${bookmarkID}/restore${(deviceID) ? `?deviceID=${deviceID}` : ""}`
     return client.post(url)
   }
 
+  // Error responses carry a machine-readable code, see problem.go in the backend
+  this.problemCode = (err) => {
+    if (err.response === undefined || err.response.data === undefined || err.response.data === null) {
+      return undefined
+    }
+
+    return err.response.data.code
+  }
+
+  // The player states have been modified in the meantime, e.g., in another tab
+  this.isOutdated = (err) => {
+    return this.problemCode(err) === "modified_concurrently"
+  }
+
+  this.fetchAutoSaveEnabled = () => {
+    return client.get(URL_AUTO_SAVE).then((res) => {
+      return res.data.enabled
+    })
+  }
+
+  this.setAutoSaveEnabled = (enabled) => {
+    return (enabled) ? client.put(URL_AUTO_SAVE) : client.delete(URL_AUTO_SAVE)
+  }
+
+  // Resolves to undefined in case no timer is set
+  this.fetchSleepTimer = () => {
+    return client.get(URL_SLEEP_TIMER).then((res) => {
+      return res.data
+    }, (err) => {
+      if (this.problemCode(err) === "sleep_timer_not_found") {
+        return undefined
+      }
+
+      throw err
+    })
+  }
+
+  // Without minutes the timer fires at the end of the current track
+  this.setSleepTimer = (minutes) => {
+    const timer = (minutes) ? {mode: "duration", minutes} : {mode: "trackEnd"}
+
+    return client.put(URL_SLEEP_TIMER, timer).then((res) => {
+      return res.data
+    })
+  }
+
+  this.cancelSleepTimer = () => {
+    return client.delete(URL_SLEEP_TIMER)
+  }
+
+  // Resolves to the time zone along with the schedules evaluated in it
+  this.fetchSchedules = () => {
+    return client.get(URL_SCHEDULES).then((res) => {
+      return res.data
+    })
+  }
+
+  // The device has to be available, missedRun is either "skip" (default) or "catchUp"
+  this.createSchedule = (slotID, deviceID, cron, name, missedRun) => {
+    return client.post(URL_SCHEDULES, {slot: slotID, deviceID, cron, name, missedRun}).then((res) => {
+      return res.data
+    })
+  }
+
+  this.updateSchedule = (scheduleID, slotID, deviceID, cron, name, missedRun) => {
+    return client.put(`${URL_SCHEDULES}/${scheduleID}`, {slot: slotID, deviceID, cron, name, missedRun}).then((res) => {
+      return res.data
+    })
+  }
+
+  this.deleteSchedule = (scheduleID) => {
+    return client.delete(`${URL_SCHEDULES}/${scheduleID}`)
+  }
+
+  // The latest events come first
+  this.fetchHistory = () => {
+    return client.get(URL_HISTORY).then((res) => {
+      return res.data
+    })
+  }
+
+  // Resolves to the stats per album, playlist, episode resp. track, the ones touched most recently come first
+  this.fetchHistoryStats = () => {
+    return client.get(`${URL_HISTORY}/stats`).then((res) => {
+      return res.data
+    })
+  }
+
+  // The slots completed most recently come first
+  this.fetchArchive = () => {
+    return client.get(URL_ARCHIVE).then((res) => {
+      return res.data
+    })
+  }
+
+  // Moves the slot back to the end of the player states
+  this.unarchiveSlot = (slotID) => {
+    return client.post(`${URL_ARCHIVE}/${slotID}/unarchive`, null, ifMatch())
+  }
+
+  this.purgeArchivedSlot = (slotID) => {
+    return client.delete(`${URL_ARCHIVE}/${slotID}`)
+  }
+
+  this.purgeArchive = () => {
+    return client.delete(URL_ARCHIVE)
+  }
+
+  // The slots deleted most recently come first
+  this.fetchTrash = () => {
+    return client.get(URL_TRASH).then((res) => {
+      return res.data
+    })
+  }
+
+  // Moves the slot back to the end of the player states
+  this.undeleteSlot = (slotID) => {
+    return client.post(`${URL_TRASH}/${slotID}/undelete`, null, ifMatch())
+  }
+
+  this.purgeTrashedSlot = (slotID) => {
+    return client.delete(`${URL_TRASH}/${slotID}`)
+  }
+
+  this.purgeTrash = () => {
+    return client.delete(URL_TRASH)
+  }
+
+  this.fetchTimeZone = () => {
+    return client.get(URL_TIME_ZONE).then((res) => {
+      return res.data.timeZone
+    })
+  }
+
+  // Defaults to the time zone of the browser
+  this.setTimeZone = (timeZone) => {
+    timeZone = timeZone || Intl.DateTimeFormat().resolvedOptions().timeZone
+
+    return client.put(URL_TIME_ZONE, {timeZone}).then((res) => {
+      return res.data.timeZone
+    })
+  }
+
+  this.fetchPreferences = () => {
+    return client.get(URL_PREFERENCES).then((res) => {
+      return res.data
+    })
+  }
+
+  this.setPreferences = (jumpBackSeconds, smartJumpBack, restoreDevice) => {
+    return client.put(URL_PREFERENCES, {jumpBackSeconds, smartJumpBack, restoreDevice}).then((res) => {
+      return res.data
+    })
+  }
+
+  // Without seconds the slot jumps back as set in the preferences again
+  this.setJumpBackOfSlot = (slotID, seconds) => {
+    const url = `${URL_PLAYER_STATES}/${slotID}/jumpBack`
+
+    if (seconds === undefined || seconds === null) {
+      return client.delete(url, ifMatch())
+    }
+
+    return client.put(url, {seconds}, ifMatch())
+  }
+
+  this.revokeToken = () => {
+    return client.delete(URL_TOKEN)
+  }
+
+  this.fetchAPITokens = () => {
+    return client.get(URL_API_TOKENS).then((res) => {
+      return res.data
+    })
+  }
+
+  // The response contains the token itself, it cannot be fetched again later on
+  this.createAPIToken = (name, scopes) => {
+    return client.post(URL_API_TOKENS, {name: name, scopes: scopes}).then((res) => {
+      return res.data
+    })
+  }
+
+  this.revokeAPIToken = (apiTokenID) => {
+    return client.delete(`${URL_API_TOKENS}/${apiTokenID}`)
+  }
+
+  // Resolves to the deletion scheduled in case there is a grace period, otherwise everything is gone already
   this.deleteYourData = () => {
-    return client.delete(URL_DATA)
+    return client.delete(URL_DATA).then((res) => {
+      return (res.status === 202) ? res.data : undefined
+    })
+  }
+
+  // Resolves to undefined in case no deletion is scheduled
+  this.fetchAccountDeletion = () => {
+    return client.get(URL_DELETION).then((res) => {
+      return res.data
+    }, (err) => {
+      if (this.problemCode(err) === "deletion_not_found") {
+        return undefined
+      }
+
+      throw err
+    })
+  }
+
+  this.cancelAccountDeletion = () => {
+    return client.delete(URL_DELETION)
   }
 
   this.giveConsent = API.giveConsent
@@ -90,7 +90,7 @@ const Intro = function () {
         on: "auto"
       },
       title: "Restore a slot/resume a state",
-      text: "Click here to restore this state and continue playback on the currently active device. If there are several active devices you may use the dropdown button to select a specific device. Don't be surprised: Cassette jumps back 10s in the track in order to ease getting back into audiobooks etc.<hr>In order to continue with the tour please make sure there is an active device and click the button. Due to energy saving measures a mobile device running a Spotify app might not show up in your active devices unless the app is running in the foreground or actually playing some music."
+      text: "Click here to restore this state and continue playback on the currently active device. If there are several active devices you may use the dropdown button to select a specific device. Don't be surprised: Cassette jumps back 10s in the track in order to ease getting back into audiobooks etc. - you can change this in your preferences.<hr>In order to continue with the tour please make sure there is an active device and click the button. Due to energy saving measures a mobile device running a Spotify app might not show up in your active devices unless the app is running in the foreground or actually playing some music."
     })
 
     tour.addStep({